@@ -0,0 +1,225 @@
+// Package bigquery exports the summary of every run and a snapshot of the
+// items it wrote to BigQuery tables through streaming inserts, for
+// freshness and coverage dashboards. The tables must exist, with the
+// columns of Run and Item.
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/googleauth"
+	"go_data_fashion_accessories/httpclient"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/retry"
+)
+
+// Scope is the OAuth scope for inserting rows
+const Scope = "https://www.googleapis.com/auth/bigquery.insertdata"
+
+// Count is a count by name, a REPEATED RECORD column
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Run is a row of the runs table
+type Run struct {
+	Started       time.Time `json:"started"` // TIMESTAMP; identifies the run
+	Duration      float64   `json:"duration_seconds"`
+	Error         string    `json:"error,omitempty"`
+	Fetched       int       `json:"fetched"`
+	Parsed        int       `json:"parsed"`
+	Emitted       int       `json:"emitted"`
+	Filtered      int       `json:"filtered"`
+	Deduped       int       `json:"deduped"`
+	Skipped       []Count   `json:"skipped"`
+	Subcategories []Count   `json:"subcategories"`
+	Quality       []Count   `json:"quality"`
+}
+
+// Item is a row of the items table: an item as a run wrote it
+type Item struct {
+	RunStarted   time.Time  `json:"run_started"` // TIMESTAMP; the Started of its Run
+	ID           string     `json:"id"`
+	AdID         string     `json:"ad_id"`
+	SellerID     string     `json:"seller_id,omitempty"`
+	Title        string     `json:"title"`
+	Brand        string     `json:"brand,omitempty"`
+	Price        string     `json:"price"` // NUMERIC
+	SalePrice    string     `json:"sale_price,omitempty"`
+	Currency     string     `json:"currency"`
+	Availability string     `json:"availability"`
+	GTIN         string     `json:"gtin,omitempty"`
+	Subcategory  string     `json:"subcategory,omitempty"`
+	Category     string     `json:"google_product_category,omitempty"`
+	Feed         string     `json:"feed,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"` // when the ad was last edited
+}
+
+// Exporter collects the items of a run, as a pipeline.Sink, and inserts
+// them with the run's row. It is safe for concurrent use.
+type Exporter struct {
+	cfg    config.BigQuery
+	retry  config.Retry
+	http   *http.Client
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	items []Item
+}
+
+// NewExporter returns an exporter authenticated with the service account
+// key in cfg.BigQuery.CredentialsFile. A nil logger uses slog.Default().
+func NewExporter(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*Exporter, error) {
+	// Token requests and API calls both go through the retrying transport
+	base, err := httpclient.NewRetrying(cfg.HTTP, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	client, err := googleauth.Client(ctx, cfg.BigQuery.CredentialsFile, base, Scope)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{cfg: cfg.BigQuery, retry: cfg.Retry, http: client, logger: logging.OrDefault(logger)}, nil
+}
+
+// Write implements pipeline.Sink
+func (e *Exporter) Write(item input.AdItem) error {
+	row := Item{
+		ID:           item.ID,
+		AdID:         item.AdID,
+		SellerID:     item.SellerID,
+		Title:        item.Title,
+		Brand:        item.Brand,
+		Price:        item.Price.Decimal(),
+		Currency:     item.Price.Currency,
+		Availability: item.Availability,
+		GTIN:         item.GTIN,
+		Subcategory:  item.SubcategoryName,
+		Category:     item.GoogleProductCategory,
+		Feed:         item.Feed,
+	}
+	if !item.SalePrice.IsZero() {
+		row.SalePrice = item.SalePrice.Decimal()
+	}
+	if !item.UpdatedAt.IsZero() {
+		updated := item.UpdatedAt.UTC()
+		row.UpdatedAt = &updated
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.items = append(e.items, row)
+	return nil
+}
+
+// Close implements pipeline.Sink
+func (e *Exporter) Close() error { return nil }
+
+// Export inserts the run's row and, when the run succeeded, the rows of
+// its items, in batches of BatchSize. Rows carry insert IDs, so a retried
+// export does not duplicate them.
+func (e *Exporter) Export(ctx context.Context, run Run) error {
+	run.Started = run.Started.UTC()
+	started := run.Started.Format(time.RFC3339Nano)
+	if err := e.insert(ctx, e.cfg.RunsTable, []row{{InsertID: started, JSON: run}}); err != nil {
+		return err
+	}
+	if run.Error != "" {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for start := 0; start < len(e.items); start += e.cfg.BatchSize {
+		batch := e.items[start:min(start+e.cfg.BatchSize, len(e.items))]
+		rows := make([]row, len(batch))
+		for i, item := range batch {
+			item.RunStarted = run.Started
+			rows[i] = row{InsertID: started + "/" + item.ID, JSON: item}
+		}
+		if err := e.insert(ctx, e.cfg.ItemsTable, rows); err != nil {
+			return err
+		}
+	}
+	e.logger.Info("Exported run to BigQuery", "dataset", e.cfg.Dataset, "items", len(e.items))
+	return nil
+}
+
+// row is one row of a tabledata.insertAll request
+type row struct {
+	InsertID string `json:"insertId"`
+	JSON     any    `json:"json"`
+}
+
+// insert streams rows into table, failing if BigQuery rejects any of them
+func (e *Exporter) insert(ctx context.Context, table string, rows []row) error {
+	body, err := json.Marshal(struct {
+		Rows []row `json:"rows"`
+	}{rows})
+	if err != nil {
+		return err
+	}
+	target := fmt.Sprintf("%s/projects/%s/datasets/%s/tables/%s/insertAll", e.cfg.Endpoint,
+		url.PathEscape(e.cfg.ProjectID), url.PathEscape(e.cfg.Dataset), url.PathEscape(table))
+
+	var response struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	err = retry.Do(ctx, e.logger, e.retry, "BigQuery insert into "+table, func() error {
+		return e.post(ctx, target, body, &response)
+	})
+	if err != nil {
+		return fmt.Errorf("bigquery: inserting %d rows into %s: %w", len(rows), table, err)
+	}
+	if len(response.InsertErrors) > 0 {
+		first := response.InsertErrors[0]
+		message := "rejected"
+		if len(first.Errors) > 0 {
+			message = first.Errors[0].Reason + ": " + first.Errors[0].Message
+		}
+		return fmt.Errorf("bigquery: %d of %d rows rejected by %s, row %d: %s",
+			len(response.InsertErrors), len(rows), table, first.Index, message)
+	}
+	return nil
+}
+
+// post sends body to target and decodes the JSON answer into v
+func (e *Exporter) post(ctx context.Context, target string, body []byte, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := e.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiErr)
+		return fmt.Errorf("POST %s: %s: %s", target, res.Status, apiErr.Error.Message)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
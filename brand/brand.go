@@ -0,0 +1,42 @@
+// Package brand matches the brand names sellers type against the brand
+// lists in the config, ignoring case, spacing and punctuation so "Louis
+// Vuitton", "LOUIS-VUITTON" and "louis vuitton." are the same brand, and
+// normalizes them to canonical names, matching misspellings fuzzily.
+package brand
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Key folds a brand name for comparison: lower case letters and digits
+// only
+func Key(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// Set is a list of brands, keyed by Key
+type Set map[string]string
+
+// NewSet returns the set of the named brands. Names that fold to nothing
+// are ignored.
+func NewSet(names []string) Set {
+	set := make(Set, len(names))
+	for _, name := range names {
+		if k := Key(name); k != "" {
+			set[k] = strings.TrimSpace(name)
+		}
+	}
+	return set
+}
+
+// Match returns the listed name of brand, if the set has it
+func (s Set) Match(brand string) (string, bool) {
+	name, ok := s[Key(brand)]
+	return name, ok
+}
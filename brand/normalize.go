@@ -0,0 +1,166 @@
+package brand
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go_data_fashion_accessories/model/input"
+)
+
+// minFuzzyKey is the shortest Key matched fuzzily; shorter names are too
+// close to each other, such as Dior and Dion
+const minFuzzyKey = 4
+
+// Normalizer replaces the brands of items with their canonical names. It
+// remembers the brands it could not match, for a report. It is not safe
+// for concurrent use.
+type Normalizer struct {
+	canonical     Set
+	keys          []string          // of canonical, sorted, for the fuzzy search
+	aliases       map[string]string // canonical name by Key of the alias
+	minSimilarity float64
+	maxEdits      int
+	matches       map[string]string // canonical name by Key of a brand seen, "" when none matched
+	unmatched     map[string]int    // items by brand name no canonical name matched
+}
+
+// NewNormalizer returns a Normalizer for the canonical names and aliases,
+// matching other names when their Jaro-Winkler similarity to a canonical
+// name is at least minSimilarity and their Levenshtein distance at most
+// maxEdits. Aliases of names missing from canonical add them.
+func NewNormalizer(canonical []string, aliases map[string]string, minSimilarity float64, maxEdits int) *Normalizer {
+	n := &Normalizer{
+		canonical:     NewSet(canonical),
+		aliases:       map[string]string{},
+		minSimilarity: minSimilarity,
+		maxEdits:      maxEdits,
+		matches:       map[string]string{},
+		unmatched:     map[string]int{},
+	}
+	for alias, name := range aliases {
+		if k := Key(name); k != "" {
+			if _, ok := n.canonical[k]; !ok {
+				n.canonical[k] = strings.TrimSpace(name)
+			}
+			n.aliases[Key(alias)] = n.canonical[k]
+		}
+	}
+	for k := range n.canonical {
+		n.keys = append(n.keys, k)
+	}
+	slices.Sort(n.keys)
+	return n
+}
+
+// Len returns the number of canonical names
+func (n *Normalizer) Len() int {
+	return len(n.canonical)
+}
+
+// Match returns the canonical name of brand, if one matches it
+func (n *Normalizer) Match(brand string) (string, bool) {
+	key := Key(brand)
+	if key == "" {
+		return "", false
+	}
+	if name, ok := n.matches[key]; ok {
+		return name, name != ""
+	}
+	name, ok := n.canonical[key]
+	if !ok {
+		name, ok = n.aliases[key]
+	}
+	if !ok {
+		name = n.closest(key)
+	}
+	n.matches[key] = name
+	return name, name != ""
+}
+
+// closest returns the canonical name closest to key, or "" when none is
+// close enough
+func (n *Normalizer) closest(key string) string {
+	if len([]rune(key)) < minFuzzyKey {
+		return ""
+	}
+	best, bestScore := "", 0.0
+	for _, k := range n.keys {
+		if len([]rune(k)) < minFuzzyKey {
+			continue
+		}
+		score := JaroWinkler(key, k)
+		if score < n.minSimilarity || score <= bestScore || Levenshtein(key, k) > n.maxEdits {
+			continue
+		}
+		best, bestScore = n.canonical[k], score
+	}
+	return best
+}
+
+// Apply replaces the item's brand with its canonical name, or counts it as
+// unmatched. It satisfies pipeline.Transformer.
+func (n *Normalizer) Apply(item *input.AdItem) error {
+	if strings.TrimSpace(item.Brand) == "" {
+		return nil
+	}
+	if name, ok := n.Match(item.Brand); ok {
+		item.Brand = name
+		return nil
+	}
+	n.unmatched[strings.TrimSpace(item.Brand)]++
+	return nil
+}
+
+// Unmatched returns the brand names no canonical name matched, with the
+// number of items they were on
+func (n *Normalizer) Unmatched() map[string]int {
+	return n.unmatched
+}
+
+// WriteUnmatched writes the unmatched brands as CSV, the most common first
+func WriteUnmatched(w io.Writer, unmatched map[string]int) error {
+	names := make([]string, 0, len(unmatched))
+	for name := range unmatched {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(unmatched[b], unmatched[a]), strings.Compare(a, b))
+	})
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"brand", "items"}); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := cw.Write([]string{name, strconv.Itoa(unmatched[name])}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadFile reads canonical brand names, one per line. Blank lines and lines
+// starting with # are skipped.
+func ReadFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, scanner.Err()
+}
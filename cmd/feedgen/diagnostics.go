@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"go_data_fashion_accessories/diagnostics"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/model/output/contentapi"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/runner"
+)
+
+// idSink maps the ID of every item to the ID of its ad
+type idSink map[string]string
+
+func (s idSink) Write(item input.AdItem) error {
+	s[item.ID] = item.AdID
+	return nil
+}
+
+func (s idSink) Close() error { return nil }
+
+// runDiagnostics pulls the issues Merchant Center found on the products it
+// received, writes them with the ad of each product to the report file and
+// records the disapproved ads, which later runs leave out once they were
+// disapproved in Diagnostics.ExcludeAfter pulls in a row
+func runDiagnostics(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("diagnostics", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	out := fs.String("out", "", "CSV file of the issues (default the config's Diagnostics.ReportPath)")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if cfg.ContentAPI.MerchantID == "" || cfg.ContentAPI.CredentialsFile == "" {
+		return errors.New("diagnostics needs ContentAPI.MerchantID and ContentAPI.CredentialsFile")
+	}
+	path := cfg.Diagnostics.ReportPath
+	if *out != "" {
+		path = *out
+	}
+
+	// Merchant Center only knows the item IDs; a run over every ad gives
+	// their ads. Ads already excluded still have products to map.
+	idCfg := *cfg
+	idCfg.FullRefresh = true
+	idCfg.Diagnostics.ExcludeAfter = 0
+	ids := idSink{}
+	if _, err := runner.Run(ctx, &idCfg, runner.Options{
+		Sinks:    []pipeline.Sink{ids},
+		ReadOnly: true,
+		Logger:   logger,
+	}); err != nil {
+		return err
+	}
+
+	client, err := contentapi.New(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	pulled := time.Now()
+	statuses, err := client.Statuses(ctx)
+	if err != nil {
+		return err
+	}
+	products := diagnostics.Join(statuses, ids)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := diagnostics.WriteCSV(file, products); err != nil {
+		file.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	disapprovals, err := diagnostics.Record(ctx, runner.DefaultStore(cfg), statuses, ids, pulled)
+	if err != nil {
+		return err
+	}
+	disapproved, repeated := 0, 0
+	for _, p := range products {
+		if p.Disapproved {
+			disapproved++
+		}
+	}
+	for _, d := range disapprovals {
+		if cfg.Diagnostics.ExcludeAfter > 0 && d.Count >= cfg.Diagnostics.ExcludeAfter {
+			repeated++
+		}
+	}
+	logger.Info("Pulled Merchant Center diagnostics",
+		"products", len(statuses), "with_issues", len(products), "disapproved", disapproved,
+		"repeatedly_disapproved", repeated, "report", path)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"go_data_fashion_accessories/feeddiff"
+)
+
+// runDiff compares two generated feed files and prints the items added,
+// removed and changed between them, to explain a sudden change in the item
+// count before the new feed is pushed
+func runDiff(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "", "feed format, xml or csv (default from the file extension)")
+	summary := fs.Bool("summary", false, "print only the counts, not every item")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: feedgen diff [flags] old-feed new-feed")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("diff needs the old and the new feed file")
+	}
+
+	before, err := feeddiff.Open(fs.Arg(0), *format)
+	if err != nil {
+		return err
+	}
+	after, err := feeddiff.Open(fs.Arg(1), *format)
+	if err != nil {
+		return err
+	}
+	d := feeddiff.Compare(before, after)
+
+	if !*summary {
+		for _, id := range d.Removed {
+			fmt.Fprintf(os.Stdout, "- %s\t%s\n", id, before[id]["title"])
+		}
+		for _, id := range d.Added {
+			fmt.Fprintf(os.Stdout, "+ %s\t%s\n", id, after[id]["title"])
+		}
+		for _, id := range d.ChangedIDs() {
+			fmt.Fprintf(os.Stdout, "~ %s\n", id)
+			for _, c := range d.Changed[id] {
+				fmt.Fprintf(os.Stdout, "    %s: %q -> %q\n", c.Field, c.Old, c.New)
+			}
+		}
+		fmt.Fprintln(os.Stdout)
+	}
+
+	fmt.Fprintf(os.Stdout, "%d items -> %d items (%+d): %d added, %d removed, %d changed\n",
+		len(before), len(after), len(after)-len(before), len(d.Added), len(d.Removed), len(d.Changed))
+	counts := d.FieldCounts()
+	for _, field := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Fprintf(os.Stdout, "  %s changed on %d items\n", field, counts[field])
+	}
+	return nil
+}
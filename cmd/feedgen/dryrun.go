@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/runner"
+)
+
+// dryRunFeeds runs the pipeline with opts as generate would, for the feed
+// targets in cfg or the given formats, but encodes the feeds in memory. It
+// prints the validation issues, then every file that would be written with
+// its destinations and its changes from the file in place. Nothing is
+// written, uploaded, recorded or notified.
+func dryRunFeeds(ctx context.Context, cfg *config.Config, formats []string, out string, targets bool, opts runner.Options) error {
+	// The summary is still printed with -summary, just not saved
+	cfg.Summary.Path = ""
+
+	var sinks []pipeline.Sink
+	var previews []*runner.Preview
+	var err error
+	if targets {
+		sinks, previews, err = runner.TargetPreviews(ctx, cfg)
+	} else {
+		sinks, previews, err = runner.CreatePreviews(ctx, cfg, formats, out)
+	}
+	if err != nil {
+		return err
+	}
+
+	issues := &issueSink{}
+	opts.Sinks = append(sinks, issues)
+	opts.ReadOnly = true
+	if _, err := runner.Run(ctx, cfg, opts); err != nil {
+		return err
+	}
+
+	for _, issue := range issues.Issues {
+		fmt.Fprintln(os.Stdout, issue)
+	}
+	fmt.Fprintf(os.Stdout, "%d items checked, %d with issues\n\n", issues.Items, issues.Failed)
+
+	for _, p := range previews {
+		split := ""
+		if p.Split {
+			split = ", in parts"
+		}
+		fmt.Fprintf(os.Stdout, "Would write %s: %d items, %d bytes%s\n", p.Path, p.Items, p.Bytes, split)
+		if uploads := p.Uploads(); len(uploads) > 0 {
+			fmt.Fprintf(os.Stdout, "  and upload it to %s\n", strings.Join(uploads, ", "))
+		}
+
+		d, err := p.Diff()
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "  %d items -> %d items (%+d): %d added, %d removed, %d changed\n",
+			d.Before, d.After, d.After-d.Before, len(d.Added), len(d.Removed), len(d.Changed))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"go_data_fashion_accessories/runner"
+)
+
+// runExplain traces one ad through the feed checks and prints every
+// decision, to answer why an ad is or is not in the feed
+func runExplain(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	adID := fs.String("ad-id", "", "UUID of the ad to explain (required)")
+	fs.Parse(args)
+
+	if *adID == "" {
+		return errors.New("-ad-id is required")
+	}
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	decisions, err := runner.Explain(ctx, cfg, *adID, logger)
+	if err != nil {
+		return err
+	}
+	for _, d := range decisions {
+		fmt.Fprintln(os.Stdout, d)
+	}
+	return nil
+}
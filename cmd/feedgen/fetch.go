@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+
+	"go_data_fashion_accessories/model/input"
+)
+
+// runFetch streams the raw ads to a JSON file that the file source can read
+// back, so feeds can be generated later without calling Hasura again
+func runFetch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	out := fs.String("out", "-", "output file, - for stdout")
+	full := fs.Bool("full", false, "fetch every ad instead of the recent window")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	source, err := input.NewSource(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	ads := make(chan input.RawAd, 100)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(ads)
+		errc <- source.Stream(ctx, input.FetchOptions{
+			Window:      cfg.Window.Duration,
+			FullRefresh: *full || cfg.FullRefresh,
+		}, ads)
+	}()
+
+	count, writeErr := writeRawAds(w, ads)
+	if err := <-errc; err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+
+	logger.Info("Fetched ads", "ads", count)
+	return nil
+}
+
+// writeRawAds writes the ads as {"ads": [...]}, one ad per line. It keeps
+// draining ads after a write error so the source is never blocked.
+func writeRawAds(w io.Writer, ads <-chan input.RawAd) (int, error) {
+	count := 0
+	_, err := io.WriteString(w, "{\"ads\": [\n")
+	for ad := range ads {
+		if err != nil {
+			continue
+		}
+		var line []byte
+		line, err = json.Marshal(ad)
+		if err != nil {
+			continue
+		}
+		if count > 0 {
+			_, err = io.WriteString(w, ",\n")
+		}
+		if err == nil {
+			_, err = w.Write(line)
+		}
+		count++
+	}
+	if err == nil {
+		_, err = io.WriteString(w, "\n]}\n")
+	}
+	return count, err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/secrets"
+)
+
+// configFlags are the flags shared by every command that talks to the source
+type configFlags struct {
+	path     string
+	endpoint string
+	secret   string
+	record   string
+	replay   string
+}
+
+// register adds the shared flags to fs
+func (f *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "config", "", "config file (default $CONFIG_FILE or "+config.DefaultPath+")")
+	fs.StringVar(&f.endpoint, "endpoint", "", "Hasura GraphQL endpoint, overrides the config")
+	fs.StringVar(&f.secret, "secret", "", "Hasura admin secret, overrides the config; visible in the process list, prefer ADMIN_SECRET or a secrets provider")
+	fs.StringVar(&f.record, "record", "", "save the Hasura responses to this file, for -replay")
+	fs.StringVar(&f.replay, "replay", "", "run from Hasura responses saved with -record instead of the endpoint")
+}
+
+// load reads the config, applies the flag overrides and fills in the
+// secrets from the configured provider before validating.
+// It also returns the logger configured there, which becomes the slog
+// default so the command's own messages share its format.
+func (f *configFlags) load(ctx context.Context) (*config.Config, *slog.Logger, error) {
+	cfg, err := config.Read(f.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.endpoint != "" {
+		cfg.HasuraEndpoint = f.endpoint
+	}
+	if f.secret != "" {
+		cfg.AdminSecret = f.secret
+	}
+	if f.record != "" {
+		cfg.Source.Record = f.record
+	}
+	if f.replay != "" {
+		cfg.Source.Type = config.SourceHasura
+		cfg.Source.Replay = f.replay
+	}
+	if err := secrets.Apply(ctx, cfg); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	logger := logging.New(os.Stderr, cfg.Log)
+	slog.SetDefault(logger)
+	return cfg, logger, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/runner"
+)
+
+// runGenerate writes one feed file per requested format, or the feed
+// targets in the config when no -format is given
+func runGenerate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	formats := fs.String("format", "xml", "comma separated output formats: "+strings.Join(runner.FormatNames(), ", ")+" (default the config's Targets, if any)")
+	out := fs.String("out", "", "output file (only with a single format; default depends on the format)")
+	full := fs.Bool("full", false, "rebuild from every ad instead of the recent window")
+	gz := fs.Bool("gzip", false, "gzip the feed files, adding .gz to their names")
+	summary := fs.Bool("summary", false, "print a summary of the run to stderr when it ends")
+	force := fs.Bool("force", false, "publish the feeds even when their item count dropped beyond the Guard")
+	dryRun := fs.Bool("dry-run", false, "run and validate everything, then print what would be written and uploaded and how it differs from the current feeds, without writing or uploading anything")
+	limit := fs.Int("limit", 0, "write a sample feed of at most this many items; sample feeds are written where the feeds go unless -out is given, and are not uploaded")
+	var adIDs []string
+	fs.Func("ad-id", "write a sample feed of only this ad's items; repeat for more ads", func(id string) error {
+		adIDs = append(adIDs, id)
+		return nil
+	})
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if *full {
+		cfg.FullRefresh = true
+	}
+	if *gz {
+		cfg.Gzip = true
+	}
+	var report io.Writer
+	if *summary {
+		report = os.Stderr
+	}
+
+	explicit := false
+	fs.Visit(func(f *flag.Flag) {
+		explicit = explicit || f.Name == "format" || f.Name == "out"
+	})
+	targets := len(cfg.Targets) > 0 && !explicit
+
+	names := strings.Split(*formats, ",")
+	if *out != "" && len(names) > 1 {
+		return errors.New("-out can only be used with a single -format")
+	}
+
+	opts := runner.Options{
+		Store:   runner.DefaultStore(cfg),
+		Report:  true,
+		Logger:  logger,
+		Summary: report,
+		Force:   *force,
+		Limit:   *limit,
+	}
+	// Sample feeds are for review: they leave the run state alone and are
+	// neither uploaded nor reported
+	sample := *limit > 0 || len(adIDs) > 0
+	if len(adIDs) > 0 {
+		if opts.Ads, err = runner.LookupAds(ctx, cfg, adIDs, logger); err != nil {
+			return err
+		}
+	}
+	if sample {
+		opts.ReadOnly = true
+		cfg.Summary.Path = ""
+	}
+
+	if *dryRun {
+		return dryRunFeeds(ctx, cfg, names, *out, targets, opts)
+	}
+	if targets {
+		return guardHint(generateTargets(ctx, cfg, opts, sample, logger))
+	}
+
+	if opts.Sinks, err = runner.CreateSinks(ctx, cfg, names, *out); err != nil {
+		return err
+	}
+	stats, err := runner.Run(ctx, cfg, opts)
+	if !sample {
+		runner.PushMetrics(ctx, cfg, logger)
+	}
+	if err != nil {
+		return guardHint(err)
+	}
+	if sample {
+		logger.Info("Generated sample feeds", "formats", *formats, "items", stats.Written)
+		return nil
+	}
+	logger.Info("Generated feeds", "formats", *formats, "items", stats.Written)
+
+	paths, err := runner.FeedPaths(cfg, names, *out)
+	if err != nil {
+		return err
+	}
+	return runner.UploadFeeds(ctx, cfg, paths, logger)
+}
+
+// generateTargets writes every feed target in one run with opts and
+// delivers each one that succeeded, unless it is a sample run. Failed
+// targets are reported after the others were delivered.
+func generateTargets(ctx context.Context, cfg *config.Config, opts runner.Options, sample bool, logger *slog.Logger) error {
+	targets, err := runner.CreateTargets(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	opts.Sinks = runner.TargetSinks(targets)
+	stats, err := runner.Run(ctx, cfg, opts)
+	if !sample {
+		runner.PushMetrics(ctx, cfg, logger)
+	}
+	if err != nil {
+		return err
+	}
+	if sample {
+		logger.Info("Generated sample feed targets", "targets", len(targets), "items", stats.Written)
+		return nil
+	}
+	logger.Info("Generated feed targets", "targets", len(targets), "items", stats.Written)
+	return runner.DeliverTargets(ctx, cfg, targets, logger)
+}
+
+// guardHint tells how to publish anyway when the item count guard stopped
+// the run
+func guardHint(err error) error {
+	if errors.Is(err, runner.ErrGuard) {
+		return fmt.Errorf("%w; the feeds were not replaced, run with -force to publish them anyway", err)
+	}
+	return err
+}
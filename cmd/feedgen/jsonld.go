@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"go_data_fashion_accessories/model/output/jsonld"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/runner"
+)
+
+// runJSONLD writes one schema.org Product JSON-LD file per item
+func runJSONLD(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("jsonld", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	dir := fs.String("dir", "jsonld", "directory for the <item id>.json files")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	// Files of items missing from the run are removed, so every item is
+	// needed, and the run must not move the feed runs' incremental window
+	cfg.FullRefresh = true
+
+	writer, err := jsonld.NewDirWriter(*dir)
+	if err != nil {
+		return err
+	}
+	stats, err := runner.Run(ctx, cfg, runner.Options{
+		Sinks:  []pipeline.Sink{writer},
+		Logger: logger,
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("Generated JSON-LD", "dir", *dir, "items", stats.Written)
+	return nil
+}
@@ -0,0 +1,90 @@
+// Command feedgen fetches marketplace ads and turns them into product feeds.
+//
+// Usage:
+//
+//	feedgen <command> [flags]
+//
+// Run "feedgen <command> -h" for the flags of a command.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	// database/sql drivers for the item history
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// command is one feedgen subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+var commands = []command{
+	{"fetch", "pull raw ads from the source and save them as JSON", runFetch},
+	{"generate", "write feed files from the source ads", runGenerate},
+	{"validate", "check the source ads against the feed specs", runValidate},
+	{"explain", "show why one ad is or is not in the feed", runExplain},
+	{"rules", "test the inclusion rules against the source ads", runRules},
+	{"diff", "compare two generated feed files item by item", runDiff},
+	{"upload", "push generated feed files to their destinations", runUpload},
+	{"jsonld", "write schema.org Product JSON-LD for each item", runJSONLD},
+	{"push", "send the items to Merchant Center or a Meta catalog by API", runPush},
+	{"promotions", "write the Merchant Center promotions feed", runPromotions},
+	{"diagnostics", "report the items Merchant Center disapproved and exclude repeat offenders", runDiagnostics},
+	{"serve", "regenerate the feeds on a schedule and serve them over HTTP", runServe},
+	{"watch", "push ad changes to a channel API as they happen, by polling and webhook", runWatch},
+	{"schedule", "regenerate the feed files on a cron schedule", runSchedule},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: feedgen <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.summary)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if name == "-h" || name == "--help" || name == "help" {
+		usage()
+		return
+	}
+
+	// Stop the run cleanly on Ctrl+C or when the runner is cancelled
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// A second signal kills the process without waiting for the run
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(ctx, os.Args[2:]); err != nil {
+				stop()
+				slog.Error("Command failed", "command", name, "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "feedgen: unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
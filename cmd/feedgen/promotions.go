@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/promotions"
+)
+
+// runPromotions writes the promotions feed from the promotions of the config
+// and the promotions table. The items they apply to get their promotion_id
+// in every generate run.
+func runPromotions(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("promotions", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	out := fs.String("out", "", "promotions feed file (default the config's Promotions.Path)")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if !promotions.Enabled(cfg) {
+		return errors.New("no promotions: set Promotions.List or Promotions.Table")
+	}
+	path := cfg.Promotions.Path
+	if *out != "" {
+		path = *out
+	}
+
+	var source input.StreamingSource
+	if cfg.Promotions.Table != "" {
+		if source, err = input.NewSource(cfg, logger); err != nil {
+			return err
+		}
+	}
+	list, err := promotions.Load(ctx, cfg, source, time.Now())
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := promotions.WriteXML(file, list); err != nil {
+		file.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	logger.Info("Wrote promotions feed", "path", path, "promotions", len(list))
+	return nil
+}
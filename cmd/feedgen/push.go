@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/delta"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/model/output/contentapi"
+	"go_data_fashion_accessories/model/output/metaapi"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/runner"
+)
+
+// Channels items can be pushed to. The names also select the channel
+// options in the config that apply to pushed items.
+const (
+	pushGoogle = "contentapi"
+	pushMeta   = "metaapi"
+)
+
+// pusher is a channel API client as the push command uses it
+type pusher struct {
+	sink     pipeline.Sink
+	upsert   func(ctx context.Context, items []input.AdItem) error
+	delete   func(ctx context.Context, ids []string) error
+	rejected func() int
+}
+
+// newPusher returns the client for channel
+func newPusher(ctx context.Context, cfg *config.Config, channel string) (pusher, error) {
+	switch channel {
+	case pushGoogle:
+		if cfg.ContentAPI.MerchantID == "" || cfg.ContentAPI.CredentialsFile == "" {
+			return pusher{}, errors.New("push needs ContentAPI.MerchantID and ContentAPI.CredentialsFile")
+		}
+		client, err := contentapi.New(ctx, cfg, nil)
+		if err != nil {
+			return pusher{}, err
+		}
+		return pusher{
+			sink:     contentapi.NewSink(ctx, client),
+			upsert:   client.Insert,
+			delete:   client.Delete,
+			rejected: func() int { return len(client.Errors) },
+		}, nil
+	case pushMeta:
+		if cfg.MetaAPI.CatalogID == "" || cfg.MetaAPI.AccessToken == "" {
+			return pusher{}, errors.New("push needs MetaAPI.CatalogID and MetaAPI.AccessToken")
+		}
+		client, err := metaapi.New(cfg, nil)
+		if err != nil {
+			return pusher{}, err
+		}
+		return pusher{
+			sink:     metaapi.NewSink(ctx, client),
+			upsert:   client.Update,
+			delete:   client.Delete,
+			rejected: func() int { return len(client.Errors) },
+		}, nil
+	default:
+		return pusher{}, fmt.Errorf("unknown channel %q (want %s or %s)", channel, pushGoogle, pushMeta)
+	}
+}
+
+// runPush sends the items straight to a channel's API instead of writing a
+// feed file. With delta feeds enabled, the items that disappeared are
+// deleted too.
+func runPush(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	channel := fs.String("to", pushGoogle, "channel API to push to: "+pushGoogle+" (Merchant Center) or "+pushMeta+" (Meta catalog)")
+	full := fs.Bool("full", false, "push every ad instead of the recent window")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if *full {
+		cfg.FullRefresh = true
+	}
+
+	p, err := newPusher(ctx, cfg, *channel)
+	if err != nil {
+		return err
+	}
+	stats, err := runner.Run(ctx, cfg, runner.Options{
+		Sinks:  []pipeline.Sink{runner.ChannelSink(cfg, *channel, "", p.sink)},
+		Store:  runner.DefaultStore(cfg),
+		Report: true,
+		Logger: logger,
+		OnDelete: func(ctx context.Context, tombstones []delta.Tombstone) error {
+			ids := make([]string, len(tombstones))
+			for i, ts := range tombstones {
+				ids[i] = ts.ID
+			}
+			return p.delete(ctx, ids)
+		},
+	})
+	runner.PushMetrics(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Pushed items", "channel", *channel, "items", stats.Written, "rejected", p.rejected())
+	return nil
+}
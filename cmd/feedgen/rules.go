@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/rules"
+	"go_data_fashion_accessories/runner"
+)
+
+// ruleSink evaluates rules against every item instead of writing it
+type ruleSink struct {
+	rules   []*rules.Rule
+	verbose bool
+	items   int
+	kept    map[string]int
+	errs    int
+}
+
+func (s *ruleSink) Write(item input.AdItem) error {
+	s.items++
+	for _, rule := range s.rules {
+		ok, err := rule.Eval(item)
+		switch {
+		case err != nil:
+			s.errs++
+			fmt.Fprintf(os.Stdout, "ERROR %s %s: %v\n", rule.Name, item.ID, err)
+		case ok:
+			s.kept[rule.Name]++
+			if s.verbose {
+				fmt.Fprintf(os.Stdout, "PASS  %s %s\n", rule.Name, item.ID)
+			}
+		default:
+			fmt.Fprintf(os.Stdout, "FAIL  %s %s (%s)\n", rule.Name, item.ID, item.Title)
+		}
+	}
+	return nil
+}
+
+func (s *ruleSink) Close() error { return nil }
+
+// runRules runs a rules subcommand; test is the only one
+func runRules(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "test" {
+		return errors.New("usage: feedgen rules test [flags]")
+	}
+	return runRulesTest(ctx, args[1:])
+}
+
+// runRulesTest runs the pipeline with the inclusion rules turned off and
+// reports which items each rule would drop, so rules can be tried out before
+// they are deployed
+func runRulesTest(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rules test", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	full := fs.Bool("full", false, "test every ad instead of the recent window")
+	when := fs.String("when", "", "test this expression instead of the configured rules")
+	verbose := fs.Bool("v", false, "also list the items each rule keeps")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if *full {
+		cfg.FullRefresh = true
+	}
+
+	tested := cfg.Rules
+	if *when != "" {
+		tested = []config.Rule{{Name: "when", When: *when}}
+	}
+	if len(tested) == 0 {
+		return errors.New("no rules configured; pass -when to test an expression")
+	}
+	compiled, err := rules.Compile(tested)
+	if err != nil {
+		return fmt.Errorf("%w\nvariables: %s", err, strings.Join(rules.Names(), ", "))
+	}
+
+	// The rules under test must see every item, not only the ones they keep
+	cfg.Rules = nil
+	sink := &ruleSink{rules: compiled, verbose: *verbose, kept: map[string]int{}}
+	if _, err := runner.Run(ctx, cfg, runner.Options{
+		Sinks:    []pipeline.Sink{sink},
+		Store:    runner.DefaultStore(cfg),
+		ReadOnly: true,
+		Logger:   logger,
+	}); err != nil {
+		return err
+	}
+
+	for _, rule := range compiled {
+		fmt.Fprintf(os.Stdout, "%s: keeps %d of %d items\n", rule.Name, sink.kept[rule.Name], sink.items)
+	}
+	if sink.errs > 0 {
+		return fmt.Errorf("%d rule evaluations failed", sink.errs)
+	}
+	return nil
+}
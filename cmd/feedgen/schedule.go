@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/metrics"
+	"go_data_fashion_accessories/runner"
+	"go_data_fashion_accessories/scheduler"
+)
+
+// runSchedule regenerates the feed files on a cron schedule until the
+// process receives SIGINT or SIGTERM
+func runSchedule(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	spec := fs.String("cron", "", `cron expression, e.g. "0 * * * *" or "@every 30m" (default from config, @hourly)`)
+	formats := fs.String("format", "xml,csv", "comma separated output formats: "+strings.Join(runner.FormatNames(), ", "))
+	statusAddr := fs.String("status-addr", "", "serve the last run status as JSON at /status on this address")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if *spec != "" {
+		cfg.Schedule = *spec
+	}
+
+	names := strings.Split(*formats, ",")
+	job := func(ctx context.Context) error {
+		sinks, err := runner.CreateSinks(ctx, cfg, names, "")
+		if err != nil {
+			return err
+		}
+		if _, err := runner.Run(ctx, cfg, runner.Options{
+			Sinks:  sinks,
+			Store:  runner.DefaultStore(cfg),
+			Report: true,
+			Logger: logger,
+		}); err != nil {
+			return err
+		}
+		paths, err := runner.FeedPaths(cfg, names, "")
+		if err != nil {
+			return err
+		}
+		return runner.UploadFeeds(ctx, cfg, paths, logger)
+	}
+
+	sched, err := scheduler.New(cfg.Schedule, job, logger)
+	if err != nil {
+		return err
+	}
+	sched.GracePeriod = cfg.Shutdown.GracePeriod.Duration
+
+	if *statusAddr != "" {
+		go serveStatus(ctx, *statusAddr, sched, logger)
+	}
+
+	logger.Info("Generating feeds on schedule", "formats", *formats, "schedule", cfg.Schedule)
+	return sched.Run(ctx)
+}
+
+// serveStatus exposes the scheduler status and metrics until ctx is cancelled
+func serveStatus(ctx context.Context, addr string, sched *scheduler.Scheduler, logger *slog.Logger) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sched.Status())
+	})
+	mux.Handle("/metrics", metrics.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Status server stopped", "error", err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"go_data_fashion_accessories/server"
+)
+
+// runServe keeps the feeds fresh in memory and serves them over HTTP until
+// the process is stopped
+func runServe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	addr := fs.String("addr", "", "listen address (default from config, :8080)")
+	interval := fs.Duration("interval", 0, "feed refresh interval (default from config, 1h)")
+	adminAddr := fs.String("admin-addr", "", "serve the dashboard and the items API on this address (default from config, 127.0.0.1:8081)")
+	grpcAddr := fs.String("grpc-addr", "", "serve the gRPC API on this address (default from config, off)")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if *addr != "" {
+		cfg.Server.Addr = *addr
+	}
+	if *adminAddr != "" {
+		cfg.Server.AdminAddr = *adminAddr
+	}
+	if *grpcAddr != "" {
+		cfg.Server.GRPCAddr = *grpcAddr
+	}
+	if *interval > 0 {
+		cfg.Server.RefreshInterval.Duration = *interval
+	}
+	// The addresses given as flags are checked like the configured ones
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	return server.New(cfg, cfg.Server.RefreshInterval.Duration, logger).Run(ctx, cfg.Server.Addr)
+}
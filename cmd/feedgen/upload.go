@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/runner"
+	"go_data_fashion_accessories/secrets"
+	"go_data_fashion_accessories/upload"
+)
+
+// runUpload pushes feed files to a destination URL. A destination ending in
+// "/" is treated as a directory and each file keeps its base name. SFTP
+// logins come from the Upload section of the config.
+func runUpload(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	configPath := fs.String("config", "", "config file with the SFTP and object storage settings (default $CONFIG_FILE or "+config.DefaultPath+")")
+	dest := fs.String("dest", "", "destination URL ("+strings.Join(upload.Schemes(), ", ")+")")
+	fs.Parse(args)
+
+	files := fs.Args()
+	if *dest == "" || len(files) == 0 {
+		return errors.New("usage: feedgen upload -dest URL FILE...")
+	}
+	if len(files) > 1 && !strings.HasSuffix(*dest, "/") {
+		return errors.New("-dest must end in / when uploading several files")
+	}
+
+	cfg, err := config.Read(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := secrets.Apply(ctx, cfg); err != nil {
+		return err
+	}
+	runner.RegisterUploaders(cfg, slog.Default())
+
+	for _, file := range files {
+		target := *dest
+		if strings.HasSuffix(target, "/") {
+			target += filepath.Base(file)
+		}
+		if err := upload.Upload(ctx, file, target); err != nil {
+			return err
+		}
+		slog.Info("Uploaded feed", "file", file, "dest", redact(target))
+	}
+	return nil
+}
+
+// redact hides any password in a destination URL before it is logged
+func redact(dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+	return u.Redacted()
+}
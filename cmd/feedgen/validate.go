@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/imagecheck"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/runner"
+	"go_data_fashion_accessories/validate"
+)
+
+// issueSink collects the validation issues of every item
+type issueSink struct {
+	validate.Report
+	ctx     context.Context
+	images  *imagecheck.Checker // nil to leave the image links unchecked
+	minSide func(item input.AdItem) int
+}
+
+// checkImages makes the sink request every image link too, with the
+// settings in cfg.ImageCheck
+func (s *issueSink) checkImages(ctx context.Context, cfg *config.Config) error {
+	images, err := runner.NewImageChecker(cfg)
+	if err != nil {
+		return err
+	}
+	s.ctx = ctx
+	s.images = images
+	s.minSide = func(item input.AdItem) int { return runner.MinImageSide(cfg, item) }
+	return nil
+}
+
+func (s *issueSink) Write(item input.AdItem) error {
+	issues := validate.Item(item)
+	if s.images != nil {
+		broken, err := validate.Images(s.ctx, s.images, s.minSide(item), item)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, broken...)
+	}
+	s.Add(issues)
+	return nil
+}
+
+func (s *issueSink) Close() error { return nil }
+
+// runValidate runs the pipeline without writing feeds and reports every item
+// that breaks a feed rule. It fails when any item does, so it can gate CI.
+func runValidate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	full := fs.Bool("full", false, "validate every ad instead of the recent window")
+	images := fs.Bool("images", false, "also request every image link and report the ones that are broken or too small, with the ImageCheck settings")
+	reportPath := fs.String("report", "", "write the issues to this file for CI, as JUnit XML if it ends in .xml and JSON otherwise")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if *full {
+		cfg.FullRefresh = true
+	}
+
+	sink := &issueSink{}
+	if *images {
+		if err := sink.checkImages(ctx, cfg); err != nil {
+			return err
+		}
+	}
+	if _, err := runner.Run(ctx, cfg, runner.Options{
+		Sinks:    []pipeline.Sink{sink},
+		Store:    runner.DefaultStore(cfg),
+		ReadOnly: true,
+		Logger:   logger,
+	}); err != nil {
+		return err
+	}
+
+	for _, issue := range sink.Issues {
+		fmt.Fprintln(os.Stdout, issue)
+	}
+	fmt.Fprintf(os.Stdout, "%d items checked, %d with issues\n", sink.Items, sink.Failed)
+
+	if *reportPath != "" {
+		if err := writeReport(&sink.Report, *reportPath); err != nil {
+			return err
+		}
+	}
+	if sink.Failed > 0 {
+		return fmt.Errorf("%d of %d items failed validation", sink.Failed, sink.Items)
+	}
+	return nil
+}
+
+// writeReport writes report to path, in the format its extension names
+func writeReport(report *validate.Report, path string) error {
+	format := validate.ReportJSON
+	if filepath.Ext(path) == ".xml" {
+		format = validate.ReportJUnit
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(file, format); err != nil {
+		file.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return file.Close()
+}
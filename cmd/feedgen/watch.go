@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	"go_data_fashion_accessories/watch"
+)
+
+// runWatch keeps a channel API up to date by polling the source for the ads
+// updated in the last few minutes
+func runWatch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	var cf configFlags
+	cf.register(fs)
+	channel := fs.String("to", pushGoogle, "channel API to push to: "+pushGoogle+" (Merchant Center) or "+pushMeta+" (Meta catalog)")
+	interval := fs.Duration("interval", 0, "poll interval (default from config, 1m)")
+	initial := fs.Bool("push-initial", false, "push every item on start, not only later changes")
+	listen := fs.String("listen", "", "address to receive Hasura event triggers on at /events (default from config, off)")
+	fs.Parse(args)
+
+	cfg, logger, err := cf.load(ctx)
+	if err != nil {
+		return err
+	}
+	if *interval > 0 {
+		cfg.Watch.PollInterval.Duration = *interval
+	}
+	if *initial {
+		cfg.Watch.PushInitial = true
+	}
+	if *listen != "" {
+		cfg.Watch.Listen = *listen
+	}
+
+	p, err := newPusher(ctx, cfg, *channel)
+	if err != nil {
+		return err
+	}
+	logger.Info("Watching for ad changes", "channel", *channel, "interval", cfg.Watch.PollInterval.String())
+	return watch.New(cfg, *channel, watch.Pusher{Upsert: p.upsert, Delete: p.delete}, logger).Run(ctx)
+}
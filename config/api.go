@@ -0,0 +1,107 @@
+package config
+
+import (
+	"errors"
+	"os"
+)
+
+// ContentAPI configures pushing items to Google Merchant Center with the
+// Content API for Shopping instead of a feed file
+type ContentAPI struct {
+	MerchantID        string  `json:"MerchantID"`
+	CredentialsFile   string  `json:"CredentialsFile"` // service account key JSON
+	Endpoint          string  `json:"Endpoint"`
+	BatchSize         int     `json:"BatchSize"`         // entries per custombatch request, at most 1000
+	RequestsPerSecond float64 `json:"RequestsPerSecond"` // batch requests per second
+	ContentLanguage   string  `json:"ContentLanguage"`
+	TargetCountry     string  `json:"TargetCountry"`
+	Channel           string  `json:"Channel"` // online or local
+}
+
+// DefaultContentAPI is used for any Content API setting left unset
+var DefaultContentAPI = ContentAPI{
+	Endpoint:          "https://shoppingcontent.googleapis.com/content/v2.1",
+	BatchSize:         500,
+	RequestsPerSecond: 2,
+	ContentLanguage:   "en",
+	TargetCountry:     "AE",
+	Channel:           "online",
+}
+
+// MetaAPI configures pushing items to a Meta Commerce catalog with the
+// Graph API instead of a catalog CSV
+type MetaAPI struct {
+	CatalogID   string `json:"CatalogID"`
+	AccessToken string `json:"AccessToken"` // system user token with catalog_management
+	Endpoint    string `json:"Endpoint"`    // Graph API base URL, including the version
+	BatchSize   int    `json:"BatchSize"`   // requests per items_batch call, at most 5000
+}
+
+// DefaultMetaAPI is used for any Meta API setting left unset
+var DefaultMetaAPI = MetaAPI{
+	Endpoint:  "https://graph.facebook.com/v21.0",
+	BatchSize: 1000,
+}
+
+// applyAPIEnv overrides the channel API settings with their environment
+// variables
+func (c *Config) applyAPIEnv() error {
+	if v := os.Getenv("MERCHANT_ID"); v != "" {
+		c.ContentAPI.MerchantID = v
+	}
+	if v := os.Getenv("CONTENT_API_CREDENTIALS"); v != "" {
+		c.ContentAPI.CredentialsFile = v
+	}
+	if v := os.Getenv("META_CATALOG_ID"); v != "" {
+		c.MetaAPI.CatalogID = v
+	}
+	if v := os.Getenv("META_ACCESS_TOKEN"); v != "" {
+		c.MetaAPI.AccessToken = v
+	}
+	return nil
+}
+
+// applyAPIDefaults fills in the channel API settings that were left unset
+func (c *Config) applyAPIDefaults() {
+	if c.ContentAPI.Endpoint == "" {
+		c.ContentAPI.Endpoint = DefaultContentAPI.Endpoint
+	}
+	if c.ContentAPI.BatchSize == 0 {
+		c.ContentAPI.BatchSize = DefaultContentAPI.BatchSize
+	}
+	if c.ContentAPI.RequestsPerSecond == 0 {
+		c.ContentAPI.RequestsPerSecond = DefaultContentAPI.RequestsPerSecond
+	}
+	if c.ContentAPI.ContentLanguage == "" {
+		c.ContentAPI.ContentLanguage = DefaultContentAPI.ContentLanguage
+	}
+	if c.ContentAPI.TargetCountry == "" {
+		c.ContentAPI.TargetCountry = DefaultContentAPI.TargetCountry
+	}
+	if c.ContentAPI.Channel == "" {
+		c.ContentAPI.Channel = DefaultContentAPI.Channel
+	}
+	if c.MetaAPI.Endpoint == "" {
+		c.MetaAPI.Endpoint = DefaultMetaAPI.Endpoint
+	}
+	if c.MetaAPI.BatchSize == 0 {
+		c.MetaAPI.BatchSize = DefaultMetaAPI.BatchSize
+	}
+}
+
+// validateAPI checks the batch sizes and rates of the channel APIs
+func (c *Config) validateAPI() error {
+	if c.ContentAPI.BatchSize < 1 || c.ContentAPI.BatchSize > 1000 {
+		return errors.New("config: ContentAPI.BatchSize must be between 1 and 1000")
+	}
+	if c.ContentAPI.RequestsPerSecond < 0 {
+		return errors.New("config: ContentAPI.RequestsPerSecond must be positive")
+	}
+	if c.ContentAPI.Channel != "online" && c.ContentAPI.Channel != "local" {
+		return errors.New(`config: ContentAPI.Channel must be "online" or "local"`)
+	}
+	if c.MetaAPI.BatchSize < 1 || c.MetaAPI.BatchSize > 5000 {
+		return errors.New("config: MetaAPI.BatchSize must be between 1 and 5000")
+	}
+	return nil
+}
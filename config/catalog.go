@@ -0,0 +1,461 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go_data_fashion_accessories/money"
+)
+
+// Policies for ads whose code number is not a valid GTIN
+const (
+	InvalidGTINFlag   = "flag"   // keep the item without a GTIN
+	InvalidGTINReject = "reject" // leave the item out of the feed
+)
+
+// Policies for ads without a code number
+const (
+	MissingGTINSkip         = "skip"          // leave the ad out of the feed
+	MissingGTINMPN          = "mpn"           // include it when it has an MPN
+	MissingGTINNoIdentifier = "no_identifier" // include it, with identifier_exists=no if it has no MPN
+)
+
+// Policies for items that share a GTIN, which Google flags as duplicates
+const (
+	DuplicateGTINKeepNewest = "keep_newest" // keep the most recently updated ad's item
+	DuplicateGTINKeepAll    = "keep_all"    // send them all
+)
+
+// Orders the feed items can be written in
+const (
+	OrderID        = "id"         // by item ID
+	OrderUpdatedAt = "updated_at" // most recently updated ads first, then by ID
+	OrderSource    = "source"     // as the source returns them, without holding them in memory
+)
+
+// Handling of ads whose only qualifying payment method is cash on delivery,
+// in Eligibility.CashOnDelivery
+const (
+	CashOnDeliveryExclude  = "exclude"  // leave the ads out
+	CashOnDeliveryInclude  = "include"  // treat cash on delivery as qualifying
+	CashOnDeliverySeparate = "separate" // write the ads to their own feed files
+)
+
+// Handling of auction ads, in Eligibility.Auctions
+const (
+	AuctionsInclude  = "include"
+	AuctionsExclude  = "exclude"
+	AuctionsSeparate = "separate" // write the ads to their own feed files
+)
+
+// Handling of items with no stock left, in Eligibility.OutOfStock
+const (
+	OutOfStockInclude = "include" // list them as out of stock
+	OutOfStockExclude = "exclude" // leave them out
+)
+
+// Eligibility chooses which ads qualify for the feeds by payment method and
+// ad type, and whether items with no stock left are listed. Payment methods
+// are compared case-insensitively.
+type Eligibility struct {
+	PaymentMethods       []string `json:"PaymentMethods"`       // methods that qualify an ad
+	CashOnDeliveryMethod string   `json:"CashOnDeliveryMethod"` // the cash on delivery method's name
+	CashOnDelivery       string   `json:"CashOnDelivery"`       // exclude (default), include or separate
+	Auctions             string   `json:"Auctions"`             // include (default), exclude or separate
+	OutOfStock           string   `json:"OutOfStock"`           // include (default) or exclude
+}
+
+// DefaultEligibility is used for any eligibility setting left unset
+var DefaultEligibility = Eligibility{
+	PaymentMethods:       []string{"Online Payment"},
+	CashOnDeliveryMethod: "Cash on Delivery",
+	CashOnDelivery:       CashOnDeliveryExclude,
+	Auctions:             AuctionsInclude,
+	OutOfStock:           OutOfStockInclude,
+}
+
+// Brands lists the brands items are filtered by. Names are compared
+// ignoring case, spacing and punctuation.
+//
+// The brand of every item is also looked up in a dictionary of canonical
+// names: Canonical, the names in File, Allow and Block. A brand that is one
+// of them, one of their Aliases or close enough to one is written as the
+// canonical name, so "RayBan" and "ray-ban" both become "Ray-Ban". Close
+// means a Jaro-Winkler similarity of at least MinSimilarity and a
+// Levenshtein distance of at most MaxEdits.
+type Brands struct {
+	Block []string `json:"Block"` // brands never advertised, such as counterfeit-prone ones
+	Allow []string `json:"Allow"` // when set, only these brands are advertised
+
+	Canonical     []string          `json:"Canonical"`
+	File          string            `json:"File"`    // more canonical names, one per line
+	Aliases       map[string]string `json:"Aliases"` // other names of a brand, such as LV, to its canonical name
+	MinSimilarity float64           `json:"MinSimilarity"`
+	MaxEdits      int               `json:"MaxEdits"`
+	// UnmatchedPath is a CSV of the brands no canonical name matched in the
+	// last run, with their item counts; "" writes none
+	UnmatchedPath string `json:"UnmatchedPath"`
+}
+
+// DefaultBrands is used for any brand matching setting left unset
+var DefaultBrands = Brands{
+	MinSimilarity: 0.9,
+	MaxEdits:      2,
+}
+
+// PriceRange is a range of plausible prices, written as amounts in
+// Currency such as "50" or "25000.00". An empty bound is not checked.
+type PriceRange struct {
+	Min string `json:"Min"`
+	Max string `json:"Max"`
+}
+
+// Bounds returns the parsed range; an unset bound is the zero Money
+func (r PriceRange) Bounds(currency string) (min, max money.Money, err error) {
+	if r.Min != "" {
+		if min, err = money.Parse(r.Min, currency); err != nil {
+			return min, max, fmt.Errorf("invalid Min %q: %w", r.Min, err)
+		}
+	}
+	if r.Max != "" {
+		if max, err = money.Parse(r.Max, currency); err != nil {
+			return min, max, fmt.Errorf("invalid Max %q: %w", r.Max, err)
+		}
+		if max.Minor < min.Minor {
+			return min, max, fmt.Errorf("Max %s is below Min %s", r.Max, r.Min)
+		}
+	}
+	return min, max, nil
+}
+
+// PriceBounds rejects items priced outside the plausible range for their
+// subcategory, such as a watch listed at 1 AED
+type PriceBounds struct {
+	Default       PriceRange            `json:"Default"`
+	Subcategories map[string]PriceRange `json:"Subcategories"` // by subcategory ID or name, instead of Default
+}
+
+// Rule is an inclusion rule: items for which When, a CEL expression over
+// the item fields, is false are left out of the feeds. See package rules.
+type Rule struct {
+	Name string `json:"Name"`
+	When string `json:"When"`
+}
+
+// MaxCustomLabels is the number of custom_label_N attributes, 0 to 4
+const MaxCustomLabels = 5
+
+// CustomLabel sets custom_label_<Index> to Value on the items for which
+// When, a CEL expression like those of the Rules, is true. The first
+// matching label of each index wins.
+type CustomLabel struct {
+	Index int    `json:"Index"`
+	Value string `json:"Value"`
+	When  string `json:"When"`
+}
+
+// MaxQualityScore is the quality score of an item meeting every criterion
+const MaxQualityScore = 6
+
+// Quality configures the quality score an item needs to go into the feeds;
+// the quality package describes how items are scored
+type Quality struct {
+	MinScore int `json:"MinScore"` // 0 to MaxQualityScore, 0 keeps every item
+}
+
+// Category is one marketplace category fetched in a run, such as
+// accessories, apparel or footwear
+type Category struct {
+	ID            string   `json:"ID"`
+	Subcategories []string `json:"Subcategories"` // allowed subcategory IDs
+	// FeedLabel names the category's feed files, e.g. apparel for
+	// productsfashionaccessories_apparel.xml, and is copied to its items.
+	// One category may leave it empty to write the unsuffixed files.
+	FeedLabel string `json:"FeedLabel"`
+	// Outputs lists the output formats the category is written in, or
+	// every requested format when empty
+	Outputs []string `json:"Outputs"`
+}
+
+// Allows reports whether subcategory is one of the category's
+func (c Category) Allows(subcategory string) bool {
+	return slices.Contains(c.Subcategories, subcategory)
+}
+
+// Writes reports whether the category is written in format
+func (c Category) Writes(format string) bool {
+	return len(c.Outputs) == 0 || slices.Contains(c.Outputs, format)
+}
+
+// CategoryRegistry lists the categories of a run
+type CategoryRegistry []Category
+
+// IDs returns the category IDs, in registry order
+func (r CategoryRegistry) IDs() []string {
+	ids := make([]string, len(r))
+	for i, c := range r {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+// ByID returns the category with the given ID
+func (r CategoryRegistry) ByID(id string) (Category, bool) {
+	for _, c := range r {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
+
+// BySubcategory returns the first category allowing subcategory, for ads
+// whose source does not report their category
+func (r CategoryRegistry) BySubcategory(subcategory string) (Category, bool) {
+	for _, c := range r {
+		if c.Allows(subcategory) {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
+
+// ForFormat returns the categories written in format
+func (r CategoryRegistry) ForFormat(format string) CategoryRegistry {
+	var out CategoryRegistry
+	for _, c := range r {
+		if c.Writes(format) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// applyCatalogEnv overrides the settings choosing the items of the feeds
+// with their environment variables
+func (c *Config) applyCatalogEnv() error {
+	if v := os.Getenv("CATEGORY_ID"); v != "" {
+		c.CategoryID = v
+	}
+	if v := os.Getenv("ALLOWED_SUBCATEGORIES"); v != "" {
+		c.AllowedSubcategories = splitList(v)
+	}
+	if v := os.Getenv("PAYMENT_METHODS"); v != "" {
+		c.Eligibility.PaymentMethods = splitList(v)
+	}
+	if v := os.Getenv("CASH_ON_DELIVERY"); v != "" {
+		c.Eligibility.CashOnDelivery = v
+	}
+	if v := os.Getenv("AUCTIONS"); v != "" {
+		c.Eligibility.Auctions = v
+	}
+	if v := os.Getenv("OUT_OF_STOCK"); v != "" {
+		c.Eligibility.OutOfStock = v
+	}
+	if v := os.Getenv("BLOCKED_BRANDS"); v != "" {
+		c.Brands.Block = splitList(v)
+	}
+	if v := os.Getenv("BRANDS_FILE"); v != "" {
+		c.Brands.File = v
+	}
+	if v := os.Getenv("MIN_PRICE"); v != "" {
+		c.PriceBounds.Default.Min = v
+	}
+	if v := os.Getenv("MAX_PRICE"); v != "" {
+		c.PriceBounds.Default.Max = v
+	}
+	if v := os.Getenv("INVALID_GTIN"); v != "" {
+		c.InvalidGTIN = v
+	}
+	if v := os.Getenv("MISSING_GTIN"); v != "" {
+		c.MissingGTIN = v
+	}
+	if v := os.Getenv("DUPLICATE_GTIN"); v != "" {
+		c.DuplicateGTIN = v
+	}
+	if v := os.Getenv("FEED_ORDER"); v != "" {
+		c.Order = v
+	}
+	if v := os.Getenv("MIN_QUALITY_SCORE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid MIN_QUALITY_SCORE %q: %w", v, err)
+		}
+		c.Quality.MinScore = n
+	}
+	return nil
+}
+
+// applyCatalogDefaults fills in the settings choosing the items of the
+// feeds that were left unset
+func (c *Config) applyCatalogDefaults() {
+	if c.Eligibility.PaymentMethods == nil {
+		c.Eligibility.PaymentMethods = DefaultEligibility.PaymentMethods
+	}
+	if c.Eligibility.CashOnDeliveryMethod == "" {
+		c.Eligibility.CashOnDeliveryMethod = DefaultEligibility.CashOnDeliveryMethod
+	}
+	if c.Eligibility.CashOnDelivery == "" {
+		c.Eligibility.CashOnDelivery = DefaultEligibility.CashOnDelivery
+	}
+	if c.Eligibility.Auctions == "" {
+		c.Eligibility.Auctions = DefaultEligibility.Auctions
+	}
+	if c.Eligibility.OutOfStock == "" {
+		c.Eligibility.OutOfStock = DefaultEligibility.OutOfStock
+	}
+	if c.Brands.MinSimilarity == 0 {
+		c.Brands.MinSimilarity = DefaultBrands.MinSimilarity
+	}
+	if c.Brands.MaxEdits == 0 {
+		c.Brands.MaxEdits = DefaultBrands.MaxEdits
+	}
+	if c.InvalidGTIN == "" {
+		c.InvalidGTIN = InvalidGTINFlag
+	}
+	if c.MissingGTIN == "" {
+		c.MissingGTIN = MissingGTINSkip
+	}
+	if c.DuplicateGTIN == "" {
+		c.DuplicateGTIN = DuplicateGTINKeepNewest
+	}
+	if c.Order == "" {
+		c.Order = OrderID
+	}
+}
+
+// validateCatalog checks the categories, eligibility, rules, labels and
+// item policies
+func (c *Config) validateCatalog() error {
+	if len(c.Categories) == 0 {
+		if c.CategoryID == "" {
+			return errors.New("config: CategoryID or Categories is required")
+		}
+		if len(c.AllowedSubcategories) == 0 {
+			return errors.New("config: AllowedSubcategories must not be empty")
+		}
+	}
+	if err := c.validateCategories(); err != nil {
+		return err
+	}
+	if len(c.Eligibility.PaymentMethods) == 0 && c.Eligibility.CashOnDelivery == CashOnDeliveryExclude {
+		return errors.New("config: Eligibility.PaymentMethods must not be empty unless cash on delivery is included")
+	}
+	names := map[string]bool{}
+	for i, rule := range c.Rules {
+		if rule.Name == "" || rule.When == "" {
+			return fmt.Errorf("config: Rules[%d] needs a Name and a When expression", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("config: Rules: %q is listed twice", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	for i, label := range c.CustomLabels {
+		if label.Index < 0 || label.Index >= MaxCustomLabels {
+			return fmt.Errorf("config: CustomLabels[%d].Index must be between 0 and %d", i, MaxCustomLabels-1)
+		}
+		if label.Value == "" || label.When == "" {
+			return fmt.Errorf("config: CustomLabels[%d] needs a Value and a When expression", i)
+		}
+		if len([]rune(label.Value)) > 100 {
+			return fmt.Errorf("config: CustomLabels[%d].Value is longer than 100 characters", i)
+		}
+	}
+	switch c.Eligibility.CashOnDelivery {
+	case CashOnDeliveryExclude, CashOnDeliveryInclude, CashOnDeliverySeparate:
+	default:
+		return fmt.Errorf("config: Eligibility.CashOnDelivery must be %q, %q or %q",
+			CashOnDeliveryExclude, CashOnDeliveryInclude, CashOnDeliverySeparate)
+	}
+	switch c.Eligibility.Auctions {
+	case AuctionsInclude, AuctionsExclude, AuctionsSeparate:
+	default:
+		return fmt.Errorf("config: Eligibility.Auctions must be %q, %q or %q", AuctionsInclude, AuctionsExclude, AuctionsSeparate)
+	}
+	switch c.Eligibility.OutOfStock {
+	case OutOfStockInclude, OutOfStockExclude:
+	default:
+		return fmt.Errorf("config: Eligibility.OutOfStock must be %q or %q", OutOfStockInclude, OutOfStockExclude)
+	}
+	if c.InvalidGTIN != InvalidGTINFlag && c.InvalidGTIN != InvalidGTINReject {
+		return fmt.Errorf("config: InvalidGTIN must be %q or %q", InvalidGTINFlag, InvalidGTINReject)
+	}
+	switch c.MissingGTIN {
+	case MissingGTINSkip, MissingGTINMPN, MissingGTINNoIdentifier:
+	default:
+		return fmt.Errorf("config: MissingGTIN must be %q, %q or %q", MissingGTINSkip, MissingGTINMPN, MissingGTINNoIdentifier)
+	}
+	if c.DuplicateGTIN != DuplicateGTINKeepNewest && c.DuplicateGTIN != DuplicateGTINKeepAll {
+		return fmt.Errorf("config: DuplicateGTIN must be %q or %q", DuplicateGTINKeepNewest, DuplicateGTINKeepAll)
+	}
+	switch c.Order {
+	case OrderID, OrderUpdatedAt, OrderSource:
+	default:
+		return fmt.Errorf("config: Order must be %q, %q or %q", OrderID, OrderUpdatedAt, OrderSource)
+	}
+	if _, _, err := c.PriceBounds.Default.Bounds(c.Currency); err != nil {
+		return fmt.Errorf("config: PriceBounds.Default: %w", err)
+	}
+	for subcategory, r := range c.PriceBounds.Subcategories {
+		if _, _, err := r.Bounds(c.Currency); err != nil {
+			return fmt.Errorf("config: PriceBounds.Subcategories.%s: %w", subcategory, err)
+		}
+	}
+	if c.Brands.MinSimilarity < 0 || c.Brands.MinSimilarity > 1 || c.Brands.MaxEdits < 0 {
+		return errors.New("config: Brands.MinSimilarity must be between 0 and 1 and Brands.MaxEdits not negative")
+	}
+	if c.Quality.MinScore < 0 || c.Quality.MinScore > MaxQualityScore {
+		return fmt.Errorf("config: Quality.MinScore must be between 0 and %d", MaxQualityScore)
+	}
+	return nil
+}
+
+// validateCategories checks that the Categories entries can be told apart
+func (c *Config) validateCategories() error {
+	ids, labels := map[string]bool{}, map[string]bool{}
+	for i, category := range c.Categories {
+		if category.ID == "" {
+			return fmt.Errorf("config: Categories[%d]: ID is required", i)
+		}
+		if len(category.Subcategories) == 0 {
+			return fmt.Errorf("config: Categories[%d]: Subcategories must not be empty", i)
+		}
+		if ids[category.ID] {
+			return fmt.Errorf("config: Categories[%d]: duplicate ID %s", i, category.ID)
+		}
+		if labels[category.FeedLabel] {
+			return fmt.Errorf("config: Categories[%d]: duplicate FeedLabel %q", i, category.FeedLabel)
+		}
+		if strings.ContainsAny(category.FeedLabel, `/\`) {
+			return fmt.Errorf("config: Categories[%d]: FeedLabel %q must not contain a path separator", i, category.FeedLabel)
+		}
+		ids[category.ID], labels[category.FeedLabel] = true, true
+	}
+	return nil
+}
+
+// CategoryRegistry returns Categories, or the single category made of
+// CategoryID and AllowedSubcategories when it is empty
+func (c *Config) CategoryRegistry() CategoryRegistry {
+	if len(c.Categories) > 0 {
+		return c.Categories
+	}
+	return CategoryRegistry{{ID: c.CategoryID, Subcategories: c.AllowedSubcategories}}
+}
+
+// SubcategorySet returns the subcategory IDs allowed in any category as a
+// lookup set
+func (c *Config) SubcategorySet() map[string]bool {
+	set := map[string]bool{}
+	for _, category := range c.CategoryRegistry() {
+		for _, id := range category.Subcategories {
+			set[id] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,263 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is the config file read when CONFIG_FILE is not set
+const DefaultPath = "config/config.json"
+
+type Config struct {
+	HasuraEndpoint       string              `json:"HasuraEndpoint"`
+	AdminSecret          string              `json:"AdminSecret"`
+	HasuraAuth           HasuraAuth          `json:"HasuraAuth"`
+	Secrets              Secrets             `json:"Secrets"`
+	CategoryID           string              `json:"CategoryID"`           // single category, when Categories is empty
+	AllowedSubcategories []string            `json:"AllowedSubcategories"` // of CategoryID
+	Categories           CategoryRegistry    `json:"Categories"`           // categories fetched in one run
+	Eligibility          Eligibility         `json:"Eligibility"`
+	Rules                []Rule              `json:"Rules"`        // every rule must hold for an item to be kept
+	CustomLabels         []CustomLabel       `json:"CustomLabels"` // campaign segments for Merchant Center
+	Brands               Brands              `json:"Brands"`
+	PriceBounds          PriceBounds         `json:"PriceBounds"`
+	PageSize             int                 `json:"PageSize"`
+	Retry                Retry               `json:"Retry"`
+	Source               Source              `json:"Source"`
+	HTTP                 HTTP                `json:"HTTP"`        // outgoing connections, for everything but the source
+	Window               Duration            `json:"Window"`      // lookback for updated ads, default 24h
+	FullRefresh          bool                `json:"FullRefresh"` // fetch every ad, ignoring Window
+	Gzip                 bool                `json:"Gzip"`        // gzip the feed files, adding .gz to their names
+	State                State               `json:"State"`
+	Delta                Delta               `json:"Delta"`
+	Server               Server              `json:"Server"`
+	Watch                Watch               `json:"Watch"`
+	Shutdown             Shutdown            `json:"Shutdown"`
+	Schedule             string              `json:"Schedule"`       // cron expression for scheduled runs
+	InvalidGTIN          string              `json:"InvalidGTIN"`    // flag (default) or reject
+	MissingGTIN          string              `json:"MissingGTIN"`    // skip (default), mpn or no_identifier
+	DuplicateGTIN        string              `json:"DuplicateGTIN"`  // keep_newest (default) or keep_all
+	Order                string              `json:"Order"`          // id (default), updated_at or source
+	Currency             string              `json:"Currency"`       // ISO 4217 code of ad prices, default AED
+	FeedCurrencies       []FeedCurrency      `json:"FeedCurrencies"` // extra currencies to write feeds in
+	TaxonomyFile         string              `json:"TaxonomyFile"`   // subcategory to Google category mapping
+	SynonymsFile         string              `json:"SynonymsFile"`   // extra color, size, material, gender synonyms
+	TitleTemplate        string              `json:"TitleTemplate"`  // e.g. "{brand} {title} - {color} {size}"; "" keeps the ad titles
+	Rates                Rates               `json:"Rates"`
+	Languages            Languages           `json:"Languages"`
+	Enrich               Enrich              `json:"Enrich"`
+	Images               Images              `json:"Images"`
+	Links                Links               `json:"Links"`
+	ImageCheck           ImageCheck          `json:"ImageCheck"`
+	Channels             map[string]Channel  `json:"Channels"`     // per output format options
+	Sanitize             map[string][]string `json:"Sanitize"`     // cleanup steps per text field, see sanitize.Parse
+	StripEmoji           bool                `json:"StripEmoji"`   // also remove emoji from titles, descriptions and brands
+	LengthLimits         map[string]int      `json:"LengthLimits"` // longest value per field before it is truncated, 0 for no limit
+	Upload               Upload              `json:"Upload"`
+	Targets              []Target            `json:"Targets"` // feeds generate writes instead of -format
+	ContentAPI           ContentAPI          `json:"ContentAPI"`
+	MetaAPI              MetaAPI             `json:"MetaAPI"`
+	Metrics              Metrics             `json:"Metrics"`
+	Summary              Summary             `json:"Summary"`
+	Exclusions           Exclusions          `json:"Exclusions"`
+	History              History             `json:"History"`
+	BigQuery             BigQuery            `json:"BigQuery"`
+	Notify               Notify              `json:"Notify"`
+	Email                Email               `json:"Email"`
+	Guard                Guard               `json:"Guard"`
+	Diagnostics          Diagnostics         `json:"Diagnostics"`
+	Quality              Quality             `json:"Quality"`
+	Promotions           Promotions          `json:"Promotions"`
+	Log                  Log                 `json:"Log"`
+}
+
+// LoadConfig reads the config file (CONFIG_FILE or config/config.json) and
+// then applies any environment variable overrides on top of it. A missing
+// file is not an error so the binary can be configured from the environment
+// alone.
+func LoadConfig() (*Config, error) {
+	config, err := Read("")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Read loads path (or CONFIG_FILE / config/config.json when path is empty),
+// applies environment overrides and defaults, but does not validate, so
+// callers can apply their own overrides first. Files ending in .yaml or
+// .yml are read as YAML, any other as JSON.
+func Read(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path = DefaultPath
+	}
+
+	var config Config
+	file, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer file.Close()
+
+		if err := decode(file, filepath.Ext(path), &config); err != nil {
+			return nil, fmt.Errorf("config: %s: %w", path, err)
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	config.applyDefaults()
+
+	return &config, nil
+}
+
+// decode reads a config file with the extension ext into config. YAML is
+// turned into JSON first, so both formats use the JSON field names and
+// the same duration and number forms.
+func decode(r io.Reader, ext string, config *Config) error {
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		var doc any
+		if err := yaml.NewDecoder(r).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		data, err := json.Marshal(stringKeys(doc))
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	return json.NewDecoder(r).Decode(config)
+}
+
+// stringKeys converts the keys of the YAML mappings in v to strings, as
+// JSON objects need, so that subcategory IDs such as 12 can be keys
+func stringKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = stringKeys(value)
+		}
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = stringKeys(value)
+		}
+		return m
+	case []any:
+		for i, value := range v {
+			v[i] = stringKeys(value)
+		}
+	}
+	return v
+}
+
+// applyEnv overrides file values with the matching environment variables
+func (c *Config) applyEnv() error {
+	for _, apply := range []func() error{
+		c.applyHTTPEnv,
+		c.applySourceEnv,
+		c.applySecretsEnv,
+		c.applyCatalogEnv,
+		c.applyStateEnv,
+		c.applyServerEnv,
+		c.applyCurrencyEnv,
+		c.applyTextEnv,
+		c.applyLanguagesEnv,
+		c.applyEnrichEnv,
+		c.applyImagesEnv,
+		c.applyLinksEnv,
+		c.applyOutputsEnv,
+		c.applyUploadEnv,
+		c.applyAPIEnv,
+		c.applyReportsEnv,
+		c.applyNotifyEnv,
+		c.applyLogEnv,
+	} {
+		if err := apply(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDefaults fills in settings that were left unset
+func (c *Config) applyDefaults() {
+	c.applyHTTPDefaults()
+	c.applySourceDefaults()
+	c.applySecretsDefaults()
+	c.applyCatalogDefaults()
+	c.applyStateDefaults()
+	c.applyServerDefaults()
+	c.applyCurrencyDefaults()
+	c.applyTextDefaults()
+	c.applyLanguagesDefaults()
+	c.applyEnrichDefaults()
+	c.applyImagesDefaults()
+	c.applyLinksDefaults()
+	c.applyUploadDefaults()
+	c.applyAPIDefaults()
+	c.applyReportsDefaults()
+	c.applyNotifyDefaults()
+	c.applyPromotionsDefaults()
+	c.applyLogDefaults()
+}
+
+// Validate checks that the settings needed to fetch ads are present
+func (c *Config) Validate() error {
+	for _, validate := range []func() error{
+		c.validateHTTP,
+		c.validateSource,
+		c.validateSecrets,
+		c.validateCurrencies,
+		c.validateCatalog,
+		c.validateServer,
+		c.validateText,
+		c.validateLanguages,
+		c.validateEnrich,
+		c.validateImages,
+		c.validateLinks,
+		c.validateOutputs,
+		c.validateUpload,
+		c.validateAPI,
+		c.validateReports,
+		c.validateNotify,
+		c.validatePromotions,
+		c.validateLog,
+	} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitList splits a comma separated value, dropping empty entries
+func splitList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go_data_fashion_accessories/money"
+)
+
+// DefaultCurrency is the ISO 4217 code used when Currency is unset
+const DefaultCurrency = "AED"
+
+// FeedCurrency is an extra currency every feed is also written in, with
+// prices converted from Currency
+type FeedCurrency struct {
+	Code     string `json:"Code"`     // ISO 4217 code
+	Rounding string `json:"Rounding"` // half_up (default), down or up
+	Step     string `json:"Step"`     // rounding increment such as "0.05" or "1"; default the minor unit
+}
+
+// Exchange rate providers accepted in Rates.Provider
+const (
+	RatesStatic = "static"
+	RatesECB    = "ecb"
+	RatesHTTP   = "http"
+)
+
+// Rates configures where exchange rates for FeedCurrencies come from
+type Rates struct {
+	Provider string                 `json:"Provider"` // static (default), ecb or http
+	Static   map[string]json.Number `json:"Static"`   // units of each currency per unit of Currency
+	URL      string                 `json:"URL"`      // endpoint of the http provider
+	Headers  map[string]string      `json:"Headers"`  // extra headers for the http provider
+	TTL      Duration               `json:"TTL"`      // how long fetched rates are reused
+}
+
+// DefaultRates is used for any rates setting left unset
+var DefaultRates = Rates{
+	Provider: RatesStatic,
+	TTL:      Duration{12 * time.Hour},
+}
+
+// applyCurrencyEnv overrides the currency settings with their environment
+// variables
+func (c *Config) applyCurrencyEnv() error {
+	if v := os.Getenv("CURRENCY"); v != "" {
+		c.Currency = v
+	}
+	if v := os.Getenv("FEED_CURRENCIES"); v != "" {
+		c.FeedCurrencies = nil
+		for _, code := range splitList(v) {
+			c.FeedCurrencies = append(c.FeedCurrencies, FeedCurrency{Code: code})
+		}
+	}
+	if v := os.Getenv("RATES_PROVIDER"); v != "" {
+		c.Rates.Provider = v
+	}
+	return nil
+}
+
+// applyCurrencyDefaults fills in the currency settings that were left unset
+func (c *Config) applyCurrencyDefaults() {
+	if c.Currency == "" {
+		c.Currency = DefaultCurrency
+	}
+	for i := range c.FeedCurrencies {
+		if c.FeedCurrencies[i].Rounding == "" {
+			c.FeedCurrencies[i].Rounding = string(money.RoundHalfUp)
+		}
+	}
+	if c.Rates.Provider == "" {
+		c.Rates.Provider = DefaultRates.Provider
+	}
+	if c.Rates.TTL.Duration == 0 {
+		c.Rates.TTL = DefaultRates.TTL
+	}
+}
+
+// validateCurrencies checks Currency, FeedCurrencies and that their rates
+// can be found
+func (c *Config) validateCurrencies() error {
+	if !money.ValidCurrency(c.Currency) {
+		return fmt.Errorf("config: Currency %q is not an ISO 4217 code", c.Currency)
+	}
+	switch c.Rates.Provider {
+	case RatesStatic, RatesECB:
+	case RatesHTTP:
+		if c.Rates.URL == "" {
+			return errors.New("config: Rates.URL is required for the http rates provider")
+		}
+	default:
+		return fmt.Errorf("config: unknown Rates.Provider %q", c.Rates.Provider)
+	}
+
+	seen := map[string]bool{c.Currency: true}
+	for _, fc := range c.FeedCurrencies {
+		if !money.ValidCurrency(fc.Code) {
+			return fmt.Errorf("config: FeedCurrencies: %q is not an ISO 4217 code", fc.Code)
+		}
+		if seen[fc.Code] {
+			return fmt.Errorf("config: FeedCurrencies: %s listed twice or same as Currency", fc.Code)
+		}
+		seen[fc.Code] = true
+		if !money.ValidRoundingMode(money.RoundingMode(fc.Rounding)) {
+			return fmt.Errorf("config: FeedCurrencies: %s: unknown Rounding %q", fc.Code, fc.Rounding)
+		}
+		if fc.Step != "" {
+			if _, err := money.Parse(fc.Step, fc.Code); err != nil {
+				return fmt.Errorf("config: FeedCurrencies: %s: invalid Step %q: %w", fc.Code, fc.Step, err)
+			}
+		}
+		if c.Rates.Provider == RatesStatic {
+			rate, ok := c.Rates.Static[fc.Code]
+			if !ok {
+				return fmt.Errorf("config: Rates.Static has no rate for %s", fc.Code)
+			}
+			if _, err := money.ParseRate(rate.String()); err != nil {
+				return fmt.Errorf("config: Rates.Static: %s: %w", fc.Code, err)
+			}
+		}
+	}
+	return nil
+}
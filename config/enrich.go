@@ -0,0 +1,85 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Enrich configures writing descriptions for items whose description is
+// empty or shorter than MinDescriptionLength, through a description
+// generation or translation service at URL. The service is POSTed batches
+// of items as {"items": [{"id", "title", "brand", "description",
+// "product_type", "language"}]} and answers {"descriptions": [...]} in the
+// same order, "" for the items it has nothing for. Descriptions are kept in
+// the state and asked again only once the ad changes.
+type Enrich struct {
+	Enabled              bool              `json:"Enabled"`
+	URL                  string            `json:"URL"`
+	Headers              map[string]string `json:"Headers"`
+	MinDescriptionLength int               `json:"MinDescriptionLength"` // in characters
+	BatchSize            int               `json:"BatchSize"`            // items per request
+	MaxWait              Duration          `json:"MaxWait"`              // how long a partial batch waits for more items
+	RequestsPerSecond    float64           `json:"RequestsPerSecond"`
+	CacheTTL             Duration          `json:"CacheTTL"` // descriptions of ads not seen for this long are forgotten
+}
+
+// DefaultEnrich is used for any enrichment setting left unset
+var DefaultEnrich = Enrich{
+	MinDescriptionLength: 50,
+	BatchSize:            20,
+	MaxWait:              Duration{500 * time.Millisecond},
+	RequestsPerSecond:    1,
+	CacheTTL:             Duration{30 * 24 * time.Hour},
+}
+
+// applyEnrichEnv overrides the enrichment settings with their environment
+// variables
+func (c *Config) applyEnrichEnv() error {
+	if v := os.Getenv("ENRICH_ENABLED"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid ENRICH_ENABLED %q: %w", v, err)
+		}
+		c.Enrich.Enabled = b
+	}
+	if v := os.Getenv("ENRICH_URL"); v != "" {
+		c.Enrich.URL = v
+	}
+	return nil
+}
+
+// applyEnrichDefaults fills in the enrichment settings that were left unset
+func (c *Config) applyEnrichDefaults() {
+	if c.Enrich.MinDescriptionLength == 0 {
+		c.Enrich.MinDescriptionLength = DefaultEnrich.MinDescriptionLength
+	}
+	if c.Enrich.BatchSize == 0 {
+		c.Enrich.BatchSize = DefaultEnrich.BatchSize
+	}
+	if c.Enrich.MaxWait.Duration == 0 {
+		c.Enrich.MaxWait = DefaultEnrich.MaxWait
+	}
+	if c.Enrich.RequestsPerSecond == 0 {
+		c.Enrich.RequestsPerSecond = DefaultEnrich.RequestsPerSecond
+	}
+	if c.Enrich.CacheTTL.Duration == 0 {
+		c.Enrich.CacheTTL = DefaultEnrich.CacheTTL
+	}
+}
+
+// validateEnrich checks the description service and its limits
+func (c *Config) validateEnrich() error {
+	if c.Enrich.Enabled {
+		if u, err := url.Parse(c.Enrich.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("config: Enrich.URL is not an absolute http(s) URL")
+		}
+	}
+	if c.Enrich.MinDescriptionLength < 0 || c.Enrich.BatchSize < 1 || c.Enrich.RequestsPerSecond < 0 {
+		return errors.New("config: Enrich.MinDescriptionLength must not be negative, BatchSize must be at least 1 and RequestsPerSecond positive")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+// HTTP configures outgoing connections, for networks that only reach the
+// internet through a proxy or intercept TLS with their own CA
+type HTTP struct {
+	// Proxy is the http, https or socks5 proxy URL. When unset, HTTPS_PROXY,
+	// HTTP_PROXY and NO_PROXY are honoured.
+	Proxy  string `json:"Proxy"`
+	CAFile string `json:"CAFile"` // PEM bundle trusted on top of the system roots
+
+	Timeout               Duration `json:"Timeout"`     // whole request, 0 for none
+	DialTimeout           Duration `json:"DialTimeout"` // TCP connect
+	TLSHandshakeTimeout   Duration `json:"TLSHandshakeTimeout"`
+	ResponseHeaderTimeout Duration `json:"ResponseHeaderTimeout"` // after the request is sent, 0 for none
+	KeepAlive             Duration `json:"KeepAlive"`             // TCP keep-alive probe interval
+	IdleConnTimeout       Duration `json:"IdleConnTimeout"`       // idle connections are closed after this long
+	MaxIdleConnsPerHost   int      `json:"MaxIdleConnsPerHost"`
+	DisableKeepAlives     bool     `json:"DisableKeepAlives"` // one connection per request
+}
+
+// DefaultHTTP is used for any HTTP setting left unset. It matches Go's
+// default transport, with more idle connections kept per host.
+var DefaultHTTP = HTTP{
+	DialTimeout:         Duration{30 * time.Second},
+	TLSHandshakeTimeout: Duration{10 * time.Second},
+	KeepAlive:           Duration{30 * time.Second},
+	IdleConnTimeout:     Duration{90 * time.Second},
+	MaxIdleConnsPerHost: 10,
+}
+
+// applyHTTPEnv overrides the HTTP settings with their environment variables
+func (c *Config) applyHTTPEnv() error {
+	if v := os.Getenv("HTTP_CA_FILE"); v != "" {
+		c.HTTP.CAFile = v
+	}
+	if v := os.Getenv("HTTP_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid HTTP_TIMEOUT %q: %w", v, err)
+		}
+		c.HTTP.Timeout.Duration = d
+	}
+	return nil
+}
+
+// applyHTTPDefaults fills in the HTTP settings that were left unset
+func (c *Config) applyHTTPDefaults() {
+	c.HTTP.applyDefaults()
+}
+
+// validateHTTP checks the HTTP settings
+func (c *Config) validateHTTP() error {
+	return c.HTTP.validate("HTTP")
+}
+
+// inherit fills in the settings of h that were left unset from parent
+func (h *HTTP) inherit(parent HTTP) {
+	if h.Proxy == "" {
+		h.Proxy = parent.Proxy
+	}
+	if h.CAFile == "" {
+		h.CAFile = parent.CAFile
+	}
+	for _, d := range []struct{ field, parent *Duration }{
+		{&h.Timeout, &parent.Timeout},
+		{&h.DialTimeout, &parent.DialTimeout},
+		{&h.TLSHandshakeTimeout, &parent.TLSHandshakeTimeout},
+		{&h.ResponseHeaderTimeout, &parent.ResponseHeaderTimeout},
+		{&h.KeepAlive, &parent.KeepAlive},
+		{&h.IdleConnTimeout, &parent.IdleConnTimeout},
+	} {
+		if d.field.Duration == 0 {
+			*d.field = *d.parent
+		}
+	}
+	if h.MaxIdleConnsPerHost == 0 {
+		h.MaxIdleConnsPerHost = parent.MaxIdleConnsPerHost
+	}
+	h.DisableKeepAlives = h.DisableKeepAlives || parent.DisableKeepAlives
+}
+
+// applyDefaults fills in the HTTP settings that were left unset
+func (h *HTTP) applyDefaults() {
+	if h.DialTimeout.Duration == 0 {
+		h.DialTimeout = DefaultHTTP.DialTimeout
+	}
+	if h.TLSHandshakeTimeout.Duration == 0 {
+		h.TLSHandshakeTimeout = DefaultHTTP.TLSHandshakeTimeout
+	}
+	if h.KeepAlive.Duration == 0 {
+		h.KeepAlive = DefaultHTTP.KeepAlive
+	}
+	if h.IdleConnTimeout.Duration == 0 {
+		h.IdleConnTimeout = DefaultHTTP.IdleConnTimeout
+	}
+	if h.MaxIdleConnsPerHost == 0 {
+		h.MaxIdleConnsPerHost = DefaultHTTP.MaxIdleConnsPerHost
+	}
+}
+
+// validate checks the proxy URL and that no timeout is negative. name is
+// the setting reported in errors, such as Source.HTTP.
+func (h HTTP) validate(name string) error {
+	if h.Proxy != "" {
+		u, err := url.Parse(h.Proxy)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" || u.Host == "" {
+			return fmt.Errorf("config: %s.Proxy %q must be an http, https or socks5 URL", name, h.Proxy)
+		}
+	}
+	for field, d := range map[string]Duration{
+		"Timeout":               h.Timeout,
+		"DialTimeout":           h.DialTimeout,
+		"TLSHandshakeTimeout":   h.TLSHandshakeTimeout,
+		"ResponseHeaderTimeout": h.ResponseHeaderTimeout,
+		"KeepAlive":             h.KeepAlive,
+		"IdleConnTimeout":       h.IdleConnTimeout,
+	} {
+		if d.Duration < 0 {
+			return fmt.Errorf("config: %s.%s must not be negative", name, field)
+		}
+	}
+	if h.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("config: %s.MaxIdleConnsPerHost must not be negative", name)
+	}
+	return nil
+}
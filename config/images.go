@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Images configures the links built to ad images
+type Images struct {
+	StorageURL  string `json:"StorageURL"`  // folder holding each draft's images
+	ProxyURL    string `json:"ProxyURL"`    // image resizing proxy
+	DirectLinks bool   `json:"DirectLinks"` // link to storage, bypassing the proxy
+	Width       int    `json:"Width"`       // w parameter sent to the proxy
+	Quality     int    `json:"Quality"`     // q parameter sent to the proxy, 1-100
+}
+
+// DefaultImages is used for any image setting left unset
+var DefaultImages = Images{
+	StorageURL: "https://storage.ayshei.com/prod/public/drafts",
+	ProxyURL:   "https://ayshei.com/_next/image",
+	Width:      3840,
+	Quality:    75,
+}
+
+// Actions for items whose main image is broken, in ImageCheck.Action
+const (
+	ImageCheckDrop = "drop" // leave the item out of the feed
+	ImageCheckFlag = "flag" // keep the item and log a warning
+)
+
+// Image check modes, in ImageCheck.Mode
+const (
+	ImageCheckHead   = "head"   // HEAD request: status and size
+	ImageCheckDecode = "decode" // also download the image header for its dimensions
+)
+
+// ImageCheck configures the requests that verify image links
+type ImageCheck struct {
+	Enabled       bool     `json:"Enabled"`
+	Mode          string   `json:"Mode"`          // head (default) or decode
+	Action        string   `json:"Action"`        // drop (default) or flag
+	Workers       int      `json:"Workers"`       // items checked at once
+	RatePerSecond float64  `json:"RatePerSecond"` // request limit across workers
+	MinBytes      int64    `json:"MinBytes"`      // smaller images count as broken
+	Timeout       Duration `json:"Timeout"`       // per request
+
+	// Smallest width and height accepted in decode mode. Items whose
+	// google_product_category is one of ApparelCategories, or below one in
+	// the taxonomy path, need ApparelMinSize.
+	MinSize           int      `json:"MinSize"`
+	ApparelMinSize    int      `json:"ApparelMinSize"`
+	ApparelCategories []string `json:"ApparelCategories"`
+}
+
+// DefaultImageCheck is used for any image check setting left unset
+var DefaultImageCheck = ImageCheck{
+	Mode:          ImageCheckHead,
+	Action:        ImageCheckDrop,
+	Workers:       8,
+	RatePerSecond: 20,
+	MinBytes:      1024,
+	Timeout:       Duration{10 * time.Second},
+
+	MinSize:           100,
+	ApparelMinSize:    250,
+	ApparelCategories: []string{"166", "Apparel & Accessories"},
+}
+
+// applyImagesEnv overrides the image settings with their environment
+// variables
+func (c *Config) applyImagesEnv() error {
+	if v := os.Getenv("IMAGE_PROXY_URL"); v != "" {
+		c.Images.ProxyURL = v
+	}
+	if v := os.Getenv("IMAGE_CHECK"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid IMAGE_CHECK %q: %w", v, err)
+		}
+		c.ImageCheck.Enabled = b
+	}
+	return nil
+}
+
+// applyImagesDefaults fills in the image settings that were left unset
+func (c *Config) applyImagesDefaults() {
+	if c.Images.StorageURL == "" {
+		c.Images.StorageURL = DefaultImages.StorageURL
+	}
+	if c.Images.ProxyURL == "" {
+		c.Images.ProxyURL = DefaultImages.ProxyURL
+	}
+	if c.Images.Width == 0 {
+		c.Images.Width = DefaultImages.Width
+	}
+	if c.Images.Quality == 0 {
+		c.Images.Quality = DefaultImages.Quality
+	}
+	if c.ImageCheck.Mode == "" {
+		c.ImageCheck.Mode = DefaultImageCheck.Mode
+	}
+	if c.ImageCheck.MinSize == 0 {
+		c.ImageCheck.MinSize = DefaultImageCheck.MinSize
+	}
+	if c.ImageCheck.ApparelMinSize == 0 {
+		c.ImageCheck.ApparelMinSize = DefaultImageCheck.ApparelMinSize
+	}
+	if c.ImageCheck.ApparelCategories == nil {
+		c.ImageCheck.ApparelCategories = DefaultImageCheck.ApparelCategories
+	}
+	if c.ImageCheck.Action == "" {
+		c.ImageCheck.Action = DefaultImageCheck.Action
+	}
+	if c.ImageCheck.Workers == 0 {
+		c.ImageCheck.Workers = DefaultImageCheck.Workers
+	}
+	if c.ImageCheck.RatePerSecond == 0 {
+		c.ImageCheck.RatePerSecond = DefaultImageCheck.RatePerSecond
+	}
+	if c.ImageCheck.MinBytes == 0 {
+		c.ImageCheck.MinBytes = DefaultImageCheck.MinBytes
+	}
+	if c.ImageCheck.Timeout.Duration == 0 {
+		c.ImageCheck.Timeout = DefaultImageCheck.Timeout
+	}
+}
+
+// validateImages checks the image links and the image check settings
+func (c *Config) validateImages() error {
+	for name, raw := range map[string]string{"StorageURL": c.Images.StorageURL, "ProxyURL": c.Images.ProxyURL} {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config: Images.%s %q is not an absolute http(s) URL", name, raw)
+		}
+	}
+	if c.Images.Width < 0 {
+		return errors.New("config: Images.Width must not be negative")
+	}
+	if c.Images.Quality < 0 || c.Images.Quality > 100 {
+		return errors.New("config: Images.Quality must be between 1 and 100")
+	}
+	if c.ImageCheck.Mode != ImageCheckHead && c.ImageCheck.Mode != ImageCheckDecode {
+		return fmt.Errorf("config: ImageCheck.Mode must be %q or %q", ImageCheckHead, ImageCheckDecode)
+	}
+	if c.ImageCheck.MinSize < 0 || c.ImageCheck.ApparelMinSize < 0 {
+		return errors.New("config: ImageCheck.MinSize and ApparelMinSize must not be negative")
+	}
+	if c.ImageCheck.Action != ImageCheckDrop && c.ImageCheck.Action != ImageCheckFlag {
+		return fmt.Errorf("config: ImageCheck.Action must be %q or %q", ImageCheckDrop, ImageCheckFlag)
+	}
+	if c.ImageCheck.Workers < 1 {
+		return errors.New("config: ImageCheck.Workers must be at least 1")
+	}
+	if c.ImageCheck.RatePerSecond < 0 {
+		return errors.New("config: ImageCheck.RatePerSecond must be positive")
+	}
+	if c.ImageCheck.MinBytes < 0 {
+		return errors.New("config: ImageCheck.MinBytes must not be negative")
+	}
+	return nil
+}
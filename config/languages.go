@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+)
+
+// Languages configures feeds in more than one content language. The ad
+// form holds the title and description in Default; an ad may also give them
+// in other languages as title_<language> and description_<language>
+// product details. Languages an ad has no text in are asked of TranslateURL
+// when it is set, or else keep the text in Default.
+type Languages struct {
+	Default string   `json:"Default"` // ISO 639-1 code, default en
+	Feeds   []string `json:"Feeds"`   // more languages written to their own files, e.g. ar
+	// TranslateURL is POSTed {"text", "source", "target"} and answers
+	// {"text"}
+	TranslateURL     string            `json:"TranslateURL"`
+	TranslateHeaders map[string]string `json:"TranslateHeaders"`
+}
+
+// DefaultLanguages is used for any language setting left unset
+var DefaultLanguages = Languages{
+	Default: "en",
+}
+
+// languageCode matches ISO 639-1 language codes
+var languageCode = regexp.MustCompile(`^[a-z]{2}$`)
+
+// applyLanguagesEnv overrides the language settings with their environment
+// variables
+func (c *Config) applyLanguagesEnv() error {
+	if v := os.Getenv("FEED_LANGUAGES"); v != "" {
+		c.Languages.Feeds = splitList(v)
+	}
+	if v := os.Getenv("TRANSLATE_URL"); v != "" {
+		c.Languages.TranslateURL = v
+	}
+	return nil
+}
+
+// applyLanguagesDefaults fills in the language settings that were left unset
+func (c *Config) applyLanguagesDefaults() {
+	if c.Languages.Default == "" {
+		c.Languages.Default = DefaultLanguages.Default
+	}
+}
+
+// validateLanguages checks the language codes of Languages and the targets
+func (c *Config) validateLanguages() error {
+	if !languageCode.MatchString(c.Languages.Default) {
+		return fmt.Errorf("config: Languages.Default %q is not an ISO 639-1 code", c.Languages.Default)
+	}
+	for _, lang := range c.Languages.Feeds {
+		if !languageCode.MatchString(lang) || lang == c.Languages.Default {
+			return fmt.Errorf("config: Languages.Feeds: %q is not an ISO 639-1 code other than Languages.Default", lang)
+		}
+	}
+	for _, t := range c.Targets {
+		if t.Language != "" && !languageCode.MatchString(t.Language) {
+			return fmt.Errorf("config: Targets.%s: Language %q is not an ISO 639-1 code", t.Name, t.Language)
+		}
+	}
+	if c.Languages.TranslateURL != "" {
+		if u, err := url.Parse(c.Languages.TranslateURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("config: Languages.TranslateURL is not an absolute http(s) URL")
+		}
+	}
+	return nil
+}
+
+// ContentLanguages returns the languages other than Languages.Default that
+// items are written in: the language feeds, those of the targets and that
+// of the Content API
+func (c *Config) ContentLanguages() []string {
+	var langs []string
+	add := func(lang string) {
+		if lang != "" && lang != c.Languages.Default && !slices.Contains(langs, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	for _, lang := range c.Languages.Feeds {
+		add(lang)
+	}
+	for _, t := range c.Targets {
+		add(t.Language)
+	}
+	add(c.ContentAPI.ContentLanguage)
+	return langs
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Links configures the product links of the items. Template is the link
+// with placeholders: {id} the ad ID, {draft_id}, {slug} the title written
+// for a URL, and {locale} the Locale path prefix.
+type Links struct {
+	Template string `json:"Template"`
+	Locale   string `json:"Locale"` // e.g. en or ar; "" drops {locale} and its slash
+	// Slug says how {slug} is written: transliterate (the default) spells
+	// Arabic and accented titles in plain ASCII, unicode keeps every
+	// script. Titles that leave no slug drop {slug} and the dash, slash or
+	// underscore next to it, so the link falls back to the ID.
+	Slug          string `json:"Slug"`
+	MaxSlugLength int    `json:"MaxSlugLength"` // longest slug, cut at a word
+	// NoAutoUTM leaves out the utm_source and utm_medium every channel adds
+	// to its links by default; the UTM of a channel are added regardless
+	NoAutoUTM bool `json:"NoAutoUTM"`
+}
+
+// Slug styles, in Links.Slug
+const (
+	SlugTransliterate = "transliterate"
+	SlugUnicode       = "unicode"
+)
+
+// DefaultLinks is used for any link setting left unset
+var DefaultLinks = Links{
+	Template:      "https://ayshei.com/product/{id}",
+	Slug:          SlugTransliterate,
+	MaxSlugLength: 60,
+}
+
+// DefaultUTM are the UTM parameters added to the links of each channel
+// unless Links.NoAutoUTM is set. Data exports such as ndjson get none.
+var DefaultUTM = map[string]map[string]string{
+	"xml":           {"utm_source": "google_shopping", "utm_medium": "product_feed"},
+	"contentapi":    {"utm_source": "google_shopping", "utm_medium": "product_feed"},
+	"csv":           {"utm_source": "facebook", "utm_medium": "product_feed"},
+	"metaapi":       {"utm_source": "facebook", "utm_medium": "product_feed"},
+	"tiktok":        {"utm_source": "tiktok", "utm_medium": "product_feed"},
+	"tiktok-xml":    {"utm_source": "tiktok", "utm_medium": "product_feed"},
+	"snapchat":      {"utm_source": "snapchat", "utm_medium": "product_feed"},
+	"pinterest":     {"utm_source": "pinterest", "utm_medium": "product_feed"},
+	"pinterest-tsv": {"utm_source": "pinterest", "utm_medium": "product_feed"},
+}
+
+// applyLinksEnv overrides the link settings with their environment
+// variables
+func (c *Config) applyLinksEnv() error {
+	if v := os.Getenv("LINK_TEMPLATE"); v != "" {
+		c.Links.Template = v
+	}
+	if v := os.Getenv("LINK_LOCALE"); v != "" {
+		c.Links.Locale = v
+	}
+	if v := os.Getenv("LINK_SLUG"); v != "" {
+		c.Links.Slug = v
+	}
+	return nil
+}
+
+// applyLinksDefaults fills in the link settings that were left unset
+func (c *Config) applyLinksDefaults() {
+	if c.Links.Template == "" {
+		c.Links.Template = DefaultLinks.Template
+	}
+	if c.Links.Slug == "" {
+		c.Links.Slug = DefaultLinks.Slug
+	}
+	if c.Links.MaxSlugLength == 0 {
+		c.Links.MaxSlugLength = DefaultLinks.MaxSlugLength
+	}
+}
+
+// validateLinks checks the link settings shared by every target
+func (c *Config) validateLinks() error {
+	if err := c.Links.validate("Links"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validate checks the link settings, which are named field in errors
+func (l Links) validate(field string) error {
+	if !strings.Contains(l.Template, "{id}") {
+		return fmt.Errorf("config: %s.Template %q must contain {id}", field, l.Template)
+	}
+	sample := strings.NewReplacer("{id}", "id", "{draft_id}", "draft", "{slug}", "slug", "{locale}", "en").Replace(l.Template)
+	if u, err := url.Parse(sample); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("config: %s.Template %q is not an absolute http(s) URL", field, l.Template)
+	}
+	if l.Slug != SlugTransliterate && l.Slug != SlugUnicode {
+		return fmt.Errorf("config: %s.Slug must be %q or %q", field, SlugTransliterate, SlugUnicode)
+	}
+	if l.MaxSlugLength < 0 {
+		return fmt.Errorf("config: %s.MaxSlugLength must not be negative", field)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Metrics configures where one-shot runs push their Prometheus metrics
+type Metrics struct {
+	PushgatewayURL string `json:"PushgatewayURL"` // empty disables pushing
+	Job            string `json:"Job"`            // Pushgateway job label
+}
+
+// DefaultMetricsJob is the Pushgateway job used when Metrics.Job is unset
+const DefaultMetricsJob = "feedgen"
+
+// Log formats accepted in Log.Format
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Log configures the structured logger
+type Log struct {
+	Level  string `json:"Level"`  // debug, info (default), warn or error
+	Format string `json:"Format"` // text (default) or json
+}
+
+// DefaultLog is used for any log setting left unset
+var DefaultLog = Log{
+	Level:  "info",
+	Format: LogFormatText,
+}
+
+// applyLogEnv overrides the log and metrics settings with their environment
+// variables
+func (c *Config) applyLogEnv() error {
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		c.Log.Level = v
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		c.Log.Format = v
+	}
+	if v := os.Getenv("PUSHGATEWAY_URL"); v != "" {
+		c.Metrics.PushgatewayURL = v
+	}
+	return nil
+}
+
+// applyLogDefaults fills in the log and metrics settings that were left
+// unset
+func (c *Config) applyLogDefaults() {
+	if c.Log.Level == "" {
+		c.Log.Level = DefaultLog.Level
+	}
+	if c.Log.Format == "" {
+		c.Log.Format = DefaultLog.Format
+	}
+	if c.Metrics.Job == "" {
+		c.Metrics.Job = DefaultMetricsJob
+	}
+}
+
+// validateLog checks the log level and format
+func (c *Config) validateLog() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		return fmt.Errorf("config: invalid Log.Level %q", c.Log.Level)
+	}
+	if c.Log.Format != LogFormatText && c.Log.Format != LogFormatJSON {
+		return fmt.Errorf("config: Log.Format must be %q or %q", LogFormatText, LogFormatJSON)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// Notify configures the chat messages sent about runs. Failed runs are
+// always reported; successful ones when their item count dropped by more
+// than DropPercent since the last run, or with Always.
+type Notify struct {
+	WebhookURL  string  `json:"WebhookURL"`  // Slack or Teams incoming webhook; empty sends nothing
+	Kind        string  `json:"Kind"`        // slack (default) or teams
+	Environment string  `json:"Environment"` // named in the messages, e.g. production
+	Always      bool    `json:"Always"`      // report every run
+	DropPercent float64 `json:"DropPercent"` // 100 turns drop reports off
+}
+
+// Webhook kinds accepted in Notify.Kind
+const (
+	NotifySlack = "slack"
+	NotifyTeams = "teams"
+)
+
+// DefaultNotify is used for any notification setting left unset
+var DefaultNotify = Notify{
+	Kind:        NotifySlack,
+	DropPercent: 20,
+}
+
+// Email configures emailing a CSV of the ads each run left out to the
+// catalog team, so they can fix them at the source
+type Email struct {
+	To        []string `json:"To"` // recipients; none sends no email
+	From      string   `json:"From"`
+	Subject   string   `json:"Subject"`
+	Transport string   `json:"Transport"` // smtp (default) or ses
+	// Reasons are the skip reasons reported, such as invalid_gtin; empty
+	// reports every reason
+	Reasons []string `json:"Reasons"`
+	SMTP    SMTP     `json:"SMTP"`
+	// SESRegion is the region of the SES API, which is called with the
+	// usual AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	SESRegion   string `json:"SESRegion"`
+	SESEndpoint string `json:"SESEndpoint"` // empty for AWS
+}
+
+// SMTP is the mail server the smtp email transport sends through. STARTTLS
+// is used when the server offers it.
+type SMTP struct {
+	Host     string `json:"Host"`
+	Port     int    `json:"Port"`
+	Username string `json:"Username"` // empty sends without logging in
+	Password string `json:"Password"`
+}
+
+// Email transports accepted in Email.Transport
+const (
+	EmailSMTP = "smtp"
+	EmailSES  = "ses"
+)
+
+// DefaultEmail is used for any email setting left unset
+var DefaultEmail = Email{
+	Subject:   "Ads left out of the feed",
+	Transport: EmailSMTP,
+	SMTP:      SMTP{Port: 587},
+	SESRegion: "us-east-1",
+}
+
+// applyNotifyEnv overrides the notification settings with their
+// environment variables
+func (c *Config) applyNotifyEnv() error {
+	if v := os.Getenv("NOTIFY_WEBHOOK_URL"); v != "" {
+		c.Notify.WebhookURL = v
+	}
+	if v := os.Getenv("NOTIFY_KIND"); v != "" {
+		c.Notify.Kind = v
+	}
+	if v := os.Getenv("NOTIFY_ENVIRONMENT"); v != "" {
+		c.Notify.Environment = v
+	}
+	if v := os.Getenv("NOTIFY_ALWAYS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid NOTIFY_ALWAYS %q: %w", v, err)
+		}
+		c.Notify.Always = b
+	}
+	if v := os.Getenv("EMAIL_TO"); v != "" {
+		c.Email.To = splitList(v)
+	}
+	if v := os.Getenv("EMAIL_FROM"); v != "" {
+		c.Email.From = v
+	}
+	if v := os.Getenv("EMAIL_TRANSPORT"); v != "" {
+		c.Email.Transport = v
+	}
+	if v := os.Getenv("SMTP_HOST"); v != "" {
+		c.Email.SMTP.Host = v
+	}
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid SMTP_PORT %q: %w", v, err)
+		}
+		c.Email.SMTP.Port = n
+	}
+	if v := os.Getenv("SMTP_USERNAME"); v != "" {
+		c.Email.SMTP.Username = v
+	}
+	if v := os.Getenv("SMTP_PASSWORD"); v != "" {
+		c.Email.SMTP.Password = v
+	}
+	if v := os.Getenv("NOTIFY_DROP_PERCENT"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("config: invalid NOTIFY_DROP_PERCENT %q: %w", v, err)
+		}
+		c.Notify.DropPercent = f
+	}
+	return nil
+}
+
+// applyNotifyDefaults fills in the notification settings that were left
+// unset
+func (c *Config) applyNotifyDefaults() {
+	if c.Notify.Kind == "" {
+		c.Notify.Kind = DefaultNotify.Kind
+	}
+	if c.Email.Subject == "" {
+		c.Email.Subject = DefaultEmail.Subject
+	}
+	if c.Email.Transport == "" {
+		c.Email.Transport = DefaultEmail.Transport
+	}
+	if c.Email.SMTP.Port == 0 {
+		c.Email.SMTP.Port = DefaultEmail.SMTP.Port
+	}
+	if c.Email.SESRegion == "" {
+		c.Email.SESRegion = DefaultEmail.SESRegion
+	}
+	if c.Notify.DropPercent == 0 {
+		c.Notify.DropPercent = DefaultNotify.DropPercent
+	}
+}
+
+// validateNotify checks the webhook and the email settings
+func (c *Config) validateNotify() error {
+	if c.Notify.WebhookURL != "" {
+		if u, err := url.Parse(c.Notify.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("config: Notify.WebhookURL is not an absolute http(s) URL")
+		}
+	}
+	if c.Notify.Kind != NotifySlack && c.Notify.Kind != NotifyTeams {
+		return fmt.Errorf("config: Notify.Kind must be %q or %q", NotifySlack, NotifyTeams)
+	}
+	if c.Notify.DropPercent < 0 || c.Notify.DropPercent > 100 {
+		return errors.New("config: Notify.DropPercent must be between 0 and 100")
+	}
+	if err := c.Email.validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validate checks that the email settings can send, when there are
+// recipients
+func (e Email) validate() error {
+	if len(e.To) == 0 {
+		return nil
+	}
+	for _, addr := range append([]string{e.From}, e.To...) {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("config: Email: invalid address %q: %w", addr, err)
+		}
+	}
+	switch e.Transport {
+	case EmailSMTP:
+		if e.SMTP.Host == "" {
+			return errors.New("config: Email.SMTP.Host is required for the smtp transport")
+		}
+		if e.SMTP.Port < 1 || e.SMTP.Port > 65535 {
+			return errors.New("config: Email.SMTP.Port must be between 1 and 65535")
+		}
+	case EmailSES:
+	default:
+		return fmt.Errorf("config: Email.Transport must be %q or %q", EmailSMTP, EmailSES)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Channel holds the options of one output channel, keyed by format name
+// (xml for Google Merchant Center, csv for Meta, tiktok, snapchat and so on)
+type Channel struct {
+	// ExcludeConditions leaves items in these conditions (new, used or
+	// refurbished) out of the channel's feed
+	ExcludeConditions []string `json:"ExcludeConditions"`
+	// MaxItems and MaxBytes split the channel's feed into numbered files
+	// listed in a manifest, for channels that cap file sizes. MaxBytes
+	// counts bytes before compression. 0 means no limit.
+	MaxItems int   `json:"MaxItems"`
+	MaxBytes int64 `json:"MaxBytes"`
+	// UTM are query parameters added to the channel's product links, such
+	// as utm_campaign, on top of its DefaultUTM. An empty value removes a
+	// default one.
+	UTM map[string]string `json:"UTM"`
+}
+
+// Split reports whether the channel's feed is written in parts
+func (c Channel) Split() bool {
+	return c.MaxItems > 0 || c.MaxBytes > 0
+}
+
+// Target is a feed that a generate run writes and delivers on its own: one
+// format in one file, uploaded to its own destinations, with the channel's
+// settings overridden where the target sets them. A target that fails is
+// reported and left out without stopping the others.
+type Target struct {
+	Name   string `json:"Name"`   // names the target in logs and its default file
+	Format string `json:"Format"` // output format, as for generate -format
+	// Path is the local file, by default the format's file with "_" and
+	// Name added before its extension
+	Path string `json:"Path"`
+	// Destinations are directory URLs as in Upload; none keeps the file
+	// on local disk
+	Destinations []string `json:"Destinations"`
+	// Currency writes prices in one of FeedCurrencies instead of Currency
+	Currency string `json:"Currency"`
+	// Language writes the titles and descriptions in another language than
+	// Languages.Default, which is also the link's locale unless Links sets one
+	Language string `json:"Language"`
+	// Links overrides the link settings that it sets, such as Template
+	Links Links             `json:"Links"`
+	UTM   map[string]string `json:"UTM"` // on top of the channel's UTM
+}
+
+// LinkSettings returns links with the settings the target overrides
+func (t Target) LinkSettings(links Links) Links {
+	if t.Links.Template != "" {
+		links.Template = t.Links.Template
+	}
+	if t.Links.Locale != "" {
+		links.Locale = t.Links.Locale
+	}
+	if t.Links.Slug != "" {
+		links.Slug = t.Links.Slug
+	}
+	if t.Links.MaxSlugLength != 0 {
+		links.MaxSlugLength = t.Links.MaxSlugLength
+	}
+	links.NoAutoUTM = links.NoAutoUTM || t.Links.NoAutoUTM
+	return links
+}
+
+// applyOutputsEnv overrides the output settings with their environment
+// variables
+func (c *Config) applyOutputsEnv() error {
+	if v := os.Getenv("GZIP_FEEDS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid GZIP_FEEDS %q: %w", v, err)
+		}
+		c.Gzip = b
+	}
+	return nil
+}
+
+// validateOutputs checks the channel options and the targets
+func (c *Config) validateOutputs() error {
+	for name, channel := range c.Channels {
+		for _, condition := range channel.ExcludeConditions {
+			switch condition {
+			case "new", "used", "refurbished":
+			default:
+				return fmt.Errorf("config: Channels.%s: unknown condition %q", name, condition)
+			}
+		}
+		if channel.MaxItems < 0 || channel.MaxBytes < 0 {
+			return fmt.Errorf("config: Channels.%s: MaxItems and MaxBytes must not be negative", name)
+		}
+		for param := range channel.UTM {
+			if !strings.HasPrefix(param, "utm_") {
+				return fmt.Errorf("config: Channels.%s.UTM: %q is not a utm_ parameter", name, param)
+			}
+		}
+	}
+	if err := c.validateTargets(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateTargets checks that Targets have unique names and settings the
+// rest of the config can satisfy. Formats are checked by the runner, which
+// knows them.
+func (c *Config) validateTargets() error {
+	names := map[string]bool{}
+	for i, t := range c.Targets {
+		if t.Name == "" || strings.ContainsAny(t.Name, `/\`) {
+			return fmt.Errorf("config: Targets[%d]: Name must be set and must not contain a path separator", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("config: Targets[%d]: duplicate Name %q", i, t.Name)
+		}
+		names[t.Name] = true
+		if t.Format == "" {
+			return fmt.Errorf("config: Targets.%s: Format is required", t.Name)
+		}
+		for _, dest := range t.Destinations {
+			if u, err := url.Parse(dest); err != nil || u.Scheme == "" || !strings.HasSuffix(dest, "/") {
+				return fmt.Errorf("config: Targets.%s.Destinations: %q is not a URL ending in /", t.Name, dest)
+			}
+		}
+		if t.Currency != "" && t.Currency != c.Currency && !slices.ContainsFunc(c.FeedCurrencies, func(fc FeedCurrency) bool { return fc.Code == t.Currency }) {
+			return fmt.Errorf("config: Targets.%s: Currency %s is not one of FeedCurrencies", t.Name, t.Currency)
+		}
+		if err := t.LinkSettings(c.Links).validate("Targets." + t.Name + ".Links"); err != nil {
+			return err
+		}
+		for param := range t.UTM {
+			if !strings.HasPrefix(param, "utm_") {
+				return fmt.Errorf("config: Targets.%s.UTM: %q is not a utm_ parameter", t.Name, param)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Promotions configures the Merchant Center promotions feed and the
+// promotion_id of the items each promotion applies to. Promotions come from
+// List and, when Table is set, from that Hasura table; IDs must not repeat
+// across both.
+type Promotions struct {
+	Path  string      `json:"Path"`  // promotions feed written by the promotions command
+	Table string      `json:"Table"` // "" reads no table
+	List  []Promotion `json:"List"`
+}
+
+// Promotion is one promotion of the promotions feed. A specific_products
+// promotion applies to the items of the ads matching every list it sets,
+// which are sent with its ID as promotion_id; an all_products one applies
+// to every item and is not linked to any.
+type Promotion struct {
+	ID                    string    `json:"ID"`                    // promotion_id, at most 50 characters
+	ProductApplicability  string    `json:"ProductApplicability"`  // all_products or specific_products
+	OfferType             string    `json:"OfferType"`             // no_code or generic_code
+	GenericRedemptionCode string    `json:"GenericRedemptionCode"` // code shoppers enter, for generic_code offers
+	LongTitle             string    `json:"LongTitle"`             // at most 60 characters
+	Start                 time.Time `json:"Start"`                 // redemption period
+	End                   time.Time `json:"End"`
+
+	AdIDs         []string `json:"AdIDs"`
+	Brands        []string `json:"Brands"`        // matched ignoring case
+	Subcategories []string `json:"Subcategories"` // IDs or names
+}
+
+// Values accepted in Promotion.ProductApplicability and OfferType
+const (
+	PromotionAllProducts      = "all_products"
+	PromotionSpecificProducts = "specific_products"
+	PromotionNoCode           = "no_code"
+	PromotionGenericCode      = "generic_code"
+)
+
+// DefaultPromotions is used for any promotions setting left unset
+var DefaultPromotions = Promotions{
+	Path: "productsfashionaccessories_promotions.xml",
+}
+
+// applyPromotionsDefaults fills in the promotions settings that were left
+// unset
+func (c *Config) applyPromotionsDefaults() {
+	if c.Promotions.Path == "" {
+		c.Promotions.Path = DefaultPromotions.Path
+	}
+}
+
+// validatePromotions checks the promotions table and list
+func (c *Config) validatePromotions() error {
+	if c.Promotions.Table != "" && !graphQLName.MatchString(c.Promotions.Table) {
+		return fmt.Errorf("config: Promotions.Table %q is not a GraphQL name", c.Promotions.Table)
+	}
+	ids := map[string]bool{}
+	for i, p := range c.Promotions.List {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("config: Promotions.List[%d]: %w", i, err)
+		}
+		if ids[p.ID] {
+			return fmt.Errorf("config: Promotions.List[%d]: duplicate ID %q", i, p.ID)
+		}
+		ids[p.ID] = true
+	}
+	return nil
+}
+
+// Validate checks the promotion against the rules of the promotions feed.
+// It is also used for promotions read from Promotions.Table.
+func (p Promotion) Validate() error {
+	switch {
+	case p.ID == "" || len(p.ID) > 50 || strings.ContainsAny(p.ID, " \t\n"):
+		return fmt.Errorf("promotion ID %q must be 1 to 50 characters without spaces", p.ID)
+	case p.ProductApplicability != PromotionAllProducts && p.ProductApplicability != PromotionSpecificProducts:
+		return fmt.Errorf("promotion %s: ProductApplicability must be %q or %q", p.ID, PromotionAllProducts, PromotionSpecificProducts)
+	case p.OfferType != PromotionNoCode && p.OfferType != PromotionGenericCode:
+		return fmt.Errorf("promotion %s: OfferType must be %q or %q", p.ID, PromotionNoCode, PromotionGenericCode)
+	case (p.OfferType == PromotionGenericCode) != (p.GenericRedemptionCode != ""):
+		return fmt.Errorf("promotion %s: GenericRedemptionCode is required for %s offers and only for them", p.ID, PromotionGenericCode)
+	case p.LongTitle == "" || utf8.RuneCountInString(p.LongTitle) > 60:
+		return fmt.Errorf("promotion %s: LongTitle must be 1 to 60 characters", p.ID)
+	case p.Start.IsZero() || p.End.IsZero() || !p.End.After(p.Start):
+		return fmt.Errorf("promotion %s: Start and End are required and End must be after Start", p.ID)
+	case p.ProductApplicability == PromotionSpecificProducts && len(p.AdIDs)+len(p.Brands)+len(p.Subcategories) == 0:
+		return fmt.Errorf("promotion %s: a %s promotion needs AdIDs, Brands or Subcategories", p.ID, PromotionSpecificProducts)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Summary configures the report of what each run did
+type Summary struct {
+	Path string `json:"Path"` // JSON file rewritten after every run; empty writes none
+}
+
+// Exclusions configures the report of the ads and items each full run left
+// out, with their seller, reason and offending value. Every run writes new
+// files named after its start time, e.g. exclusions_20240102T150405Z.csv, so
+// data quality can be compared across runs.
+type Exclusions struct {
+	Dir     string   `json:"Dir"`     // directory of the reports, such as the feeds'; empty writes none
+	Formats []string `json:"Formats"` // csv (default) and/or json
+}
+
+// Formats accepted in Exclusions.Formats
+const (
+	ExclusionsCSV  = "csv"
+	ExclusionsJSON = "json"
+)
+
+// DefaultExclusions is used for any exclusions setting left unset
+var DefaultExclusions = Exclusions{
+	Formats: []string{ExclusionsCSV},
+}
+
+// History configures the database recording the lifecycle of every item:
+// when it was first and last seen, its price and availability changes and
+// why it left the feed. Only full runs are recorded.
+type History struct {
+	Driver string `json:"Driver"` // database/sql driver: pgx (default) for Postgres, or sqlite
+	DSN    string `json:"DSN"`    // connection string or SQLite file; empty records nothing
+}
+
+// History drivers the binary is built with
+const (
+	HistoryPostgres = "pgx"
+	HistorySQLite   = "sqlite"
+)
+
+// BigQuery configures exporting the summary of every full run and a
+// snapshot of its items to BigQuery tables, which must exist
+type BigQuery struct {
+	ProjectID       string `json:"ProjectID"` // empty exports nothing
+	Dataset         string `json:"Dataset"`
+	RunsTable       string `json:"RunsTable"`
+	ItemsTable      string `json:"ItemsTable"`
+	CredentialsFile string `json:"CredentialsFile"` // service account key JSON
+	Endpoint        string `json:"Endpoint"`
+	BatchSize       int    `json:"BatchSize"` // rows per streaming insert
+}
+
+// DefaultBigQuery is used for any BigQuery setting left unset
+var DefaultBigQuery = BigQuery{
+	RunsTable:  "feed_runs",
+	ItemsTable: "feed_items",
+	Endpoint:   "https://bigquery.googleapis.com/bigquery/v2",
+	BatchSize:  500,
+}
+
+// Guard keeps a run from publishing a feed whose item count dropped
+// sharply: by more than MaxDropPercent below the average of the last Runs
+// successful runs, in all or in a subcategory that averaged at least
+// MinItems items. Such a run fails without replacing the feed files.
+type Guard struct {
+	MaxDropPercent float64 `json:"MaxDropPercent"` // 0 turns the guard off
+	Runs           int     `json:"Runs"`           // runs averaged, which the state keeps
+	MinItems       int     `json:"MinItems"`       // smaller subcategories are not checked
+}
+
+// DefaultGuard is used for any guard setting left unset
+var DefaultGuard = Guard{
+	Runs:     5,
+	MinItems: 20,
+}
+
+// Diagnostics configures the diagnostics command, which pulls the issues
+// Merchant Center found on the products it received. Ads disapproved in
+// ExcludeAfter pulls in a row are left out of later runs until the seller
+// edits them.
+type Diagnostics struct {
+	ReportPath   string `json:"ReportPath"`   // CSV of the issues, rewritten by every pull
+	ExcludeAfter int    `json:"ExcludeAfter"` // 0 never excludes
+}
+
+// DefaultDiagnostics is used for any diagnostics setting left unset
+var DefaultDiagnostics = Diagnostics{
+	ReportPath: "disapprovals.csv",
+}
+
+// applyReportsEnv overrides the settings of the run reports with their
+// environment variables
+func (c *Config) applyReportsEnv() error {
+	if v := os.Getenv("SUMMARY_PATH"); v != "" {
+		c.Summary.Path = v
+	}
+	if v := os.Getenv("BIGQUERY_PROJECT"); v != "" {
+		c.BigQuery.ProjectID = v
+	}
+	if v := os.Getenv("BIGQUERY_DATASET"); v != "" {
+		c.BigQuery.Dataset = v
+	}
+	if v := os.Getenv("HISTORY_DSN"); v != "" {
+		c.History.DSN = v
+	}
+	if v := os.Getenv("EXCLUSIONS_DIR"); v != "" {
+		c.Exclusions.Dir = v
+	}
+	if v := os.Getenv("GUARD_MAX_DROP_PERCENT"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("config: invalid GUARD_MAX_DROP_PERCENT %q: %w", v, err)
+		}
+		c.Guard.MaxDropPercent = f
+	}
+	if v := os.Getenv("DIAGNOSTICS_EXCLUDE_AFTER"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid DIAGNOSTICS_EXCLUDE_AFTER %q: %w", v, err)
+		}
+		c.Diagnostics.ExcludeAfter = n
+	}
+	return nil
+}
+
+// applyReportsDefaults fills in the settings of the run reports that were
+// left unset
+func (c *Config) applyReportsDefaults() {
+	if c.Guard.Runs == 0 {
+		c.Guard.Runs = DefaultGuard.Runs
+	}
+	if c.Guard.MinItems == 0 {
+		c.Guard.MinItems = DefaultGuard.MinItems
+	}
+	if c.BigQuery.RunsTable == "" {
+		c.BigQuery.RunsTable = DefaultBigQuery.RunsTable
+	}
+	if c.BigQuery.ItemsTable == "" {
+		c.BigQuery.ItemsTable = DefaultBigQuery.ItemsTable
+	}
+	if c.BigQuery.Endpoint == "" {
+		c.BigQuery.Endpoint = DefaultBigQuery.Endpoint
+	}
+	if c.BigQuery.BatchSize == 0 {
+		c.BigQuery.BatchSize = DefaultBigQuery.BatchSize
+	}
+	if c.History.Driver == "" {
+		c.History.Driver = HistoryPostgres
+	}
+	if len(c.Exclusions.Formats) == 0 {
+		c.Exclusions.Formats = DefaultExclusions.Formats
+	}
+	if c.Diagnostics.ReportPath == "" {
+		c.Diagnostics.ReportPath = DefaultDiagnostics.ReportPath
+	}
+}
+
+// validateReports checks the settings of the run reports
+func (c *Config) validateReports() error {
+	if c.Guard.MaxDropPercent < 0 || c.Guard.MaxDropPercent > 100 {
+		return errors.New("config: Guard.MaxDropPercent must be between 0 and 100")
+	}
+	if c.Guard.Runs < 1 || c.Guard.MinItems < 0 {
+		return errors.New("config: Guard.Runs must be at least 1 and Guard.MinItems not negative")
+	}
+	if c.Diagnostics.ExcludeAfter < 0 {
+		return errors.New("config: Diagnostics.ExcludeAfter must not be negative")
+	}
+	if c.BigQuery.ProjectID != "" && (c.BigQuery.Dataset == "" || c.BigQuery.CredentialsFile == "") {
+		return errors.New("config: BigQuery needs a Dataset and a CredentialsFile")
+	}
+	if c.BigQuery.BatchSize < 1 || c.BigQuery.BatchSize > 50000 {
+		return errors.New("config: BigQuery.BatchSize must be between 1 and 50000")
+	}
+	if c.History.Driver != HistoryPostgres && c.History.Driver != HistorySQLite {
+		return fmt.Errorf("config: unknown History.Driver %q", c.History.Driver)
+	}
+	for _, format := range c.Exclusions.Formats {
+		if format != ExclusionsCSV && format != ExclusionsJSON {
+			return fmt.Errorf("config: unknown Exclusions format %q", format)
+		}
+	}
+	return nil
+}
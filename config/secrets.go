@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Secrets providers accepted in Secrets.Provider
+const (
+	SecretsEnv   = "env"
+	SecretsVault = "vault"
+	SecretsAWS   = "aws"
+)
+
+// Secrets selects where the admin secret and API tokens left unset in the
+// file and the environment are read from. Each value is looked up under the
+// name of its environment variable, e.g. ADMIN_SECRET.
+type Secrets struct {
+	Provider string `json:"Provider"` // env (default), vault or aws
+	// Vault KV version 2 secret holding the values. The token comes from
+	// VAULT_TOKEN.
+	VaultAddress string `json:"VaultAddress"`
+	VaultMount   string `json:"VaultMount"` // default secret
+	VaultPath    string `json:"VaultPath"`
+	// AWS Secrets Manager secret holding the values as a JSON object.
+	// Requests are signed with AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+	AWSSecretID string `json:"AWSSecretID"`
+	AWSRegion   string `json:"AWSRegion"`
+	AWSEndpoint string `json:"AWSEndpoint"` // empty for AWS
+}
+
+// DefaultSecrets is used for any secrets setting left unset
+var DefaultSecrets = Secrets{
+	Provider:   SecretsEnv,
+	VaultMount: "secret",
+	AWSRegion:  "us-east-1",
+}
+
+// applySecretsEnv overrides the secrets settings with their environment
+// variables
+func (c *Config) applySecretsEnv() error {
+	if v := os.Getenv("SECRETS_PROVIDER"); v != "" {
+		c.Secrets.Provider = v
+	}
+	if v := os.Getenv("VAULT_ADDR"); v != "" {
+		c.Secrets.VaultAddress = v
+	}
+	if v := os.Getenv("VAULT_SECRET_PATH"); v != "" {
+		c.Secrets.VaultPath = v
+	}
+	if v := os.Getenv("AWS_SECRET_ID"); v != "" {
+		c.Secrets.AWSSecretID = v
+	}
+	if v := os.Getenv("AWS_REGION"); v != "" {
+		c.Secrets.AWSRegion = v
+	}
+	return nil
+}
+
+// applySecretsDefaults fills in the secrets settings that were left unset
+func (c *Config) applySecretsDefaults() {
+	if c.Secrets.Provider == "" {
+		c.Secrets.Provider = DefaultSecrets.Provider
+	}
+	if c.Secrets.VaultMount == "" {
+		c.Secrets.VaultMount = DefaultSecrets.VaultMount
+	}
+	if c.Secrets.AWSRegion == "" {
+		c.Secrets.AWSRegion = DefaultSecrets.AWSRegion
+	}
+}
+
+// validateSecrets checks that the provider has what it needs
+func (c *Config) validateSecrets() error {
+	switch c.Secrets.Provider {
+	case SecretsEnv:
+	case SecretsVault:
+		if c.Secrets.VaultAddress == "" || c.Secrets.VaultPath == "" {
+			return errors.New("config: Secrets.VaultAddress and Secrets.VaultPath are required for vault")
+		}
+	case SecretsAWS:
+		if c.Secrets.AWSSecretID == "" {
+			return errors.New("config: Secrets.AWSSecretID is required for aws")
+		}
+	default:
+		return fmt.Errorf("config: unknown Secrets.Provider %q", c.Secrets.Provider)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// DefaultSchedule is the cron expression used by the schedule command
+const DefaultSchedule = "@hourly"
+
+// Server configures the long-running HTTP server mode
+type Server struct {
+	Addr            string   `json:"Addr"`            // listen address
+	RefreshInterval Duration `json:"RefreshInterval"` // how often feeds are rebuilt
+	GRPCAddr        string   `json:"GRPCAddr"`        // listen address of the gRPC API; empty disables it
+	// GRPCToken, if set, must be sent by gRPC clients as a bearer token in
+	// the authorization metadata. It is required unless GRPCAddr is a
+	// loopback address, since the API can start a refresh.
+	GRPCToken string `json:"GRPCToken"`
+	// AdminAddr is the listen address of the dashboard and the items API,
+	// apart from the public feeds since they can start a refresh and list
+	// the whole catalog. It defaults to loopback.
+	AdminAddr string `json:"AdminAddr"`
+	// StaleAfter fails /readyz once the feeds were last refreshed this long
+	// ago, and StuckAfter fails /healthz; zero means 2 and 4 refresh
+	// intervals
+	StaleAfter Duration `json:"StaleAfter"`
+	StuckAfter Duration `json:"StuckAfter"`
+}
+
+// DefaultServer is used for any server setting left unset
+var DefaultServer = Server{
+	Addr:            ":8080",
+	RefreshInterval: Duration{time.Hour},
+	AdminAddr:       "127.0.0.1:8081",
+}
+
+// Watch configures the watch command, which polls the source for recently
+// updated ads and pushes the changes to a channel API
+type Watch struct {
+	PollInterval Duration `json:"PollInterval"` // how often the source is polled
+	PushInitial  bool     `json:"PushInitial"`  // push every item on start, not only changes
+	// Listen is the address of the Hasura event trigger webhook, served at
+	// /events; empty disables it
+	Listen string `json:"Listen"`
+	// WebhookSecret, if set, must be sent in the X-Webhook-Secret header of
+	// every event, configured as a header of the trigger
+	WebhookSecret string `json:"WebhookSecret"`
+}
+
+// DefaultWatch is used for any watch setting left unset
+var DefaultWatch = Watch{
+	PollInterval: Duration{time.Minute},
+}
+
+// Shutdown configures how the serve, schedule and watch commands stop on
+// SIGINT or SIGTERM: they start no new run, and the run in progress may go
+// on for GracePeriod before it is cancelled
+type Shutdown struct {
+	GracePeriod Duration `json:"GracePeriod"`
+}
+
+// DefaultShutdown is used for any shutdown setting left unset. It fits in
+// the default 30s termination grace period of Kubernetes.
+var DefaultShutdown = Shutdown{
+	GracePeriod: Duration{25 * time.Second},
+}
+
+// applyServerEnv overrides the settings of the long-running commands with
+// their environment variables
+func (c *Config) applyServerEnv() error {
+	if v := os.Getenv("SCHEDULE"); v != "" {
+		c.Schedule = v
+	}
+	if v := os.Getenv("SERVER_ADDR"); v != "" {
+		c.Server.Addr = v
+	}
+	if v := os.Getenv("SERVER_ADMIN_ADDR"); v != "" {
+		c.Server.AdminAddr = v
+	}
+	if v := os.Getenv("GRPC_ADDR"); v != "" {
+		c.Server.GRPCAddr = v
+	}
+	if v := os.Getenv("GRPC_TOKEN"); v != "" {
+		c.Server.GRPCToken = v
+	}
+	if v := os.Getenv("WATCH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid WATCH_INTERVAL %q: %w", v, err)
+		}
+		c.Watch.PollInterval.Duration = d
+	}
+	if v := os.Getenv("SHUTDOWN_GRACE_PERIOD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid SHUTDOWN_GRACE_PERIOD %q: %w", v, err)
+		}
+		c.Shutdown.GracePeriod.Duration = d
+	}
+	if v := os.Getenv("WATCH_LISTEN"); v != "" {
+		c.Watch.Listen = v
+	}
+	if v := os.Getenv("WEBHOOK_SECRET"); v != "" {
+		c.Watch.WebhookSecret = v
+	}
+	return nil
+}
+
+// applyServerDefaults fills in the settings of the long-running commands
+// that were left unset
+func (c *Config) applyServerDefaults() {
+	if c.Schedule == "" {
+		c.Schedule = DefaultSchedule
+	}
+	if c.Server.Addr == "" {
+		c.Server.Addr = DefaultServer.Addr
+	}
+	if c.Server.RefreshInterval.Duration == 0 {
+		c.Server.RefreshInterval = DefaultServer.RefreshInterval
+	}
+	if c.Server.AdminAddr == "" {
+		c.Server.AdminAddr = DefaultServer.AdminAddr
+	}
+	if c.Watch.PollInterval.Duration == 0 {
+		c.Watch.PollInterval = DefaultWatch.PollInterval
+	}
+	if c.Shutdown.GracePeriod.Duration == 0 {
+		c.Shutdown.GracePeriod = DefaultShutdown.GracePeriod
+	}
+}
+
+// validateServer checks the intervals of the long-running commands
+func (c *Config) validateServer() error {
+	if c.Server.RefreshInterval.Duration < time.Minute {
+		return errors.New("config: Server.RefreshInterval must be at least 1m")
+	}
+	if c.Server.GRPCAddr != "" && c.Server.GRPCToken == "" && !IsLoopback(c.Server.GRPCAddr) {
+		return fmt.Errorf("config: Server.GRPCToken is required to serve the gRPC API on %s, which is not a loopback address", c.Server.GRPCAddr)
+	}
+	if c.Server.AdminAddr == c.Server.Addr {
+		return errors.New("config: Server.AdminAddr must differ from Server.Addr")
+	}
+	if c.Server.StaleAfter.Duration < 0 || c.Server.StuckAfter.Duration < 0 {
+		return errors.New("config: Server.StaleAfter and Server.StuckAfter must not be negative")
+	}
+	if c.Watch.PollInterval.Duration < time.Second {
+		return errors.New("config: Watch.PollInterval must be at least 1s")
+	}
+	if c.Shutdown.GracePeriod.Duration < 0 {
+		return errors.New("config: Shutdown.GracePeriod must not be negative")
+	}
+	return nil
+}
+
+// IsLoopback reports whether the listen address addr only accepts
+// connections from the local host. An address without a host listens on
+// every interface.
+func IsLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestGRPCTokenRequiredOffLoopback(t *testing.T) {
+	tests := []struct {
+		addr, token string
+		ok          bool
+	}{
+		{"127.0.0.1:9090", "", true},
+		{"localhost:9090", "", true},
+		{"[::1]:9090", "", true},
+		{":9090", "", false},
+		{"0.0.0.0:9090", "", false},
+		{"10.0.0.5:9090", "", false},
+		{":9090", "s3cret", true},
+	}
+	for _, tt := range tests {
+		c := &Config{}
+		c.applyServerDefaults()
+		c.Server.GRPCAddr, c.Server.GRPCToken = tt.addr, tt.token
+		if err := c.validateServer(); (err == nil) != tt.ok {
+			t.Errorf("GRPCAddr %q with token %q: validateServer() = %v", tt.addr, tt.token, err)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultPageSize is the number of ads requested per GraphQL page
+const DefaultPageSize = 500
+
+// Retry controls how failed Hasura requests are retried
+type Retry struct {
+	MaxAttempts int      `json:"MaxAttempts"`
+	BaseDelay   Duration `json:"BaseDelay"`
+	MaxDelay    Duration `json:"MaxDelay"`
+	Jitter      float64  `json:"Jitter"` // fraction of the delay to randomize, 0-1
+}
+
+// DefaultRetry is used for any retry setting left unset
+var DefaultRetry = Retry{
+	MaxAttempts: 3,
+	BaseDelay:   Duration{time.Second},
+	MaxDelay:    Duration{30 * time.Second},
+	Jitter:      0.2,
+}
+
+// Hasura authentication modes accepted in HasuraAuth.Mode
+const (
+	HasuraAuthAdminSecret = "admin_secret"
+	HasuraAuthJWT         = "jwt"
+)
+
+// HasuraAuth selects how requests to Hasura are authenticated. The admin
+// secret grants full database access; with jwt, requests carry a bearer
+// token and run as Role, which only needs select permission on the tables
+// the feed reads. The token comes from Token, TokenFile or an OAuth2 client
+// credentials grant at TokenURL, which is refreshed before it expires.
+type HasuraAuth struct {
+	Mode         string   `json:"Mode"`      // admin_secret (default) or jwt
+	Role         string   `json:"Role"`      // sent as X-Hasura-Role, e.g. feed_reader
+	Token        string   `json:"Token"`     // fixed JWT
+	TokenFile    string   `json:"TokenFile"` // file holding the JWT, read again when it changes
+	TokenURL     string   `json:"TokenURL"`  // OAuth2 token endpoint issuing the JWT
+	ClientID     string   `json:"ClientID"`  // client credentials for TokenURL
+	ClientSecret string   `json:"ClientSecret"`
+	Scopes       []string `json:"Scopes"`
+}
+
+// Ad source types accepted in Source.Type
+const (
+	SourceHasura = "hasura"
+	SourceFile   = "file"
+	SourceREST   = "rest"
+)
+
+// Source selects where ads are read from
+type Source struct {
+	Type    string            `json:"Type"`    // hasura (default), file or rest
+	Path    string            `json:"Path"`    // JSON file read by the file source
+	URL     string            `json:"URL"`     // endpoint called by the rest source
+	Headers map[string]string `json:"Headers"` // extra headers for the rest source
+	Filters Filters           `json:"Filters"`
+	Names   Names             `json:"Names"`
+	// Inventory is the store stock read for local inventory feeds
+	Inventory Inventory `json:"Inventory"`
+	// HTTP configures the connections to the Hasura endpoint and the rest
+	// source. Settings left unset are taken from the top-level HTTP.
+	HTTP HTTP `json:"HTTP"`
+	// MaxPartialErrors is how many GraphQL errors a run of the hasura source
+	// tolerates in responses that also carry data. The ads they point at are
+	// skipped as incomplete. 0 fails the run on the first one.
+	MaxPartialErrors int `json:"MaxPartialErrors"`
+	// Record saves every GraphQL request of the hasura source with its
+	// response to this file; Replay answers them from such a file instead
+	// of the endpoint, for offline runs
+	Record string `json:"Record"`
+	Replay string `json:"Replay"`
+	// StrictAttributes fails the run on the first ad whose attributes do
+	// not match the expected form, instead of feeding what could be read
+	StrictAttributes bool `json:"StrictAttributes"`
+}
+
+// Filters narrow the ads the hasura source asks for. They are applied by
+// Hasura, on top of the categories and the updated_at window.
+type Filters struct {
+	Status    string   `json:"Status"`    // ad status fetched, default Published
+	SellerIDs []string `json:"SellerIDs"` // only ads of these sellers, when set
+	Brands    []string `json:"Brands"`    // only ads of these brands, matched exactly, when set
+}
+
+// DefaultStatus is the ad status fetched when Filters.Status is unset
+const DefaultStatus = "Published"
+
+// Names configures the secondary query of the hasura source that resolves
+// subcategory IDs to the names used in product_type, and brand IDs to
+// canonical brand names. Both tables need id and name columns.
+type Names struct {
+	Enabled          bool   `json:"Enabled"`
+	SubcategoryTable string `json:"SubcategoryTable"`
+	BrandTable       string `json:"BrandTable"`
+}
+
+// Inventory configures the secondary query of the hasura source that reads
+// the stock of ads in physical stores, for the local-inventory format. The
+// table needs ad_id, store_code, quantity, pickup_method and pickup_sla
+// columns, one row per ad and store.
+type Inventory struct {
+	Enabled bool   `json:"Enabled"`
+	Table   string `json:"Table"`
+}
+
+// DefaultInventory is used for any inventory setting left unset
+var DefaultInventory = Inventory{
+	Table: "store_inventory",
+}
+
+// graphQLName matches the names GraphQL allows for fields
+var graphQLName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// DefaultNames is used for any Names table left unset
+var DefaultNames = Names{
+	SubcategoryTable: "subcategories",
+	BrandTable:       "brands",
+}
+
+// applySourceEnv overrides the source settings with their environment
+// variables
+func (c *Config) applySourceEnv() error {
+	if v := os.Getenv("HASURA_ENDPOINT"); v != "" {
+		c.HasuraEndpoint = v
+	}
+	if v := os.Getenv("ADMIN_SECRET"); v != "" {
+		c.AdminSecret = v
+	}
+	if v := os.Getenv("HASURA_AUTH"); v != "" {
+		c.HasuraAuth.Mode = v
+	}
+	if v := os.Getenv("HASURA_ROLE"); v != "" {
+		c.HasuraAuth.Role = v
+	}
+	if v := os.Getenv("HASURA_JWT"); v != "" {
+		c.HasuraAuth.Token = v
+	}
+	if v := os.Getenv("HASURA_JWT_FILE"); v != "" {
+		c.HasuraAuth.TokenFile = v
+	}
+	if v := os.Getenv("HASURA_TOKEN_URL"); v != "" {
+		c.HasuraAuth.TokenURL = v
+	}
+	if v := os.Getenv("HASURA_CLIENT_ID"); v != "" {
+		c.HasuraAuth.ClientID = v
+	}
+	if v := os.Getenv("HASURA_CLIENT_SECRET"); v != "" {
+		c.HasuraAuth.ClientSecret = v
+	}
+	if v := os.Getenv("AD_SOURCE"); v != "" {
+		c.Source.Type = v
+	}
+	if v := os.Getenv("AD_SOURCE_PATH"); v != "" {
+		c.Source.Path = v
+	}
+	if v := os.Getenv("AD_SOURCE_URL"); v != "" {
+		c.Source.URL = v
+	}
+	if v := os.Getenv("AD_STATUS"); v != "" {
+		c.Source.Filters.Status = v
+	}
+	if v := os.Getenv("SELLER_IDS"); v != "" {
+		c.Source.Filters.SellerIDs = splitList(v)
+	}
+	if v := os.Getenv("SOURCE_BRANDS"); v != "" {
+		c.Source.Filters.Brands = splitList(v)
+	}
+	if v := os.Getenv("RECORD_FILE"); v != "" {
+		c.Source.Record = v
+	}
+	if v := os.Getenv("REPLAY_FILE"); v != "" {
+		c.Source.Replay = v
+	}
+	if v := os.Getenv("STRICT_ATTRIBUTES"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid STRICT_ATTRIBUTES %q: %w", v, err)
+		}
+		c.Source.StrictAttributes = b
+	}
+	if v := os.Getenv("SOURCE_PROXY"); v != "" {
+		c.Source.HTTP.Proxy = v
+	}
+	if v := os.Getenv("SOURCE_CA_FILE"); v != "" {
+		c.Source.HTTP.CAFile = v
+	}
+	if v := os.Getenv("SOURCE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid SOURCE_TIMEOUT %q: %w", v, err)
+		}
+		c.Source.HTTP.Timeout.Duration = d
+	}
+	if v := os.Getenv("RESOLVE_NAMES"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid RESOLVE_NAMES %q: %w", v, err)
+		}
+		c.Source.Names.Enabled = b
+	}
+	if v := os.Getenv("LOCAL_INVENTORY"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid LOCAL_INVENTORY %q: %w", v, err)
+		}
+		c.Source.Inventory.Enabled = b
+	}
+	if v := os.Getenv("PAGE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid PAGE_SIZE %q: %w", v, err)
+		}
+		c.PageSize = n
+	}
+	if v := os.Getenv("FETCH_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid FETCH_WINDOW %q: %w", v, err)
+		}
+		c.Window.Duration = d
+	}
+	if v := os.Getenv("FULL_REFRESH"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid FULL_REFRESH %q: %w", v, err)
+		}
+		c.FullRefresh = b
+	}
+	if v := os.Getenv("RETRY_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid RETRY_MAX_ATTEMPTS %q: %w", v, err)
+		}
+		c.Retry.MaxAttempts = n
+	}
+	return nil
+}
+
+// applySourceDefaults fills in the source settings that were left unset
+func (c *Config) applySourceDefaults() {
+	if c.Source.Type == "" {
+		c.Source.Type = SourceHasura
+	}
+	if c.HasuraAuth.Mode == "" {
+		c.HasuraAuth.Mode = HasuraAuthAdminSecret
+	}
+	if c.Source.Filters.Status == "" {
+		c.Source.Filters.Status = DefaultStatus
+	}
+	if c.Source.Names.SubcategoryTable == "" {
+		c.Source.Names.SubcategoryTable = DefaultNames.SubcategoryTable
+	}
+	if c.Source.Names.BrandTable == "" {
+		c.Source.Names.BrandTable = DefaultNames.BrandTable
+	}
+	if c.Source.Inventory.Table == "" {
+		c.Source.Inventory.Table = DefaultInventory.Table
+	}
+	c.Source.HTTP.inherit(c.HTTP)
+	c.Source.HTTP.applyDefaults()
+	if c.PageSize == 0 {
+		c.PageSize = DefaultPageSize
+	}
+	if c.Retry.MaxAttempts == 0 {
+		c.Retry.MaxAttempts = DefaultRetry.MaxAttempts
+	}
+	if c.Retry.BaseDelay.Duration == 0 {
+		c.Retry.BaseDelay = DefaultRetry.BaseDelay
+	}
+	if c.Retry.MaxDelay.Duration == 0 {
+		c.Retry.MaxDelay = DefaultRetry.MaxDelay
+	}
+}
+
+// validateSource checks that the configured source can be read
+func (c *Config) validateSource() error {
+	switch c.Source.Type {
+	case SourceHasura:
+		if c.Source.Record != "" && c.Source.Replay != "" {
+			return errors.New("config: Source.Record and Source.Replay cannot both be set")
+		}
+		if c.Source.Replay != "" {
+			break // nothing is sent to Hasura
+		}
+		if c.HasuraEndpoint == "" {
+			return errors.New("config: HasuraEndpoint is required")
+		}
+		if err := c.HasuraAuth.validate(); err != nil {
+			return err
+		}
+	case SourceFile:
+		if c.Source.Path == "" {
+			return errors.New("config: Source.Path is required for the file source")
+		}
+	case SourceREST:
+		if c.Source.URL == "" {
+			return errors.New("config: Source.URL is required for the rest source")
+		}
+	default:
+		return fmt.Errorf("config: unknown Source.Type %q", c.Source.Type)
+	}
+	// The table names are written into the lookup query
+	for name, table := range map[string]string{
+		"SubcategoryTable": c.Source.Names.SubcategoryTable,
+		"BrandTable":       c.Source.Names.BrandTable,
+	} {
+		if !graphQLName.MatchString(table) {
+			return fmt.Errorf("config: Source.Names.%s %q is not a GraphQL name", name, table)
+		}
+	}
+	if !graphQLName.MatchString(c.Source.Inventory.Table) {
+		return fmt.Errorf("config: Source.Inventory.Table %q is not a GraphQL name", c.Source.Inventory.Table)
+	}
+	if c.PageSize < 0 {
+		return errors.New("config: PageSize must be positive")
+	}
+	if c.Window.Duration < 0 {
+		return errors.New("config: Window must not be negative")
+	}
+	if c.Retry.MaxAttempts < 1 {
+		return errors.New("config: Retry.MaxAttempts must be at least 1")
+	}
+	if c.Retry.Jitter < 0 || c.Retry.Jitter > 1 {
+		return errors.New("config: Retry.Jitter must be between 0 and 1")
+	}
+	if c.Source.MaxPartialErrors < 0 {
+		return errors.New("config: Source.MaxPartialErrors must not be negative")
+	}
+	return c.Source.HTTP.validate("Source.HTTP")
+}
+
+// validate checks that the mode has what it needs
+func (a HasuraAuth) validate() error {
+	switch a.Mode {
+	case HasuraAuthAdminSecret:
+	case HasuraAuthJWT:
+		sources := 0
+		for _, set := range []bool{a.Token != "", a.TokenFile != "", a.TokenURL != ""} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return errors.New("config: HasuraAuth needs exactly one of Token, TokenFile or TokenURL for jwt")
+		}
+		if a.TokenURL != "" && a.ClientID == "" {
+			return errors.New("config: HasuraAuth.ClientID is required with TokenURL")
+		}
+	default:
+		return fmt.Errorf("config: unknown HasuraAuth.Mode %q", a.Mode)
+	}
+	return nil
+}
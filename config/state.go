@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// State configures where run bookkeeping is persisted between runs
+type State struct {
+	Path    string   `json:"Path"`    // JSON state file
+	Overlap Duration `json:"Overlap"` // re-fetch margin before the last run
+}
+
+// DefaultState is used for any state setting left unset
+var DefaultState = State{
+	Path:    "state.json",
+	Overlap: Duration{time.Hour},
+}
+
+// Delta configures the supplemental feeds of the items that changed since
+// the last run. Each feed file gets a _delta counterpart, and the items that
+// disappeared are listed in DeletionsPath.
+type Delta struct {
+	Enabled       bool   `json:"Enabled"`
+	DeletionsPath string `json:"DeletionsPath"` // CSV of the item IDs to delete
+}
+
+// DefaultDelta is used for any delta setting left unset
+var DefaultDelta = Delta{
+	DeletionsPath: "productsfashionaccessories_deleted.csv",
+}
+
+// applyStateEnv overrides the state and delta settings with their
+// environment variables
+func (c *Config) applyStateEnv() error {
+	if v := os.Getenv("DELTA_FEED"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid DELTA_FEED %q: %w", v, err)
+		}
+		c.Delta.Enabled = b
+	}
+	if v := os.Getenv("STATE_PATH"); v != "" {
+		c.State.Path = v
+	}
+	if v := os.Getenv("STATE_OVERLAP"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid STATE_OVERLAP %q: %w", v, err)
+		}
+		c.State.Overlap.Duration = d
+	}
+	return nil
+}
+
+// applyStateDefaults fills in the state and delta settings that were left
+// unset
+func (c *Config) applyStateDefaults() {
+	if c.State.Path == "" {
+		c.State.Path = DefaultState.Path
+	}
+	if c.Delta.DeletionsPath == "" {
+		c.Delta.DeletionsPath = DefaultDelta.DeletionsPath
+	}
+	if c.State.Overlap.Duration == 0 {
+		c.State.Overlap = DefaultState.Overlap
+	}
+}
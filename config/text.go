@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"go_data_fashion_accessories/sanitize"
+)
+
+// DefaultTaxonomyFile is the subcategory mapping read when TaxonomyFile is
+// not set
+const DefaultTaxonomyFile = "config/taxonomy.json"
+
+// DefaultSynonymsFile is the attribute synonym dictionary read when
+// SynonymsFile is not set
+const DefaultSynonymsFile = "config/synonyms.json"
+
+// Text fields whose cleanup can be configured in Sanitize
+const (
+	FieldTitle       = "Title"
+	FieldDescription = "Description"
+	FieldBrand       = "Brand"
+	FieldProductType = "ProductType"
+	FieldColor       = "Color"
+	FieldSize        = "Size"
+	FieldMaterial    = "Material"
+)
+
+// DefaultSanitize is the cleanup applied to a field missing from Sanitize.
+// Entities are decoded first, so an encoded control or invisible character
+// such as &#8203; is stripped like a literal one.
+var DefaultSanitize = map[string][]string{
+	FieldTitle:       {"decode_entities", "strip_control", "strip_invisible", "normalize_space", "nfc"},
+	FieldDescription: {"strip_html", "decode_entities", "strip_control", "strip_invisible", "normalize_space", "nfc"},
+	FieldBrand:       {"decode_entities", "strip_control", "strip_invisible", "normalize_space", "nfc"},
+}
+
+// DefaultLengthLimits are Google Merchant Center's maximum lengths, in
+// characters, used for a field missing from LengthLimits
+var DefaultLengthLimits = map[string]int{
+	FieldTitle:       150,
+	FieldDescription: 5000,
+	FieldBrand:       70,
+	FieldProductType: 750,
+	FieldColor:       100,
+	FieldSize:        100,
+	FieldMaterial:    200,
+}
+
+// applyTextEnv overrides the settings of the item texts with their
+// environment variables
+func (c *Config) applyTextEnv() error {
+	if v := os.Getenv("TAXONOMY_FILE"); v != "" {
+		c.TaxonomyFile = v
+	}
+	if v := os.Getenv("SYNONYMS_FILE"); v != "" {
+		c.SynonymsFile = v
+	}
+	if v := os.Getenv("STRIP_EMOJI"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: invalid STRIP_EMOJI %q: %w", v, err)
+		}
+		c.StripEmoji = b
+	}
+	if v := os.Getenv("TITLE_TEMPLATE"); v != "" {
+		c.TitleTemplate = v
+	}
+	return nil
+}
+
+// applyTextDefaults fills in the settings of the item texts that were left
+// unset
+func (c *Config) applyTextDefaults() {
+	if c.TaxonomyFile == "" {
+		c.TaxonomyFile = DefaultTaxonomyFile
+	}
+	if c.SynonymsFile == "" {
+		c.SynonymsFile = DefaultSynonymsFile
+	}
+	for field, steps := range DefaultSanitize {
+		if _, ok := c.Sanitize[field]; !ok {
+			if c.Sanitize == nil {
+				c.Sanitize = map[string][]string{}
+			}
+			c.Sanitize[field] = steps
+		}
+	}
+	for field, limit := range DefaultLengthLimits {
+		if _, ok := c.LengthLimits[field]; !ok {
+			if c.LengthLimits == nil {
+				c.LengthLimits = map[string]int{}
+			}
+			c.LengthLimits[field] = limit
+		}
+	}
+}
+
+// validateText checks the cleanup steps and length limits of the text
+// fields
+func (c *Config) validateText() error {
+	for field, steps := range c.Sanitize {
+		if _, ok := DefaultSanitize[field]; !ok {
+			return fmt.Errorf("config: Sanitize: unknown field %q", field)
+		}
+		if _, err := sanitize.Parse(steps); err != nil {
+			return fmt.Errorf("config: Sanitize.%s: %w", field, err)
+		}
+	}
+	for field, limit := range c.LengthLimits {
+		if _, ok := DefaultLengthLimits[field]; !ok {
+			return fmt.Errorf("config: LengthLimits: unknown field %q", field)
+		}
+		if limit < 0 || limit == 1 {
+			return fmt.Errorf("config: LengthLimits.%s must be 0 or at least 2", field)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"go_data_fashion_accessories/sanitize"
+)
+
+func TestDefaultSanitizeStripsEncodedInvisibles(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"zero width space", "Prada&#8203; Bag", "Prada Bag"},
+		{"hex zero width space", "Pra&#x200B;da", "Prada"},
+		{"left-to-right mark", "&lrm;Gucci&lrm;", "Gucci"},
+		{"right-to-left mark", "&rlm;حقيبة", "حقيبة"},
+		{"encoded control", "Ray-Ban&#7;", "Ray-Ban"},
+	}
+	for field, steps := range DefaultSanitize {
+		chain, err := sanitize.Parse(steps)
+		if err != nil {
+			t.Fatalf("%s: %v", field, err)
+		}
+		for _, tt := range tests {
+			t.Run(field+"/"+tt.name, func(t *testing.T) {
+				if got := chain.Apply(tt.in); got != tt.want {
+					t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestDefaultSanitizeKeepsAmpersands(t *testing.T) {
+	chain, err := sanitize.Parse(DefaultSanitize[FieldTitle])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for in, want := range map[string]string{
+		"H&M Tote":                 "H&M Tote",
+		"H&amp;M Tote":             "H&M Tote",
+		"Dolce &amp; Gabbana  Bag": "Dolce & Gabbana Bag",
+	} {
+		if got := chain.Apply(in); got != want {
+			t.Errorf("Apply(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
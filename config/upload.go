@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// SFTP configures the login to SFTP upload destinations. The user and
+// password may also be given in the destination URL.
+type SFTP struct {
+	User                  string `json:"User"`
+	KeyFile               string `json:"KeyFile"` // private key in OpenSSH or PEM format
+	Password              string `json:"Password"`
+	KnownHostsFile        string `json:"KnownHostsFile"`        // host keys to verify servers against
+	InsecureIgnoreHostKey bool   `json:"InsecureIgnoreHostKey"` // skip host key checks, for testing only
+}
+
+// ObjectStorage configures uploads to S3 (s3://bucket/prefix/) and Google
+// Cloud Storage (gs://bucket/prefix/) destinations. S3 credentials come
+// from the usual AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY variables.
+type ObjectStorage struct {
+	// KeyTemplate is the object key below the URL's prefix. {file} is the
+	// feed's file name, {date} and {time} the upload time in UTC as
+	// 2006-01-02 and 150405.
+	KeyTemplate  string            `json:"KeyTemplate"`
+	ContentTypes map[string]string `json:"ContentTypes"` // by file extension, e.g. ".xml"
+	CacheControl string            `json:"CacheControl"`
+	PublicRead   bool              `json:"PublicRead"` // let anyone, such as Merchant Center, fetch the object
+	S3Region     string            `json:"S3Region"`
+	S3Endpoint   string            `json:"S3Endpoint"` // S3 compatible service, addressed path-style; empty for AWS
+
+	GCSCredentialsFile string `json:"GCSCredentialsFile"` // service account key
+	GCSEndpoint        string `json:"GCSEndpoint"`
+}
+
+// DefaultObjectStorage is used for any object storage setting left unset
+var DefaultObjectStorage = ObjectStorage{
+	KeyTemplate: "{file}",
+	ContentTypes: map[string]string{
+		".xml":     "application/xml; charset=utf-8",
+		".csv":     "text/csv; charset=utf-8",
+		".tsv":     "text/tab-separated-values; charset=utf-8",
+		".ndjson":  "application/x-ndjson",
+		".parquet": "application/vnd.apache.parquet",
+	},
+	CacheControl: "no-cache",
+	S3Region:     "us-east-1",
+	GCSEndpoint:  "https://storage.googleapis.com",
+}
+
+// Upload configures copying the generated feed files to the destinations
+// channels fetch them from, after every successful generation
+type Upload struct {
+	// Destinations are directory URLs ending in "/", such as
+	// sftp://host/incoming/; each feed file keeps its name
+	Destinations  []string      `json:"Destinations"`
+	SFTP          SFTP          `json:"SFTP"`
+	ObjectStorage ObjectStorage `json:"ObjectStorage"`
+}
+
+// applyUploadEnv overrides the upload settings with their environment
+// variables
+func (c *Config) applyUploadEnv() error {
+	if v := os.Getenv("UPLOAD_DESTINATIONS"); v != "" {
+		c.Upload.Destinations = splitList(v)
+	}
+	if v := os.Getenv("SFTP_KEY_FILE"); v != "" {
+		c.Upload.SFTP.KeyFile = v
+	}
+	if v := os.Getenv("SFTP_PASSWORD"); v != "" {
+		c.Upload.SFTP.Password = v
+	}
+	if v := os.Getenv("SFTP_KNOWN_HOSTS"); v != "" {
+		c.Upload.SFTP.KnownHostsFile = v
+	}
+	if v := os.Getenv("AWS_REGION"); v != "" {
+		c.Upload.ObjectStorage.S3Region = v
+	}
+	if v := os.Getenv("GCS_CREDENTIALS"); v != "" {
+		c.Upload.ObjectStorage.GCSCredentialsFile = v
+	}
+	return nil
+}
+
+// applyUploadDefaults fills in the upload settings that were left unset
+func (c *Config) applyUploadDefaults() {
+	if c.Upload.ObjectStorage.KeyTemplate == "" {
+		c.Upload.ObjectStorage.KeyTemplate = DefaultObjectStorage.KeyTemplate
+	}
+	if c.Upload.ObjectStorage.ContentTypes == nil {
+		c.Upload.ObjectStorage.ContentTypes = DefaultObjectStorage.ContentTypes
+	}
+	if c.Upload.ObjectStorage.CacheControl == "" {
+		c.Upload.ObjectStorage.CacheControl = DefaultObjectStorage.CacheControl
+	}
+	if c.Upload.ObjectStorage.S3Region == "" {
+		c.Upload.ObjectStorage.S3Region = DefaultObjectStorage.S3Region
+	}
+	if c.Upload.ObjectStorage.GCSEndpoint == "" {
+		c.Upload.ObjectStorage.GCSEndpoint = DefaultObjectStorage.GCSEndpoint
+	}
+}
+
+// validateUpload checks the destinations and the object keys
+func (c *Config) validateUpload() error {
+	for _, dest := range c.Upload.Destinations {
+		if u, err := url.Parse(dest); err != nil || u.Scheme == "" || !strings.HasSuffix(dest, "/") {
+			return fmt.Errorf("config: Upload.Destinations: %q is not a URL ending in /", dest)
+		}
+	}
+	if !strings.Contains(c.Upload.ObjectStorage.KeyTemplate, "{file}") {
+		return errors.New("config: Upload.ObjectStorage.KeyTemplate must contain {file}")
+	}
+	return nil
+}
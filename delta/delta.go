@@ -0,0 +1,220 @@
+// Package delta tracks what each run sent for every item, so a run can
+// write a supplemental feed of only the items that changed since the last
+// one, plus tombstones for the items that disappeared, for channels that
+// take incremental updates.
+package delta
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/state"
+)
+
+// keyItems holds the Snapshot of the last successful run
+const keyItems = "delta_items"
+
+// Entry is what a run sent for one item
+type Entry struct {
+	AdID string `json:"ad_id"`
+	Hash string `json:"hash"`
+}
+
+// Snapshot is every item in the feed after a run, by item ID
+type Snapshot map[string]Entry
+
+// Load returns the snapshot saved by the last successful run, empty when
+// there is none
+func Load(ctx context.Context, s state.Store) (Snapshot, error) {
+	snapshot := Snapshot{}
+	_, err := s.Load(ctx, keyItems, &snapshot)
+	return snapshot, err
+}
+
+// Save stores snapshot for the next run
+func Save(ctx context.Context, s state.Store, snapshot Snapshot) error {
+	return s.Save(ctx, keyItems, snapshot)
+}
+
+// Hash fingerprints the feed fields of an item. The update time and the
+// Changed mark are left out, so an edit that changes nothing in the feed
+// does not make the item changed.
+func Hash(item input.AdItem) string {
+	item.UpdatedAt = time.Time{}
+	item.Changed = false
+	data, _ := json.Marshal(item)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Tombstone is an item to delete from the channel
+type Tombstone struct {
+	ID     string
+	AdID   string
+	Reason string // the skip reason, or Removed
+}
+
+// Removed is the tombstone reason of items that are no longer returned by
+// the source, because their ad was unpublished or sold or the variant was
+// deleted
+const Removed = "removed"
+
+// Tracker compares the items of a run against the last snapshot. It is safe
+// for concurrent use.
+type Tracker struct {
+	last Snapshot
+
+	mu      sync.Mutex
+	current Snapshot
+	seen    map[string]bool             // ads with at least one item this run
+	skipped map[string]input.SkipReason // ads left out this run
+}
+
+// NewTracker returns a tracker comparing against last
+func NewTracker(last Snapshot) *Tracker {
+	return &Tracker{
+		last:    last,
+		current: Snapshot{},
+		seen:    map[string]bool{},
+		skipped: map[string]input.SkipReason{},
+	}
+}
+
+// Sink returns a sink that marks each item Changed when it is new or
+// differs from the last run, records it, and writes it to every sink
+func (t *Tracker) Sink(sinks []pipeline.Sink) pipeline.Sink {
+	return &trackSink{tracker: t, sinks: sinks}
+}
+
+// Skip records an ad left out of this run, whose items from the last run
+// must be deleted
+func (t *Tracker) Skip(report input.SkipReport) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.skipped[report.AdID]; !ok {
+		t.skipped[report.AdID] = report.Reason
+	}
+}
+
+// record stores the item's entry, reporting whether it changed
+func (t *Tracker) record(item input.AdItem) bool {
+	entry := Entry{AdID: item.AdID, Hash: Hash(item)}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current[item.ID] = entry
+	t.seen[item.AdID] = true
+	last, ok := t.last[item.ID]
+	return !ok || last != entry
+}
+
+// Tombstones returns the items of the last run that are gone: those of ads
+// that were skipped, those of ads whose other items were sent, such as
+// deleted variants, and, when full is set because the run saw every
+// published ad, all the others that were not sent. Runs over a window of
+// recently updated ads cannot tell a removed ad from one that did not
+// change. Tombstones are sorted by ID.
+func (t *Tracker) Tombstones(full bool) []Tombstone {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var tombstones []Tombstone
+	for id, entry := range t.last {
+		if _, ok := t.current[id]; ok {
+			continue
+		}
+		// An ad can be skipped and still have items, e.g. a variant that
+		// failed a filter
+		reason, skipped := t.skipped[entry.AdID]
+		switch {
+		case skipped && !t.seen[entry.AdID]:
+			tombstones = append(tombstones, Tombstone{ID: id, AdID: entry.AdID, Reason: string(reason)})
+		case full, t.seen[entry.AdID]:
+			tombstones = append(tombstones, Tombstone{ID: id, AdID: entry.AdID, Reason: Removed})
+		}
+	}
+	slices.SortFunc(tombstones, func(a, b Tombstone) int { return strings.Compare(a.ID, b.ID) })
+	return tombstones
+}
+
+// Snapshot returns the items in the feed after this run: the ones it sent,
+// plus those of the last run that were neither sent again nor deleted
+func (t *Tracker) Snapshot(tombstones []Tombstone) Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := make(Snapshot, len(t.last)+len(t.current))
+	for id, entry := range t.last {
+		snapshot[id] = entry
+	}
+	for _, ts := range tombstones {
+		delete(snapshot, ts.ID)
+	}
+	for id, entry := range t.current {
+		snapshot[id] = entry
+	}
+	return snapshot
+}
+
+// WriteTombstones writes the tombstones as CSV with an id, ad_id and reason
+// column
+func WriteTombstones(w io.Writer, tombstones []Tombstone) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "ad_id", "reason"}); err != nil {
+		return err
+	}
+	for _, ts := range tombstones {
+		if err := cw.Write([]string{ts.ID, ts.AdID, ts.Reason}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// trackSink records items before passing them on
+type trackSink struct {
+	tracker *Tracker
+	sinks   []pipeline.Sink
+}
+
+func (s *trackSink) Write(item input.AdItem) error {
+	item.Changed = s.tracker.record(item)
+	for _, sink := range s.sinks {
+		if err := sink.Write(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every sink, returning the first error
+func (s *trackSink) Close() error {
+	var err error
+	for _, sink := range s.sinks {
+		if closeErr := sink.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Abort aborts every sink, returning the first error
+func (s *trackSink) Abort() error {
+	var err error
+	for _, sink := range s.sinks {
+		if abortErr := pipeline.Abort(sink); err == nil {
+			err = abortErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+// Package diagnostics joins the issues Merchant Center reports on the
+// products it received back to the marketplace ads, writes them out for the
+// catalog team and keeps count of how many pulls in a row each ad was
+// disapproved, so ads that keep failing can be left out of later runs.
+package diagnostics
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/model/output/contentapi"
+	"go_data_fashion_accessories/state"
+)
+
+// keyDisapprovals holds the Disapprovals recorded by the last pulls
+const keyDisapprovals = "disapprovals"
+
+// Product is a product Merchant Center reported issues on, with the ad it
+// was made from
+type Product struct {
+	OfferID     string
+	AdID        string // "" when no current item has the offer ID
+	Title       string
+	Disapproved bool
+	Issues      []contentapi.ItemIssue
+}
+
+// Join returns the statuses that have issues, with the ad ID of their offer
+// ID in adIDs, disapproved products first and then by offer ID
+func Join(statuses []contentapi.ProductStatus, adIDs map[string]string) []Product {
+	var products []Product
+	for _, s := range statuses {
+		if len(s.Issues) == 0 {
+			continue
+		}
+		offer := s.OfferID()
+		products = append(products, Product{
+			OfferID:     offer,
+			AdID:        adIDs[offer],
+			Title:       s.Title,
+			Disapproved: s.Disapproved(),
+			Issues:      s.Issues,
+		})
+	}
+	slices.SortFunc(products, func(a, b Product) int {
+		if a.Disapproved != b.Disapproved {
+			if a.Disapproved {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.OfferID, b.OfferID)
+	})
+	return products
+}
+
+// WriteCSV writes one row per issue of every product
+func WriteCSV(w io.Writer, products []Product) error {
+	cw := csv.NewWriter(w)
+	header := []string{"offer_id", "ad_id", "title", "servability", "code", "attribute", "destination", "description", "detail", "documentation"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, p := range products {
+		for _, issue := range p.Issues {
+			row := []string{p.OfferID, p.AdID, p.Title, issue.Servability, issue.Code, issue.Attribute,
+				issue.Destination, issue.Description, issue.Detail, issue.Documentation}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Disapproval counts the pulls in a row an ad had a disapproved product in
+type Disapproval struct {
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Codes     []string  `json:"codes,omitempty"` // issue codes of the last pull
+}
+
+// Disapprovals are the ads disapproved in the last pulls, by ad ID
+type Disapprovals map[string]Disapproval
+
+// Load returns the disapprovals recorded so far, empty when there are none
+func Load(ctx context.Context, s state.Store) (Disapprovals, error) {
+	d := Disapprovals{}
+	_, err := s.Load(ctx, keyDisapprovals, &d)
+	return d, err
+}
+
+// Record updates the disapprovals with the statuses of a pull made at
+// pulled, with the ad ID of each offer ID in adIDs. Ads with a disapproved
+// product count one more pull; ads whose products all came back without
+// one are forgotten. Ads the pull did not list keep their count, as an ad
+// left out of the feed is no longer in Merchant Center.
+func Record(ctx context.Context, s state.Store, statuses []contentapi.ProductStatus, adIDs map[string]string, pulled time.Time) (Disapprovals, error) {
+	d, err := Load(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := map[string]bool{}
+	codes := map[string][]string{}
+	for _, status := range statuses {
+		adID := adIDs[status.OfferID()]
+		if adID == "" {
+			continue
+		}
+		listed[adID] = true
+		for _, issue := range status.Issues {
+			if issue.Servability == contentapi.Disapproved && !slices.Contains(codes[adID], issue.Code) {
+				codes[adID] = append(codes[adID], issue.Code)
+			}
+		}
+	}
+	for adID := range listed {
+		if _, ok := codes[adID]; !ok {
+			delete(d, adID)
+		}
+	}
+	for adID, c := range codes {
+		entry, ok := d[adID]
+		if !ok {
+			entry.FirstSeen = pulled.UTC()
+		}
+		entry.Count++
+		entry.LastSeen = pulled.UTC()
+		slices.Sort(c)
+		entry.Codes = c
+		d[adID] = entry
+	}
+	return d, s.Save(ctx, keyDisapprovals, d)
+}
+
+// Excluded reports whether an ad updated at updated should be left out of
+// the feed: it was disapproved in at least after pulls in a row and not
+// edited since the last of them
+func (d Disapprovals) Excluded(adID string, updated time.Time, after int) bool {
+	entry, ok := d[adID]
+	return ok && after > 0 && entry.Count >= after && !updated.After(entry.LastSeen)
+}
@@ -0,0 +1,290 @@
+// Package enrich fills in the descriptions of items whose ads have none, or
+// too short a one, through a pluggable Enricher such as a description
+// generation or translation service. Items are sent in batches at a limited
+// rate, and the descriptions are cached by ad so each one is asked for once
+// until the ad changes.
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/time/rate"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/state"
+)
+
+// keyCache holds the Cache of the last runs
+const keyCache = "enrichments"
+
+// Enricher writes descriptions for a batch of items. It returns one
+// description per item, in their order, "" for the items it has none for.
+type Enricher interface {
+	Enrich(ctx context.Context, items []input.AdItem) ([]string, error)
+}
+
+// HTTP is an Enricher calling the service described in config.Enrich
+type HTTP struct {
+	client   *http.Client
+	url      string
+	headers  map[string]string
+	language string
+}
+
+// NewHTTP returns an Enricher for the service at url, asking for
+// descriptions in language and sending headers with every request. A nil
+// client uses http.DefaultClient.
+func NewHTTP(client *http.Client, url string, headers map[string]string, language string) *HTTP {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTP{client: client, url: url, headers: headers, language: language}
+}
+
+// request is one item sent to the service
+type request struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Brand       string `json:"brand,omitempty"`
+	Description string `json:"description,omitempty"`
+	ProductType string `json:"product_type,omitempty"`
+	Language    string `json:"language"`
+}
+
+// Enrich implements Enricher
+func (e *HTTP) Enrich(ctx context.Context, items []input.AdItem) ([]string, error) {
+	body := struct {
+		Items []request `json:"items"`
+	}{Items: make([]request, len(items))}
+	for i, item := range items {
+		body.Items[i] = request{
+			ID:          item.ID,
+			Title:       item.Title,
+			Brand:       item.Brand,
+			Description: item.Description,
+			ProductType: item.ProductType,
+			Language:    e.language,
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("enriching %d items: %s", len(items), res.Status)
+	}
+	var answer struct {
+		Descriptions []string `json:"descriptions"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("enriching %d items: %w", len(items), err)
+	}
+	if len(answer.Descriptions) != len(items) {
+		return nil, fmt.Errorf("enriching %d items: got %d descriptions", len(items), len(answer.Descriptions))
+	}
+	return answer.Descriptions, nil
+}
+
+// Entry is the description written for one ad
+type Entry struct {
+	Hash        string    `json:"hash"` // of the item it was written for
+	Description string    `json:"description"`
+	Used        time.Time `json:"used"` // when a run last used it
+}
+
+// Cache holds the descriptions written so far, by cacheKey
+type Cache map[string]Entry
+
+// cacheKey identifies an item across runs: its ad ID and, as variants share
+// their ad, its item ID
+func cacheKey(item input.AdItem) string {
+	return item.AdID + " " + item.ID
+}
+
+// LoadCache returns the descriptions cached by the last runs, empty when
+// there are none
+func LoadCache(ctx context.Context, s state.Store) (Cache, error) {
+	c := Cache{}
+	_, err := s.Load(ctx, keyCache, &c)
+	return c, err
+}
+
+// hash identifies what an item's description was written from, so a
+// description is asked for again once the ad changes
+func hash(item input.AdItem) string {
+	sum := sha256.New()
+	for _, field := range []string{item.Title, item.Brand, item.Description, item.ProductType} {
+		sum.Write([]byte(field))
+		sum.Write([]byte{0})
+	}
+	return hex.EncodeToString(sum.Sum(nil))[:16]
+}
+
+// pending is an item waiting in a batch for its description
+type pending struct {
+	item input.AdItem
+	done chan string
+}
+
+// Batcher collects the items that need a description from the check
+// workers into batches for its Enricher. A batch is sent once it is full or
+// MaxWait after its first item. It is safe for concurrent use.
+type Batcher struct {
+	ctx      context.Context
+	enricher Enricher
+	cfg      config.Enrich
+	limiter  *rate.Limiter
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	batch []pending
+	timer *time.Timer
+	cache Cache
+}
+
+// NewBatcher returns a Batcher sending batches to enricher with ctx,
+// answering from cache first. A nil logger uses slog.Default().
+func NewBatcher(ctx context.Context, enricher Enricher, cfg config.Enrich, cache Cache, logger *slog.Logger) *Batcher {
+	return &Batcher{
+		ctx:      ctx,
+		enricher: enricher,
+		cfg:      cfg,
+		limiter:  rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), 1),
+		logger:   logging.OrDefault(logger),
+		cache:    cache,
+	}
+}
+
+// Keep fills in the item's description when it is too short and always
+// keeps the item, for a pipeline.Check. A description that cannot be
+// written leaves the item as it is.
+func (b *Batcher) Keep(ctx context.Context, item *input.AdItem) bool {
+	if utf8.RuneCountInString(item.Description) >= b.cfg.MinDescriptionLength {
+		return true
+	}
+	key := hash(*item)
+	b.mu.Lock()
+	if entry, ok := b.cache[cacheKey(*item)]; ok && entry.Hash == key {
+		entry.Used = time.Now().UTC()
+		b.cache[cacheKey(*item)] = entry
+		b.mu.Unlock()
+		if entry.Description != "" {
+			item.Description = entry.Description
+		}
+		return true
+	}
+	done := make(chan string, 1)
+	b.batch = append(b.batch, pending{item: *item, done: done})
+	if len(b.batch) >= b.cfg.BatchSize {
+		b.flushLocked()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.cfg.MaxWait.Duration, b.flush)
+	}
+	b.mu.Unlock()
+
+	select {
+	case description := <-done:
+		if description != "" {
+			item.Description = description
+		}
+	case <-ctx.Done():
+	}
+	return true
+}
+
+// flush sends the batch waiting for MaxWait
+func (b *Batcher) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushLocked()
+}
+
+// flushLocked sends the current batch on a goroutine of its own. b.mu must
+// be held.
+func (b *Batcher) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.batch) == 0 {
+		return
+	}
+	batch := b.batch
+	b.batch = nil
+	go b.send(batch)
+}
+
+// send asks the enricher for the descriptions of a batch and hands them to
+// the items waiting for them
+func (b *Batcher) send(batch []pending) {
+	items := make([]input.AdItem, len(batch))
+	for i, p := range batch {
+		items[i] = p.item
+	}
+	descriptions, err := b.enrich(items)
+	if err != nil {
+		b.logger.Warn("Error enriching items, keeping their descriptions", "items", len(items), "error", err)
+		for _, p := range batch {
+			p.done <- ""
+		}
+		return
+	}
+
+	now := time.Now().UTC()
+	b.mu.Lock()
+	for i, p := range batch {
+		b.cache[cacheKey(p.item)] = Entry{Hash: hash(p.item), Description: descriptions[i], Used: now}
+	}
+	b.mu.Unlock()
+	b.logger.Debug("Enriched items", "items", len(items))
+	for i, p := range batch {
+		p.done <- descriptions[i]
+	}
+}
+
+// enrich waits for the rate limit and calls the enricher
+func (b *Batcher) enrich(items []input.AdItem) ([]string, error) {
+	if err := b.limiter.Wait(b.ctx); err != nil {
+		return nil, err
+	}
+	return b.enricher.Enrich(b.ctx, items)
+}
+
+// Save stores the cache, forgetting the descriptions no run used in the
+// last CacheTTL
+func (b *Batcher) Save(ctx context.Context, s state.Store) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cutoff := time.Now().Add(-b.cfg.CacheTTL.Duration)
+	for id, entry := range b.cache {
+		if entry.Used.Before(cutoff) {
+			delete(b.cache, id)
+		}
+	}
+	return s.Save(ctx, keyCache, b.cache)
+}
@@ -0,0 +1,135 @@
+package feed
+
+import (
+	"fmt"
+	"net/url"
+
+	"go_data_fashion_accessories/model/input"
+)
+
+// FromAdItem returns the typed form of a legacy item. Empty links become
+// nil; links that do not parse are reported in a *ValidationError. The
+// availability and condition are taken as they are, for Validate to check.
+func FromAdItem(ad input.AdItem) (Item, error) {
+	var v validator
+	parse := func(field, link string) *url.URL {
+		if link == "" {
+			return nil
+		}
+		u, err := url.Parse(link)
+		if err != nil {
+			v.add(field, "%v", err)
+		}
+		return u
+	}
+
+	item := Item{
+		AdID:                  ad.AdID,
+		DraftID:               ad.DraftID,
+		SellerID:              ad.SellerID,
+		ID:                    ad.ID,
+		Title:                 ad.Title,
+		Description:           ad.Description,
+		Translations:          ad.Translations,
+		Link:                  parse("link", ad.Link),
+		ImageLink:             parse("image_link", ad.ImageLink),
+		Brand:                 ad.Brand,
+		Price:                 ad.Price,
+		Availability:          Availability(ad.Availability),
+		Quantity:              ad.Quantity,
+		CodeNumber:            ad.CodeNumber,
+		GTIN:                  ad.GTIN,
+		MPN:                   ad.MPN,
+		NoIdentifier:          ad.NoIdentifier,
+		Category:              ad.Category,
+		Feed:                  ad.Feed,
+		UpdatedAt:             ad.UpdatedAt,
+		Changed:               ad.Changed,
+		AuctionEnd:            ad.AuctionEnd,
+		StartingBid:           ad.StartingBid,
+		SalePrice:             ad.SalePrice,
+		SaleStart:             ad.SaleStart,
+		SaleEnd:               ad.SaleEnd,
+		Shipping:              ad.Shipping,
+		MinHandlingDays:       ad.MinHandlingDays,
+		MaxHandlingDays:       ad.MaxHandlingDays,
+		ItemGroupID:           ad.ItemGroupID,
+		Color:                 ad.Color,
+		Size:                  ad.Size,
+		Material:              ad.Material,
+		Gender:                ad.Gender,
+		AgeGroup:              ad.AgeGroup,
+		Condition:             Condition(ad.Condition),
+		Subcategory:           ad.Subcategory,
+		SubcategoryName:       ad.SubcategoryName,
+		GoogleProductCategory: ad.GoogleProductCategory,
+		ProductType:           ad.ProductType,
+		CustomLabels:          ad.CustomLabels,
+		Stores:                ad.Stores,
+		PromotionIDs:          ad.PromotionIDs,
+	}
+	if ad.AdditionalImageLinks != nil {
+		item.AdditionalImageLinks = make([]*url.URL, 0, len(ad.AdditionalImageLinks))
+	}
+	for i, link := range ad.AdditionalImageLinks {
+		if u := parse(fmt.Sprintf("additional_image_link[%d]", i), link); u != nil {
+			item.AdditionalImageLinks = append(item.AdditionalImageLinks, u)
+		}
+	}
+
+	if len(v.fields) > 0 {
+		return item, &ValidationError{ItemID: ad.ID, Fields: v.fields}
+	}
+	return item, nil
+}
+
+// AdItem returns the item as the legacy struct the pipeline and the output
+// formats take. Links come back in their escaped form.
+func (it Item) AdItem() input.AdItem {
+	return input.AdItem{
+		AdID:                  it.AdID,
+		DraftID:               it.DraftID,
+		SellerID:              it.SellerID,
+		ID:                    it.ID,
+		Title:                 it.Title,
+		Description:           it.Description,
+		Translations:          it.Translations,
+		Link:                  URLString(it.Link),
+		ImageLink:             URLString(it.ImageLink),
+		AdditionalImageLinks:  URLStrings(it.AdditionalImageLinks),
+		Brand:                 it.Brand,
+		Price:                 it.Price,
+		Availability:          string(it.Availability),
+		Quantity:              it.Quantity,
+		CodeNumber:            it.CodeNumber,
+		GTIN:                  it.GTIN,
+		MPN:                   it.MPN,
+		NoIdentifier:          it.NoIdentifier,
+		Category:              it.Category,
+		Feed:                  it.Feed,
+		UpdatedAt:             it.UpdatedAt,
+		Changed:               it.Changed,
+		AuctionEnd:            it.AuctionEnd,
+		StartingBid:           it.StartingBid,
+		SalePrice:             it.SalePrice,
+		SaleStart:             it.SaleStart,
+		SaleEnd:               it.SaleEnd,
+		Shipping:              it.Shipping,
+		MinHandlingDays:       it.MinHandlingDays,
+		MaxHandlingDays:       it.MaxHandlingDays,
+		ItemGroupID:           it.ItemGroupID,
+		Color:                 it.Color,
+		Size:                  it.Size,
+		Material:              it.Material,
+		Gender:                it.Gender,
+		AgeGroup:              it.AgeGroup,
+		Condition:             string(it.Condition),
+		Subcategory:           it.Subcategory,
+		SubcategoryName:       it.SubcategoryName,
+		GoogleProductCategory: it.GoogleProductCategory,
+		ProductType:           it.ProductType,
+		CustomLabels:          it.CustomLabels,
+		Stores:                it.Stores,
+		PromotionIDs:          it.PromotionIDs,
+	}
+}
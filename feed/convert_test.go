@@ -0,0 +1,103 @@
+package feed
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/money"
+)
+
+// valid is an item Validate accepts
+func valid() input.AdItem {
+	return input.AdItem{
+		ID:                   "a1",
+		Title:                "Gucci Marmont bag",
+		Description:          "Matelassé leather shoulder bag",
+		Link:                 "https://example.com/ads/a1?ref=feed",
+		ImageLink:            "https://cdn.example.com/a1.jpg",
+		AdditionalImageLinks: []string{"https://cdn.example.com/a1-2.jpg"},
+		Price:                money.Money{Minor: 120000, Currency: "AED"},
+		SalePrice:            money.Money{Minor: 99900, Currency: "AED"},
+		SaleStart:            time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		SaleEnd:              time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC),
+		Availability:         "in stock",
+		Condition:            "used",
+		PromotionIDs:         []string{"spring"},
+	}
+}
+
+func TestFromAdItemRoundTrip(t *testing.T) {
+	ad := valid()
+	it, err := FromAdItem(ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.Availability != InStock || it.Condition != Used || it.Link.Host != "example.com" {
+		t.Errorf("unexpected typed fields %+v", it)
+	}
+	if err := it.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if got := it.AdItem(); !reflect.DeepEqual(got, ad) {
+		t.Errorf("AdItem() =\n%+v\nwant\n%+v", got, ad)
+	}
+	if got, want := it.SalePriceEffectiveDate(), ad.SalePriceEffectiveDate(); got != want {
+		t.Errorf("SalePriceEffectiveDate() = %q, want %q", got, want)
+	}
+}
+
+func TestFromAdItemReportsBadLinks(t *testing.T) {
+	ad := valid()
+	ad.ImageLink = "https://cdn.example.com/%zz.jpg"
+	_, err := FromAdItem(ad)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("FromAdItem() = %v, want a *ValidationError", err)
+	}
+	if len(verr.Fields) != 1 || verr.Fields[0].Field != "image_link" {
+		t.Errorf("fields = %v, want image_link only", verr.Fields)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(*input.AdItem)
+		fields []string
+	}{
+		{"missing title", func(ad *input.AdItem) { ad.Title = " " }, []string{"title"}},
+		{"relative link", func(ad *input.AdItem) { ad.Link = "/ads/a1" }, []string{"link"}},
+		{"unknown availability", func(ad *input.AdItem) { ad.Availability = "sold" }, []string{"availability"}},
+		{"unknown condition", func(ad *input.AdItem) { ad.Condition = "mint" }, []string{"condition"}},
+		{"sale above price", func(ad *input.AdItem) { ad.SalePrice.Minor = 130000 }, []string{"sale_price"}},
+		{"sale ends before it starts", func(ad *input.AdItem) { ad.SaleEnd = ad.SaleStart }, []string{"sale_price_effective_date"}},
+		{"no price or image", func(ad *input.AdItem) {
+			ad.Price, ad.SalePrice, ad.ImageLink = money.Money{}, money.Money{}, ""
+		}, []string{"image_link", "price"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ad := valid()
+			tt.edit(&ad)
+			it, err := FromAdItem(ad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var verr *ValidationError
+			if !errors.As(it.Validate(), &verr) {
+				t.Fatalf("Validate() returned no *ValidationError")
+			}
+			var fields []string
+			for _, f := range verr.Fields {
+				fields = append(fields, f.Field)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
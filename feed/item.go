@@ -0,0 +1,142 @@
+// Package feed defines Item, the typed form of a feed item: prices carry
+// their currency, links are parsed URLs and availability and condition are
+// closed sets of values. Item.Validate reports every problem by field.
+// The pipeline passes input.AdItem between its stages; the exporters and
+// package validate convert each item with FromAdItem and work on the typed
+// form, and Item.AdItem converts back.
+package feed
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/money"
+)
+
+// Availability is the stock status of an item, as Google Merchant spells it
+type Availability string
+
+const (
+	InStock    Availability = "in stock"
+	OutOfStock Availability = "out of stock"
+	Preorder   Availability = "preorder"
+	Backorder  Availability = "backorder"
+)
+
+// Valid reports whether a is one of the known values
+func (a Availability) Valid() bool {
+	switch a {
+	case InStock, OutOfStock, Preorder, Backorder:
+		return true
+	}
+	return false
+}
+
+// Condition is the state of the product being sold
+type Condition string
+
+const (
+	New         Condition = "new"
+	Used        Condition = "used"
+	Refurbished Condition = "refurbished"
+)
+
+// Valid reports whether c is one of the known values
+func (c Condition) Valid() bool {
+	switch c {
+	case New, Used, Refurbished:
+		return true
+	}
+	return false
+}
+
+// Item is one product in a feed. It holds the same data as input.AdItem
+// with typed fields where the legacy struct uses text.
+type Item struct {
+	AdID         string // marketplace ad UUID; ID may be rekeyed for the feed
+	DraftID      string
+	SellerID     string
+	ID           string
+	Title        string
+	Description  string
+	Translations map[string]input.Text // by ISO 639-1 code
+	Link         *url.URL
+	ImageLink    *url.URL
+	Brand        string
+	Price        money.Money
+	Availability Availability
+	Quantity     *int // units left, nil when the ad does not say
+	CodeNumber   json.Number
+	GTIN         string // validated GTIN, empty when CodeNumber is not one
+	MPN          string
+	NoIdentifier bool
+	Category     string
+	Feed         string
+	UpdatedAt    time.Time
+	Changed      bool
+
+	AuctionEnd  time.Time
+	StartingBid money.Money
+
+	SalePrice money.Money // zero when the item is not on sale
+	SaleStart time.Time
+	SaleEnd   time.Time
+
+	Shipping        []input.Shipping
+	MinHandlingDays int
+	MaxHandlingDays int
+
+	AdditionalImageLinks []*url.URL
+
+	ItemGroupID string
+	Color       string
+	Size        string
+	Material    string
+	Gender      string
+	AgeGroup    string
+	Condition   Condition // empty when the ad does not say
+
+	Subcategory           string
+	SubcategoryName       string
+	GoogleProductCategory string
+	ProductType           string
+
+	CustomLabels [config.MaxCustomLabels]string
+
+	Stores []input.StoreStock
+
+	PromotionIDs []string
+}
+
+// SalePriceEffectiveDate returns the period of the item's sale as an ISO
+// 8601 interval, e.g. "2024-05-01T00:00:00Z/2024-05-08T00:00:00Z", or ""
+// unless both of its ends are known
+func (it Item) SalePriceEffectiveDate() string {
+	if it.SalePrice.IsZero() || it.SaleStart.IsZero() || it.SaleEnd.IsZero() {
+		return ""
+	}
+	return it.SaleStart.UTC().Format(time.RFC3339) + "/" + it.SaleEnd.UTC().Format(time.RFC3339)
+}
+
+// URLString returns u as text, or "" for nil
+func URLString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+// URLStrings returns links as text; nil stays nil
+func URLStrings(links []*url.URL) []string {
+	if links == nil {
+		return nil
+	}
+	out := make([]string, len(links))
+	for i, link := range links {
+		out[i] = URLString(link)
+	}
+	return out
+}
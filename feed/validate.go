@@ -0,0 +1,132 @@
+package feed
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go_data_fashion_accessories/money"
+)
+
+// FieldError is one problem with one field of an item. Field is named as
+// in the Google Merchant feed, e.g. image_link.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every field error found on an item
+type ValidationError struct {
+	ItemID string
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return fmt.Sprintf("item %s: %s", e.ItemID, strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the field errors, for errors.As
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Fields))
+	for i, f := range e.Fields {
+		errs[i] = f
+	}
+	return errs
+}
+
+// validator collects the field errors of one item
+type validator struct {
+	fields []*FieldError
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.fields = append(v.fields, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+	}
+}
+
+// url checks that u, if set, is an absolute http(s) URL
+func (v *validator) url(field string, u *url.URL) {
+	if u != nil && ((u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+		v.add(field, "must be an absolute http(s) URL")
+	}
+}
+
+// price checks that m, if set, is a positive amount in a known currency
+func (v *validator) price(field string, m money.Money) {
+	if m.IsZero() {
+		return
+	}
+	if m.Minor <= 0 {
+		v.add(field, "must be greater than zero")
+	}
+	if !money.ValidCurrency(m.Currency) {
+		v.add(field, "has invalid currency %q", m.Currency)
+	}
+}
+
+// Validate returns a *ValidationError listing every field that is missing
+// or invalid, or nil when the item is fit for a feed. The limits of the
+// channels, such as value lengths and GTIN check digits, are left to
+// package validate.
+func (it Item) Validate() error {
+	var v validator
+	v.required("id", it.ID)
+	v.required("title", it.Title)
+	v.required("description", it.Description)
+	if it.Link == nil {
+		v.add("link", "is required")
+	}
+	v.url("link", it.Link)
+	if it.ImageLink == nil {
+		v.add("image_link", "is required")
+	}
+	v.url("image_link", it.ImageLink)
+	for i, link := range it.AdditionalImageLinks {
+		v.url(fmt.Sprintf("additional_image_link[%d]", i), link)
+	}
+
+	if it.Price.IsZero() {
+		v.add("price", "is required")
+	}
+	v.price("price", it.Price)
+	v.price("starting_bid", it.StartingBid)
+	v.price("sale_price", it.SalePrice)
+	if !it.SalePrice.IsZero() && (it.SalePrice.Currency != it.Price.Currency || it.SalePrice.Minor >= it.Price.Minor) {
+		v.add("sale_price", "must be less than price")
+	}
+	if !it.SaleStart.IsZero() && !it.SaleEnd.IsZero() && !it.SaleEnd.After(it.SaleStart) {
+		v.add("sale_price_effective_date", "must end after it starts")
+	}
+
+	for i, option := range it.Shipping {
+		if option.Price.Minor < 0 || !money.ValidCurrency(option.Price.Currency) {
+			v.add(fmt.Sprintf("shipping[%d]", i), "has invalid price %s", option.Price)
+		}
+	}
+
+	if it.Availability == "" {
+		v.add("availability", "is required")
+	} else if !it.Availability.Valid() {
+		v.add("availability", "%q is not a known availability", it.Availability)
+	}
+	if it.Condition != "" && !it.Condition.Valid() {
+		v.add("condition", "%q is not a known condition", it.Condition)
+	}
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{ItemID: it.ID, Fields: v.fields}
+}
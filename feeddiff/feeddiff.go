@@ -0,0 +1,238 @@
+// Package feeddiff reads generated feed files back into their items and
+// compares two of them, to show what changed between runs before a feed is
+// pushed to a channel.
+package feeddiff
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Item holds the fields of one feed item by attribute name, without the g:
+// prefix. Repeated elements such as additional_image_link are joined with
+// commas, as in the CSV feed.
+type Item map[string]string
+
+// Feed is the items of one feed file by ID
+type Feed map[string]Item
+
+// Formats accepted by Read
+const (
+	FormatXML = "xml"
+	FormatCSV = "csv"
+)
+
+// Open reads the feed file at path, uncompressing it if its name ends in
+// .gz. An empty format is taken from the file's extension.
+func Open(path, format string) (Feed, error) {
+	name, gz := strings.CutSuffix(path, ".gz")
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(name), ".")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if gz {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	feed, err := Read(r, format)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return feed, nil
+}
+
+// Read decodes a feed in the given format
+func Read(r io.Reader, format string) (Feed, error) {
+	switch format {
+	case FormatXML:
+		return readXML(r)
+	case FormatCSV:
+		return readCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown feed format %q", format)
+	}
+}
+
+// readXML collects the child elements of every <item> in an RSS feed
+func readXML(r io.Reader) (Feed, error) {
+	feed := Feed{}
+	decoder := xml.NewDecoder(r)
+	var item Item
+	var field string
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return feed, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "item":
+				item = Item{}
+			case item != nil:
+				field = t.Name.Local
+				text.Reset()
+			}
+		case xml.CharData:
+			if field != "" {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case t.Name.Local == "item" && item != nil:
+				if err := feed.add(item); err != nil {
+					return nil, err
+				}
+				item = nil
+			case field != "":
+				if prev, ok := item[field]; ok {
+					item[field] = prev + "," + text.String()
+				} else {
+					item[field] = text.String()
+				}
+				field = ""
+			}
+		}
+	}
+}
+
+// readCSV reads a feed whose first row names the columns
+func readCSV(r io.Reader) (Feed, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	feed := Feed{}
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return feed, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		item := make(Item, len(header))
+		for i, column := range header {
+			if row[i] != "" {
+				item[column] = row[i]
+			}
+		}
+		if err := feed.add(item); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// add stores item under its ID
+func (f Feed) add(item Item) error {
+	id := item["id"]
+	if id == "" {
+		return errors.New("item without an id")
+	}
+	if _, ok := f[id]; ok {
+		return fmt.Errorf("item %s is listed twice", id)
+	}
+	f[id] = item
+	return nil
+}
+
+// Change is one field that differs between two versions of an item. Old or
+// New is empty when the field was added or removed.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Diff lists what changed from one feed to the next. IDs are sorted.
+type Diff struct {
+	Added   []string
+	Removed []string
+	Changed map[string][]Change // by item ID, fields sorted by name
+}
+
+// Compare returns the differences from before to after
+func Compare(before, after Feed) Diff {
+	d := Diff{Changed: map[string][]Change{}}
+	for id, item := range after {
+		prev, ok := before[id]
+		if !ok {
+			d.Added = append(d.Added, id)
+			continue
+		}
+		if changes := compareItems(prev, item); len(changes) > 0 {
+			d.Changed[id] = changes
+		}
+	}
+	for id := range before {
+		if _, ok := after[id]; !ok {
+			d.Removed = append(d.Removed, id)
+		}
+	}
+	slices.Sort(d.Added)
+	slices.Sort(d.Removed)
+	return d
+}
+
+// compareItems returns the fields that differ between two versions of an
+// item
+func compareItems(before, after Item) []Change {
+	var changes []Change
+	for field, value := range after {
+		if before[field] != value {
+			changes = append(changes, Change{Field: field, Old: before[field], New: value})
+		}
+	}
+	for field, value := range before {
+		if _, ok := after[field]; !ok {
+			changes = append(changes, Change{Field: field, Old: value})
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return strings.Compare(a.Field, b.Field) })
+	return changes
+}
+
+// ChangedIDs returns the IDs of the changed items, sorted
+func (d Diff) ChangedIDs() []string {
+	ids := make([]string, 0, len(d.Changed))
+	for id := range d.Changed {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// FieldCounts returns how many changed items each field changed on
+func (d Diff) FieldCounts() map[string]int {
+	counts := map[string]int{}
+	for _, changes := range d.Changed {
+		for _, c := range changes {
+			counts[c.Field]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,6 @@
+// Package feedpb holds the protobuf messages and gRPC service of the feed
+// server's API, generated from feed.proto with protoc-gen-go and
+// protoc-gen-go-grpc.
+package feedpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative feed.proto
@@ -0,0 +1,211 @@
+// Package golden renders a fixed set of items through every output format
+// and compares the results byte for byte with files checked in under
+// testdata/golden, so serializer regressions such as broken escaping show
+// up. The comparison runs with go test; after an intended change, rewrite
+// the files with "go test ./golden -update" and review their diff.
+package golden
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/model/output/jsonld"
+	"go_data_fashion_accessories/money"
+	"go_data_fashion_accessories/runner"
+)
+
+// DefaultDir holds the golden files, relative to the repository root
+const DefaultDir = "testdata/golden"
+
+// jsonldFile is the golden file of the JSON-LD documents, one per item
+const jsonldFile = "jsonld.json"
+
+// Items returns the canonical fixtures: a complete item, one full of
+// characters that need escaping, an auction, a variant and a bare item
+func Items() []input.AdItem {
+	updated := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	aed := func(minor int64) money.Money { return money.Money{Minor: minor, Currency: "AED"} }
+	soldOut := 0
+	return []input.AdItem{
+		{
+			AdID:                 "0b6f1d1e-0000-4000-8000-000000000001",
+			ID:                   "0b6f1d1e-0000-4000-8000-000000000001",
+			Title:                "Gucci Marmont Shoulder Bag",
+			Description:          "Matelassé leather shoulder bag with the double G hardware.",
+			Link:                 "https://example.com/ads/0b6f1d1e-0000-4000-8000-000000000001",
+			ImageLink:            "https://cdn.example.com/img/1.jpg",
+			AdditionalImageLinks: []string{"https://cdn.example.com/img/2.jpg", "https://cdn.example.com/img/3.jpg"},
+			Brand:                "Gucci",
+			Price:                aed(1250000),
+			SalePrice:            aed(999900),
+			SaleStart:            time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			SaleEnd:              time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC),
+			Shipping: []input.Shipping{
+				{Service: "Courier", Price: aed(2500), MinTransitDays: 1, MaxTransitDays: 3},
+				{Service: "Pickup", Price: aed(0)},
+			},
+			MinHandlingDays:       1,
+			MaxHandlingDays:       2,
+			CustomLabels:          [5]string{0: "luxury", 2: "new arrival"},
+			Availability:          "in stock",
+			CodeNumber:            "4006381333931",
+			GTIN:                  "4006381333931",
+			MPN:                   "443497-DTDIT-1000",
+			Category:              "accessories",
+			UpdatedAt:             updated,
+			Color:                 "Black",
+			Material:              "Leather",
+			Gender:                "female",
+			AgeGroup:              "adult",
+			Condition:             "new",
+			Subcategory:           "212818c2-5ae3-4a95-88c9-370b3b906df0",
+			SubcategoryName:       "Bags",
+			GoogleProductCategory: "3032",
+			ProductType:           "Fashion Accessories > Bags",
+			Stores: []input.StoreStock{
+				{StoreCode: "DXB-01", Quantity: 2, PickupMethod: "buy", PickupSLA: "same day"},
+				{StoreCode: "AUH-02", Quantity: 0},
+			},
+			PromotionIDs: []string{"SUMMER-BAGS"},
+		},
+		{
+			AdID:         "0b6f1d1e-0000-4000-8000-000000000002",
+			ID:           "0b6f1d1e-0000-4000-8000-000000000002",
+			Title:        `Dolce & Gabbana "Sicily" Bag <Limited>, 'Rare'`,
+			Description:  "Line one,\nline two;\ttabbed \"quoted\" text & more.\nحقيبة يد جلدية 👜 — ½ price?",
+			Link:         "https://example.com/ads/0b6f1d1e-0000-4000-8000-000000000002?ref=feed&utm_source=golden",
+			ImageLink:    "https://cdn.example.com/img/a b&c.jpg",
+			Brand:        "Dolce & Gabbana",
+			Price:        aed(99999),
+			Availability: "in stock",
+			NoIdentifier: true,
+			Category:     "accessories",
+			UpdatedAt:    updated,
+			Condition:    "used",
+			ProductType:  `Bags > "Totes" & Clutches`,
+		},
+		{
+			AdID:         "0b6f1d1e-0000-4000-8000-000000000003",
+			ID:           "0b6f1d1e-0000-4000-8000-000000000003",
+			Title:        "Rolex Datejust 36",
+			Description:  "Auction, bidding starts low.",
+			Link:         "https://example.com/ads/0b6f1d1e-0000-4000-8000-000000000003",
+			ImageLink:    "https://cdn.example.com/img/watch.jpg",
+			Brand:        "Rolex",
+			Price:        aed(4500000),
+			Availability: "in stock",
+			MPN:          "126234",
+			Category:     "accessories",
+			Feed:         input.FeedAuction,
+			UpdatedAt:    updated,
+			AuctionEnd:   time.Date(2024, 5, 8, 18, 0, 0, 0, time.UTC),
+			StartingBid:  aed(2000000),
+			Condition:    "used",
+		},
+		{
+			AdID:         "0b6f1d1e-0000-4000-8000-000000000004",
+			ID:           "0b6f1d1e-0000-4000-8000-000000000004-m",
+			Title:        "Silk Scarf",
+			Description:  "Printed silk twill scarf.",
+			Link:         "https://example.com/ads/0b6f1d1e-0000-4000-8000-000000000004",
+			ImageLink:    "https://cdn.example.com/img/scarf.jpg",
+			Brand:        "Hermès",
+			Price:        aed(185050),
+			Availability: "out of stock",
+			Quantity:     &soldOut,
+			Category:     "apparel",
+			UpdatedAt:    updated,
+			ItemGroupID:  "0b6f1d1e-0000-4000-8000-000000000004",
+			Color:        "Blue/Orange",
+			Size:         "M",
+			Material:     "Silk",
+			Gender:       "unisex",
+			AgeGroup:     "adult",
+			Condition:    "new",
+		},
+		{
+			AdID:         "0b6f1d1e-0000-4000-8000-000000000005",
+			ID:           "0b6f1d1e-0000-4000-8000-000000000005",
+			Title:        "Belt",
+			Link:         "https://example.com/ads/0b6f1d1e-0000-4000-8000-000000000005",
+			ImageLink:    "https://cdn.example.com/img/belt.jpg",
+			Price:        aed(5000),
+			Availability: "in stock",
+			NoIdentifier: true,
+		},
+	}
+}
+
+// Render returns what format writes for items
+func Render(format string, items []input.AdItem) ([]byte, error) {
+	f, err := runner.LookupFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	sink, err := f.New(&buf)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if err := sink.Write(item); err != nil {
+			return nil, fmt.Errorf("%s: %w", format, err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		return nil, fmt.Errorf("%s: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Files renders the fixtures in every format, by golden file name: the
+// format name with the extension of its default path
+func Files() (map[string][]byte, error) {
+	items := Items()
+	files := map[string][]byte{}
+	for _, format := range runner.FormatNames() {
+		data, err := Render(format, items)
+		if err != nil {
+			return nil, err
+		}
+		files[format+filepath.Ext(runner.Formats[format].DefaultPath)] = data
+	}
+
+	var docs bytes.Buffer
+	for _, item := range items {
+		it, err := feed.FromAdItem(item)
+		if err != nil {
+			return nil, fmt.Errorf("jsonld: %w", err)
+		}
+		doc, err := jsonld.Marshal(it)
+		if err != nil {
+			return nil, fmt.Errorf("jsonld: %w", err)
+		}
+		docs.Write(doc)
+		docs.WriteByte('\n')
+	}
+	files[jsonldFile] = docs.Bytes()
+	return files, nil
+}
+
+// Update writes the renderings to dir, replacing the golden files
+func Update(dir string) error {
+	files, err := Files()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"go_data_fashion_accessories/runner"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files from the current output")
+
+// dir is DefaultDir seen from this package
+var dir = filepath.Join("..", DefaultDir)
+
+func TestGolden(t *testing.T) {
+	files, err := Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := Update(dir); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("updated %d golden files in %s", len(files), dir)
+		return
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			want, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("%v; run go test ./golden -update to create it", err)
+			}
+			if got := files[name]; !bytes.Equal(want, got) {
+				t.Errorf("%s\nrerun with go test ./golden -update if the change is intended", firstDifference(want, got))
+			}
+		})
+	}
+}
+
+func TestEveryFormatHasAGoldenFile(t *testing.T) {
+	files, err := Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range runner.FormatNames() {
+		name := format + filepath.Ext(runner.Formats[format].DefaultPath)
+		if _, ok := files[name]; !ok {
+			t.Errorf("format %s is not rendered to %s", format, name)
+		}
+	}
+
+	// Files left behind by a removed or renamed format
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if _, ok := files[entry.Name()]; !ok {
+			t.Errorf("%s matches no format", entry.Name())
+		}
+	}
+}
+
+// firstDifference describes the first line where got differs from want.
+// Binary files such as Parquet are compared as one line per newline byte
+// too.
+func firstDifference(want, got []byte) string {
+	wantLines := strings.SplitAfter(string(want), "\n")
+	gotLines := strings.SplitAfter(string(got), "\n")
+	for i := 0; ; i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want %q\n  got  %q", i+1, w, g)
+		}
+	}
+}
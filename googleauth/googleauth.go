@@ -0,0 +1,54 @@
+// Package googleauth authenticates calls to Google APIs with a service
+// account key file, signing token requests locally with the JWT flow.
+package googleauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/jwt"
+)
+
+// defaultTokenURI is used for key files that do not name a token endpoint
+const defaultTokenURI = "https://oauth2.googleapis.com/token"
+
+// serviceAccount holds the fields of a Google service account key file
+// that are needed to sign token requests
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// Client returns an HTTP client that authorizes its requests with tokens
+// for scopes, issued to the service account in the key file at path.
+// Token requests and API calls both go through base.
+func Client(ctx context.Context, path string, base *http.Client, scopes ...string) (*http.Client, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var key serviceAccount
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("reading service account key %s: %w", path, err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("service account key %s has no client_email or private_key", path)
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = defaultTokenURI
+	}
+
+	jwtConfig := &jwt.Config{
+		Email:      key.ClientEmail,
+		PrivateKey: []byte(key.PrivateKey),
+		TokenURL:   key.TokenURI,
+		Scopes:     scopes,
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, base)
+	return jwtConfig.Client(ctx), nil
+}
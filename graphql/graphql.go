@@ -0,0 +1,248 @@
+// Package graphql is a minimal GraphQL over HTTP client. It posts an
+// operation with its variables as JSON and decodes the data, errors and
+// extensions of the response. Middleware can change requests and inspect
+// responses, e.g. to add persisted query hashes or record metrics.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Request is one GraphQL operation
+type Request struct {
+	Query         string
+	Variables     map[string]any
+	OperationName string         // needed when Query holds several operations
+	Extensions    map[string]any // sent as the request's extensions
+	Header        http.Header    // sent with the HTTP request
+}
+
+// NewRequest returns a request for query without variables
+func NewRequest(query string) *Request {
+	return &Request{Query: query, Variables: map[string]any{}, Header: http.Header{}}
+}
+
+// Var sets the variable name to value
+func (r *Request) Var(name string, value any) {
+	r.Variables[name] = value
+}
+
+// Response is the body of a GraphQL response
+type Response struct {
+	Data       json.RawMessage `json:"data"`
+	Errors     []Error         `json:"errors"`
+	Extensions map[string]any  `json:"extensions"`
+}
+
+// hasData reports whether the response carries data, which it may along
+// with errors
+func (r *Response) hasData() bool {
+	return len(r.Data) > 0 && string(r.Data) != "null"
+}
+
+// Location is a position in the query text
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error is one entry of the errors of a response
+type Error struct {
+	Message    string         `json:"message"`
+	Locations  []Location     `json:"locations"`
+	Path       []any          `json:"path"` // field names and list indexes
+	Extensions map[string]any `json:"extensions"`
+}
+
+func (e Error) Error() string {
+	msg := "graphql: " + e.Message
+	if len(e.Path) > 0 {
+		msg = fmt.Sprintf("graphql: %s: %s", e.PathString(), e.Message)
+	}
+	if code := e.Code(); code != "" {
+		msg += " (" + code + ")"
+	}
+	return msg
+}
+
+// PathString returns the path joined with dots, e.g. ads.3.attributes
+func (e Error) PathString() string {
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+	return strings.Join(path, ".")
+}
+
+// Index returns the list index following field at the start of the path,
+// e.g. 3 for ads.3.attributes and field ads
+func (e Error) Index(field string) (int, bool) {
+	if len(e.Path) < 2 || e.Path[0] != field {
+		return 0, false
+	}
+	// Indexes decode from JSON as float64
+	index, ok := e.Path[1].(float64)
+	return int(index), ok && index >= 0
+}
+
+// Code returns extensions.code, which Hasura sets to e.g. validation-failed
+// or access-denied
+func (e Error) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// ResponseError is returned by Run for a response with errors
+type ResponseError struct {
+	Errors []Error
+	// Partial is set when the response also had data, which was decoded
+	Partial bool
+}
+
+func (e *ResponseError) Error() string {
+	msg := e.Errors[0].Error()
+	if len(e.Errors) > 1 {
+		msg += fmt.Sprintf(" (and %d more errors)", len(e.Errors)-1)
+	}
+	return msg
+}
+
+// Unwrap returns the errors, so errors.As can find an Error
+func (e *ResponseError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Handler sends a request and returns its response
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Middleware wraps the handler that sends requests
+type Middleware func(next Handler) Handler
+
+// Client sends requests to one GraphQL endpoint
+type Client struct {
+	endpoint string
+	http     *http.Client
+	handler  Handler
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests through client instead of
+// http.DefaultClient
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.http = client
+	}
+}
+
+// WithMiddleware wraps every request in middleware, the first one outermost
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *Client) {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			c.handler = middleware[i](c.handler)
+		}
+	}
+}
+
+// WithHandler sends requests with handler instead of posting them to the
+// endpoint, e.g. to answer them from a recording. It must come before any
+// WithMiddleware.
+func WithHandler(handler Handler) Option {
+	return func(c *Client) {
+		c.handler = handler
+	}
+}
+
+// NewClient returns a client for endpoint
+func NewClient(endpoint string, opts ...Option) *Client {
+	c := &Client{endpoint: endpoint, http: http.DefaultClient}
+	c.handler = c.send
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Do sends req and returns its response, which may hold errors
+func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
+	return c.handler(ctx, req)
+}
+
+// Run sends req and decodes the data of the response into resp. A response
+// with errors gives a *ResponseError; if it had data too, resp is still
+// filled in and the error is Partial.
+func (c *Client) Run(ctx context.Context, req *Request, resp any) error {
+	res, err := c.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	if res.hasData() && resp != nil {
+		if err := json.Unmarshal(res.Data, resp); err != nil {
+			return fmt.Errorf("graphql: decoding data: %w", err)
+		}
+	}
+	if len(res.Errors) > 0 {
+		return &ResponseError{Errors: res.Errors, Partial: res.hasData()}
+	}
+	return nil
+}
+
+// send posts req to the endpoint
+func (c *Client) send(ctx context.Context, req *Request) (*Response, error) {
+	body, err := json.Marshal(struct {
+		Query         string         `json:"query"`
+		Variables     map[string]any `json:"variables,omitempty"`
+		OperationName string         `json:"operationName,omitempty"`
+		Extensions    map[string]any `json:"extensions,omitempty"`
+	}{req.Query, req.Variables, req.OperationName, req.Extensions})
+	if err != nil {
+		return nil, fmt.Errorf("graphql: encoding request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.Header {
+		httpReq.Header[name] = values
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+
+	res, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var response Response
+	decodeErr := json.Unmarshal(data, &response)
+	switch {
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		// Servers may answer a bad request with GraphQL errors
+		if decodeErr == nil && len(response.Errors) > 0 {
+			return &response, nil
+		}
+		return nil, fmt.Errorf("graphql: server returned %s", res.Status)
+	case decodeErr != nil:
+		return nil, fmt.Errorf("graphql: decoding response: %w", decodeErr)
+	case !response.hasData() && len(response.Errors) == 0:
+		return nil, errors.New("graphql: response has neither data nor errors")
+	}
+	return &response, nil
+}
@@ -0,0 +1,126 @@
+package graphql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// exchange is one recorded request and its response, a line of a recording
+type exchange struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables,omitempty"`
+	OperationName string         `json:"operationName,omitempty"`
+	Response      *Response      `json:"response"`
+}
+
+// Record returns middleware that writes every request and its response to
+// the file at path, one JSON object per line, replacing the file on the
+// first request. Headers are not recorded, and each of secrets is replaced
+// by REDACTED wherever it appears.
+func Record(path string, secrets ...string) Middleware {
+	var (
+		mu   sync.Mutex
+		file *os.File
+	)
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			res, err := next(ctx, req)
+			if err != nil {
+				return res, err
+			}
+
+			line, err := json.Marshal(exchange{req.Query, req.Variables, req.OperationName, res})
+			if err != nil {
+				return nil, fmt.Errorf("graphql: recording: %w", err)
+			}
+			line = redact(line, secrets)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if file == nil {
+				if file, err = os.Create(path); err != nil {
+					return nil, fmt.Errorf("graphql: recording: %w", err)
+				}
+			}
+			if _, err := file.Write(append(line, '\n')); err != nil {
+				return nil, fmt.Errorf("graphql: recording: %w", err)
+			}
+			return res, nil
+		}
+	}
+}
+
+// redact replaces the secrets in a JSON document, as they are escaped there
+func redact(data []byte, secrets []string) []byte {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		quoted, _ := json.Marshal(secret)
+		data = bytes.ReplaceAll(data, quoted[1:len(quoted)-1], []byte("REDACTED"))
+	}
+	return data
+}
+
+// Replay returns a handler that answers requests from a recording made by
+// Record at path instead of sending them. Each query gets its recorded
+// responses in order, whatever its variables, so a replayed run sees the
+// same pages as the recorded one even though its time window differs.
+func Replay(path string) Handler {
+	var (
+		once      sync.Once
+		loadErr   error
+		mu        sync.Mutex
+		responses map[string][]*Response // by query
+	)
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		once.Do(func() { responses, loadErr = loadRecording(path) })
+		if loadErr != nil {
+			return nil, loadErr
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		queue := responses[req.Query]
+		if len(queue) == 0 {
+			return nil, fmt.Errorf("graphql: %s has no recorded response left for the query", path)
+		}
+		responses[req.Query] = queue[1:]
+		return queue[0], nil
+	}
+}
+
+// loadRecording reads the responses of a recording by query
+func loadRecording(path string) (map[string][]*Response, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("graphql: replaying: %w", err)
+	}
+	defer file.Close()
+
+	responses := map[string][]*Response{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20) // a page of ads is one line
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e exchange
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("graphql: replaying %s line %d: %w", path, n, err)
+		}
+		if e.Response == nil {
+			return nil, fmt.Errorf("graphql: replaying %s line %d: no response", path, n)
+		}
+		responses[e.Query] = append(responses[e.Query], e.Response)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("graphql: replaying %s: %w", path, err)
+	}
+	return responses, nil
+}
@@ -0,0 +1,92 @@
+// Package httpclient builds HTTP clients from config.HTTP, with the proxy,
+// CA bundle, timeouts and keep-alive settings applied.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/retry"
+)
+
+// New returns a client with a transport of its own configured by cfg
+func New(cfg config.HTTP) (*http.Client, error) {
+	transport, err := Transport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.Timeout.Duration}, nil
+}
+
+// NewRetrying returns a client configured by cfg whose transport is wrapped
+// in retry.StatusTransport, for calls made through retry.Do. timeout bounds
+// each request when cfg.Timeout is unset.
+func NewRetrying(cfg config.HTTP, timeout time.Duration) (*http.Client, error) {
+	client, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = retry.StatusTransport{Base: client.Transport}
+	if client.Timeout == 0 {
+		client.Timeout = timeout
+	}
+	return client, nil
+}
+
+// Transport returns a transport configured by cfg
+func Transport(cfg config.HTTP) (*http.Transport, error) {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout.Duration,
+		KeepAlive: cfg.KeepAlive.Duration,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout.Duration,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout.Duration,
+		IdleConnTimeout:       cfg.IdleConnTimeout.Duration,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+	}
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if cfg.CAFile != "" {
+		roots, err := certPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	return transport, nil
+}
+
+// certPool returns the system roots with the certificates in the PEM file
+// at path added
+func certPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,185 @@
+// Package imagecheck verifies that feed image links can be fetched before
+// they are sent to Merchant Center, which disapproves items whose images are
+// broken or too small. Requests are rate limited and their results cached
+// by URL, so an image shared by several variants is only requested once.
+package imagecheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	// Formats image.DecodeConfig recognizes
+	_ "golang.org/x/image/webp"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// headerBytes is how much of an image is downloaded to read its
+// dimensions. JPEG files with large EXIF blocks may need more, in which case
+// the dimensions are left unknown rather than guessed.
+const headerBytes = 64 << 10
+
+// Result is the outcome of checking one image URL
+type Result struct {
+	Status int   // HTTP status, 0 when the request failed
+	Size   int64 // size of the whole image, -1 when the server did not say
+	Err    error // request error, or an unreadable image when decoding
+
+	// Width and Height are only set when the Checker decodes images and
+	// the header could be read
+	Width, Height int
+}
+
+// Options adjusts a Checker
+type Options struct {
+	PerSecond float64 // request limit, 0 for none
+	MinBytes  int64   // smaller images are reported as broken
+	// Decode downloads the start of each image to read its dimensions
+	// instead of only making a HEAD request
+	Decode bool
+}
+
+// Checker requests image URLs and reports the ones that are not usable
+type Checker struct {
+	client  *http.Client
+	limiter *rate.Limiter
+	opts    Options
+
+	mu    sync.Mutex
+	cache map[string]Result
+}
+
+// New returns a Checker. A nil client uses http.DefaultClient.
+func New(client *http.Client, opts Options) *Checker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	limit := rate.Inf
+	if opts.PerSecond > 0 {
+		limit = rate.Limit(opts.PerSecond)
+	}
+	return &Checker{
+		client:  client,
+		limiter: rate.NewLimiter(limit, 1),
+		opts:    opts,
+		cache:   map[string]Result{},
+	}
+}
+
+// Check requests url, or returns the result of an earlier check of it.
+// Only a cancelled ctx makes it return an error; failed requests are part of
+// the Result.
+func (c *Checker) Check(ctx context.Context, url string) (Result, error) {
+	c.mu.Lock()
+	r, ok := c.cache[url]
+	c.mu.Unlock()
+	if ok {
+		return r, nil
+	}
+
+	if err := c.limiter.Wait(ctx); err != nil {
+		return Result{}, err
+	}
+	if c.opts.Decode {
+		r = c.decode(ctx, url)
+	} else {
+		r = c.head(ctx, url)
+	}
+	if ctx.Err() != nil {
+		return Result{}, ctx.Err()
+	}
+
+	c.mu.Lock()
+	c.cache[url] = r
+	c.mu.Unlock()
+	return r, nil
+}
+
+// head makes a HEAD request for url
+func (c *Checker) head(ctx context.Context, url string) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return Result{Err: err}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Result{Err: err}
+	}
+	resp.Body.Close()
+	return Result{Status: resp.StatusCode, Size: resp.ContentLength}
+}
+
+// decode requests the first headerBytes of url and reads the image
+// dimensions from them. Servers that ignore the Range header are read up to
+// the same limit.
+func (c *Checker) decode(ctx context.Context, url string) Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", headerBytes-1))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer resp.Body.Close()
+
+	r := Result{Status: resp.StatusCode, Size: resp.ContentLength}
+	if resp.StatusCode == http.StatusPartialContent {
+		r.Status = http.StatusOK
+		r.Size = totalSize(resp.Header.Get("Content-Range"))
+	}
+	if r.Status != http.StatusOK {
+		return r
+	}
+
+	config, _, err := image.DecodeConfig(io.LimitReader(resp.Body, headerBytes))
+	switch {
+	case errors.Is(err, image.ErrFormat):
+		r.Err = errors.New("not a JPEG, PNG, GIF or WebP image")
+	case err == nil:
+		r.Width, r.Height = config.Width, config.Height
+	}
+	return r
+}
+
+// totalSize returns the complete length from a Content-Range header such as
+// "bytes 0-65535/1048576", or -1 when it is not known
+func totalSize(contentRange string) int64 {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// Problem describes why the image in r is not usable, or returns "" when it
+// is. Images whose dimensions are known must be at least minSide pixels on
+// each side.
+func (c *Checker) Problem(r Result, minSide int) string {
+	switch {
+	case r.Err != nil:
+		return r.Err.Error()
+	case r.Status < 200 || r.Status > 299:
+		return fmt.Sprintf("HTTP %d", r.Status)
+	case r.Size >= 0 && r.Size < c.opts.MinBytes:
+		return fmt.Sprintf("%d bytes, below the %d byte minimum", r.Size, c.opts.MinBytes)
+	case r.Width > 0 && (r.Width < minSide || r.Height < minSide):
+		return fmt.Sprintf("%dx%d, below the %dx%d minimum", r.Width, r.Height, minSide, minSide)
+	}
+	return ""
+}
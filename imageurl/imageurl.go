@@ -0,0 +1,62 @@
+// Package imageurl builds the links to ad images served through the image
+// proxy, escaping each part so the result is valid as-is in both XML and
+// CSV feeds.
+package imageurl
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+)
+
+// Builder turns the image file names stored on drafts into feed image links
+type Builder struct {
+	storage *url.URL
+	proxy   *url.URL // nil links straight to storage
+	width   int
+	quality int
+}
+
+// New returns a Builder for the image settings in cfg, which are expected
+// to have passed config.Validate
+func New(cfg config.Images) *Builder {
+	b := &Builder{width: cfg.Width, quality: cfg.Quality}
+	b.storage, _ = url.Parse(cfg.StorageURL)
+	if !cfg.DirectLinks {
+		b.proxy, _ = url.Parse(cfg.ProxyURL)
+	}
+	return b
+}
+
+// Build returns the link to image src of a draft, or "" when src is empty.
+// A src that is already an absolute URL is proxied as it is; otherwise it
+// is a file name under the draft's folder in storage and is percent-encoded,
+// so spaces and Arabic file names survive.
+func (b *Builder) Build(draftID, src string) string {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return ""
+	}
+
+	source, err := url.Parse(src)
+	if err != nil || !source.IsAbs() {
+		source = b.storage.JoinPath(url.PathEscape(draftID), "web", url.PathEscape(src))
+	}
+	if b.proxy == nil {
+		return source.String()
+	}
+
+	link := *b.proxy
+	query := link.Query()
+	query.Set("url", source.String())
+	if b.width > 0 {
+		query.Set("w", strconv.Itoa(b.width))
+	}
+	if b.quality > 0 {
+		query.Set("q", strconv.Itoa(b.quality))
+	}
+	link.RawQuery = query.Encode()
+	return link.String()
+}
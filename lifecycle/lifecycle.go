@@ -0,0 +1,250 @@
+// Package lifecycle keeps the history of every item in a SQL database,
+// Postgres or SQLite, so questions such as which products dropped out of the
+// feed this week and why can be answered with a query. The items table
+// holds each item's current state; item_events holds one row per item and
+// run in which it was added, changed price or availability, was removed or
+// came back.
+package lifecycle
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"go_data_fashion_accessories/model/input"
+)
+
+// Events recorded in item_events
+const (
+	EventAdded        = "added"
+	EventReturned     = "returned"
+	EventPrice        = "price_changed"
+	EventAvailability = "availability_changed"
+	EventRemoved      = "removed"
+)
+
+// Removed is the removal reason of items that are no longer returned by the
+// source, because their ad was unpublished or sold or the variant was
+// deleted
+const Removed = "removed"
+
+// schema creates the tables, in SQL both Postgres and SQLite accept
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS items (
+		id             TEXT PRIMARY KEY,
+		ad_id          TEXT NOT NULL,
+		seller_id      TEXT NOT NULL DEFAULT '',
+		title          TEXT NOT NULL,
+		price          TEXT NOT NULL,
+		availability   TEXT NOT NULL,
+		first_seen     TIMESTAMP NOT NULL,
+		last_seen      TIMESTAMP NOT NULL,
+		removed_at     TIMESTAMP,
+		removal_reason TEXT NOT NULL DEFAULT '',
+		removal_detail TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE TABLE IF NOT EXISTS item_events (
+		run_started TIMESTAMP NOT NULL,
+		item_id     TEXT NOT NULL,
+		ad_id       TEXT NOT NULL,
+		event       TEXT NOT NULL,
+		old_value   TEXT NOT NULL DEFAULT '',
+		new_value   TEXT NOT NULL DEFAULT '',
+		reason      TEXT NOT NULL DEFAULT '',
+		detail      TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS item_events_run ON item_events (run_started)`,
+	`CREATE INDEX IF NOT EXISTS item_events_item ON item_events (item_id)`,
+}
+
+// state is what the items table holds for an item in the feed
+type state struct {
+	AdID         string
+	SellerID     string
+	Title        string
+	Price        string
+	Availability string
+	Removed      bool
+}
+
+// removal is why the items of a skipped ad left the feed
+type removal struct {
+	reason input.SkipReason
+	detail string
+}
+
+// Recorder collects the items and skipped ads of a run, as a pipeline.Sink
+// and an OnSkip hook, and records them with Commit. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	items   map[string]state
+	seen    map[string]bool    // ads with at least one item this run
+	skipped map[string]removal // ads left out this run
+}
+
+// NewRecorder returns an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{items: map[string]state{}, seen: map[string]bool{}, skipped: map[string]removal{}}
+}
+
+// Write implements pipeline.Sink
+func (r *Recorder) Write(item input.AdItem) error {
+	price := item.Price
+	if !item.SalePrice.IsZero() {
+		price = item.SalePrice
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[item.ID] = state{
+		AdID:         item.AdID,
+		SellerID:     item.SellerID,
+		Title:        item.Title,
+		Price:        price.String(),
+		Availability: item.Availability,
+	}
+	r.seen[item.AdID] = true
+	return nil
+}
+
+// Close implements pipeline.Sink
+func (r *Recorder) Close() error { return nil }
+
+// Skip records an ad left out of the run, whose items leave the feed
+func (r *Recorder) Skip(report input.SkipReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.skipped[report.AdID]; !ok {
+		r.skipped[report.AdID] = removal{reason: report.Reason, detail: report.Detail}
+	}
+}
+
+// Commit records the run that started at started in db, in one
+// transaction. Items of the database that the run did not send are removed
+// when their ad was skipped, when other items of their ad were sent, or,
+// when full is set because the run saw every published ad, in any case.
+func (r *Recorder) Commit(ctx context.Context, db *sql.DB, started time.Time, full bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Written as text both databases read as a timestamp, which SQLite's
+	// date functions understand
+	at := started.UTC().Format(time.DateTime)
+
+	for _, stmt := range schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("lifecycle: creating tables: %w", err)
+		}
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("lifecycle: %w", err)
+	}
+	defer tx.Rollback()
+
+	last, err := load(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for id, item := range r.items {
+		if err := record(ctx, tx, at, id, item, last); err != nil {
+			return fmt.Errorf("lifecycle: recording item %s: %w", id, err)
+		}
+	}
+	for id, item := range last {
+		if _, ok := r.items[id]; ok || item.Removed {
+			continue
+		}
+		why, skipped := r.skipped[item.AdID]
+		switch {
+		case skipped && !r.seen[item.AdID]:
+		case full, r.seen[item.AdID]:
+			why = removal{reason: Removed}
+		default:
+			continue
+		}
+		if err := remove(ctx, tx, at, id, item, why); err != nil {
+			return fmt.Errorf("lifecycle: removing item %s: %w", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("lifecycle: %w", err)
+	}
+	return nil
+}
+
+// load returns the items table by item ID
+func load(ctx context.Context, tx *sql.Tx) (map[string]state, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, ad_id, seller_id, title, price, availability, removed_at IS NOT NULL FROM items`)
+	if err != nil {
+		return nil, fmt.Errorf("lifecycle: reading items: %w", err)
+	}
+	defer rows.Close()
+	items := map[string]state{}
+	for rows.Next() {
+		var id string
+		var s state
+		if err := rows.Scan(&id, &s.AdID, &s.SellerID, &s.Title, &s.Price, &s.Availability, &s.Removed); err != nil {
+			return nil, fmt.Errorf("lifecycle: reading items: %w", err)
+		}
+		items[id] = s
+	}
+	return items, rows.Err()
+}
+
+// record stores an item the run sent and the events that lead to its state
+func record(ctx context.Context, tx *sql.Tx, at string, id string, item state, last map[string]state) error {
+	before, ok := last[id]
+	var events [][3]string // event, old value, new value
+	switch {
+	case !ok:
+		events = append(events, [3]string{EventAdded, "", item.Price})
+	case before.Removed:
+		events = append(events, [3]string{EventReturned, "", item.Price})
+	}
+	if ok && before.Price != item.Price {
+		events = append(events, [3]string{EventPrice, before.Price, item.Price})
+	}
+	if ok && before.Availability != item.Availability {
+		events = append(events, [3]string{EventAvailability, before.Availability, item.Availability})
+	}
+	for _, e := range events {
+		if err := event(ctx, tx, at, id, item.AdID, e[0], e[1], e[2], removal{}); err != nil {
+			return err
+		}
+	}
+
+	if !ok {
+		_, err := tx.ExecContext(ctx, `INSERT INTO items
+			(id, ad_id, seller_id, title, price, availability, first_seen, last_seen)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $7)`,
+			id, item.AdID, item.SellerID, item.Title, item.Price, item.Availability, at)
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE items SET
+		ad_id = $2, seller_id = $3, title = $4, price = $5, availability = $6, last_seen = $7,
+		removed_at = NULL, removal_reason = '', removal_detail = ''
+		WHERE id = $1`,
+		id, item.AdID, item.SellerID, item.Title, item.Price, item.Availability, at)
+	return err
+}
+
+// remove marks an item as gone from the feed for the reason why
+func remove(ctx context.Context, tx *sql.Tx, at string, id string, item state, why removal) error {
+	if err := event(ctx, tx, at, id, item.AdID, EventRemoved, item.Price, "", why); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE items SET removed_at = $2, removal_reason = $3, removal_detail = $4 WHERE id = $1`,
+		id, at, string(why.reason), why.detail)
+	return err
+}
+
+// event adds a row to item_events, with the reason of removals
+func event(ctx context.Context, tx *sql.Tx, at string, id, adID, name, oldValue, newValue string, why removal) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO item_events
+		(run_started, item_id, ad_id, event, old_value, new_value, reason, detail)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		at, id, adID, name, oldValue, newValue, string(why.reason), why.detail)
+	return err
+}
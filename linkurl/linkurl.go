@@ -0,0 +1,100 @@
+// Package linkurl builds the product links of feed items from the link
+// template in the config, and tags them with the UTM parameters of the
+// channel they are sent to.
+package linkurl
+
+import (
+	"net/url"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+)
+
+// Builder fills in the link template
+type Builder struct {
+	template      string
+	locale        string
+	transliterate bool
+	maxSlug       int
+}
+
+// New returns a Builder for the link settings in cfg, which are expected to
+// have passed config.Validate
+func New(cfg config.Links) *Builder {
+	template := cfg.Template
+	if cfg.Locale == "" {
+		template = strings.ReplaceAll(template, "{locale}/", "")
+	}
+	return &Builder{
+		template:      template,
+		locale:        cfg.Locale,
+		transliterate: cfg.Slug == config.SlugTransliterate,
+		maxSlug:       cfg.MaxSlugLength,
+	}
+}
+
+// Build returns the link to an ad. Every value is escaped as a path
+// segment. A title that leaves no slug drops {slug} and one separator next
+// to it, so "/product/{slug}-{id}" becomes "/product/{id}".
+func (b *Builder) Build(adID, draftID, title string) string {
+	template := b.template
+	slug := Slug(title, b.transliterate, b.maxSlug)
+	if slug == "" {
+		template = withoutSlug(template)
+	}
+	return strings.NewReplacer(
+		"{id}", url.PathEscape(adID),
+		"{draft_id}", url.PathEscape(draftID),
+		"{slug}", url.PathEscape(slug),
+		"{locale}", url.PathEscape(b.locale),
+	).Replace(template)
+}
+
+// withoutSlug removes {slug} from template together with the separator
+// after it, or else the one before it
+func withoutSlug(template string) string {
+	for _, sep := range []string{"-", "_", "/"} {
+		template = strings.ReplaceAll(template, "{slug}"+sep, "")
+		template = strings.ReplaceAll(template, sep+"{slug}", "")
+	}
+	return strings.ReplaceAll(template, "{slug}", "")
+}
+
+// UTM returns the UTM parameters of the links of channel: its DefaultUTM
+// unless Links.NoAutoUTM is set, overridden by the channel's own.
+// Parameters with an empty value are left out.
+func UTM(cfg *config.Config, channel string) map[string]string {
+	params := map[string]string{}
+	if !cfg.Links.NoAutoUTM {
+		for k, v := range config.DefaultUTM[channel] {
+			params[k] = v
+		}
+	}
+	for k, v := range cfg.Channels[channel].UTM {
+		params[k] = v
+	}
+	for k, v := range params {
+		if v == "" {
+			delete(params, k)
+		}
+	}
+	return params
+}
+
+// Tag returns link with params set in its query, replacing any it already
+// has. Links that do not parse are returned as they are.
+func Tag(link string, params map[string]string) string {
+	if len(params) == 0 || link == "" {
+		return link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	query := u.Query()
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
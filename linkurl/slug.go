@@ -0,0 +1,79 @@
+package linkurl
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// transliterations spells Arabic letters and digits and accented Latin
+// letters in ASCII. Arabic diacritics and the hamza on its own are dropped.
+var transliterations = map[rune]string{
+	'ا': "a", 'أ': "a", 'إ': "i", 'آ': "a", 'ى': "a", 'ب': "b", 'ت': "t",
+	'ث': "th", 'ج': "j", 'ح': "h", 'خ': "kh", 'د': "d", 'ذ': "dh", 'ر': "r",
+	'ز': "z", 'س': "s", 'ش': "sh", 'ص': "s", 'ض': "d", 'ط': "t", 'ظ': "z",
+	'ع': "", 'غ': "gh", 'ف': "f", 'ق': "q", 'ك': "k", 'ل': "l", 'م': "m",
+	'ن': "n", 'ه': "h", 'ة': "a", 'و': "w", 'ؤ': "u", 'ي': "y", 'ئ': "e",
+	'ء': "", 'پ': "p", 'چ': "ch", 'ژ': "zh", 'ک': "k", 'گ': "g", 'ی': "y",
+	'٠': "0", '١': "1", '٢': "2", '٣': "3", '٤': "4",
+	'٥': "5", '٦': "6", '٧': "7", '٨': "8", '٩': "9",
+
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+	'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ì': "i", 'í': "i",
+	'î': "i", 'ï': "i", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o",
+	'ö': "o", 'ø': "o", 'œ': "oe", 'ù': "u", 'ú': "u", 'û': "u", 'ü': "u",
+	'ý': "y", 'ÿ': "y", 'ß': "ss",
+}
+
+// Slug returns title in lower case with every run of other characters than
+// letters and digits replaced by a single dash, e.g. "gucci-bag-co" for
+// "Gucci Bag & Co". With transliterate, Arabic and accented letters are
+// spelled in ASCII and letters of other scripts are dropped; otherwise
+// letters of any script are kept. The slug is cut at the last dash within
+// limit bytes, or at limit when it has none; 0 means no limit.
+func Slug(title string, transliterate bool, limit int) string {
+	var sb strings.Builder
+	dash := false
+	write := func(s string) {
+		if dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteString(s)
+		dash = false
+	}
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.Is(unicode.Mn, r), r == 'ـ': // diacritics and tatweel
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			write(string(r))
+		case transliterate:
+			if s, ok := transliterations[r]; ok {
+				if s != "" {
+					write(s)
+				}
+			} else {
+				dash = true
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			write(string(r))
+		default:
+			dash = true
+		}
+	}
+	return cut(sb.String(), limit)
+}
+
+// cut shortens slug to at most limit bytes, ending at a whole word when it can
+func cut(slug string, limit int) string {
+	if limit <= 0 || len(slug) <= limit {
+		return slug
+	}
+	end := limit
+	for end > 0 && !utf8.RuneStart(slug[end]) {
+		end--
+	}
+	if i := strings.LastIndexByte(slug[:end+1], '-'); i > 0 {
+		end = i
+	}
+	return slug[:end]
+}
@@ -2,110 +2,68 @@
 package main
 
 import (
+	"context"
 	"go_data_fashion_accessories/config"
-	"go_data_fashion_accessories/model/input"
-	"go_data_fashion_accessories/model/output"
-	"go_data_fashion_accessories/util"
-	"log"
-	"regexp"
-	"strconv"
-	"strings"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/runner"
+	"go_data_fashion_accessories/secrets"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
-// Function to calculate check digit for GTIN-13
-func calculateGTINCheckDigit(gtin string) string {
-	sum := 0
-	for i, r := range gtin {
-		digit := int(r - '0')
-		if i%2 == 0 {
-			sum += digit // Multiply odd position digits by 1
-		} else {
-			sum += digit * 3 // Multiply even position digits by 3
-		}
-	}
-	remainder := sum % 10
-	if remainder == 0 {
-		return "0"
-	}
-	return strconv.Itoa(10 - remainder)
+// Function to log an error and exit with a failure status
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
-// Function to ensure valid GTIN
-func ensureValidGTIN(gtin string) string {
-	for len(gtin) < 12 {
-		gtin = "0" + gtin
+func main() {
+	// Stop the run cleanly on Ctrl+C or when the runner is cancelled
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.Read("")
+	if err != nil {
+		fatal("Error loading config", err)
 	}
-	if len(gtin) == 12 {
-		gtin += calculateGTINCheckDigit(gtin)
+	if err := secrets.Apply(ctx, cfg); err != nil {
+		fatal("Error reading secrets", err)
 	}
-	return gtin
-}
-
-// Function to strip HTML tags from the description and clean up the text
-func cleanUpDescription(description string) string {
-	// Regex to match all HTML tags
-	re := regexp.MustCompile(`<[^>]*>`)
-	// Remove all HTML tags
-	cleaned := re.ReplaceAllString(description, "")
-	// Ensure proper punctuation between sentences
-	cleaned = strings.ReplaceAll(cleaned, ". ", ".")
-	cleaned = strings.ReplaceAll(cleaned, ".", ". ")
-	// Replace multiple spaces/newlines with a single space
-	cleaned = strings.TrimSpace(strings.Join(strings.Fields(cleaned), " "))
-
-	// Check description length (Google Merchant Center recommends at least 30 characters)
-	if len(cleaned) < 30 {
-		cleaned += " This product is of high quality and in stock."
+	if err := cfg.Validate(); err != nil {
+		fatal("Error loading config", err)
 	}
 
-	return cleaned
-}
+	logger := logging.New(os.Stderr, cfg.Log)
+	slog.SetDefault(logger)
 
-// Function to ensure proper encoding for special characters like &, <, and >
-func escapeSpecialCharacters(text string) string {
-	text = strings.ReplaceAll(text, "&", "&amp;")
-	text = strings.ReplaceAll(text, "<", "&lt;")
-	text = strings.ReplaceAll(text, ">", "&gt;")
-	return text
-}
-
-func main() {
-	cfg, err := config.LoadConfig()
+	// Write the Google Merchant feed and the Meta catalog, in every
+	// configured currency
+	formats := []string{"xml", "csv"}
+	sinks, err := runner.CreateSinks(ctx, cfg, formats, "")
 	if err != nil {
-		log.Fatalf("Error loading config: %v", err)
+		fatal("Error creating feed files", err)
 	}
 
-	ads, err := input.FetchAds(cfg.HasuraEndpoint, cfg.AdminSecret)
+	_, err = runner.Run(ctx, cfg, runner.Options{
+		Sinks:  sinks,
+		Store:  runner.DefaultStore(cfg),
+		Report: true,
+		Logger: logger,
+	})
+	runner.PushMetrics(ctx, cfg, logger)
 	if err != nil {
-		log.Fatalf("Error fetching ads: %v", err)
-	}
-
-	var outputAds []output.Item
-	for _, ad := range ads {
-		gtin := ad.CodeNumber.String()
-		validGTIN := ensureValidGTIN(gtin)
-
-		// Clean up the description before adding it to the output
-		cleanedDescription := cleanUpDescription(ad.Description)
-		cleanedDescription = escapeSpecialCharacters(cleanedDescription)
-
-		outputAds = append(outputAds, output.Item{
-			ID:           ad.CodeNumber.String(),
-			Title:        ad.Title,
-			Description:  cleanedDescription, // Use cleaned description here
-			Link:         ad.Link,
-			ImageLink:    ad.ImageLink,
-			Brand:        ad.Brand,
-			Price:        ad.Price,
-			Availability: ad.Availability,
-			GTIN:         validGTIN,
-		})
+		fatal("Error generating feeds", err)
 	}
 
-	err = util.GenerateXML(outputAds)
+	paths, err := runner.FeedPaths(cfg, formats, "")
 	if err != nil {
-		log.Fatalf("Error generating XML: %v", err)
+		fatal("Error listing feed files", err)
+	}
+	if err := runner.UploadFeeds(ctx, cfg, paths, logger); err != nil {
+		fatal("Error uploading feeds", err)
 	}
 
-	log.Println("Successfully generated XML file")
+	logger.Info("Successfully generated XML and CSV files")
 }
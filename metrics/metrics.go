@@ -0,0 +1,115 @@
+// Package metrics holds the Prometheus instruments for feed runs. They are
+// exposed at /metrics by the long-running modes and pushed to a Pushgateway
+// after one-shot runs.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const namespace = "feedgen"
+
+// Registry holds every feedgen metric along with the Go runtime and process
+// collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// AdsFetched counts raw ads received from the source
+	AdsFetched = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ads_fetched_total",
+		Help:      "Raw ads received from the ad source.",
+	})
+
+	// ItemsEmitted counts items written to the feeds
+	ItemsEmitted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_emitted_total",
+		Help:      "Items written to the output feeds.",
+	})
+
+	// ItemsSkipped counts ads left out of the feeds by skip reason
+	ItemsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_skipped_total",
+		Help:      "Ads left out of the feeds, by reason.",
+	}, []string{"reason"})
+
+	// FieldsTruncated counts item fields cut to their length limit
+	FieldsTruncated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fields_truncated_total",
+		Help:      "Item fields truncated to their length limit, by field.",
+	}, []string{"field"})
+
+	// GraphQLLatency observes the duration of each GraphQL request
+	GraphQLLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_request_duration_seconds",
+		Help:      "Latency of GraphQL requests to Hasura, by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	// RunDuration observes the duration of whole runs
+	RunDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of feed generation runs, by outcome.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"outcome"})
+
+	// LastSuccess is the Unix time of the last successful run
+	LastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time the last successful run finished.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		AdsFetched,
+		ItemsEmitted,
+		ItemsSkipped,
+		FieldsTruncated,
+		GraphQLLatency,
+		RunDuration,
+		LastSuccess,
+	)
+}
+
+// Outcome returns the outcome label for err
+func Outcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// ObserveRun records the duration and outcome of a finished run
+func ObserveRun(d time.Duration, err error) {
+	RunDuration.WithLabelValues(Outcome(err)).Observe(d.Seconds())
+	if err == nil {
+		LastSuccess.SetToCurrentTime()
+	}
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Push sends the registry to the Pushgateway at url under job through
+// client, replacing whatever the previous run of the job pushed
+func Push(ctx context.Context, client *http.Client, url, job string) error {
+	return push.New(url, job).Client(client).Gatherer(Registry).PushContext(ctx)
+}
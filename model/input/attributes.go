@@ -3,212 +3,646 @@ package input
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
-	"github.com/machinebox/graphql"
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/imageurl"
+	"go_data_fashion_accessories/linkurl"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/money"
 )
 
 // AdItem represents the structure for storing ad information
 type AdItem struct {
+	AdID         string // marketplace ad UUID; ID may be rekeyed for the feed
+	DraftID      string
+	SellerID     string // marketplace user ID of the seller, "" when the source does not say
 	ID           string
 	Title        string
 	Description  string
+	Translations map[string]Text // title and description in other languages than the form's, by ISO 639-1 code
 	Link         string
 	ImageLink    string
 	Brand        string
-	Price        string
+	Price        money.Money
 	Availability string
+	Quantity     *int        // units left, nil when the ad does not say
 	CodeNumber   json.Number // Handle GTIN as json.Number
+	GTIN         string      // validated GTIN, empty when CodeNumber is not one
+	MPN          string      // manufacturer part number, when the seller gave one
+	NoIdentifier bool        // sent with identifier_exists=no
+	Category     string      // FeedLabel of the item's category in the registry
+	Feed         string      // separate feed the item goes to, "" for the main feeds
+	UpdatedAt    time.Time   // when the ad was last edited, zero if the source does not say
+	Changed      bool        // new or different since the last run, set when delta feeds are enabled
+
+	AuctionEnd  time.Time   // when the auction closes, zero for other ads
+	StartingBid money.Money // opening bid of an auction
+
+	SalePrice money.Money // discounted price, zero when the item is not on sale
+	SaleStart time.Time   // when the sale starts, zero if it is not known
+	SaleEnd   time.Time   // when the sale ends, zero if it is not known
+
+	Shipping        []Shipping // delivery options, none when the ad lists none
+	MinHandlingDays int        // business days before dispatch, 0 when not known
+	MaxHandlingDays int
+
+	CustomLabels [config.MaxCustomLabels]string // custom_label_0 to 4, set by the labeling stage
+
+	AdditionalImageLinks []string // images after the first, at most MaxAdditionalImages
+
+	ItemGroupID string // parent ad ID, set on variant items
+	Color       string
+	Size        string
+	Material    string
+	Gender      string // male, female or unisex
+	AgeGroup    string // newborn, infant, toddler, kids or adult
+	Condition   string // new, used or refurbished
+
+	Subcategory           string // marketplace subcategory UUID
+	SubcategoryName       string
+	GoogleProductCategory string // Google product taxonomy ID or path
+	ProductType           string // merchant category path
+
+	Stores []StoreStock // stock of the ad in physical stores, set when Source.Inventory is enabled
+
+	PromotionIDs []string // specific_products promotions the item is in, set when Promotions are configured
+}
+
+// Ad types counted in Processor.AdTypes
+const (
+	AdTypeAuction = "auction"
+	AdTypeOther   = "other"
+)
+
+// Separate feeds an item can be routed to with AdItem.Feed
+const (
+	// FeedCashOnDelivery holds ads that only accept cash on delivery
+	FeedCashOnDelivery = "cod"
+	// FeedAuction holds auction ads
+	FeedAuction = "auction"
+)
+
+// MaxAdditionalImages is the number of images kept after the main one, the
+// most Google Merchant Center accepts
+const MaxAdditionalImages = 10
+
+// errZeroPrice is returned for prices of 0, which sellers enter for "call
+// me" listings and which would show as free on paid channels
+var errZeroPrice = errors.New("price is zero")
+
+// parsePrice reads a seller-entered price, rejecting zero
+func parsePrice(price, currency string) (money.Money, error) {
+	amount, err := money.Parse(price, currency)
+	if err == nil && amount.Minor == 0 {
+		return money.Money{}, errZeroPrice
+	}
+	return amount, err
 }
 
-// AdAttributes represents the structure of attributes for each ad
+// AdAttributes represents the structure of attributes for each ad. They are
+// read with ParseAttributes, which tolerates changes to the form.
 type AdAttributes struct {
-	StepsData []struct {
-		Name string `json:"name"`
-		Data struct {
-			ID struct {
-				ID    string `json:"id"`
-				Value string `json:"value"`
-			} `json:"id"`
-			InputSearchValue struct {
-				Value string `json:"value"`
-			} `json:"inputSearchValue"`
-			Values struct {
-				Brand  string `json:"brand"`
-				Price  string `json:"price"`
-				Images []struct {
-					Src string `json:"src"`
-				} `json:"images"`
-				AdType string `json:"ad_type"`
-			} `json:"values"`
-			PaymentMethods struct {
-				Data []struct {
-					Value string `json:"value"`
-				} `json:"data"`
-			} `json:"paymentMethods"`
-		} `json:"data"`
-	} `json:"stepsData"`
-}
-
-func FetchAds(endpoint, adminSecret string) ([]AdItem, error) {
-	client := graphql.NewClient(endpoint)
-
-	// GraphQL query with status, category, and payment method filter
-	req := graphql.NewRequest(`
-	query ($last24Hours: timestamptz!) {
-		ads(where: {
-			status: {_eq: "Published"},
-			category_id: {_eq: "e87e7959-03ef-4bd1-930d-4a96c5743108"},
-			updated_at: { _gte: $last24Hours }
-		}) {
-			id
-			draft_id
-			description
-			attributes
-			code_number
-		}
-	}
-`)
-
-	// Calculate the timestamp for the last 24 hours
-	last24Hours := time.Now().Add(-24 * time.Hour).Format(time.RFC3339)
-	req.Var("last24Hours", last24Hours)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Hasura-Admin-Secret", adminSecret)
-
-	var response struct {
-		Ads []struct {
-			ID          string          `json:"id"`
-			DraftID     string          `json:"draft_id"`
-			Description string          `json:"description"`
-			CodeNumber  json.Number     `json:"code_number"`
-			Attributes  json.RawMessage `json:"attributes"`
-		} `json:"ads"`
-	}
-
-	err := client.Run(context.Background(), req, &response)
-	if err != nil {
-		return nil, err
+	StepsData []Step `json:"stepsData"`
+}
+
+// Step is one step of the ad form, identified by Name
+type Step struct {
+	Name string   `json:"name"`
+	Data StepData `json:"data"`
+}
+
+// StepData holds the answers of a step; each step fills some of the fields
+type StepData struct {
+	ID               Option     `json:"id"`               // search_product: the subcategory
+	InputSearchValue Option     `json:"inputSearchValue"` // search_product: the title
+	Values           StepValues `json:"values"`           // product_detail
+	PaymentMethods   struct {
+		Data []Option `json:"data"`
+	} `json:"paymentMethods"` // delivery_and_payment_methods
+	DeliveryMethods struct {
+		Data []DeliveryMethod `json:"data"`
+	} `json:"deliveryMethods"` // delivery_and_payment_methods
+	HandlingTime HandlingTime `json:"handlingTime"` // delivery_and_payment_methods
+	Promotion                 // promotion: a discount on the price
+}
+
+// Option is a value picked or typed in the form, with its ID when it was
+// picked from a list
+type Option struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+// Image is an uploaded image file
+type Image struct {
+	Src string `json:"src"`
+}
+
+// StepValues are the product details entered in the product_detail step
+type StepValues struct {
+	Brand       string    `json:"brand"`
+	Price       string    `json:"price"`
+	Images      []Image   `json:"images"`
+	AdType      string    `json:"ad_type"`
+	MPN         string    `json:"mpn"`
+	ModelNumber string    `json:"model_number"`
+	Variants    []Variant `json:"variants"`
+	Color       string    `json:"color"`
+	Size        string    `json:"size"`
+	Material    string    `json:"material"`
+	Gender      string    `json:"gender"`
+	AgeGroup    string    `json:"age_group"`
+	Condition   string    `json:"condition"`
+	Quantity    string    `json:"quantity"` // units left
+	Stock       string    `json:"stock"`    // stock status, e.g. in_stock or preorder
+	SalePrice   string    `json:"sale_price"`
+
+	AuctionEndTime string `json:"auction_end_time"` // RFC 3339
+	StartingBid    string `json:"starting_bid"`
+
+	// Translations holds the title_<language> and description_<language>
+	// values, by language
+	Translations map[string]Text `json:"-"`
+}
+
+// RawAd is an ad row as stored in the marketplace database, before its
+// attributes are parsed. Every AdSource produces these.
+type RawAd struct {
+	ID          string          `json:"id"`
+	DraftID     string          `json:"draft_id"`
+	Description string          `json:"description"`
+	CodeNumber  json.Number     `json:"code_number"`
+	Attributes  json.RawMessage `json:"attributes"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	SellerID    string          `json:"user_id,omitempty"` // marketplace user ID of the seller
+
+	// Category of the ad, when the source reports it. Ads without one are
+	// placed in a category by their subcategory.
+	CategoryID string `json:"category_id,omitempty"`
+
+	// Only filled by single-ad lookups, which skip the server-side filters,
+	// and by fetches with AllStatuses. Ads in another status than the one
+	// fetched are skipped as Unpublished.
+	Status string `json:"status,omitempty"`
+
+	// SourceError is set by sources that got the ad along with an error
+	// about it, so some of its fields may be missing. It is skipped as
+	// Incomplete.
+	SourceError string `json:"-"`
+}
+
+// missingField returns the first field every ad needs that is null or
+// empty, or "" when it has them all
+func (ad RawAd) missingField() string {
+	switch {
+	case ad.ID == "":
+		return "id"
+	case len(ad.Attributes) == 0 || string(ad.Attributes) == "null":
+		return "attributes"
 	}
+	return ""
+}
 
-	var items []AdItem
-	auctionCount := 0
-	otherCount := 0
+// Processor turns raw ads into feed items one at a time, keeping the ones in
+// an allowed subcategory of their category that accept a qualifying payment
+// method. It is not safe for concurrent use.
+type Processor struct {
+	categories           config.CategoryRegistry
+	paymentMethods       map[string]bool // normalized by paymentKey
+	cashOnDeliveryMethod string
+	cashOnDelivery       string
+	auctions             string
+	outOfStock           string
+	missingGTIN          string
+	strict               bool // skip ads whose attributes do not match the expected form
+	status               string
+	currency             string
+	images               *imageurl.Builder
+	links                *linkurl.Builder
+	logger               *slog.Logger
 
-	// Process the attributes of each ad
-	for _, ad := range response.Ads {
-		var attrs AdAttributes
-		err := json.Unmarshal(ad.Attributes, &attrs)
-		if err != nil {
-			log.Printf("Error unmarshalling attributes for ad ID %s: %v", ad.ID, err)
-			continue
+	// AdTypes counts the processed ads by type: AdTypeAuction or
+	// AdTypeOther
+	AdTypes map[string]int
+
+	// Trace, if set, receives every decision Process makes about an ad
+	Trace func(Decision)
+}
+
+// NewProcessor returns a Processor for the categories registered in cfg. A
+// nil logger uses slog.Default().
+func NewProcessor(cfg *config.Config, logger *slog.Logger) *Processor {
+	methods := make(map[string]bool, len(cfg.Eligibility.PaymentMethods))
+	for _, method := range cfg.Eligibility.PaymentMethods {
+		methods[paymentKey(method)] = true
+	}
+	return &Processor{
+		categories:           cfg.CategoryRegistry(),
+		paymentMethods:       methods,
+		cashOnDeliveryMethod: paymentKey(cfg.Eligibility.CashOnDeliveryMethod),
+		cashOnDelivery:       cfg.Eligibility.CashOnDelivery,
+		auctions:             cfg.Eligibility.Auctions,
+		outOfStock:           cfg.Eligibility.OutOfStock,
+		missingGTIN:          cfg.MissingGTIN,
+		strict:               cfg.Source.StrictAttributes,
+		status:               cfg.Source.Filters.Status,
+		currency:             cfg.Currency,
+		images:               imageurl.New(cfg.Images),
+		links:                linkurl.New(cfg.Links),
+		logger:               logging.OrDefault(logger),
+		AdTypes:              map[string]int{},
+	}
+}
+
+// Process parses one raw ad into its feed items: one per variant when the
+// ad lists variants, otherwise one. When the ad is excluded from the feed it
+// returns a SkipReport saying why instead.
+func (p *Processor) Process(ad RawAd) ([]AdItem, *SkipReport) {
+	adTitle := ""
+	skip := func(reason SkipReason, detail string) ([]AdItem, *SkipReport) {
+		return nil, &SkipReport{AdID: ad.ID, DraftID: ad.DraftID, SellerID: ad.SellerID, Title: adTitle, Reason: reason, Detail: detail}
+	}
+
+	if ad.SourceError != "" {
+		p.trace("complete", false, "source error: %s", ad.SourceError)
+		return skip(Incomplete, ad.SourceError)
+	}
+	if field := ad.missingField(); field != "" {
+		p.trace("complete", false, "%s is missing", field)
+		return skip(Incomplete, field+" is missing")
+	}
+
+	if ad.Status != "" && p.status != "" && ad.Status != p.status {
+		p.trace("status", false, "status is %q", ad.Status)
+		return skip(Unpublished, ad.Status)
+	}
+
+	attrs, warnings, err := ParseAttributes(ad.Attributes, p.strict)
+	if err != nil {
+		p.trace("parse", false, "%v", err)
+		return skip(ParseError, err.Error())
+	}
+	for _, step := range attrs.StepsData {
+		if step.Name == "search_product" && adTitle == "" {
+			adTitle = strings.TrimSpace(step.Data.InputSearchValue.Value)
 		}
+	}
+	if len(warnings) > 0 && p.strict {
+		p.logger.Error("Attributes do not match the expected schema", logging.AdID, ad.ID, "fields", warnings)
+		p.trace("parse", false, "attributes do not match the expected schema: %s", strings.Join(warnings, "; "))
+		return skip(SchemaChanged, strings.Join(warnings, "; "))
+	}
+	if len(warnings) > 0 {
+		p.logger.Debug("Attributes do not match the expected form", logging.AdID, ad.ID, "warnings", warnings)
+		p.trace("parse", true, "attributes decoded with warnings: %s", strings.Join(warnings, "; "))
+	} else {
+		p.trace("parse", true, "attributes decoded")
+	}
 
-		// Check for specific subcategories
-		shouldInclude := false
-		allowedSubcategories := map[string]bool{
-			"212818c2-5ae3-4a95-88c9-370b3b906df0": true,
-			"456ceaaa-de4d-449f-8621-3af7253fe452": true,
-			"5feb2aa4-3361-401b-ab05-d0623bab291b": true,
-			"7685d106-a4dd-48ed-876b-4dd8116f114c": true,
-			"34991934-f9ef-457c-9824-c82dad366889": true,
-			"1c4df47a-e94a-49b4-aeea-1d77dc4f5458": true,
-			"e84fd5e8-c303-46db-b1c6-e493781aef40": true,
-			"63d47c2b-a5eb-4439-b45d-ccbaa4ca671a": true,
-			"73a17eb3-1686-40d6-bcce-edc5c69b5540": true,
+	// Check for specific subcategories
+	shouldInclude := false
+	subcategory, subcategoryName := "", ""
+	var category config.Category
+	for _, step := range attrs.StepsData {
+		if step.Name == "search_product" {
+			subcategory = step.Data.ID.ID
+			subcategoryName = step.Data.ID.Value
+			if category, shouldInclude = p.category(ad.CategoryID, subcategory); shouldInclude {
+				break
+			}
 		}
+	}
 
-		for _, step := range attrs.StepsData {
-			if step.Name == "search_product" {
-				if allowedSubcategories[step.Data.ID.ID] {
-					shouldInclude = true
-					break
+	if !shouldInclude {
+		p.trace("subcategory", false, "%q is not an allowed subcategory", subcategory)
+		return skip(DisallowedSubcategory, subcategory)
+	}
+	p.trace("subcategory", true, "%s is allowed in category %s", subcategory, category.ID)
+
+	adType := ""
+	price := ""
+	var auctionEndTime, startingBid string
+	var variants []Variant
+	var promo Promotion
+	var delivery []DeliveryMethod
+	var handling HandlingTime
+	salePrice := ""
+	qualifies, cashOnDelivery := false, false
+	var payments []string
+	for _, step := range attrs.StepsData {
+		if step.Name == "delivery_and_payment_methods" {
+			delivery, handling = step.Data.DeliveryMethods.Data, step.Data.HandlingTime
+			for _, payment := range step.Data.PaymentMethods.Data {
+				payments = append(payments, payment.Value)
+				switch key := paymentKey(payment.Value); {
+				case p.paymentMethods[key]:
+					qualifies = true
+				case key == p.cashOnDeliveryMethod:
+					cashOnDelivery = true
 				}
 			}
+		} else if step.Name == "product_detail" {
+			adType = step.Data.Values.AdType
+			price = step.Data.Values.Price
+			auctionEndTime = step.Data.Values.AuctionEndTime
+			startingBid = step.Data.Values.StartingBid
+			variants = step.Data.Values.Variants
+			salePrice = step.Data.Values.SalePrice
+		} else if step.Name == "promotion" {
+			promo = step.Data.Promotion
 		}
+	}
+	if promo.SalePrice == "" {
+		promo = Promotion{SalePrice: salePrice}
+	}
 
-		if !shouldInclude {
-			continue
+	isAuction := adType == AdTypeAuction
+	if isAuction {
+		p.AdTypes[AdTypeAuction]++
+	} else {
+		p.AdTypes[AdTypeOther]++
+	}
+
+	if isAuction && p.auctions == config.AuctionsExclude {
+		p.trace("ad_type", false, "auctions are excluded")
+		return skip(AuctionExcluded, "")
+	}
+	p.trace("ad_type", true, "%q", adType)
+
+	// Cash on delivery qualifies too unless excluded, possibly for its own feed
+	feed := ""
+	if !qualifies && cashOnDelivery && p.cashOnDelivery != config.CashOnDeliveryExclude {
+		qualifies = true
+		if p.cashOnDelivery == config.CashOnDeliverySeparate {
+			feed = FeedCashOnDelivery
+		}
+	}
+
+	// Auctions carry their end time and opening bid, which stands in for a
+	// missing price
+	var auctionEnd time.Time
+	var openingBid money.Money
+	if isAuction && qualifies {
+		var err error
+		if auctionEnd, openingBid, err = p.parseAuction(auctionEndTime, startingBid); err != nil {
+			p.trace("auction", false, "%v", err)
+			return skip(ParseError, err.Error())
+		}
+		if !auctionEnd.IsZero() && auctionEnd.Before(time.Now()) {
+			p.trace("auction", false, "ended at %s", auctionEnd.Format(time.RFC3339))
+			return skip(AuctionEnded, auctionEnd.Format(time.RFC3339))
+		}
+		p.trace("auction", true, "ends %s, starting bid %s", auctionEndTime, openingBid)
+		if strings.TrimSpace(price) == "" && !openingBid.IsZero() {
+			price = openingBid.Decimal()
+		}
+		if p.auctions == config.AuctionsSeparate {
+			feed = FeedAuction
+		}
+	}
+
+	// Process the ad if it accepts a qualifying payment method
+	if qualifies {
+		p.trace("payment", true, "accepts %s", strings.Join(payments, ", "))
+
+		amount, err := parsePrice(price, p.currency)
+		if err != nil {
+			p.trace("price", false, "%q: %v", price, err)
+			return skip(InvalidPrice, err.Error())
+		}
+		p.trace("price", true, "%s", amount)
+
+		// A promotion that cannot be read leaves the item at its price
+		discount, err := parseSale(promo, p.currency)
+		if err != nil {
+			p.trace("sale", false, "%v", err)
 		}
 
-		adType := ""
-		price := ""
-		hasOnlinePayment := false
+		// Extract title, brand, MPN, image src and descriptive attributes
+		title, brand, mpn, imageSrc := "", "", "", ""
+		var additionalSrcs []string
+		var color, size, material, gender, ageGroup, condition string
+		var stock, quantity string
+		var translations map[string]Text
 		for _, step := range attrs.StepsData {
-			if step.Name == "delivery_and_payment_methods" {
-				for _, payment := range step.Data.PaymentMethods.Data {
-					if payment.Value == "Online Payment" {
-						hasOnlinePayment = true
+			if step.Name == "search_product" {
+				title = step.Data.InputSearchValue.Value
+			} else if step.Name == "product_detail" {
+				brand = step.Data.Values.Brand
+				mpn = strings.TrimSpace(step.Data.Values.MPN)
+				if mpn == "" {
+					mpn = strings.TrimSpace(step.Data.Values.ModelNumber)
+				}
+				for _, image := range step.Data.Values.Images {
+					switch {
+					case strings.TrimSpace(image.Src) == "":
+					case imageSrc == "":
+						imageSrc = image.Src
+					case len(additionalSrcs) < MaxAdditionalImages:
+						additionalSrcs = append(additionalSrcs, image.Src)
 					}
 				}
-			} else if step.Name == "product_detail" {
-				adType = step.Data.Values.AdType
-				price = step.Data.Values.Price
+				values := step.Data.Values
+				color, size, material = values.Color, values.Size, values.Material
+				gender, ageGroup, condition = values.Gender, values.AgeGroup, values.Condition
+				stock, quantity = values.Stock, values.Quantity
+				translations = values.Translations
 			}
 		}
 
-		// Count ad types
-		if adType == "auction" {
-			auctionCount++
-		} else {
-			otherCount++
-		}
-
-		// If "Online Payment" is found and no "Auctions" in attributes, process the ad
-		if hasOnlinePayment {
-			// Extract title, brand, and image src from attributes
-			title, brand, imageSrc := "", "", ""
-			for _, step := range attrs.StepsData {
-				if step.Name == "search_product" {
-					title = step.Data.InputSearchValue.Value
-				} else if step.Name == "product_detail" {
-					brand = step.Data.Values.Brand
-					if len(step.Data.Values.Images) > 0 {
-						imageSrc = step.Data.Values.Images[0].Src
-					}
-				}
-			}
+		// Build the image proxy URL; XML escaping is left to the feed writer
+		imageSrc = p.images.Build(ad.DraftID, imageSrc)
+		var additionalImages []string
+		for _, src := range additionalSrcs {
+			additionalImages = append(additionalImages, p.images.Build(ad.DraftID, src))
+		}
+
+		// Build the AdItem
+		item := AdItem{
+			AdID:        ad.ID,
+			DraftID:     ad.DraftID,
+			SellerID:    ad.SellerID,
+			ID:          ad.ID,
+			Title:       title,
+			Description: ad.Description,
+			Link:        p.links.Build(ad.ID, ad.DraftID, title),
+			ImageLink:   imageSrc,
+
+			Translations: translations,
+
+			AdditionalImageLinks: additionalImages,
+
+			Brand:      brand,
+			Price:      amount,
+			Quantity:   parseQuantity(quantity),
+			CodeNumber: ad.CodeNumber,
+			MPN:        mpn,
+			Category:   category.FeedLabel,
+			Feed:       feed,
+			UpdatedAt:  ad.UpdatedAt,
+
+			AuctionEnd:  auctionEnd,
+			StartingBid: openingBid,
+
+			Shipping: p.shipping(delivery),
+
+			Color:    color,
+			Size:     size,
+			Material: material,
+			Gender:   gender,
+			AgeGroup: ageGroup,
+
+			Condition: condition,
 
-			// Ensure that `imageSrc` is properly formatted without encoding issues
-			if imageSrc != "" {
-				imageSrc = fmt.Sprintf(
-					"https://ayshei.com/_next/image?url=https://storage.ayshei.com/prod/public/drafts/%s/web/%s&amp;w=3840&amp;q=75",
-					ad.DraftID, imageSrc)
+			Subcategory:     subcategory,
+			SubcategoryName: subcategoryName,
+		}
+
+		item.MinHandlingDays, item.MaxHandlingDays = parseDays(handling.Min, handling.Max)
+
+		items := []AdItem{item}
+		if len(variants) > 0 {
+			items = p.expandVariants(item, variants)
+			if len(items) == 0 {
+				return skip(InvalidPrice, "no variant has a valid price")
 			}
+		}
 
-			// Skip items with empty CodeNumber
-			if ad.CodeNumber == "" {
-				log.Printf("Skipping ad %s due to missing code_number", ad.ID)
+		// Items with no stock left are listed as such unless excluded
+		stocked := items[:0]
+		for _, item := range items {
+			item.Availability = availability(stock, item.Quantity)
+			if item.Availability == AvailabilityOutOfStock && p.outOfStock == config.OutOfStockExclude {
+				p.trace("stock", false, "%s is out of stock", item.ID)
 				continue
 			}
+			p.trace("stock", true, "%s is %s", item.ID, item.Availability)
+			stocked = append(stocked, item)
+		}
+		if len(stocked) == 0 {
+			return skip(OutOfStock, "")
+		}
+		items = stocked
+		for i := range items {
+			p.applySale(&items[i], discount)
+		}
 
-			// Clean up description by removing U+200E character
-			description := strings.ReplaceAll(ad.Description, "\u200E", "")
+		// Items without a CodeNumber are skipped unless the policy lets them
+		// through with an MPN or with identifier_exists=no
+		kept := items[:0]
+		for _, item := range items {
+			if p.identify(&item) {
+				kept = append(kept, item)
+			}
+		}
+		if len(kept) == 0 {
+			if p.missingGTIN == config.MissingGTINSkip {
+				return skip(MissingGTIN, "")
+			}
+			return skip(MissingGTIN, "no MPN either")
+		}
+		return kept, nil
+	}
 
-			// Clean up title by removing '&' symbol
-			title = strings.ReplaceAll(title, "&", "")
+	if len(payments) == 0 {
+		p.trace("payment", false, "no payment methods listed")
+	} else {
+		p.trace("payment", false, "only accepts %s", strings.Join(payments, ", "))
+	}
+	return skip(NoOnlinePayment, "")
+}
 
-			// Build the AdItem
-			items = append(items, AdItem{
-				ID:           ad.ID,
-				Title:        title,
-				Description:  description,
-				Link:         fmt.Sprintf("https://ayshei.com/product/%s", ad.ID),
-				ImageLink:    imageSrc,
-				Brand:        brand,
-				Price:        price + " AED",
-				Availability: "in stock",
-				CodeNumber:   ad.CodeNumber,
-			})
+// parseAuction parses the end time and starting bid of an auction. Either
+// may be missing.
+func (p *Processor) parseAuction(endTime, startingBid string) (time.Time, money.Money, error) {
+	var end time.Time
+	if endTime = strings.TrimSpace(endTime); endTime != "" {
+		var err error
+		if end, err = time.Parse(time.RFC3339, endTime); err != nil {
+			return time.Time{}, money.Money{}, fmt.Errorf("auction_end_time %q: %w", endTime, err)
+		}
+	}
+	var bid money.Money
+	if strings.TrimSpace(startingBid) != "" {
+		var err error
+		if bid, err = money.Parse(startingBid, p.currency); err != nil {
+			return time.Time{}, money.Money{}, fmt.Errorf("starting_bid %q: %w", startingBid, err)
 		}
 	}
+	return end, bid, nil
+}
+
+// paymentKey normalizes a payment method name for comparison
+func paymentKey(method string) string {
+	return strings.ToLower(strings.TrimSpace(method))
+}
+
+// identify applies the missing GTIN policy to item, reporting whether it
+// stays in the feed
+func (p *Processor) identify(item *AdItem) bool {
+	if item.CodeNumber != "" {
+		p.trace("gtin", true, "%s: code_number %s present", item.ID, item.CodeNumber)
+		return true
+	}
+	switch {
+	case p.missingGTIN == config.MissingGTINSkip:
+		p.trace("gtin", false, "%s: no code_number and MissingGTIN is %q", item.ID, p.missingGTIN)
+		return false
+	case item.MPN != "":
+		p.trace("gtin", true, "%s: no code_number, identified by MPN %q", item.ID, item.MPN)
+		return true
+	case p.missingGTIN == config.MissingGTINNoIdentifier:
+		p.trace("gtin", true, "%s: no code_number or MPN, sent with identifier_exists=no", item.ID)
+		item.NoIdentifier = true
+		return true
+	default:
+		p.trace("gtin", false, "%s: no code_number or MPN", item.ID)
+		return false
+	}
+}
+
+// category returns the registered category of an ad in subcategory,
+// reporting whether there is one that allows the subcategory. Ads whose
+// source does not report a category ID are placed by subcategory alone.
+func (p *Processor) category(categoryID, subcategory string) (config.Category, bool) {
+	if categoryID == "" {
+		return p.categories.BySubcategory(subcategory)
+	}
+	category, ok := p.categories.ByID(categoryID)
+	return category, ok && category.Allows(subcategory)
+}
 
-	// Log counts of "auction" and other ad types
-	log.Printf("Total ads with ad_type 'auction': %d", auctionCount)
-	log.Printf("Total ads with other ad types: %d", otherCount)
+// trace reports a decision to p.Trace when it is set
+func (p *Processor) trace(step string, passed bool, format string, args ...any) {
+	if p.Trace != nil {
+		p.Trace(Decision{Step: step, Passed: passed, Detail: fmt.Sprintf(format, args...)})
+	}
+}
+
+// ProcessAds runs every raw ad through a Processor and returns the kept
+// items along with a report for each skipped ad. Cancelling ctx stops
+// processing between ads.
+func ProcessAds(ctx context.Context, cfg *config.Config, logger *slog.Logger, ads []RawAd) (FetchResult, error) {
+	var result FetchResult
+	processor := NewProcessor(cfg, logger)
+
+	for _, ad := range ads {
+		if err := ctx.Err(); err != nil {
+			return FetchResult{}, err
+		}
+		items, skipped := processor.Process(ad)
+		if skipped != nil {
+			result.Skipped = append(result.Skipped, *skipped)
+			continue
+		}
+		result.Items = append(result.Items, items...)
+	}
 
-	return items, nil
+	result.AdTypes = processor.AdTypes
+	return result, nil
 }
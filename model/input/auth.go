@@ -0,0 +1,102 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go_data_fashion_accessories/config"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// newHasuraClient returns the client used for Hasura requests, which
+// authenticates every request as configured in cfg.HasuraAuth
+func newHasuraClient(cfg *config.Config, client *http.Client) *http.Client {
+	base := newHTTPClient(client)
+	auth := &authTransport{base: base.Transport, role: cfg.HasuraAuth.Role}
+	switch a := cfg.HasuraAuth; {
+	case a.Mode != config.HasuraAuthJWT:
+		auth.adminSecret = cfg.AdminSecret
+	case a.Token != "":
+		auth.tokens = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: a.Token})
+	case a.TokenFile != "":
+		auth.tokens = &fileTokenSource{path: a.TokenFile}
+	default:
+		credentials := clientcredentials.Config{
+			ClientID:     a.ClientID,
+			ClientSecret: a.ClientSecret,
+			TokenURL:     a.TokenURL,
+			Scopes:       a.Scopes,
+		}
+		// Token requests go through the retrying transport too
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, base)
+		auth.tokens = credentials.TokenSource(ctx)
+	}
+	return &http.Client{Transport: auth, Timeout: base.Timeout}
+}
+
+// authTransport sets the Hasura authentication headers: the admin secret,
+// or a bearer token and the role to run as
+type authTransport struct {
+	base        http.RoundTripper
+	adminSecret string
+	role        string
+	tokens      oauth2.TokenSource // nil with the admin secret
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.tokens == nil {
+		req.Header.Set("X-Hasura-Admin-Secret", t.adminSecret)
+	} else {
+		token, err := t.tokens.Token()
+		if err != nil {
+			return nil, fmt.Errorf("getting Hasura token: %w", err)
+		}
+		token.SetAuthHeader(req)
+	}
+	if t.role != "" {
+		req.Header.Set("X-Hasura-Role", t.role)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// fileTokenSource reads the token from a file, again whenever the file is
+// modified, so a token rotated by another process is picked up
+type fileTokenSource struct {
+	path string
+
+	mu       sync.Mutex
+	token    *oauth2.Token
+	modified time.Time
+}
+
+func (s *fileTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if s.token != nil && info.ModTime().Equal(s.modified) {
+		return s.token, nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return nil, fmt.Errorf("%s is empty", s.path)
+	}
+	s.token = &oauth2.Token{AccessToken: token}
+	s.modified = info.ModTime()
+	return s.token, nil
+}
@@ -0,0 +1,190 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/graphql"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/metrics"
+	"go_data_fashion_accessories/retry"
+)
+
+// adByIDQuery selects one ad by primary key with none of the feed filters, so
+// the caller can see its status and category as well
+const adByIDQuery = `
+	query ($id: uuid!) {
+		ads_by_pk(id: $id) {
+			id
+			draft_id
+			description
+			attributes
+			code_number
+			updated_at
+			status
+			category_id
+			user_id
+		}
+	}
+`
+
+// firstCursor sorts before every UUID so the first page starts at the beginning
+const firstCursor = "00000000-0000-0000-0000-000000000000"
+
+// HasuraSource reads ads from the marketplace Hasura GraphQL API
+type HasuraSource struct {
+	cfg    *config.Config
+	logger *slog.Logger
+	client *graphql.Client
+}
+
+// NewHasuraSource returns a source for the endpoint and categories in cfg,
+// sending requests through client. A nil client uses the default transport
+// and a nil logger slog.Default(). Requests are recorded to Source.Record,
+// or answered from Source.Replay without being sent.
+func NewHasuraSource(cfg *config.Config, client *http.Client, logger *slog.Logger) *HasuraSource {
+	opts := []graphql.Option{graphql.WithHTTPClient(newHasuraClient(cfg, client))}
+	switch {
+	case cfg.Source.Replay != "":
+		opts = append(opts, graphql.WithHandler(graphql.Replay(cfg.Source.Replay)))
+	case cfg.Source.Record != "":
+		opts = append(opts, graphql.WithMiddleware(graphql.Record(cfg.Source.Record,
+			cfg.AdminSecret, cfg.HasuraAuth.Token, cfg.HasuraAuth.ClientSecret)))
+	}
+	return &HasuraSource{
+		cfg:    cfg,
+		logger: logging.OrDefault(logger),
+		client: graphql.NewClient(cfg.HasuraEndpoint, opts...),
+	}
+}
+
+// Fetch implements AdSource
+func (s *HasuraSource) Fetch(ctx context.Context, opts FetchOptions) (FetchResult, error) {
+	ads, err := collectRaw(ctx, s, opts)
+	if err != nil {
+		return FetchResult{}, err
+	}
+	return ProcessAds(ctx, s.cfg, s.logger, ads)
+}
+
+// Stream implements RawStreamer. It pages through the ads using the last ID
+// seen as the cursor, so only one page is held in memory at a time.
+func (s *HasuraSource) Stream(ctx context.Context, opts FetchOptions, out chan<- RawAd) error {
+	since := opts.since()
+
+	total, partialErrors := 0, 0
+	cursor := firstCursor
+	for page := 1; ; page++ {
+		fields := adFields
+		if opts.AllStatuses {
+			fields = append(slices.Clip(fields), "status")
+		}
+		req := adsQuery(s.cfg, since, opts.AllStatuses, cursor).request(fields...)
+
+		var response struct {
+			Ads []RawAd `json:"ads"`
+		}
+
+		err := retry.Do(ctx, s.logger, s.cfg.Retry, fmt.Sprintf("Fetching page %d", page), func() error {
+			return s.run(ctx, req, &response)
+		})
+		// A null ads list is a failed query, not partial data
+		var gqlErr *graphql.ResponseError
+		if errors.As(err, &gqlErr) && gqlErr.Partial && response.Ads != nil {
+			partialErrors += len(gqlErr.Errors)
+			if partialErrors > s.cfg.Source.MaxPartialErrors {
+				return fmt.Errorf("fetching page %d: %d errors with partial data, more than the %d tolerated: %w",
+					page, partialErrors, s.cfg.Source.MaxPartialErrors, err)
+			}
+			s.markPartial(response.Ads, gqlErr.Errors)
+			err = nil
+		}
+		if err != nil {
+			return fmt.Errorf("fetching page %d: %w", page, err)
+		}
+
+		total += len(response.Ads)
+		s.logger.Info("Fetched page", "page", page, "ads", len(response.Ads), "total", total)
+
+		if err := send(ctx, out, response.Ads); err != nil {
+			return err
+		}
+		if len(response.Ads) < s.cfg.PageSize {
+			return nil
+		}
+		if cursor = lastID(response.Ads); cursor == "" {
+			return fmt.Errorf("fetching page %d: no ad on the page has an id to continue from", page)
+		}
+	}
+}
+
+// markPartial logs the errors returned along with a page of ads and sets
+// the SourceError of the ads they point at, so those are skipped
+func (s *HasuraSource) markPartial(ads []RawAd, errs []graphql.Error) {
+	for _, e := range errs {
+		s.logger.Warn("GraphQL error with partial data", "error", e.Message, "code", e.Code(), "path", e.PathString())
+		if i, ok := e.Index("ads"); ok && i < len(ads) {
+			ads[i].SourceError = e.Error()
+		}
+	}
+}
+
+// lastID returns the ID of the last ad that has one, the cursor for the next
+// page
+func lastID(ads []RawAd) string {
+	for i := len(ads) - 1; i >= 0; i-- {
+		if ads[i].ID != "" {
+			return ads[i].ID
+		}
+	}
+	return ""
+}
+
+// FetchByID implements AdLookup
+func (s *HasuraSource) FetchByID(ctx context.Context, id string) (*RawAd, error) {
+	req := graphql.NewRequest(adByIDQuery)
+	req.Var("id", id)
+
+	var response struct {
+		Ad *RawAd `json:"ads_by_pk"`
+	}
+	err := retry.Do(ctx, s.logger, s.cfg.Retry, fmt.Sprintf("Fetching ad %s", id), func() error {
+		return s.run(ctx, req, &response)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching ad %s: %w", id, err)
+	}
+	return response.Ad, nil
+}
+
+// Ping implements Pinger with the smallest GraphQL query. A replayed
+// source has no backend to reach.
+func (s *HasuraSource) Ping(ctx context.Context) error {
+	if s.cfg.Source.Replay != "" {
+		return nil
+	}
+	var response struct {
+		Typename string `json:"__typename"`
+	}
+	return s.run(ctx, graphql.NewRequest("query { __typename }"), &response)
+}
+
+// run sends one GraphQL request, recording its latency
+func (s *HasuraSource) run(ctx context.Context, req *graphql.Request, resp any) error {
+	start := time.Now()
+	err := s.client.Run(ctx, req, resp)
+	metrics.GraphQLLatency.WithLabelValues(metrics.Outcome(err)).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// FetchAds pulls the last 24 hours of ads from Hasura. It is shorthand for
+// NewHasuraSource(cfg, nil, nil).Fetch with default options.
+func FetchAds(ctx context.Context, cfg *config.Config) (FetchResult, error) {
+	return NewHasuraSource(cfg, nil, nil).Fetch(ctx, FetchOptions{})
+}
@@ -0,0 +1,250 @@
+package input_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/graphql"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/retry"
+	"go_data_fashion_accessories/testutil"
+)
+
+// subcategory is the allowed subcategory of the fixtures
+const subcategory = "212818c2-5ae3-4a95-88c9-370b3b906df0"
+
+// newConfig returns a config pointed at fake, with pages of pageSize ads
+// and fast retries
+func newConfig(t *testing.T, fake *testutil.FakeHasura, pageSize int) *config.Config {
+	t.Helper()
+	cfg, err := config.Read(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Configure(cfg)
+	cfg.CategoryID = "e87e7959-03ef-4bd1-930d-4a96c5743108"
+	cfg.AllowedSubcategories = []string{subcategory}
+	cfg.PageSize = pageSize
+	cfg.Retry = config.Retry{MaxAttempts: 3, BaseDelay: config.Duration{Duration: time.Millisecond}, MaxDelay: config.Duration{Duration: time.Millisecond}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// ads returns n fixtures with IDs ad-01 to ad-n
+func ads(n int) []input.RawAd {
+	out := make([]input.RawAd, n)
+	for i := range out {
+		out[i] = testutil.Ad(fmt.Sprintf("ad-%02d", i+1), subcategory)
+	}
+	return out
+}
+
+// stream collects what the hasura source streams for cfg
+func stream(cfg *config.Config, opts input.FetchOptions) ([]input.RawAd, error) {
+	source := input.NewHasuraSource(cfg, nil, logging.Discard())
+	out := make(chan input.RawAd)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out)
+		errc <- source.Stream(context.Background(), opts, out)
+	}()
+	var got []input.RawAd
+	for ad := range out {
+		got = append(got, ad)
+	}
+	return got, <-errc
+}
+
+func ids(ads []input.RawAd) []string {
+	out := make([]string, len(ads))
+	for i, ad := range ads {
+		out[i] = ad.ID
+	}
+	return out
+}
+
+func TestHasuraSourcePagesByCursor(t *testing.T) {
+	fake := testutil.NewFakeHasura(t, ads(5)...)
+	cfg := newConfig(t, fake, 2)
+
+	got, err := stream(cfg, input.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ad-01", "ad-02", "ad-03", "ad-04", "ad-05"}
+	if !slices.Equal(ids(got), want) {
+		t.Errorf("streamed %v, want %v", ids(got), want)
+	}
+
+	// Each page continues after the last ID of the one before, and the
+	// short third page ends the stream
+	var cursors []any
+	for _, req := range fake.Requests() {
+		cursors = append(cursors, req.Variables["after"])
+		if req.Variables["limit"] != float64(2) {
+			t.Errorf("limit = %v, want 2", req.Variables["limit"])
+		}
+	}
+	if want := []any{"00000000-0000-0000-0000-000000000000", "ad-02", "ad-04"}; !slices.Equal(cursors, want) {
+		t.Errorf("cursors = %v, want %v", cursors, want)
+	}
+	fake.ExpectHeader(t, "X-Hasura-Admin-Secret", testutil.AdminSecret)
+}
+
+func TestHasuraSourceFullLastPage(t *testing.T) {
+	// A full last page is followed by an empty one
+	fake := testutil.NewFakeHasura(t, ads(4)...)
+	got, err := stream(newConfig(t, fake, 2), input.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || len(fake.Requests()) != 3 {
+		t.Errorf("streamed %d ads in %d requests, want 4 in 3", len(got), len(fake.Requests()))
+	}
+}
+
+func TestHasuraSourcePartialErrors(t *testing.T) {
+	partial := []graphql.Error{{
+		Message: "cannot read attributes",
+		Path:    []any{"ads", float64(1), "attributes"},
+	}}
+
+	t.Run("tolerated", func(t *testing.T) {
+		fake := testutil.NewFakeHasura(t, ads(3)...)
+		fake.Errors = partial
+		cfg := newConfig(t, fake, 10)
+		cfg.Source.MaxPartialErrors = 1
+
+		got, err := stream(cfg, input.FetchOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 3 {
+			t.Fatalf("streamed %d ads, want 3", len(got))
+		}
+		// The ad the error points at is marked, so it is skipped
+		for i, ad := range got {
+			if marked := ad.SourceError != ""; marked != (i == 1) {
+				t.Errorf("ad %s SourceError = %q", ad.ID, ad.SourceError)
+			}
+		}
+		_, skipped := input.NewProcessor(cfg, logging.Discard()).Process(got[1])
+		if skipped == nil || skipped.Reason != input.Incomplete {
+			t.Errorf("ad with a source error skipped as %+v, want %s", skipped, input.Incomplete)
+		}
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		fake := testutil.NewFakeHasura(t, ads(3)...)
+		fake.Errors = partial
+		cfg := newConfig(t, fake, 10)
+
+		_, err := stream(cfg, input.FetchOptions{})
+		var gqlErr *graphql.ResponseError
+		if !errors.As(err, &gqlErr) || !gqlErr.Partial {
+			t.Fatalf("error = %v, want a partial GraphQL error", err)
+		}
+		if len(fake.Requests()) != 1 {
+			t.Errorf("%d requests, want 1: GraphQL errors are not retried", len(fake.Requests()))
+		}
+	})
+}
+
+func TestHasuraSourceRetriesServerErrors(t *testing.T) {
+	t.Run("recovers", func(t *testing.T) {
+		fake := testutil.NewFakeHasura(t, ads(3)...)
+		fake.Status, fake.Failures = http.StatusServiceUnavailable, 2
+
+		got, err := stream(newConfig(t, fake, 10), input.FetchOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 3 || len(fake.Requests()) != 3 {
+			t.Errorf("streamed %d ads in %d requests, want 3 in 3", len(got), len(fake.Requests()))
+		}
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		fake := testutil.NewFakeHasura(t, ads(3)...)
+		fake.Status = http.StatusBadGateway
+
+		_, err := stream(newConfig(t, fake, 10), input.FetchOptions{})
+		var retryErr *retry.Error
+		var statusErr *retry.StatusError
+		if !errors.As(err, &retryErr) || retryErr.Attempts != 3 || !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+			t.Fatalf("error = %v, want a 502 after 3 attempts", err)
+		}
+		if len(fake.Requests()) != 3 {
+			t.Errorf("%d requests, want 3", len(fake.Requests()))
+		}
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		fake := testutil.NewFakeHasura(t, ads(3)...)
+		fake.Status = http.StatusBadRequest
+
+		if _, err := stream(newConfig(t, fake, 10), input.FetchOptions{}); err == nil {
+			t.Fatal("stream succeeded")
+		}
+		if len(fake.Requests()) != 1 {
+			t.Errorf("%d requests, want 1", len(fake.Requests()))
+		}
+	})
+}
+
+func TestHasuraSourceRejectedSecret(t *testing.T) {
+	fake := testutil.NewFakeHasura(t, ads(1)...)
+	cfg := newConfig(t, fake, 10)
+	cfg.AdminSecret = "wrong"
+
+	if _, err := stream(cfg, input.FetchOptions{}); err == nil {
+		t.Fatal("stream with a wrong admin secret succeeded")
+	}
+}
+
+func TestHasuraSourceSince(t *testing.T) {
+	fresh, stale := testutil.Ad("ad-01", subcategory), testutil.Ad("ad-02", subcategory)
+	stale.UpdatedAt = time.Now().Add(-72 * time.Hour)
+	fake := testutil.NewFakeHasura(t, fresh, stale)
+	cfg := newConfig(t, fake, 10)
+
+	got, err := stream(cfg, input.FetchOptions{Window: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids(got), []string{"ad-01"}) {
+		t.Errorf("streamed %v, want only ad-01", ids(got))
+	}
+	if got, err = stream(cfg, input.FetchOptions{FullRefresh: true}); err != nil || len(got) != 2 {
+		t.Errorf("full refresh streamed %v, %v; want both ads", ids(got), err)
+	}
+}
+
+func TestHasuraSourceFetch(t *testing.T) {
+	fake := testutil.NewFakeHasura(t,
+		testutil.Ad("ad-01", subcategory),
+		testutil.Ad("ad-02", "00000000-0000-4000-8000-000000000000"),
+	)
+	cfg := newConfig(t, fake, 10)
+
+	result, err := input.NewHasuraSource(cfg, nil, logging.Discard()).Fetch(context.Background(), input.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 1 || result.Items[0].AdID != "ad-01" {
+		t.Errorf("kept %+v, want ad-01", result.Items)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].AdID != "ad-02" || result.Skipped[0].Reason != input.DisallowedSubcategory {
+		t.Errorf("skipped %+v, want ad-02 as %s", result.Skipped, input.DisallowedSubcategory)
+	}
+}
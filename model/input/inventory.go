@@ -0,0 +1,72 @@
+package input
+
+import (
+	"context"
+	"fmt"
+
+	"go_data_fashion_accessories/retry"
+)
+
+// StoreStock is the stock of an ad in one physical store
+type StoreStock struct {
+	StoreCode    string `json:"store_code"`
+	Quantity     int    `json:"quantity"`
+	PickupMethod string `json:"pickup_method"` // buy, reserve, ship to store or not supported; "" when the store does not say
+	PickupSLA    string `json:"pickup_sla"`    // same day, next day, 2-day, ... multi-week; "" when the store does not say
+}
+
+// InventoryLookup is implemented by sources that can read the stock of
+// ads in physical stores with a secondary query
+type InventoryLookup interface {
+	LookupInventory(ctx context.Context, table string) (map[string][]StoreStock, error)
+}
+
+// inventoryRow is one row of the inventory table
+type inventoryRow struct {
+	AdID string `json:"ad_id"`
+	StoreStock
+}
+
+// LookupInventory implements InventoryLookup, reading every row of table a
+// page at a time and returning the stores of each ad by ad ID
+func (s *HasuraSource) LookupInventory(ctx context.Context, table string) (map[string][]StoreStock, error) {
+	stores := map[string][]StoreStock{}
+	for offset, page := 0, 1; ; page++ {
+		q := newQuery(table)
+		q.param("offset", "Int!", offset)
+		q.param("limit", "Int!", s.cfg.PageSize)
+		req := q.arg("order_by: [{ad_id: asc}, {store_code: asc}]").arg("offset: $offset").arg("limit: $limit").
+			request("ad_id", "store_code", "quantity", "pickup_method", "pickup_sla")
+
+		var response map[string][]inventoryRow
+		err := retry.Do(ctx, s.logger, s.cfg.Retry, fmt.Sprintf("Reading %s page %d", table, page), func() error {
+			return s.run(ctx, req, &response)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", table, err)
+		}
+		rows := response[table]
+		for _, row := range rows {
+			stores[row.AdID] = append(stores[row.AdID], row.StoreStock)
+		}
+		if len(rows) < s.cfg.PageSize {
+			s.logger.Info("Read store inventory", "table", table, "ads", len(stores))
+			return stores, nil
+		}
+		offset += len(rows)
+	}
+}
+
+// Inventory sets the store stock of items from a lookup made once per run
+type Inventory map[string][]StoreStock
+
+// LoadInventory reads the store stock of every ad from table
+func LoadInventory(ctx context.Context, lookup InventoryLookup, table string) (Inventory, error) {
+	return lookup.LookupInventory(ctx, table)
+}
+
+// Apply sets the item's Stores. It satisfies pipeline.Transformer.
+func (inv Inventory) Apply(item *AdItem) error {
+	item.Stores = inv[item.AdID]
+	return nil
+}
@@ -0,0 +1,48 @@
+package input
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Text is the title and description of an ad in one content language
+type Text struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// translationValue matches the product_detail values holding the title or
+// description in a language, such as title_ar
+var translationValue = regexp.MustCompile(`^(title|description)_([a-z]{2})$`)
+
+// addTranslation stores the value of a title_<language> or
+// description_<language> key in translations. Empty values are left out.
+func addTranslation(translations map[string]Text, key, value string) {
+	m := translationValue.FindStringSubmatch(key)
+	if m == nil {
+		return
+	}
+	if value = strings.TrimSpace(value); value == "" {
+		return
+	}
+	text := translations[m[2]]
+	if m[1] == "title" {
+		text.Title = value
+	} else {
+		text.Description = value
+	}
+	translations[m[2]] = text
+}
+
+// Localized returns the item with the title and description it has in
+// lang. Either one the item lacks in lang is kept as it is.
+func (ad AdItem) Localized(lang string) AdItem {
+	text := ad.Translations[lang]
+	if text.Title != "" {
+		ad.Title = text.Title
+	}
+	if text.Description != "" {
+		ad.Description = text.Description
+	}
+	return ad
+}
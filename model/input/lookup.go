@@ -0,0 +1,46 @@
+package input
+
+import (
+	"context"
+	"fmt"
+)
+
+// Decision is one check applied to an ad on its way into the feed
+type Decision struct {
+	Step   string `json:"step"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (d Decision) String() string {
+	verdict := "PASS"
+	if !d.Passed {
+		verdict = "FAIL"
+	}
+	return fmt.Sprintf("%s  %-12s %s", verdict, d.Step, d.Detail)
+}
+
+// AdLookup is implemented by sources that can fetch a single ad by ID. It
+// returns nil without an error when there is no such ad.
+type AdLookup interface {
+	FetchByID(ctx context.Context, id string) (*RawAd, error)
+}
+
+// LookupAd fetches the ad with the given ID from src, using FetchByID when
+// the source supports it and scanning every ad otherwise
+func LookupAd(ctx context.Context, src RawStreamer, id string) (*RawAd, error) {
+	if lookup, ok := src.(AdLookup); ok {
+		return lookup.FetchByID(ctx, id)
+	}
+
+	ads, err := collectRaw(ctx, src, FetchOptions{FullRefresh: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, ad := range ads {
+		if ad.ID == id {
+			return &ad, nil
+		}
+	}
+	return nil, nil
+}
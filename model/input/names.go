@@ -0,0 +1,113 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/retry"
+)
+
+// NameLookup is implemented by sources that can resolve IDs to names with a
+// secondary query. The result leaves out IDs that are not in table.
+type NameLookup interface {
+	LookupNames(ctx context.Context, table string, ids []string) (map[string]string, error)
+}
+
+// LookupNames implements NameLookup, selecting the id and name columns of
+// table
+func (s *HasuraSource) LookupNames(ctx context.Context, table string, ids []string) (map[string]string, error) {
+	req := newQuery(table).where("id", "_in", "ids", "[uuid!]!", ids).request("id", "name")
+
+	var response map[string][]struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	err := retry.Do(ctx, s.logger, s.cfg.Retry, fmt.Sprintf("Looking up %s", table), func() error {
+		return s.run(ctx, req, &response)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s: %w", table, err)
+	}
+	names := make(map[string]string, len(ids))
+	for _, row := range response[table] {
+		names[row.ID] = row.Name
+	}
+	return names, nil
+}
+
+// uuidPattern matches brand values that are IDs rather than names
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Names resolves the subcategory and brand IDs of items to their names
+// through a NameLookup. Each ID is looked up once per run; IDs without a
+// name keep the item's value.
+type Names struct {
+	ctx    context.Context
+	lookup NameLookup
+	cfg    config.Names
+	logger *slog.Logger
+	cache  map[string]map[string]string // by table, then ID; "" when unknown
+}
+
+// NewNames returns a resolver that looks names up with ctx. A nil logger
+// uses slog.Default().
+func NewNames(ctx context.Context, lookup NameLookup, cfg config.Names, logger *slog.Logger) *Names {
+	return &Names{
+		ctx:    ctx,
+		lookup: lookup,
+		cfg:    cfg,
+		logger: logging.OrDefault(logger),
+		cache:  map[string]map[string]string{},
+	}
+}
+
+// Apply sets the item's subcategory name, from which the taxonomy builds
+// product_type, and replaces a brand ID with the brand's name. It satisfies
+// pipeline.Transformer.
+func (n *Names) Apply(item *AdItem) error {
+	if item.Subcategory != "" {
+		name, err := n.name(n.cfg.SubcategoryTable, item.Subcategory)
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			item.SubcategoryName = name
+		}
+	}
+	if uuidPattern.MatchString(item.Brand) {
+		name, err := n.name(n.cfg.BrandTable, item.Brand)
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			item.Brand = name
+		}
+	}
+	return nil
+}
+
+// name returns the name of id in table, or "" when it has none
+func (n *Names) name(table, id string) (string, error) {
+	cached := n.cache[table]
+	if cached == nil {
+		cached = map[string]string{}
+		n.cache[table] = cached
+	}
+	if name, ok := cached[id]; ok {
+		return name, nil
+	}
+
+	names, err := n.lookup.LookupNames(n.ctx, table, []string{id})
+	if err != nil {
+		return "", err
+	}
+	cached[id] = names[id]
+	if names[id] == "" {
+		n.logger.Debug("No name found", "table", table, "id", id)
+	}
+	return names[id], nil
+}
@@ -0,0 +1,307 @@
+package input
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// knownSteps are the form steps the feed reads; the promotion step is
+// optional
+var knownSteps = []string{"search_product", "product_detail", "delivery_and_payment_methods"}
+
+// knownValues are the product_detail values the feed reads or knowingly
+// ignores
+var knownValues = map[string]bool{
+	"brand": true, "price": true, "images": true, "ad_type": true, "mpn": true, "model_number": true,
+	"variants": true, "color": true, "size": true, "material": true, "gender": true, "age_group": true,
+	"condition": true, "auction_end_time": true, "starting_bid": true, "quantity": true, "stock": true,
+	"sale_price": true,
+}
+
+// rootFields and stepFields are the keys of the attributes object and of
+// each step
+var (
+	rootFields = []string{"stepsData"}
+	stepFields = []string{"name", "data"}
+)
+
+// ParseAttributes decodes the attributes of an ad without failing on
+// changes to the form. The steps are walked as generic JSON and each field
+// is extracted on its own: numbers are accepted for text, a single object
+// for a list and a plain string for an image. Fields of any other
+// unexpected type, unknown steps and values, and missing steps are reported
+// as warnings while the rest is still extracted. In strict mode unknown
+// keys of the attributes object and of each step are reported too. Only
+// attributes that are not a JSON object are an error.
+func ParseAttributes(raw json.RawMessage, strict bool) (AdAttributes, []string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return AdAttributes{}, nil, err
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return AdAttributes{}, nil, errors.New("attributes are not an object")
+	}
+
+	w := attrWalker{strict: strict}
+	w.unknownFields(root, "attributes", rootFields)
+	var attrs AdAttributes
+	seen := map[string]bool{}
+	for i, s := range w.list(root, "stepsData", "") {
+		path := fmt.Sprintf("stepsData[%d]", i)
+		obj, ok := s.(map[string]any)
+		if !ok {
+			w.warnf("%s is %s, not an object", path, typeName(s))
+			continue
+		}
+		step := Step{Name: w.str(obj, "name", path)}
+		if step.Name != "" {
+			path = step.Name
+		}
+		w.unknownFields(obj, path, stepFields)
+		data := w.object(obj, "data", path)
+		path += ".data"
+
+		switch step.Name {
+		case "search_product":
+			step.Data.ID = w.option(data, "id", path)
+			step.Data.InputSearchValue = w.option(data, "inputSearchValue", path)
+		case "product_detail":
+			step.Data.Values = w.values(w.object(data, "values", path), path+".values")
+		case "delivery_and_payment_methods":
+			methods := w.object(data, "paymentMethods", path)
+			for j, m := range w.list(methods, "data", path+".paymentMethods") {
+				step.Data.PaymentMethods.Data = append(step.Data.PaymentMethods.Data,
+					w.optionValue(m, fmt.Sprintf("%s.paymentMethods.data[%d]", path, j)))
+			}
+			delivery := w.object(data, "deliveryMethods", path)
+			for j, m := range w.list(delivery, "data", path+".deliveryMethods") {
+				methodPath := fmt.Sprintf("%s.deliveryMethods.data[%d]", path, j)
+				if obj, ok := w.asObject(m, methodPath); ok {
+					step.Data.DeliveryMethods.Data = append(step.Data.DeliveryMethods.Data, DeliveryMethod{
+						Value:   w.str(obj, "value", methodPath),
+						Price:   w.str(obj, "price", methodPath),
+						MinDays: w.str(obj, "min_days", methodPath),
+						MaxDays: w.str(obj, "max_days", methodPath),
+					})
+				}
+			}
+			handling := w.object(data, "handlingTime", path)
+			step.Data.HandlingTime = HandlingTime{
+				Min: w.str(handling, "min", path+".handlingTime"),
+				Max: w.str(handling, "max", path+".handlingTime"),
+			}
+		case "promotion":
+			step.Data.Promotion = Promotion{
+				SalePrice: w.str(data, "sale_price", path),
+				StartDate: w.str(data, "start_date", path),
+				EndDate:   w.str(data, "end_date", path),
+			}
+		default:
+			w.warnf("unknown step %q", step.Name)
+		}
+		seen[step.Name] = true
+		attrs.StepsData = append(attrs.StepsData, step)
+	}
+	for _, name := range knownSteps {
+		if !seen[name] {
+			w.warnf("step %s is missing", name)
+		}
+	}
+	return attrs, w.warnings, nil
+}
+
+// attrWalker extracts typed fields from generic JSON, collecting a warning
+// for each field it cannot use
+type attrWalker struct {
+	strict   bool // also warn about unknown keys outside product_detail
+	warnings []string
+}
+
+func (w *attrWalker) warnf(format string, args ...any) {
+	w.warnings = append(w.warnings, fmt.Sprintf(format, args...))
+}
+
+// unknownFields warns, in strict mode, about the keys of obj missing from
+// known
+func (w *attrWalker) unknownFields(obj map[string]any, path string, known []string) {
+	if !w.strict {
+		return
+	}
+	var unknown []string
+	for key := range obj {
+		if !slices.Contains(known, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		w.warnf("%s has unknown fields %s", path, strings.Join(unknown, ", "))
+	}
+}
+
+// values extracts the product_detail values
+func (w *attrWalker) values(obj map[string]any, path string) StepValues {
+	v := StepValues{
+		Brand:          w.str(obj, "brand", path),
+		Price:          w.str(obj, "price", path),
+		AdType:         w.str(obj, "ad_type", path),
+		MPN:            w.str(obj, "mpn", path),
+		ModelNumber:    w.str(obj, "model_number", path),
+		Color:          w.str(obj, "color", path),
+		Size:           w.str(obj, "size", path),
+		Material:       w.str(obj, "material", path),
+		Gender:         w.str(obj, "gender", path),
+		AgeGroup:       w.str(obj, "age_group", path),
+		Condition:      w.str(obj, "condition", path),
+		Quantity:       w.str(obj, "quantity", path),
+		Stock:          w.str(obj, "stock", path),
+		SalePrice:      w.str(obj, "sale_price", path),
+		AuctionEndTime: w.str(obj, "auction_end_time", path),
+		StartingBid:    w.str(obj, "starting_bid", path),
+	}
+	for i, image := range w.list(obj, "images", path) {
+		imagePath := fmt.Sprintf("%s.images[%d]", path, i)
+		if src, ok := image.(string); ok {
+			w.warnf("%s is a string, not an object", imagePath)
+			v.Images = append(v.Images, Image{Src: src})
+			continue
+		}
+		if img, ok := w.asObject(image, imagePath); ok {
+			v.Images = append(v.Images, Image{Src: w.str(img, "src", imagePath)})
+		}
+	}
+	for i, variant := range w.list(obj, "variants", path) {
+		variantPath := fmt.Sprintf("%s.variants[%d]", path, i)
+		if obj, ok := w.asObject(variant, variantPath); ok {
+			v.Variants = append(v.Variants, Variant{
+				ID:         w.str(obj, "id", variantPath),
+				Color:      w.str(obj, "color", variantPath),
+				Size:       w.str(obj, "size", variantPath),
+				Price:      w.str(obj, "price", variantPath),
+				CodeNumber: json.Number(w.str(obj, "code_number", variantPath)),
+				Image:      w.str(obj, "image", variantPath),
+				Quantity:   w.str(obj, "quantity", variantPath),
+			})
+		}
+	}
+
+	var unknown []string
+	for key := range obj {
+		if translationValue.MatchString(key) {
+			if v.Translations == nil {
+				v.Translations = map[string]Text{}
+			}
+			addTranslation(v.Translations, key, w.str(obj, key, path))
+			continue
+		}
+		if !knownValues[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		w.warnf("%s has unknown fields %s", path, strings.Join(unknown, ", "))
+	}
+	return v
+}
+
+// option extracts an {id, value} object, or a plain value
+func (w *attrWalker) option(obj map[string]any, key, path string) Option {
+	if obj[key] == nil {
+		return Option{}
+	}
+	return w.optionValue(obj[key], path+"."+key)
+}
+
+// optionValue extracts an {id, value} object, or a plain value
+func (w *attrWalker) optionValue(v any, path string) Option {
+	switch v := v.(type) {
+	case string, json.Number:
+		return Option{Value: fmt.Sprint(v)}
+	case map[string]any:
+		return Option{ID: w.str(v, "id", path), Value: w.str(v, "value", path)}
+	default:
+		w.warnf("%s is %s, not an object", path, typeName(v))
+		return Option{}
+	}
+}
+
+// str returns the text at key; numbers are accepted as their digits
+func (w *attrWalker) str(obj map[string]any, key, path string) string {
+	switch v := obj[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		w.warnf("%s.%s is %s, not text", path, key, typeName(v))
+		return ""
+	}
+}
+
+// object returns the object at key, or nil
+func (w *attrWalker) object(obj map[string]any, key, path string) map[string]any {
+	if obj[key] == nil {
+		return nil
+	}
+	o, _ := w.asObject(obj[key], path+"."+key)
+	return o
+}
+
+func (w *attrWalker) asObject(v any, path string) (map[string]any, bool) {
+	o, ok := v.(map[string]any)
+	if !ok {
+		w.warnf("%s is %s, not an object", path, typeName(v))
+	}
+	return o, ok
+}
+
+// list returns the list at key; a single object stands for a list of one
+func (w *attrWalker) list(obj map[string]any, key, path string) []any {
+	name := key
+	if path != "" {
+		name = path + "." + key
+	}
+	switch v := obj[key].(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	case map[string]any:
+		w.warnf("%s is an object, not a list", name)
+		return []any{v}
+	default:
+		w.warnf("%s is %s, not a list", name, typeName(v))
+		return nil
+	}
+}
+
+// typeName names the JSON type of v for warnings
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		return "a number"
+	case string:
+		return "text"
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,61 @@
+package input_test
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/testutil"
+)
+
+func TestParseAttributesUnknownKeys(t *testing.T) {
+	raw := json.RawMessage(`{"version": 2, "stepsData": [
+		{"name": "search_product", "order": 1, "data": {"id": {"id": "` + subcategory + `"}}},
+		{"name": "product_detail", "data": {"values": {"brand": "Gucci", "price": "1200"}}},
+		{"name": "delivery_and_payment_methods", "data": {}}
+	]}`)
+
+	tests := []struct {
+		strict bool
+		want   []string
+	}{
+		{false, nil},
+		{true, []string{"attributes has unknown fields version", "search_product has unknown fields order"}},
+	}
+	for _, tt := range tests {
+		attrs, warnings, err := input.ParseAttributes(raw, tt.strict)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(warnings, tt.want) {
+			t.Errorf("strict %v: warnings %q, want %q", tt.strict, warnings, tt.want)
+		}
+		// The known fields are read either way
+		if len(attrs.StepsData) != 3 || attrs.StepsData[1].Data.Values.Brand != "Gucci" {
+			t.Errorf("strict %v: parsed %+v", tt.strict, attrs)
+		}
+	}
+}
+
+func TestStrictProcessorSkipsUnknownKeys(t *testing.T) {
+	fake := testutil.NewFakeHasura(t)
+	cfg := newConfig(t, fake, 10)
+	cfg.Source.StrictAttributes = true
+
+	ad := testutil.Ad("ad-01", subcategory)
+	if _, skipped := input.NewProcessor(cfg, logging.Discard()).Process(ad); skipped != nil {
+		t.Fatalf("complete ad skipped: %+v", skipped)
+	}
+	var attrs map[string]any
+	if err := json.Unmarshal(ad.Attributes, &attrs); err != nil {
+		t.Fatal(err)
+	}
+	attrs["legacy"] = true
+	ad.Attributes, _ = json.Marshal(attrs)
+	_, skipped := input.NewProcessor(cfg, logging.Discard()).Process(ad)
+	if skipped == nil || skipped.Reason != input.SchemaChanged {
+		t.Errorf("ad with an unknown root key skipped as %+v, want %s", skipped, input.SchemaChanged)
+	}
+}
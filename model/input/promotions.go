@@ -0,0 +1,63 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/retry"
+)
+
+// PromotionLookup is implemented by sources that can read the promotions of
+// the promotions feed with a secondary query
+type PromotionLookup interface {
+	LookupPromotions(ctx context.Context, table string) ([]config.Promotion, error)
+}
+
+// promotionRow is one row of the promotions table. The list columns are
+// array or jsonb columns.
+type promotionRow struct {
+	ID                    string    `json:"promotion_id"`
+	ProductApplicability  string    `json:"product_applicability"`
+	OfferType             string    `json:"offer_type"`
+	GenericRedemptionCode string    `json:"generic_redemption_code"`
+	LongTitle             string    `json:"long_title"`
+	StartDate             time.Time `json:"start_date"`
+	EndDate               time.Time `json:"end_date"`
+	AdIDs                 []string  `json:"ad_ids"`
+	Brands                []string  `json:"brands"`
+	Subcategories         []string  `json:"subcategories"`
+}
+
+// LookupPromotions implements PromotionLookup, reading every row of table.
+// The promotions are not validated.
+func (s *HasuraSource) LookupPromotions(ctx context.Context, table string) ([]config.Promotion, error) {
+	req := newQuery(table).arg("order_by: {promotion_id: asc}").
+		request("promotion_id", "product_applicability", "offer_type", "generic_redemption_code", "long_title",
+			"start_date", "end_date", "ad_ids", "brands", "subcategories")
+
+	var response map[string][]promotionRow
+	err := retry.Do(ctx, s.logger, s.cfg.Retry, fmt.Sprintf("Reading %s", table), func() error {
+		return s.run(ctx, req, &response)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", table, err)
+	}
+	promotions := make([]config.Promotion, 0, len(response[table]))
+	for _, row := range response[table] {
+		promotions = append(promotions, config.Promotion{
+			ID:                    row.ID,
+			ProductApplicability:  row.ProductApplicability,
+			OfferType:             row.OfferType,
+			GenericRedemptionCode: row.GenericRedemptionCode,
+			LongTitle:             row.LongTitle,
+			Start:                 row.StartDate,
+			End:                   row.EndDate,
+			AdIDs:                 row.AdIDs,
+			Brands:                row.Brands,
+			Subcategories:         row.Subcategories,
+		})
+	}
+	return promotions, nil
+}
@@ -0,0 +1,133 @@
+package input
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/graphql"
+)
+
+// sellerColumn is the ads column holding the seller's user ID
+const sellerColumn = "user_id"
+
+// adFields are the columns selected for every ad
+var adFields = []string{"id", "draft_id", "description", "attributes", "code_number", "updated_at", "category_id", sellerColumn}
+
+// query builds a GraphQL query over one table. Every filter value is sent as
+// a variable, never spliced into the query text, so values from the config
+// cannot change the query. Field names, operators and types come from this
+// package only.
+type query struct {
+	table      string
+	params     []string // variable declarations, e.g. "$limit: Int!"
+	conditions []string // where clause entries, e.g. "status: {_eq: $status}"
+	args       []string // table arguments after where, e.g. "limit: $limit"
+	vars       map[string]any
+}
+
+func newQuery(table string) *query {
+	return &query{table: table, vars: map[string]any{}}
+}
+
+// param declares the variable $name of GraphQL type typ with value
+func (q *query) param(name, typ string, value any) {
+	q.params = append(q.params, fmt.Sprintf("$%s: %s", name, typ))
+	q.vars[name] = value
+}
+
+// where adds the condition field: {op: $name}
+func (q *query) where(field, op, name, typ string, value any) *query {
+	q.param(name, typ, value)
+	q.conditions = append(q.conditions, fmt.Sprintf("%s: {%s: $%s}", field, op, name))
+	return q
+}
+
+// whereAny adds a condition that holds when any of the boolean expressions
+// in value does, as _or: $name
+func (q *query) whereAny(name string, value []map[string]any) *query {
+	q.param(name, "["+q.table+"_bool_exp!]!", value)
+	q.conditions = append(q.conditions, fmt.Sprintf("_or: $%s", name))
+	return q
+}
+
+// arg adds a table argument such as limit or order_by. Values that vary go
+// through param.
+func (q *query) arg(text string) *query {
+	q.args = append(q.args, text)
+	return q
+}
+
+// String returns the query text selecting fields
+func (q *query) String(fields ...string) string {
+	var b strings.Builder
+	b.WriteString("query")
+	if len(q.params) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(q.params, ", "))
+	}
+	fmt.Fprintf(&b, " {\n\t%s(\n", q.table)
+	args := q.args
+	if len(q.conditions) > 0 {
+		args = append([]string{"where: {\n\t\t\t" + strings.Join(q.conditions, ",\n\t\t\t") + "\n\t\t}"}, args...)
+	}
+	fmt.Fprintf(&b, "\t\t%s\n\t) {\n", strings.Join(args, ",\n\t\t"))
+	for _, field := range fields {
+		fmt.Fprintf(&b, "\t\t%s\n", field)
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String()
+}
+
+// request returns the GraphQL request selecting fields, with the variables set
+func (q *query) request(fields ...string) *graphql.Request {
+	req := graphql.NewRequest(q.String(fields...))
+	for name, value := range q.vars {
+		req.Var(name, value)
+	}
+	return req
+}
+
+// adsQuery selects one page of ads matching the filters of cfg after the
+// cursor, ordered by ID so the last ID of a page can be used as the cursor
+// for the next one. The updated_at filter is left out when since is zero,
+// for full refreshes, and the status filter with allStatuses.
+func adsQuery(cfg *config.Config, since time.Time, allStatuses bool, after string) *query {
+	filters := cfg.Source.Filters
+	q := newQuery("ads")
+	if !allStatuses {
+		q.where("status", "_eq", "status", "String!", filters.Status)
+	}
+	q.where("category_id", "_in", "categoryIDs", "[uuid!]!", cfg.CategoryRegistry().IDs())
+	if !since.IsZero() {
+		q.where("updated_at", "_gte", "since", "timestamptz!", since.Format(time.RFC3339))
+	}
+	if len(filters.SellerIDs) > 0 {
+		q.where(sellerColumn, "_in", "sellerIDs", "[uuid!]!", filters.SellerIDs)
+	}
+	if len(filters.Brands) > 0 {
+		q.whereAny("brands", brandConditions(filters.Brands))
+	}
+	q.where("id", "_gt", "after", "uuid!", after)
+	q.param("limit", "Int!", cfg.PageSize)
+	return q.arg("order_by: {id: asc}").arg("limit: $limit")
+}
+
+// brandConditions matches the brand entered in the product_detail step of
+// the attributes, one condition per brand
+func brandConditions(brands []string) []map[string]any {
+	conditions := make([]map[string]any, len(brands))
+	for i, brand := range brands {
+		conditions[i] = map[string]any{
+			"attributes": map[string]any{
+				"_contains": map[string]any{
+					"stepsData": []any{map[string]any{
+						"name": "product_detail",
+						"data": map[string]any{"values": map[string]any{"brand": brand}},
+					}},
+				},
+			},
+		}
+	}
+	return conditions
+}
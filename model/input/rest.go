@@ -0,0 +1,98 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/retry"
+)
+
+// RESTSource reads raw ads from an HTTP endpoint that returns the same JSON
+// shapes FileSource accepts. The updated_at lower bound is sent as the
+// "since" query parameter (RFC 3339) and left out for full refreshes.
+type RESTSource struct {
+	cfg     *config.Config
+	logger  *slog.Logger
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewRESTSource returns a source that GETs ads from endpoint through client,
+// sending headers with every request. A nil client uses the default
+// transport and a nil logger slog.Default().
+func NewRESTSource(cfg *config.Config, endpoint string, headers map[string]string, client *http.Client, logger *slog.Logger) *RESTSource {
+	return &RESTSource{
+		cfg:     cfg,
+		logger:  logging.OrDefault(logger),
+		url:     endpoint,
+		headers: headers,
+		client:  newHTTPClient(client),
+	}
+}
+
+// Fetch implements AdSource
+func (s *RESTSource) Fetch(ctx context.Context, opts FetchOptions) (FetchResult, error) {
+	ads, err := s.get(ctx, opts)
+	if err != nil {
+		return FetchResult{}, err
+	}
+	return ProcessAds(ctx, s.cfg, s.logger, ads)
+}
+
+// Stream implements RawStreamer
+func (s *RESTSource) Stream(ctx context.Context, opts FetchOptions, out chan<- RawAd) error {
+	ads, err := s.get(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return send(ctx, out, ads)
+}
+
+// get requests the raw ads updated since the options' lower bound
+func (s *RESTSource) get(ctx context.Context, opts FetchOptions) ([]RawAd, error) {
+	endpoint, err := url.Parse(s.url)
+	if err != nil {
+		return nil, err
+	}
+	if since := opts.since(); !since.IsZero() {
+		query := endpoint.Query()
+		query.Set("since", since.Format(time.RFC3339))
+		endpoint.RawQuery = query.Encode()
+	}
+
+	var ads []RawAd
+	err = retry.Do(ctx, s.logger, s.cfg.Retry, "Fetching ads over REST", func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/json")
+		for key, value := range s.headers {
+			req.Header.Set(key, value)
+		}
+
+		res, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %s: unexpected status %s", s.url, res.Status)
+		}
+		ads, err = decodeRawAds(res.Body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Fetched ads over REST", "ads", len(ads), "url", s.url)
+	return ads, nil
+}
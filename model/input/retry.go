@@ -0,0 +1,19 @@
+package input
+
+import (
+	"net/http"
+
+	"go_data_fashion_accessories/retry"
+)
+
+// newHTTPClient returns the client used for source requests, which sends
+// them through base and retries on 5xx and 429 responses. A nil base uses
+// http.DefaultTransport.
+func newHTTPClient(base *http.Client) *http.Client {
+	if base == nil {
+		return &http.Client{Transport: retry.StatusTransport{}}
+	}
+	client := *base
+	client.Transport = retry.StatusTransport{Base: base.Transport}
+	return &client
+}
@@ -0,0 +1,89 @@
+package input
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/money"
+)
+
+// Promotion is a discount on an ad: the price it sells for instead of its
+// regular price, from StartDate until EndDate when they are set. It comes
+// from the promotion step of the form, or from the sale_price value of the
+// product_detail step.
+type Promotion struct {
+	SalePrice string `json:"sale_price"`
+	StartDate string `json:"start_date"` // RFC 3339 or 2006-01-02
+	EndDate   string `json:"end_date"`
+}
+
+// sale is a parsed promotion
+type sale struct {
+	price      money.Money
+	start, end time.Time
+}
+
+// parseSale parses a promotion in currency. A promotion without a sale
+// price is the zero sale.
+func parseSale(promo Promotion, currency string) (sale, error) {
+	if strings.TrimSpace(promo.SalePrice) == "" {
+		return sale{}, nil
+	}
+	price, err := parsePrice(promo.SalePrice, currency)
+	if err != nil {
+		return sale{}, fmt.Errorf("sale price %q: %w", promo.SalePrice, err)
+	}
+	s := sale{price: price}
+	if s.start, err = parseSaleDate(promo.StartDate); err != nil {
+		return sale{}, fmt.Errorf("sale start date: %w", err)
+	}
+	if s.end, err = parseSaleDate(promo.EndDate); err != nil {
+		return sale{}, fmt.Errorf("sale end date: %w", err)
+	}
+	if !s.start.IsZero() && !s.end.IsZero() && !s.end.After(s.start) {
+		return sale{}, fmt.Errorf("sale ends at %s, before it starts", promo.EndDate)
+	}
+	return s, nil
+}
+
+// parseSaleDate reads an RFC 3339 time or a date, which starts at
+// midnight UTC. An empty value is the zero time.
+func parseSaleDate(s string) (time.Time, error) {
+	if s = strings.TrimSpace(s); s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
+// applySale sets the sale price of item when it is lower than the item's
+// price and the sale has not ended, and reports whether it did
+func (p *Processor) applySale(item *AdItem, s sale) bool {
+	if s.price.IsZero() {
+		return false
+	}
+	if s.price.Minor >= item.Price.Minor {
+		p.trace("sale", false, "%s: sale price %s is not below the price %s", item.ID, s.price, item.Price)
+		return false
+	}
+	if !s.end.IsZero() && s.end.Before(time.Now()) {
+		p.trace("sale", false, "%s: sale ended at %s", item.ID, s.end.Format(time.RFC3339))
+		return false
+	}
+	item.SalePrice, item.SaleStart, item.SaleEnd = s.price, s.start, s.end
+	p.trace("sale", true, "%s: on sale for %s", item.ID, s.price)
+	return true
+}
+
+// SalePriceEffectiveDate returns the period of the item's sale as an ISO
+// 8601 interval, e.g. "2024-05-01T00:00:00Z/2024-05-08T00:00:00Z", or ""
+// unless both of its ends are known
+func (ad AdItem) SalePriceEffectiveDate() string {
+	if ad.SalePrice.IsZero() || ad.SaleStart.IsZero() || ad.SaleEnd.IsZero() {
+		return ""
+	}
+	return ad.SaleStart.UTC().Format(time.RFC3339) + "/" + ad.SaleEnd.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,81 @@
+package input
+
+import (
+	"strconv"
+	"strings"
+
+	"go_data_fashion_accessories/money"
+)
+
+// DeliveryMethod is a delivery option picked in the
+// delivery_and_payment_methods step
+type DeliveryMethod struct {
+	Value   string `json:"value"`    // name of the service, e.g. "Courier"
+	Price   string `json:"price"`    // shipping cost; "free" or 0 for free delivery
+	MinDays string `json:"min_days"` // delivery time in business days
+	MaxDays string `json:"max_days"`
+}
+
+// HandlingTime is how many business days the seller takes to dispatch an
+// order, from the delivery_and_payment_methods step
+type HandlingTime struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+// Shipping is one way an item can be delivered, with its cost and delivery
+// time. Days are business days; 0 means the ad does not say.
+type Shipping struct {
+	Service        string
+	Price          money.Money
+	MinTransitDays int
+	MaxTransitDays int
+}
+
+// shipping returns the delivery options of an ad. Options whose cost
+// cannot be read are left out.
+func (p *Processor) shipping(methods []DeliveryMethod) []Shipping {
+	var options []Shipping
+	for _, method := range methods {
+		price, err := parseShippingPrice(method.Price, p.currency)
+		if err != nil {
+			p.trace("shipping", false, "%s: cost %q: %v", method.Value, method.Price, err)
+			continue
+		}
+		minDays, maxDays := parseDays(method.MinDays, method.MaxDays)
+		options = append(options, Shipping{
+			Service:        strings.TrimSpace(method.Value),
+			Price:          price,
+			MinTransitDays: minDays,
+			MaxTransitDays: maxDays,
+		})
+		p.trace("shipping", true, "%s for %s in %d-%d days", method.Value, price, minDays, maxDays)
+	}
+	return options
+}
+
+// parseShippingPrice reads a shipping cost, which unlike a price may be
+// zero or "free"
+func parseShippingPrice(s, currency string) (money.Money, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "free") {
+		return money.Money{Currency: currency}, nil
+	}
+	return money.Parse(s, currency)
+}
+
+// parseDays reads a range of days. A missing or invalid bound is 0, and a
+// maximum below the minimum is raised to it.
+func parseDays(minText, maxText string) (minDays, maxDays int) {
+	day := func(s string) int {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < 0 {
+			return 0
+		}
+		return n
+	}
+	minDays, maxDays = day(minText), day(maxText)
+	if maxDays < minDays {
+		maxDays = minDays
+	}
+	return minDays, maxDays
+}
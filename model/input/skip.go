@@ -0,0 +1,92 @@
+package input
+
+import "fmt"
+
+// SkipReason says why an ad was left out of the feed
+type SkipReason string
+
+const (
+	// ParseError means the ad attributes could not be decoded
+	ParseError SkipReason = "parse_error"
+	// DisallowedSubcategory means the ad is not in an allowed subcategory
+	DisallowedSubcategory SkipReason = "disallowed_subcategory"
+	// NoOnlinePayment means the ad accepts none of the qualifying payment
+	// methods
+	NoOnlinePayment SkipReason = "no_online_payment"
+	// AuctionExcluded means the ad is an auction and auctions are excluded
+	AuctionExcluded SkipReason = "auction_excluded"
+	// AuctionEnded means the auction's end time has passed
+	AuctionEnded SkipReason = "auction_ended"
+	// MissingGTIN means the ad has no code number and the policy does not
+	// let it through without one
+	MissingGTIN SkipReason = "missing_gtin"
+	// InvalidPrice means the price is missing, zero or cannot be parsed
+	InvalidPrice SkipReason = "invalid_price"
+	// PriceOutOfRange means the price is outside the plausible range for the
+	// item's subcategory; the report's detail names the subcategory when it
+	// has its own range
+	PriceOutOfRange SkipReason = "price_out_of_range"
+	// InvalidGTIN means the code number is not a valid GTIN and the policy
+	// rejects such items
+	InvalidGTIN SkipReason = "invalid_gtin"
+	// DuplicateGTIN means another item with the same GTIN was kept instead,
+	// as it was updated more recently; the report's detail names its ad
+	DuplicateGTIN SkipReason = "duplicate_gtin"
+	// RuleFailed means one of the configured inclusion rules is false for
+	// the item; the report's detail names the rule
+	RuleFailed SkipReason = "rule_failed"
+	// BrandBlocked means the item's brand is on the blocklist; the report's
+	// detail names the listed brand
+	BrandBlocked SkipReason = "brand_blocked"
+	// BrandNotAllowed means there is a brand allowlist and the item's brand
+	// is not on it
+	BrandNotAllowed SkipReason = "brand_not_allowed"
+	// BrokenImage means the main image link could not be fetched or is too
+	// small
+	BrokenImage SkipReason = "broken_image"
+	// Unpublished means the source reported a status other than the one
+	// fetched, such as an ad that was sold; the report's detail is the status
+	Unpublished SkipReason = "unpublished"
+	// Incomplete means a field every ad needs was null or missing, or the
+	// source reported an error for the ad; the report's detail names the
+	// field or quotes the error
+	Incomplete SkipReason = "incomplete"
+	// OutOfStock means no item of the ad has stock left and
+	// Eligibility.OutOfStock excludes such items
+	OutOfStock SkipReason = "out_of_stock"
+	// SchemaChanged means the attributes do not match the expected form and
+	// Source.StrictAttributes is set; the report's detail lists the
+	// offending field paths
+	SchemaChanged SkipReason = "schema_changed"
+	// Disapproved means Merchant Center disapproved the ad's items in
+	// Diagnostics.ExcludeAfter pulls in a row and the ad was not edited
+	// since
+	Disapproved SkipReason = "disapproved"
+	// LowQuality means the item's quality score is below Quality.MinScore
+	LowQuality SkipReason = "low_quality"
+)
+
+// SkipReport records one ad that was left out of the feed
+type SkipReport struct {
+	AdID     string     `json:"ad_id"`
+	DraftID  string     `json:"draft_id,omitempty"`
+	SellerID string     `json:"seller_id,omitempty"`
+	Title    string     `json:"title,omitempty"` // as the seller wrote it, when it could be read
+	Reason   SkipReason `json:"reason"`
+	Detail   string     `json:"detail,omitempty"` // offending value or error text
+}
+
+func (r SkipReport) String() string {
+	if r.Detail == "" {
+		return fmt.Sprintf("%s: %s", r.AdID, r.Reason)
+	}
+	return fmt.Sprintf("%s: %s (%s)", r.AdID, r.Reason, r.Detail)
+}
+
+// FetchResult holds the items kept from a fetch together with a report for
+// every ad that was skipped
+type FetchResult struct {
+	Items   []AdItem
+	Skipped []SkipReport
+	AdTypes map[string]int // processed ads by type, as in Processor.AdTypes
+}
@@ -0,0 +1,167 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/httpclient"
+)
+
+// defaultWindow is how far back ads are fetched when no lower bound is given
+const defaultWindow = 24 * time.Hour
+
+// AdSource provides the ads that feed the output pipeline. Implementations
+// fetch raw ads from their backend and run them through ProcessAds, so every
+// source yields items filtered and shaped the same way.
+type AdSource interface {
+	Fetch(ctx context.Context, opts FetchOptions) (FetchResult, error)
+}
+
+// RawStreamer is implemented by sources that can deliver raw ads one at a
+// time instead of as one slice. Stream sends every ad on out and returns
+// once the source is exhausted; it does not close out.
+type RawStreamer interface {
+	Stream(ctx context.Context, opts FetchOptions, out chan<- RawAd) error
+}
+
+// Pinger is implemented by sources that can check their backend answers,
+// for health checks
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// StreamingSource is an AdSource that can also stream its raw ads
+type StreamingSource interface {
+	AdSource
+	RawStreamer
+}
+
+// FetchOptions narrows what a source returns
+type FetchOptions struct {
+	// Since is the updated_at lower bound. It takes precedence over Window.
+	Since time.Time
+	// Window fetches ads updated within this long before now; zero means
+	// the last 24 hours
+	Window time.Duration
+	// FullRefresh fetches every ad regardless of when it was updated, so
+	// the feed can be rebuilt from scratch
+	FullRefresh bool
+	// AllStatuses also fetches ads in other statuses than the configured
+	// one, with their status, so ads that were sold or unpublished are
+	// reported as Unpublished. Only the hasura source filters by status.
+	AllStatuses bool
+}
+
+// since resolves the updated_at lower bound for a fetch. The zero time means
+// no lower bound.
+func (o FetchOptions) since() time.Time {
+	switch {
+	case o.FullRefresh:
+		return time.Time{}
+	case !o.Since.IsZero():
+		return o.Since
+	case o.Window > 0:
+		return time.Now().Add(-o.Window)
+	default:
+		return time.Now().Add(-defaultWindow)
+	}
+}
+
+// NewSource builds the source selected by cfg.Source.Type, logging to logger.
+// Requests go through a client configured by cfg.Source.HTTP.
+func NewSource(cfg *config.Config, logger *slog.Logger) (StreamingSource, error) {
+	switch cfg.Source.Type {
+	case "", config.SourceHasura:
+		client, err := httpclient.New(cfg.Source.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		return NewHasuraSource(cfg, client, logger), nil
+	case config.SourceFile:
+		return NewFileSource(cfg, cfg.Source.Path, logger), nil
+	case config.SourceREST:
+		client, err := httpclient.New(cfg.Source.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		return NewRESTSource(cfg, cfg.Source.URL, cfg.Source.Headers, client, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown ad source %q", cfg.Source.Type)
+	}
+}
+
+// RawAds streams a fixed set of raw ads, such as ones delivered by a webhook
+type RawAds []RawAd
+
+// Stream implements RawStreamer, ignoring opts
+func (a RawAds) Stream(ctx context.Context, opts FetchOptions, out chan<- RawAd) error {
+	return send(ctx, out, a)
+}
+
+// send delivers ads on out, giving up if ctx is cancelled first
+func send(ctx context.Context, out chan<- RawAd, ads []RawAd) error {
+	for _, ad := range ads {
+		select {
+		case out <- ad:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// collectRaw drains a streaming source into a slice
+func collectRaw(ctx context.Context, s RawStreamer, opts FetchOptions) ([]RawAd, error) {
+	out := make(chan RawAd)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(out)
+		errc <- s.Stream(ctx, opts, out)
+	}()
+
+	var ads []RawAd
+	for ad := range out {
+		ads = append(ads, ad)
+	}
+	return ads, <-errc
+}
+
+// decodeRawAds reads raw ads from JSON shaped as a bare array, an object
+// with an "ads" array, or a full GraphQL response {"data": {"ads": [...]}}
+func decodeRawAds(r io.Reader) ([]RawAd, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var ads []RawAd
+		err := json.Unmarshal(data, &ads)
+		return ads, err
+	}
+
+	var envelope struct {
+		Ads  []RawAd `json:"ads"`
+		Data *struct {
+			Ads []RawAd `json:"ads"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Data != nil {
+		return envelope.Data.Ads, nil
+	}
+	if envelope.Ads == nil {
+		return nil, errors.New(`no "ads" array found`)
+	}
+	return envelope.Ads, nil
+}
@@ -0,0 +1,64 @@
+package input
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Availability values of AdItem, as Google Merchant spells them
+const (
+	AvailabilityInStock    = "in stock"
+	AvailabilityOutOfStock = "out of stock"
+	AvailabilityPreorder   = "preorder"
+	AvailabilityBackorder  = "backorder"
+)
+
+// stockStatuses maps the stock values sellers may pick, normalized by
+// stockKey, to an availability
+var stockStatuses = map[string]string{
+	"in stock":     AvailabilityInStock,
+	"available":    AvailabilityInStock,
+	"out of stock": AvailabilityOutOfStock,
+	"sold out":     AvailabilityOutOfStock,
+	"unavailable":  AvailabilityOutOfStock,
+	"preorder":     AvailabilityPreorder,
+	"pre order":    AvailabilityPreorder,
+	"backorder":    AvailabilityBackorder,
+	"back order":   AvailabilityBackorder,
+}
+
+// stockKey lowercases a stock value and spells it with single spaces, so
+// "In_Stock" and "pre-order" match their entries
+func stockKey(s string) string {
+	s = strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(s))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// parseQuantity reads a stock quantity such as "3". It returns nil when the
+// ad does not say or the value is not a whole number of units.
+func parseQuantity(s string) *int {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return nil
+	}
+	return &n
+}
+
+// availability derives an item's availability from its stock status and
+// quantity. A known status wins, except that nothing left in stock is out
+// of stock; ads that state neither are in stock, as they always were.
+func availability(stock string, quantity *int) string {
+	status, known := stockStatuses[stockKey(stock)]
+	empty := quantity != nil && *quantity == 0
+	switch {
+	case known && (status != AvailabilityInStock || !empty):
+		return status
+	case empty:
+		return AvailabilityOutOfStock
+	}
+	return AvailabilityInStock
+}
@@ -0,0 +1,67 @@
+package input
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Variant is one size or color option of an ad, listed in the
+// product_detail step as values.variants. Empty fields fall back to the
+// parent ad, except CodeNumber: a GTIN identifies one variant only.
+type Variant struct {
+	ID         string      `json:"id"`
+	Color      string      `json:"color"`
+	Size       string      `json:"size"`
+	Price      string      `json:"price"`
+	CodeNumber json.Number `json:"code_number"`
+	Image      string      `json:"image"` // image file name, like the ad images
+	Quantity   string      `json:"quantity"`
+}
+
+// expandVariants returns one item per variant of parent, grouped under the
+// parent ad ID with item_group_id. Variants with an invalid price are left
+// out.
+func (p *Processor) expandVariants(parent AdItem, variants []Variant) []AdItem {
+	items := make([]AdItem, 0, len(variants))
+	for i, v := range variants {
+		item := parent
+		item.ItemGroupID = parent.AdID
+		item.ID = parent.AdID + "-" + variantKey(v, i)
+		if v.Color != "" {
+			item.Color = v.Color
+		}
+		if v.Size != "" {
+			item.Size = v.Size
+		}
+		item.CodeNumber = v.CodeNumber
+
+		if v.Price != "" {
+			amount, err := parsePrice(v.Price, p.currency)
+			if err != nil {
+				p.trace("variant", false, "%s: price %q: %v", item.ID, v.Price, err)
+				continue
+			}
+			item.Price = amount
+		}
+		if q := parseQuantity(v.Quantity); q != nil {
+			item.Quantity = q
+		}
+		if v.Image != "" {
+			item.ImageLink = p.images.Build(parent.DraftID, v.Image)
+		}
+
+		p.trace("variant", true, "%s: color %q, size %q, %s", item.ID, item.Color, item.Size, item.Price)
+		items = append(items, item)
+	}
+	return items
+}
+
+// variantKey returns the suffix that makes a variant's item ID unique: its
+// own ID when it has one, otherwise its position
+func variantKey(v Variant, i int) string {
+	if id := strings.TrimSpace(v.ID); id != "" {
+		return id
+	}
+	return strconv.Itoa(i + 1)
+}
@@ -0,0 +1,238 @@
+// Package contentapi pushes items straight to Google Merchant Center with
+// the Content API for Shopping, as an alternative to hosting a feed file.
+// Items are sent in products.custombatch requests, throttled to the
+// configured rate and retried with backoff when Google reports it is over
+// quota.
+package contentapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/googleauth"
+	"go_data_fashion_accessories/httpclient"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/retry"
+)
+
+// Scope is the OAuth scope of the Content API
+const Scope = "https://www.googleapis.com/auth/content"
+
+// Client sends product batches for one merchant account. It is not safe for
+// concurrent use.
+type Client struct {
+	cfg     config.ContentAPI
+	retry   config.Retry
+	http    *http.Client
+	limiter *rate.Limiter
+	logger  *slog.Logger
+
+	// Errors lists the items Google rejected so far
+	Errors []ItemError
+}
+
+// ItemError is an item that Google rejected
+type ItemError struct {
+	OfferID string
+	Method  string // insert or delete
+	Reason  string
+	Message string
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("%s %s: %s (%s)", e.Method, e.OfferID, e.Message, e.Reason)
+}
+
+// New returns a client authenticated with the service account key in
+// cfg.ContentAPI.CredentialsFile. A nil logger uses slog.Default().
+func New(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*Client, error) {
+	// Token requests and API calls both go through the retrying transport
+	base, err := httpclient.NewRetrying(cfg.HTTP, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	client, err := googleauth.Client(ctx, cfg.ContentAPI.CredentialsFile, base, Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		cfg:     cfg.ContentAPI,
+		retry:   cfg.Retry,
+		http:    client,
+		limiter: rate.NewLimiter(rate.Limit(cfg.ContentAPI.RequestsPerSecond), 1),
+		logger:  logging.OrDefault(logger),
+	}, nil
+}
+
+// ProductID returns the REST ID of the product with the given offer ID
+func (c *Client) ProductID(offerID string) string {
+	return strings.Join([]string{c.cfg.Channel, c.cfg.ContentLanguage, c.cfg.TargetCountry, offerID}, ":")
+}
+
+// entry is one request of a products.custombatch call
+type entry struct {
+	BatchID    int      `json:"batchId"`
+	MerchantID string   `json:"merchantId"`
+	Method     string   `json:"method"`
+	Product    *Product `json:"product,omitempty"`
+	ProductID  string   `json:"productId,omitempty"`
+}
+
+// entryResponse is the outcome of one entry
+type entryResponse struct {
+	BatchID int `json:"batchId"`
+	Errors  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Errors  []struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"errors"`
+}
+
+// Insert creates or replaces the products of items
+func (c *Client) Insert(ctx context.Context, items []input.AdItem) error {
+	entries := make([]entry, len(items))
+	offers := make([]string, len(items))
+	for i, item := range items {
+		product, err := c.product(item)
+		if err != nil {
+			return err
+		}
+		entries[i] = entry{BatchID: i, MerchantID: c.cfg.MerchantID, Method: "insert", Product: &product}
+		offers[i] = item.ID
+	}
+	return c.batch(ctx, entries, offers)
+}
+
+// Delete removes the products with the given offer IDs
+func (c *Client) Delete(ctx context.Context, offerIDs []string) error {
+	for start := 0; start < len(offerIDs); start += c.cfg.BatchSize {
+		chunk := offerIDs[start:min(start+c.cfg.BatchSize, len(offerIDs))]
+		entries := make([]entry, len(chunk))
+		for i, id := range chunk {
+			entries[i] = entry{BatchID: i, MerchantID: c.cfg.MerchantID, Method: "delete", ProductID: c.ProductID(id)}
+		}
+		if err := c.batch(ctx, entries, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batch sends one custombatch request and records the entries Google
+// rejected. offers holds the offer ID of each entry, by batch ID.
+func (c *Client) batch(ctx context.Context, entries []entry, offers []string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(struct {
+		Entries []entry `json:"entries"`
+	}{entries})
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Entries []entryResponse `json:"entries"`
+	}
+	err = retry.Do(ctx, c.logger, c.retry, "Content API batch", func() error {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		return c.post(ctx, c.cfg.Endpoint+"/products/batch", body, &response)
+	})
+	if err != nil {
+		return fmt.Errorf("content API batch of %d: %w", len(entries), err)
+	}
+
+	method := entries[0].Method
+	for _, r := range response.Entries {
+		if r.Errors == nil || r.BatchID < 0 || r.BatchID >= len(offers) {
+			continue
+		}
+		itemErr := ItemError{OfferID: offers[r.BatchID], Method: method, Message: r.Errors.Message}
+		if len(r.Errors.Errors) > 0 {
+			itemErr.Reason = r.Errors.Errors[0].Reason
+			itemErr.Message = r.Errors.Errors[0].Message
+		}
+		c.Errors = append(c.Errors, itemErr)
+		c.logger.Warn("Content API rejected item",
+			"offer_id", itemErr.OfferID, "method", method, logging.Reason, itemErr.Reason, "error", itemErr.Message)
+	}
+	c.logger.Debug("Sent Content API batch", "method", method, "entries", len(entries))
+	return nil
+}
+
+// post sends body and decodes the JSON response into v
+func (c *Client) post(ctx context.Context, url string, body []byte, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&apiErr)
+		return fmt.Errorf("POST %s: %s: %s", url, res.Status, apiErr.Error.Message)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Sink inserts the items written to it in batches of BatchSize
+type Sink struct {
+	ctx     context.Context
+	client  *Client
+	pending []input.AdItem
+}
+
+// NewSink returns a sink sending items with client. ctx bounds every
+// request, as Write and Close take none.
+func NewSink(ctx context.Context, client *Client) *Sink {
+	return &Sink{ctx: ctx, client: client}
+}
+
+// Write queues an item, sending the batch once it is full
+func (s *Sink) Write(item input.AdItem) error {
+	s.pending = append(s.pending, item)
+	if len(s.pending) < s.client.cfg.BatchSize {
+		return nil
+	}
+	return s.flush()
+}
+
+// Close sends the last batch
+func (s *Sink) Close() error {
+	return s.flush()
+}
+
+func (s *Sink) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	err := s.client.Insert(s.ctx, s.pending)
+	s.pending = s.pending[:0]
+	return err
+}
@@ -0,0 +1,152 @@
+package contentapi
+
+import (
+	"strconv"
+	"time"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+)
+
+// Product is the Content API representation of an item. Only the fields
+// the feeds carry are listed.
+type Product struct {
+	OfferID                string            `json:"offerId"`
+	Title                  string            `json:"title"`
+	Description            string            `json:"description"`
+	Link                   string            `json:"link"`
+	ImageLink              string            `json:"imageLink"`
+	AdditionalImageLinks   []string          `json:"additionalImageLinks,omitempty"`
+	ContentLanguage        string            `json:"contentLanguage"`
+	TargetCountry          string            `json:"targetCountry"`
+	Channel                string            `json:"channel"`
+	Availability           string            `json:"availability"`
+	Condition              string            `json:"condition,omitempty"`
+	Brand                  string            `json:"brand,omitempty"`
+	GTIN                   string            `json:"gtin,omitempty"`
+	MPN                    string            `json:"mpn,omitempty"`
+	IdentifierExists       *bool             `json:"identifierExists,omitempty"`
+	Price                  Price             `json:"price"`
+	SalePrice              *Price            `json:"salePrice,omitempty"`
+	SalePriceEffectiveDate string            `json:"salePriceEffectiveDate,omitempty"`
+	Shipping               []ProductShipping `json:"shipping,omitempty"`
+	MinHandlingTime        string            `json:"minHandlingTime,omitempty"` // int64 as a string, in business days
+	MaxHandlingTime        string            `json:"maxHandlingTime,omitempty"`
+	GoogleProductCategory  string            `json:"googleProductCategory,omitempty"`
+	ProductTypes           []string          `json:"productTypes,omitempty"`
+	ItemGroupID            string            `json:"itemGroupId,omitempty"`
+	Color                  string            `json:"color,omitempty"`
+	Sizes                  []string          `json:"sizes,omitempty"`
+	Material               string            `json:"material,omitempty"`
+	Gender                 string            `json:"gender,omitempty"`
+	AgeGroup               string            `json:"ageGroup,omitempty"`
+
+	CustomLabel0 string `json:"customLabel0,omitempty"`
+	CustomLabel1 string `json:"customLabel1,omitempty"`
+	CustomLabel2 string `json:"customLabel2,omitempty"`
+	CustomLabel3 string `json:"customLabel3,omitempty"`
+	CustomLabel4 string `json:"customLabel4,omitempty"`
+
+	PromotionIDs []string `json:"promotionIds,omitempty"`
+
+	CustomAttributes []CustomAttribute `json:"customAttributes,omitempty"`
+}
+
+// Price is an amount as the Content API writes it
+type Price struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+// ProductShipping is one delivery option of a product
+type ProductShipping struct {
+	Country        string `json:"country"`
+	Service        string `json:"service,omitempty"`
+	Price          Price  `json:"price"`
+	MinTransitTime string `json:"minTransitTime,omitempty"`
+	MaxTransitTime string `json:"maxTransitTime,omitempty"`
+}
+
+// CustomAttribute carries attributes the Product type has no field for,
+// such as the auction ones
+type CustomAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// product maps an item to the product sent for it, in the title and
+// description of ContentLanguage when the item has them
+func (c *Client) product(ad input.AdItem) (Product, error) {
+	item, err := feed.FromAdItem(ad.Localized(c.cfg.ContentLanguage))
+	if err != nil {
+		return Product{}, err
+	}
+	p := Product{
+		OfferID:               item.ID,
+		Title:                 item.Title,
+		Description:           item.Description,
+		Link:                  feed.URLString(item.Link),
+		ImageLink:             feed.URLString(item.ImageLink),
+		AdditionalImageLinks:  feed.URLStrings(item.AdditionalImageLinks),
+		ContentLanguage:       c.cfg.ContentLanguage,
+		TargetCountry:         c.cfg.TargetCountry,
+		Channel:               c.cfg.Channel,
+		Availability:          string(item.Availability),
+		Condition:             string(item.Condition),
+		Brand:                 item.Brand,
+		GTIN:                  item.GTIN,
+		MPN:                   item.MPN,
+		Price:                 Price{Value: item.Price.Decimal(), Currency: item.Price.Currency},
+		GoogleProductCategory: item.GoogleProductCategory,
+		ItemGroupID:           item.ItemGroupID,
+		Color:                 item.Color,
+		Material:              item.Material,
+		Gender:                item.Gender,
+		AgeGroup:              item.AgeGroup,
+		CustomLabel0:          item.CustomLabels[0],
+		CustomLabel1:          item.CustomLabels[1],
+		CustomLabel2:          item.CustomLabels[2],
+		CustomLabel3:          item.CustomLabels[3],
+		CustomLabel4:          item.CustomLabels[4],
+		PromotionIDs:          item.PromotionIDs,
+	}
+	if !item.SalePrice.IsZero() {
+		p.SalePrice = &Price{Value: item.SalePrice.Decimal(), Currency: item.SalePrice.Currency}
+		p.SalePriceEffectiveDate = item.SalePriceEffectiveDate()
+	}
+	for _, option := range item.Shipping {
+		p.Shipping = append(p.Shipping, ProductShipping{
+			Country:        c.cfg.TargetCountry,
+			Service:        option.Service,
+			Price:          Price{Value: option.Price.Decimal(), Currency: option.Price.Currency},
+			MinTransitTime: days(option.MinTransitDays),
+			MaxTransitTime: days(option.MaxTransitDays),
+		})
+	}
+	p.MinHandlingTime, p.MaxHandlingTime = days(item.MinHandlingDays), days(item.MaxHandlingDays)
+	if item.NoIdentifier {
+		no := false
+		p.IdentifierExists = &no
+	}
+	if item.ProductType != "" {
+		p.ProductTypes = []string{item.ProductType}
+	}
+	if item.Size != "" {
+		p.Sizes = []string{item.Size}
+	}
+	if !item.AuctionEnd.IsZero() {
+		p.CustomAttributes = append(p.CustomAttributes, CustomAttribute{Name: "auction_end_time", Value: item.AuctionEnd.Format(time.RFC3339)})
+	}
+	if !item.StartingBid.IsZero() {
+		p.CustomAttributes = append(p.CustomAttributes, CustomAttribute{Name: "starting_bid", Value: item.StartingBid.String()})
+	}
+	return p, nil
+}
+
+// days formats a number of days, or "" when it is not known
+func days(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,114 @@
+package contentapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go_data_fashion_accessories/retry"
+)
+
+// ProductStatus is what Merchant Center reports about one product it
+// received, from the productstatuses endpoint
+type ProductStatus struct {
+	ProductID string      `json:"productId"`
+	Title     string      `json:"title"`
+	Link      string      `json:"link"`
+	Issues    []ItemIssue `json:"itemLevelIssues"`
+}
+
+// ItemIssue is one problem Merchant Center found on a product
+type ItemIssue struct {
+	Code          string `json:"code"`
+	Servability   string `json:"servability"` // disapproved or demoted
+	Resolution    string `json:"resolution"`  // merchant_action or pending_processing
+	Attribute     string `json:"attributeName"`
+	Destination   string `json:"destination"`
+	Description   string `json:"description"`
+	Detail        string `json:"detail"`
+	Documentation string `json:"documentation"`
+}
+
+// Disapproved is the Servability of issues that keep a product from
+// showing
+const Disapproved = "disapproved"
+
+// OfferID returns the offer ID in the product's REST ID, the reverse of
+// Client.ProductID
+func (s ProductStatus) OfferID() string {
+	parts := strings.SplitN(s.ProductID, ":", 4)
+	return parts[len(parts)-1]
+}
+
+// Disapproved reports whether any issue keeps the product from showing
+func (s ProductStatus) Disapproved() bool {
+	for _, issue := range s.Issues {
+		if issue.Servability == Disapproved {
+			return true
+		}
+	}
+	return false
+}
+
+// statusPageSize is the most statuses Google returns per page
+const statusPageSize = 250
+
+// Statuses lists the status of every product in the merchant account,
+// following the pages of the productstatuses endpoint
+func (c *Client) Statuses(ctx context.Context) ([]ProductStatus, error) {
+	var statuses []ProductStatus
+	token := ""
+	for {
+		query := url.Values{"maxResults": {fmt.Sprint(statusPageSize)}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		endpoint := c.cfg.Endpoint + "/" + url.PathEscape(c.cfg.MerchantID) + "/productstatuses?" + query.Encode()
+
+		var page struct {
+			Resources     []ProductStatus `json:"resources"`
+			NextPageToken string          `json:"nextPageToken"`
+		}
+		if err := c.do(ctx, "Content API product statuses", endpoint, &page); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, page.Resources...)
+		c.logger.Debug("Read Content API product statuses", "statuses", len(page.Resources))
+		if page.NextPageToken == "" {
+			return statuses, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// do sends a GET request, throttled and retried as the batches are, and
+// decodes the JSON response into v
+func (c *Client) do(ctx context.Context, op, url string, v any) error {
+	return retry.Do(ctx, c.logger, c.retry, op, func() error {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		res, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			var apiErr struct {
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			json.NewDecoder(res.Body).Decode(&apiErr)
+			return fmt.Errorf("GET %s: %s: %s", url, res.Status, apiErr.Error.Message)
+		}
+		return json.NewDecoder(res.Body).Decode(v)
+	})
+}
@@ -1,10 +1,6 @@
 package output
 
-import (
-	"encoding/json"
-	"encoding/xml"
-	"os"
-)
+import "encoding/xml"
 
 // Item represents a single product in the Google Merchant format
 type Item struct {
@@ -17,86 +13,50 @@ type Item struct {
 	Brand        string   `xml:"g:brand"`
 	Price        string   `xml:"g:price"`
 	Availability string   `xml:"g:availability"`
-	GTIN         string   `xml:"g:gtin"` // GTIN is for product identification
-}
 
-// Channel represents the channel information and items
-type Channel struct {
-	XMLName     xml.Name `xml:"channel"`
-	Title       string   `xml:"title"`
-	Link        string   `xml:"link"`
-	Description string   `xml:"description"`
-	Items       []Item   `xml:"item"`
-}
+	SalePrice              string `xml:"g:sale_price,omitempty"`
+	SalePriceEffectiveDate string `xml:"g:sale_price_effective_date,omitempty"` // start/end, ISO 8601
 
-// RSS is the root structure of the feed
-type RSS struct {
-	XMLName xml.Name `xml:"rss"`
-	Version string   `xml:"version,attr"`
-	NS      string   `xml:"xmlns:g,attr"`
-	Channel Channel  `xml:"channel"`
-}
+	GTIN             string `xml:"g:gtin,omitempty"` // GTIN is for product identification
+	MPN              string `xml:"g:mpn,omitempty"`
+	IdentifierExists string `xml:"g:identifier_exists,omitempty"` // "no" for products without a GTIN or MPN
 
-// AdItem is the internal representation of an ad item that gets converted to XML
-type AdItem struct {
-	ID           string      `json:"id"`
-	Title        string      `json:"title"`
-	Description  string      `json:"description"`
-	Link         string      `json:"link"`
-	ImageLink    string      `json:"image_link"`
-	Brand        string      `json:"brand"`
-	Price        string      `json:"price"`
-	Availability string      `json:"availability"`
-	GTIN         json.Number `json:"gtin"`
-}
+	AdditionalImageLinks []string `xml:"g:additional_image_link"` // one element per extra image
+
+	Shipping        []Shipping `xml:"g:shipping"`
+	MinHandlingTime string     `xml:"g:min_handling_time,omitempty"` // business days
+	MaxHandlingTime string     `xml:"g:max_handling_time,omitempty"`
 
-// WriteRSSFeedToFile generates and writes the RSS feed to an XML file
-func WriteRSSFeedToFile(adItems []AdItem) error {
-	// Convert []AdItem to []Item (XML format)
-	var items []Item
-	for _, ad := range adItems {
-		items = append(items, Item{
-			ID:           ad.ID,
-			Title:        ad.Title,
-			Description:  ad.Description,
-			Link:         ad.Link,
-			ImageLink:    ad.ImageLink,
-			Brand:        ad.Brand,
-			Price:        ad.Price,
-			Availability: ad.Availability,
-			GTIN:         ad.GTIN.String(),
-		})
-	}
+	GoogleProductCategory string `xml:"g:google_product_category,omitempty"`
+	ProductType           string `xml:"g:product_type,omitempty"`
 
-	rssFeed := RSS{
-		Version: "2.0",
-		NS:      "http://base.google.com/ns/1.0", // Google Merchant namespace
-		Channel: Channel{
-			Title:       "Ayshei",              // Your shop name
-			Link:        "https://ayshei.com/", // Your shop link
-			Description: "Your one-stop shop for the latest fashion items",
-			Items:       items, // List of products
-		},
-	}
+	ItemGroupID string `xml:"g:item_group_id,omitempty"` // parent ad of a variant
+	Color       string `xml:"g:color,omitempty"`
+	Size        string `xml:"g:size,omitempty"`
+	Material    string `xml:"g:material,omitempty"`
+	Gender      string `xml:"g:gender,omitempty"`
+	AgeGroup    string `xml:"g:age_group,omitempty"`
+	Condition   string `xml:"g:condition,omitempty"`
 
-	// Marshal the RSS feed into XML with indentation for readability
-	xmlOutput, err := xml.MarshalIndent(rssFeed, "", "  ")
-	if err != nil {
-		return err
-	}
+	// Campaign segments set by the CustomLabels of the config
+	CustomLabel0 string `xml:"g:custom_label_0,omitempty"`
+	CustomLabel1 string `xml:"g:custom_label_1,omitempty"`
+	CustomLabel2 string `xml:"g:custom_label_2,omitempty"`
+	CustomLabel3 string `xml:"g:custom_label_3,omitempty"`
+	CustomLabel4 string `xml:"g:custom_label_4,omitempty"`
 
-	// Create or overwrite the XML file
-	file, err := os.Create("productsfashionaccessories.xml")
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	PromotionIDs []string `xml:"g:promotion_id"` // one element per promotion of the promotions feed
 
-	// Write the XML header and the generated XML content
-	_, err = file.WriteString(xml.Header + string(xmlOutput))
-	if err != nil {
-		return err
-	}
+	// Auction details, only set in the auction feed
+	AuctionEndTime string `xml:"g:auction_end_time,omitempty"`
+	StartingBid    string `xml:"g:starting_bid,omitempty"`
+}
 
-	return nil
+// Shipping is one delivery option of an item. The country is left to the
+// feed's target country.
+type Shipping struct {
+	Service        string `xml:"g:service,omitempty"`
+	Price          string `xml:"g:price"`
+	MinTransitTime string `xml:"g:min_transit_time,omitempty"` // business days
+	MaxTransitTime string `xml:"g:max_transit_time,omitempty"`
 }
@@ -0,0 +1,174 @@
+// Package googlefeed serializes ads as a Google Merchant Center RSS 2.0 feed.
+package googlefeed
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/model/output"
+)
+
+// Namespace is the Google Merchant namespace bound to the g: prefix
+const Namespace = "http://base.google.com/ns/1.0"
+
+// Channel metadata written at the top of every feed
+const (
+	ChannelTitle       = "Ayshei"
+	ChannelLink        = "https://ayshei.com/"
+	ChannelDescription = "Your one-stop shop for the latest fashion items"
+)
+
+var (
+	rssStart = xml.StartElement{
+		Name: xml.Name{Local: "rss"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "version"}, Value: "2.0"},
+			{Name: xml.Name{Local: "xmlns:g"}, Value: Namespace},
+		},
+	}
+	channelStart = xml.StartElement{Name: xml.Name{Local: "channel"}}
+)
+
+// Writer streams feed items one at a time. Values are escaped by
+// encoding/xml, so callers must pass plain (unescaped) text.
+type Writer struct {
+	w       io.Writer
+	encoder *xml.Encoder
+}
+
+// NewWriter writes the feed header and channel metadata to w
+func NewWriter(w io.Writer) (*Writer, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.EncodeToken(rssStart); err != nil {
+		return nil, err
+	}
+	if err := encoder.EncodeToken(channelStart); err != nil {
+		return nil, err
+	}
+	metadata := []struct{ name, value string }{
+		{"title", ChannelTitle},
+		{"link", ChannelLink},
+		{"description", ChannelDescription},
+	}
+	for _, m := range metadata {
+		if err := encoder.EncodeElement(m.value, xml.StartElement{Name: xml.Name{Local: m.name}}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Writer{w: w, encoder: encoder}, nil
+}
+
+// Write adds one item to the feed
+func (w *Writer) Write(ad input.AdItem) error {
+	it, err := feed.FromAdItem(ad)
+	if err != nil {
+		return err
+	}
+	identifierExists := ""
+	if it.NoIdentifier {
+		identifierExists = "no"
+	}
+	auctionEnd, startingBid, salePrice := "", "", ""
+	if !it.AuctionEnd.IsZero() {
+		auctionEnd = it.AuctionEnd.Format(time.RFC3339)
+	}
+	if !it.StartingBid.IsZero() {
+		startingBid = it.StartingBid.String()
+	}
+	if !it.SalePrice.IsZero() {
+		salePrice = it.SalePrice.String()
+	}
+	return w.encoder.Encode(output.Item{
+		ID:           it.ID,
+		Title:        it.Title,
+		Description:  it.Description,
+		Link:         feed.URLString(it.Link),
+		ImageLink:    feed.URLString(it.ImageLink),
+		Brand:        it.Brand,
+		Price:        it.Price.String(),
+		Availability: string(it.Availability),
+
+		SalePrice:              salePrice,
+		SalePriceEffectiveDate: it.SalePriceEffectiveDate(),
+
+		GTIN:             it.GTIN,
+		MPN:              it.MPN,
+		IdentifierExists: identifierExists,
+
+		AdditionalImageLinks: feed.URLStrings(it.AdditionalImageLinks),
+
+		Shipping:        shipping(it.Shipping),
+		MinHandlingTime: days(it.MinHandlingDays),
+		MaxHandlingTime: days(it.MaxHandlingDays),
+
+		GoogleProductCategory: it.GoogleProductCategory,
+		ProductType:           it.ProductType,
+
+		ItemGroupID: it.ItemGroupID,
+		Color:       it.Color,
+		Size:        it.Size,
+		Material:    it.Material,
+		Gender:      it.Gender,
+		AgeGroup:    it.AgeGroup,
+		Condition:   string(it.Condition),
+
+		CustomLabel0: it.CustomLabels[0],
+		CustomLabel1: it.CustomLabels[1],
+		CustomLabel2: it.CustomLabels[2],
+		CustomLabel3: it.CustomLabels[3],
+		CustomLabel4: it.CustomLabels[4],
+
+		PromotionIDs: it.PromotionIDs,
+
+		AuctionEndTime: auctionEnd,
+		StartingBid:    startingBid,
+	})
+}
+
+// shipping returns the g:shipping elements of an item's delivery options
+func shipping(options []input.Shipping) []output.Shipping {
+	var elements []output.Shipping
+	for _, option := range options {
+		elements = append(elements, output.Shipping{
+			Service:        option.Service,
+			Price:          option.Price.String(),
+			MinTransitTime: days(option.MinTransitDays),
+			MaxTransitTime: days(option.MaxTransitDays),
+		})
+	}
+	return elements
+}
+
+// days formats a number of days, or "" when it is not known
+func days(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// Close ends the channel and rss elements. The underlying writer is left
+// open.
+func (w *Writer) Close() error {
+	err := w.encoder.EncodeToken(channelStart.End())
+	if err == nil {
+		err = w.encoder.EncodeToken(rssStart.End())
+	}
+	if err == nil {
+		err = w.encoder.Flush()
+	}
+	if err == nil {
+		_, err = io.WriteString(w.w, "\n")
+	}
+	return err
+}
@@ -0,0 +1,210 @@
+// Package jsonld renders items as schema.org Product structured data in
+// JSON-LD, for the web team to embed on product pages in a
+// <script type="application/ld+json"> element.
+package jsonld
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+)
+
+// ContentType is the media type of a JSON-LD document
+const ContentType = "application/ld+json"
+
+// Product is a schema.org Product
+type Product struct {
+	Context     string   `json:"@context"`
+	Type        string   `json:"@type"`
+	Name        string   `json:"name"`
+	Image       []string `json:"image,omitempty"`
+	Description string   `json:"description,omitempty"`
+	SKU         string   `json:"sku"`
+	GTIN8       string   `json:"gtin8,omitempty"`
+	GTIN12      string   `json:"gtin12,omitempty"`
+	GTIN13      string   `json:"gtin13,omitempty"`
+	GTIN14      string   `json:"gtin14,omitempty"`
+	MPN         string   `json:"mpn,omitempty"`
+	Brand       *Brand   `json:"brand,omitempty"`
+	Color       string   `json:"color,omitempty"`
+	Size        string   `json:"size,omitempty"`
+	Material    string   `json:"material,omitempty"`
+	Offers      Offer    `json:"offers"`
+}
+
+// Brand is a schema.org Brand
+type Brand struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+// Offer is a schema.org Offer
+type Offer struct {
+	Type          string `json:"@type"`
+	URL           string `json:"url"`
+	Price         string `json:"price"` // decimal amount, e.g. "1200.00"
+	PriceCurrency string `json:"priceCurrency"`
+	Availability  string `json:"availability,omitempty"`
+	ItemCondition string `json:"itemCondition,omitempty"`
+}
+
+// availability maps feed availability values to schema.org ItemAvailability
+var availability = map[feed.Availability]string{
+	feed.InStock:    "https://schema.org/InStock",
+	feed.OutOfStock: "https://schema.org/OutOfStock",
+	feed.Preorder:   "https://schema.org/PreOrder",
+	feed.Backorder:  "https://schema.org/BackOrder",
+}
+
+// condition maps feed conditions to schema.org OfferItemCondition
+var condition = map[feed.Condition]string{
+	feed.New:         "https://schema.org/NewCondition",
+	feed.Used:        "https://schema.org/UsedCondition",
+	feed.Refurbished: "https://schema.org/RefurbishedCondition",
+}
+
+// NewProduct returns the structured data of an item
+func NewProduct(it feed.Item) Product {
+	p := Product{
+		Context:     "https://schema.org",
+		Type:        "Product",
+		Name:        it.Title,
+		Description: it.Description,
+		SKU:         it.ID,
+		MPN:         it.MPN,
+		Color:       it.Color,
+		Size:        it.Size,
+		Material:    it.Material,
+		Offers: Offer{
+			Type:          "Offer",
+			URL:           feed.URLString(it.Link),
+			Price:         it.Price.Decimal(),
+			PriceCurrency: it.Price.Currency,
+			Availability:  availability[it.Availability],
+			ItemCondition: condition[it.Condition],
+		},
+	}
+	if it.ImageLink != nil {
+		p.Image = append([]string{it.ImageLink.String()}, feed.URLStrings(it.AdditionalImageLinks)...)
+	}
+	if it.Brand != "" {
+		p.Brand = &Brand{Type: "Brand", Name: it.Brand}
+	}
+	// schema.org has a property per GTIN length
+	switch len(it.GTIN) {
+	case 8:
+		p.GTIN8 = it.GTIN
+	case 12:
+		p.GTIN12 = it.GTIN
+	case 13:
+		p.GTIN13 = it.GTIN
+	case 14:
+		p.GTIN14 = it.GTIN
+	}
+	return p
+}
+
+// Marshal returns the JSON-LD document of an item
+func Marshal(it feed.Item) ([]byte, error) {
+	return json.MarshalIndent(NewProduct(it), "", "  ")
+}
+
+// FileName returns the name of the file DirWriter writes for an item ID,
+// with characters that are unsafe in file names replaced
+func FileName(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, id) + ".json"
+}
+
+// DirWriter writes one JSON-LD file per item into a directory. When it is
+// closed, files of items that were not written are removed, so it must be
+// given every item rather than only the changed ones.
+type DirWriter struct {
+	dir     string
+	written map[string]bool
+}
+
+// NewDirWriter creates dir if needed
+func NewDirWriter(dir string) (*DirWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirWriter{dir: dir, written: map[string]bool{}}, nil
+}
+
+// Write writes the file of one item
+func (w *DirWriter) Write(ad input.AdItem) error {
+	it, err := feed.FromAdItem(ad)
+	if err != nil {
+		return err
+	}
+	data, err := Marshal(it)
+	if err != nil {
+		return err
+	}
+	name := FileName(it.ID)
+	w.written[name] = true
+	return os.WriteFile(filepath.Join(w.dir, name), append(data, '\n'), 0o644)
+}
+
+// Close removes the files of items that are no longer in the feed
+func (w *DirWriter) Close() error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasSuffix(name, ".json") && !w.written[name] {
+			errs = append(errs, os.Remove(filepath.Join(w.dir, name)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Abort keeps the files of items that were not written, as the run that
+// failed may not have reached them
+func (w *DirWriter) Abort() error {
+	return nil
+}
+
+// Collector keeps the JSON-LD document of each item in memory by item ID,
+// for serving over HTTP
+type Collector struct {
+	Products map[string][]byte
+}
+
+// NewCollector returns an empty Collector
+func NewCollector() *Collector {
+	return &Collector{Products: map[string][]byte{}}
+}
+
+// Write renders one item
+func (c *Collector) Write(ad input.AdItem) error {
+	it, err := feed.FromAdItem(ad)
+	if err != nil {
+		return err
+	}
+	data, err := Marshal(it)
+	if err != nil {
+		return err
+	}
+	c.Products[it.ID] = data
+	return nil
+}
+
+// Close implements pipeline.Sink
+func (c *Collector) Close() error {
+	return nil
+}
@@ -0,0 +1,82 @@
+// Package localinventory writes the Google Merchant local product inventory
+// feed, which lists the stock of each item in the physical stores of its
+// seller. Items are matched to the main feed by id; items without store
+// stock are left out.
+package localinventory
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+)
+
+// Columns is the header row of the feed, in the order values are written
+var Columns = []string{
+	"store_code",
+	"id",
+	"quantity",
+	"availability",
+	"price",
+	"pickup_method",
+	"pickup_sla",
+}
+
+// Writer streams one tab separated row per item and store
+type Writer struct {
+	tsv *csv.Writer
+}
+
+// NewWriter writes the header row to w
+func NewWriter(w io.Writer) (*Writer, error) {
+	tsv := csv.NewWriter(w)
+	tsv.Comma = '\t'
+	if err := tsv.Write(Columns); err != nil {
+		return nil, err
+	}
+	return &Writer{tsv: tsv}, nil
+}
+
+// Write adds a row for every store the item is stocked in
+func (w *Writer) Write(ad input.AdItem) error {
+	it, err := feed.FromAdItem(ad)
+	if err != nil {
+		return err
+	}
+	for _, store := range it.Stores {
+		if err := w.tsv.Write(Row(it, store)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Row returns the values of an item in one store, in the order of Columns
+func Row(it feed.Item, store input.StoreStock) []string {
+	availability := feed.OutOfStock
+	if store.Quantity > 0 {
+		availability = feed.InStock
+	}
+	return []string{
+		store.StoreCode,
+		it.ID,
+		strconv.Itoa(max(store.Quantity, 0)),
+		string(availability),
+		it.Price.String(),
+		store.PickupMethod,
+		store.PickupSLA,
+	}
+}
+
+// Flush writes any buffered rows to the underlying writer
+func (w *Writer) Flush() error {
+	w.tsv.Flush()
+	return w.tsv.Error()
+}
+
+// Close flushes buffered rows. The underlying writer is left open.
+func (w *Writer) Close() error {
+	return w.Flush()
+}
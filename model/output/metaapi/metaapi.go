@@ -0,0 +1,236 @@
+// Package metaapi pushes items to a Meta Commerce catalog with the Graph API
+// items_batch endpoint, as an alternative to uploading the catalog CSV.
+// Items carry the same fields as the CSV columns.
+package metaapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/httpclient"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/model/output/metacsv"
+	"go_data_fashion_accessories/retry"
+)
+
+// Client sends item batches to one catalog. It is not safe for concurrent
+// use.
+type Client struct {
+	cfg    config.MetaAPI
+	retry  config.Retry
+	http   *http.Client
+	logger *slog.Logger
+
+	// Errors lists the items Meta rejected so far
+	Errors []ItemError
+}
+
+// ItemError is an item that Meta rejected
+type ItemError struct {
+	RetailerID string
+	Method     string // UPDATE or DELETE
+	Message    string
+}
+
+func (e ItemError) Error() string {
+	return fmt.Sprintf("%s %s: %s", e.Method, e.RetailerID, e.Message)
+}
+
+// New returns a client for the catalog and access token in cfg.MetaAPI. A
+// nil logger uses slog.Default().
+func New(cfg *config.Config, logger *slog.Logger) (*Client, error) {
+	client, err := httpclient.NewRetrying(cfg.HTTP, 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		cfg:    cfg.MetaAPI,
+		retry:  cfg.Retry,
+		http:   client,
+		logger: logging.OrDefault(logger),
+	}, nil
+}
+
+// request is one entry of an items_batch call. UPDATE with allow_upsert
+// creates items that do not exist yet.
+type request struct {
+	Method string         `json:"method"`
+	Data   map[string]any `json:"data"`
+}
+
+// Update creates or updates the catalog items of items
+func (c *Client) Update(ctx context.Context, items []input.AdItem) error {
+	requests := make([]request, len(items))
+	for i, item := range items {
+		it, err := feed.FromAdItem(item)
+		if err != nil {
+			return err
+		}
+		requests[i] = request{Method: "UPDATE", Data: data(it)}
+	}
+	return c.batch(ctx, requests)
+}
+
+// Delete removes the catalog items with the given retailer IDs
+func (c *Client) Delete(ctx context.Context, ids []string) error {
+	for start := 0; start < len(ids); start += c.cfg.BatchSize {
+		chunk := ids[start:min(start+c.cfg.BatchSize, len(ids))]
+		requests := make([]request, len(chunk))
+		for i, id := range chunk {
+			requests[i] = request{Method: "DELETE", Data: map[string]any{"id": id}}
+		}
+		if err := c.batch(ctx, requests); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// data maps an item to the fields of its catalog item: the CSV columns,
+// without empty values and with the currency in the price
+func data(it feed.Item) map[string]any {
+	row := metacsv.Row(it)
+	fields := make(map[string]any, len(row))
+	for i, column := range metacsv.Columns {
+		if row[i] != "" {
+			fields[column] = row[i]
+		}
+	}
+	delete(fields, "currency")
+	fields["price"] = it.Price.String()
+	if !it.StartingBid.IsZero() {
+		fields["starting_bid"] = it.StartingBid.String()
+	}
+	if !it.SalePrice.IsZero() {
+		fields["sale_price"] = it.SalePrice.String()
+	}
+	if len(it.AdditionalImageLinks) > 0 {
+		fields["additional_image_link"] = feed.URLStrings(it.AdditionalImageLinks)
+	}
+	return fields
+}
+
+// batchResponse is the reply to an items_batch call
+type batchResponse struct {
+	Handles          []string `json:"handles"`
+	ValidationStatus []struct {
+		RetailerID string `json:"retailer_id"`
+		Errors     []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"validation_status"`
+	Error *struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+// batch sends one items_batch call and records the items Meta rejected
+func (c *Client) batch(ctx context.Context, requests []request) error {
+	if len(requests) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(requests)
+	if err != nil {
+		return err
+	}
+	form := url.Values{
+		"access_token": {c.cfg.AccessToken},
+		"item_type":    {"PRODUCT_ITEM"},
+		"allow_upsert": {"true"},
+		"requests":     {string(encoded)},
+	}
+	endpoint := fmt.Sprintf("%s/%s/items_batch", c.cfg.Endpoint, c.cfg.CatalogID)
+
+	var response batchResponse
+	err = retry.Do(ctx, c.logger, c.retry, "Meta items batch", func() error {
+		response = batchResponse{}
+		return c.post(ctx, endpoint, form, &response)
+	})
+	if err != nil {
+		return fmt.Errorf("meta items batch of %d: %w", len(requests), err)
+	}
+
+	method := requests[0].Method
+	for _, status := range response.ValidationStatus {
+		for _, e := range status.Errors {
+			itemErr := ItemError{RetailerID: status.RetailerID, Method: method, Message: e.Message}
+			c.Errors = append(c.Errors, itemErr)
+			c.logger.Warn("Meta rejected item", "retailer_id", itemErr.RetailerID, "method", method, "error", itemErr.Message)
+		}
+	}
+	c.logger.Debug("Sent Meta items batch", "method", method, "requests", len(requests), "handles", strings.Join(response.Handles, ","))
+	return nil
+}
+
+// post sends form and decodes the JSON response into v. Graph API errors
+// come back as an error object with a 4xx status.
+func (c *Client) post(ctx context.Context, endpoint string, form url.Values, v *batchResponse) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil && res.StatusCode == http.StatusOK {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		message := res.Status
+		if v.Error != nil {
+			message = v.Error.Message
+		}
+		return fmt.Errorf("POST %s: %s", endpoint, message)
+	}
+	return nil
+}
+
+// Sink updates the items written to it in batches of BatchSize
+type Sink struct {
+	ctx     context.Context
+	client  *Client
+	pending []input.AdItem
+}
+
+// NewSink returns a sink sending items with client. ctx bounds every
+// request, as Write and Close take none.
+func NewSink(ctx context.Context, client *Client) *Sink {
+	return &Sink{ctx: ctx, client: client}
+}
+
+// Write queues an item, sending the batch once it is full
+func (s *Sink) Write(item input.AdItem) error {
+	s.pending = append(s.pending, item)
+	if len(s.pending) < s.client.cfg.BatchSize {
+		return nil
+	}
+	return s.flush()
+}
+
+// Close sends the last batch
+func (s *Sink) Close() error {
+	return s.flush()
+}
+
+func (s *Sink) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	err := s.client.Update(s.ctx, s.pending)
+	s.pending = s.pending[:0]
+	return err
+}
@@ -0,0 +1,103 @@
+// Package metacsv exports ads as a Meta Commerce (Facebook/Instagram Shops)
+// catalog CSV file.
+package metacsv
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/output/tabular"
+)
+
+// Columns is the catalog header row, in the order values are written
+var Columns = []string{
+	"id",
+	"title",
+	"description",
+	"availability",
+	"condition",
+	"price",
+	"currency",
+	"link",
+	"image_link",
+	"additional_image_link",
+	"brand",
+	"gtin",
+	"mpn",
+	"google_product_category",
+	"product_type",
+	"item_group_id",
+	"color",
+	"size",
+	"material",
+	"gender",
+	"age_group",
+	"auction_end_time",
+	"starting_bid",
+	"quantity_to_sell_on_facebook",
+	"sale_price",
+	"sale_price_effective_date",
+	"custom_label_0",
+	"custom_label_1",
+	"custom_label_2",
+	"custom_label_3",
+	"custom_label_4",
+}
+
+// NewWriter writes the header row to w
+func NewWriter(w io.Writer) (*tabular.Writer, error) {
+	return tabular.NewWriter(w, ',', Columns, Row)
+}
+
+// Row returns the catalog values of an item, in the order of Columns
+func Row(it feed.Item) []string {
+	auctionEnd, startingBid, quantity, salePrice := "", "", "", ""
+	if !it.AuctionEnd.IsZero() {
+		auctionEnd = it.AuctionEnd.Format(time.RFC3339)
+	}
+	if !it.StartingBid.IsZero() {
+		startingBid = it.StartingBid.Decimal()
+	}
+	if !it.SalePrice.IsZero() {
+		salePrice = it.SalePrice.Decimal()
+	}
+	if it.Quantity != nil {
+		quantity = strconv.Itoa(*it.Quantity)
+	}
+	return []string{
+		it.ID,
+		it.Title,
+		it.Description,
+		string(it.Availability),
+		tabular.Condition(it),
+		it.Price.Decimal(),
+		it.Price.Currency,
+		feed.URLString(it.Link),
+		feed.URLString(it.ImageLink),
+		strings.Join(feed.URLStrings(it.AdditionalImageLinks), ","), // Meta takes a comma separated list
+		it.Brand,
+		it.GTIN,
+		it.MPN,
+		it.GoogleProductCategory,
+		it.ProductType,
+		it.ItemGroupID,
+		it.Color,
+		it.Size,
+		it.Material,
+		it.Gender,
+		it.AgeGroup,
+		auctionEnd,
+		startingBid,
+		quantity,
+		salePrice,
+		it.SalePriceEffectiveDate(),
+		it.CustomLabels[0],
+		it.CustomLabels[1],
+		it.CustomLabels[2],
+		it.CustomLabels[3],
+		it.CustomLabels[4],
+	}
+}
@@ -0,0 +1,167 @@
+// Package ndjson exports ads as newline delimited JSON, one object per item,
+// for loading into data warehouses such as BigQuery or Snowflake or for
+// piping into jq.
+package ndjson
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+)
+
+// Record is the JSON object written for one item. Field names are part of
+// the output's schema: add fields, but do not rename or remove them.
+type Record struct {
+	ID                    string   `json:"id"`
+	AdID                  string   `json:"ad_id"`
+	Title                 string   `json:"title"`
+	Description           string   `json:"description"`
+	Link                  string   `json:"link"`
+	ImageLink             string   `json:"image_link"`
+	AdditionalImageLinks  []string `json:"additional_image_links"`
+	Brand                 string   `json:"brand"`
+	Price                 string   `json:"price"` // decimal amount, e.g. "1200.00"
+	Currency              string   `json:"currency"`
+	Availability          string   `json:"availability"`
+	Condition             string   `json:"condition"`
+	GTIN                  string   `json:"gtin"`
+	MPN                   string   `json:"mpn"`
+	IdentifierExists      bool     `json:"identifier_exists"`
+	ItemGroupID           string   `json:"item_group_id"`
+	Color                 string   `json:"color"`
+	Size                  string   `json:"size"`
+	Material              string   `json:"material"`
+	Gender                string   `json:"gender"`
+	AgeGroup              string   `json:"age_group"`
+	Subcategory           string   `json:"subcategory"`
+	SubcategoryName       string   `json:"subcategory_name"`
+	GoogleProductCategory string   `json:"google_product_category"`
+	ProductType           string   `json:"product_type"`
+	Feed                  string   `json:"feed"`
+
+	// Times are left out rather than written as zero so they load as NULL
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	AuctionEnd  *time.Time `json:"auction_end_time,omitempty"`
+	StartingBid string     `json:"starting_bid,omitempty"`
+	Quantity    *int       `json:"quantity,omitempty"` // left out when the ad does not say
+
+	SalePrice              string `json:"sale_price,omitempty"`
+	SalePriceEffectiveDate string `json:"sale_price_effective_date,omitempty"`
+
+	Shipping        []Shipping `json:"shipping,omitempty"`
+	MinHandlingDays int        `json:"min_handling_days,omitempty"`
+	MaxHandlingDays int        `json:"max_handling_days,omitempty"`
+
+	CustomLabels []string `json:"custom_labels,omitempty"` // custom_label_0 to 4, trailing empty ones left out
+}
+
+// Shipping is one delivery option of a record, with its delivery time in
+// business days
+type Shipping struct {
+	Service         string `json:"service"`
+	Price           string `json:"price"`
+	Currency        string `json:"currency"`
+	MinDeliveryDays int    `json:"min_delivery_days,omitempty"`
+	MaxDeliveryDays int    `json:"max_delivery_days,omitempty"`
+}
+
+// NewRecord returns the record of an item
+func NewRecord(it feed.Item) Record {
+	images := feed.URLStrings(it.AdditionalImageLinks)
+	if images == nil {
+		images = []string{}
+	}
+	r := Record{
+		ID:                    it.ID,
+		AdID:                  it.AdID,
+		Title:                 it.Title,
+		Description:           it.Description,
+		Link:                  feed.URLString(it.Link),
+		ImageLink:             feed.URLString(it.ImageLink),
+		AdditionalImageLinks:  images,
+		Brand:                 it.Brand,
+		Price:                 it.Price.Decimal(),
+		Currency:              it.Price.Currency,
+		Availability:          string(it.Availability),
+		Condition:             string(it.Condition),
+		GTIN:                  it.GTIN,
+		MPN:                   it.MPN,
+		IdentifierExists:      !it.NoIdentifier,
+		ItemGroupID:           it.ItemGroupID,
+		Color:                 it.Color,
+		Size:                  it.Size,
+		Material:              it.Material,
+		Gender:                it.Gender,
+		AgeGroup:              it.AgeGroup,
+		Subcategory:           it.Subcategory,
+		SubcategoryName:       it.SubcategoryName,
+		GoogleProductCategory: it.GoogleProductCategory,
+		ProductType:           it.ProductType,
+		Feed:                  it.Feed,
+		Quantity:              it.Quantity,
+		MinHandlingDays:       it.MinHandlingDays,
+		MaxHandlingDays:       it.MaxHandlingDays,
+	}
+	for i := len(it.CustomLabels); i > 0; i-- {
+		if it.CustomLabels[i-1] != "" {
+			r.CustomLabels = it.CustomLabels[:i]
+			break
+		}
+	}
+	for _, option := range it.Shipping {
+		r.Shipping = append(r.Shipping, Shipping{
+			Service:         option.Service,
+			Price:           option.Price.Decimal(),
+			Currency:        option.Price.Currency,
+			MinDeliveryDays: option.MinTransitDays,
+			MaxDeliveryDays: option.MaxTransitDays,
+		})
+	}
+	if !it.UpdatedAt.IsZero() {
+		t := it.UpdatedAt.UTC()
+		r.UpdatedAt = &t
+	}
+	if !it.AuctionEnd.IsZero() {
+		t := it.AuctionEnd.UTC()
+		r.AuctionEnd = &t
+	}
+	if !it.StartingBid.IsZero() {
+		r.StartingBid = it.StartingBid.Decimal()
+	}
+	if !it.SalePrice.IsZero() {
+		r.SalePrice = it.SalePrice.Decimal()
+		r.SalePriceEffectiveDate = it.SalePriceEffectiveDate()
+	}
+	return r
+}
+
+// Writer streams one JSON object per line
+type Writer struct {
+	encoder *json.Encoder
+}
+
+// NewWriter returns a Writer encoding to w. NDJSON has no header, so
+// nothing is written until the first item.
+func NewWriter(w io.Writer) (*Writer, error) {
+	encoder := json.NewEncoder(w)
+	// Links and descriptions stay readable; this is not embedded in HTML
+	encoder.SetEscapeHTML(false)
+	return &Writer{encoder: encoder}, nil
+}
+
+// Write adds the line of one item
+func (w *Writer) Write(ad input.AdItem) error {
+	it, err := feed.FromAdItem(ad)
+	if err != nil {
+		return err
+	}
+	return w.encoder.Encode(NewRecord(it))
+}
+
+// Close implements pipeline.Sink; lines are written as they are encoded
+func (w *Writer) Close() error {
+	return nil
+}
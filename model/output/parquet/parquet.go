@@ -0,0 +1,131 @@
+// Package parquet exports ads as an Apache Parquet file, so daily feed
+// snapshots can be queried in the data lake without a conversion step.
+// Files are gzip compressed, in row groups of RowGroupSize items.
+package parquet
+
+import (
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+)
+
+// RowGroupSize is the number of items buffered before a row group is
+// written
+const RowGroupSize = 50_000
+
+// createdBy is recorded in the file metadata
+const createdBy = "feedgen"
+
+// Record is one row of the file. Column names match the fields of the
+// ndjson format.
+type Record struct {
+	ID                    string   `parquet:"id"`
+	AdID                  string   `parquet:"ad_id"`
+	Title                 string   `parquet:"title"`
+	Description           string   `parquet:"description"`
+	Link                  string   `parquet:"link"`
+	ImageLink             string   `parquet:"image_link"`
+	AdditionalImageLinks  []string `parquet:"additional_image_links,list"`
+	Brand                 string   `parquet:"brand"`
+	Price                 string   `parquet:"price"` // decimal amount, e.g. "1200.00"
+	PriceMinor            int64    `parquet:"price_minor"`
+	Currency              string   `parquet:"currency"`
+	Availability          string   `parquet:"availability"`
+	Condition             string   `parquet:"condition"`
+	GTIN                  string   `parquet:"gtin"`
+	MPN                   string   `parquet:"mpn"`
+	IdentifierExists      bool     `parquet:"identifier_exists"`
+	ItemGroupID           string   `parquet:"item_group_id"`
+	Color                 string   `parquet:"color"`
+	Size                  string   `parquet:"size"`
+	Material              string   `parquet:"material"`
+	Gender                string   `parquet:"gender"`
+	AgeGroup              string   `parquet:"age_group"`
+	Subcategory           string   `parquet:"subcategory"`
+	SubcategoryName       string   `parquet:"subcategory_name"`
+	GoogleProductCategory string   `parquet:"google_product_category"`
+	ProductType           string   `parquet:"product_type"`
+	Feed                  string   `parquet:"feed"`
+	// Optional columns are null for zero values. Times are microseconds
+	// since the Unix epoch.
+	UpdatedAt      int64  `parquet:"updated_at,optional,timestamp(microsecond)"`
+	AuctionEndTime int64  `parquet:"auction_end_time,optional,timestamp(microsecond)"`
+	StartingBid    string `parquet:"starting_bid,optional"`
+}
+
+// NewRecord returns the row of an item
+func NewRecord(it feed.Item) Record {
+	r := Record{
+		ID:                    it.ID,
+		AdID:                  it.AdID,
+		Title:                 it.Title,
+		Description:           it.Description,
+		Link:                  feed.URLString(it.Link),
+		ImageLink:             feed.URLString(it.ImageLink),
+		AdditionalImageLinks:  feed.URLStrings(it.AdditionalImageLinks),
+		Brand:                 it.Brand,
+		Price:                 it.Price.Decimal(),
+		PriceMinor:            it.Price.Minor,
+		Currency:              it.Price.Currency,
+		Availability:          string(it.Availability),
+		Condition:             string(it.Condition),
+		GTIN:                  it.GTIN,
+		MPN:                   it.MPN,
+		IdentifierExists:      !it.NoIdentifier,
+		ItemGroupID:           it.ItemGroupID,
+		Color:                 it.Color,
+		Size:                  it.Size,
+		Material:              it.Material,
+		Gender:                it.Gender,
+		AgeGroup:              it.AgeGroup,
+		Subcategory:           it.Subcategory,
+		SubcategoryName:       it.SubcategoryName,
+		GoogleProductCategory: it.GoogleProductCategory,
+		ProductType:           it.ProductType,
+		Feed:                  it.Feed,
+	}
+	if !it.UpdatedAt.IsZero() {
+		r.UpdatedAt = it.UpdatedAt.UnixMicro()
+	}
+	if !it.AuctionEnd.IsZero() {
+		r.AuctionEndTime = it.AuctionEnd.UnixMicro()
+	}
+	if !it.StartingBid.IsZero() {
+		r.StartingBid = it.StartingBid.Decimal()
+	}
+	return r
+}
+
+// Writer streams items into row groups
+type Writer struct {
+	parquet *parquet.GenericWriter[Record]
+}
+
+// NewWriter returns a Writer to w. Nothing is written before the first
+// row group.
+func NewWriter(w io.Writer) (*Writer, error) {
+	return &Writer{parquet: parquet.NewGenericWriter[Record](w,
+		parquet.Compression(&parquet.Gzip),
+		parquet.MaxRowsPerRowGroup(RowGroupSize),
+		parquet.CreatedBy(createdBy, "", ""),
+	)}, nil
+}
+
+// Write adds one item
+func (w *Writer) Write(ad input.AdItem) error {
+	it, err := feed.FromAdItem(ad)
+	if err != nil {
+		return err
+	}
+	_, err = w.parquet.Write([]Record{NewRecord(it)})
+	return err
+}
+
+// Close writes the last row group and the file metadata. The underlying
+// writer is left open.
+func (w *Writer) Close() error {
+	return w.parquet.Close()
+}
@@ -0,0 +1,171 @@
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/money"
+)
+
+// write writes ads to a file in memory
+func write(t *testing.T, ads []input.AdItem) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ad := range ads {
+		if err := w.Write(ad); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	ads := []input.AdItem{
+		{
+			ID:                   "a1",
+			AdID:                 "1",
+			Title:                "Gucci Marmont bag",
+			AdditionalImageLinks: []string{"https://example.com/1.jpg", "https://example.com/2.jpg"},
+			Brand:                "Gucci",
+			Price:                money.Money{Minor: 120000, Currency: "AED"},
+			UpdatedAt:            updated,
+			StartingBid:          money.Money{Minor: 50000, Currency: "AED"},
+		},
+		{
+			ID:           "a2",
+			AdID:         "2",
+			Title:        "ساعة رولكس",
+			Price:        money.Money{Minor: 999, Currency: "AED"},
+			NoIdentifier: true,
+		},
+	}
+	data := write(t, ads)
+
+	got, err := parquet.Read[Record](bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []Record
+	for _, ad := range ads {
+		it, err := feed.FromAdItem(ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, NewRecord(it))
+	}
+	// An empty list reads back as an empty slice
+	want[1].AdditionalImageLinks = []string{}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("read back\n%+v\nwant\n%+v", got, want)
+	}
+	if got[0].Price != "1200.00" || !got[0].IdentifierExists || got[1].IdentifierExists {
+		t.Errorf("unexpected values %+v", got)
+	}
+	if got[0].UpdatedAt != updated.UnixMicro() || got[0].StartingBid != "500.00" {
+		t.Errorf("unexpected optional values %+v", got[0])
+	}
+}
+
+func TestSchema(t *testing.T) {
+	data := write(t, []input.AdItem{{ID: "a1"}})
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := file.Schema()
+
+	images, ok := schema.Lookup("additional_image_links", "list", "element")
+	if !ok {
+		t.Fatalf("no LIST of additional_image_links in\n%s", schema)
+	}
+	if images.MaxRepetitionLevel != 1 {
+		t.Errorf("additional_image_links repetition level %d, want 1", images.MaxRepetitionLevel)
+	}
+	list := schema.Fields()[columnIndex(t, schema, "additional_image_links")]
+	if lt := list.Type().LogicalType(); lt == nil || lt.List == nil {
+		t.Errorf("additional_image_links has no LIST logical type")
+	}
+	updated := schema.Fields()[columnIndex(t, schema, "updated_at")]
+	if !updated.Optional() {
+		t.Errorf("updated_at is not optional")
+	}
+	if lt := updated.Type().LogicalType(); lt == nil || lt.Timestamp == nil || lt.Timestamp.Unit.Micros == nil {
+		t.Errorf("updated_at is not a microsecond timestamp")
+	}
+	// Zero values are written as nulls
+	for _, name := range []string{"updated_at", "auction_end_time", "starting_bid"} {
+		chunk := file.RowGroups()[0].ColumnChunks()[columnIndex(t, schema, name)]
+		pages := chunk.Pages()
+		page, err := pages.ReadPage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.NumNulls() != 1 {
+			t.Errorf("%s has %d nulls, want 1", name, page.NumNulls())
+		}
+		parquet.Release(page)
+		pages.Close()
+	}
+	if !strings.HasPrefix(file.Metadata().CreatedBy, createdBy) {
+		t.Errorf("created by %q", file.Metadata().CreatedBy)
+	}
+}
+
+func TestLargeColumnsSpanPages(t *testing.T) {
+	ads := make([]input.AdItem, 2000)
+	for i := range ads {
+		ads[i] = input.AdItem{ID: fmt.Sprint(i), Description: strings.Repeat("leather ", 100)}
+	}
+	data := write(t, ads)
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := file.RowGroups()
+	if len(groups) != 1 || groups[0].NumRows() != int64(len(ads)) {
+		t.Fatalf("%d row groups, want 1 of %d rows", len(groups), len(ads))
+	}
+	chunk := groups[0].ColumnChunks()[columnIndex(t, file.Schema(), "description")]
+	pages := chunk.Pages()
+	defer pages.Close()
+	count := 0
+	for {
+		page, err := pages.ReadPage()
+		if err != nil {
+			break
+		}
+		parquet.Release(page)
+		count++
+	}
+	if count < 2 {
+		t.Errorf("description is written in %d page, want several", count)
+	}
+}
+
+// columnIndex returns the index of the top-level field name
+func columnIndex(t *testing.T, schema *parquet.Schema, name string) int {
+	t.Helper()
+	for i, field := range schema.Fields() {
+		if field.Name() == name {
+			return i
+		}
+	}
+	t.Fatalf("no column %s", name)
+	return -1
+}
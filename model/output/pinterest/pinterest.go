@@ -0,0 +1,81 @@
+// Package pinterest exports ads as a Pinterest catalog data source, as CSV
+// or TSV.
+package pinterest
+
+import (
+	"io"
+	"strings"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/output/tabular"
+)
+
+// Columns is the header row of the catalog, the columns Pinterest requires
+// first, in the order values are written
+var Columns = []string{
+	"id",
+	"title",
+	"description",
+	"link",
+	"image_link",
+	"price",
+	"availability",
+	"condition",
+	"brand",
+	"product_type",
+	"google_product_category",
+	"additional_image_link",
+	"item_group_id",
+	"gtin",
+	"mpn",
+	"color",
+	"size",
+	"material",
+	"gender",
+	"age_group",
+	"sale_price",
+}
+
+// maxAdditionalImages is the most additional images Pinterest reads
+const maxAdditionalImages = 10
+
+// NewWriter writes the header row to w as CSV
+func NewWriter(w io.Writer) (*tabular.Writer, error) {
+	return tabular.NewWriter(w, ',', Columns, Row)
+}
+
+// NewTSVWriter writes the header row to w as tab separated values
+func NewTSVWriter(w io.Writer) (*tabular.Writer, error) {
+	return tabular.NewWriter(w, '\t', Columns, Row)
+}
+
+// Row returns the catalog values of an item, in the order of Columns
+func Row(it feed.Item) []string {
+	images := feed.URLStrings(it.AdditionalImageLinks)
+	if len(images) > maxAdditionalImages {
+		images = images[:maxAdditionalImages]
+	}
+	return []string{
+		it.ID,
+		it.Title,
+		it.Description,
+		feed.URLString(it.Link),
+		feed.URLString(it.ImageLink),
+		it.Price.String(), // amount and ISO currency code, e.g. "1200.00 AED"
+		string(it.Availability),
+		tabular.Condition(it),
+		it.Brand,
+		it.ProductType,
+		it.GoogleProductCategory,
+		strings.Join(images, ","),
+		it.ItemGroupID,
+		it.GTIN,
+		it.MPN,
+		it.Color,
+		it.Size,
+		it.Material,
+		it.Gender,
+		it.AgeGroup,
+		tabular.SalePrice(it),
+	}
+}
@@ -0,0 +1,70 @@
+// Package snapchat exports ads as a Snapchat product catalog CSV file.
+package snapchat
+
+import (
+	"io"
+	"strings"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/output/tabular"
+)
+
+// Columns is the header row of the Snapchat product feed, required columns
+// first, in the order values are written
+var Columns = []string{
+	"id",
+	"title",
+	"description",
+	"link",
+	"image_link",
+	"availability",
+	"price",
+	"condition",
+	"brand",
+	"gtin",
+	"mpn",
+	"additional_image_link",
+	"google_product_category",
+	"product_type",
+	"item_group_id",
+	"color",
+	"size",
+	"material",
+	"gender",
+	"age_group",
+	"sale_price",
+	"sale_price_effective_date",
+}
+
+// NewWriter writes the header row to w
+func NewWriter(w io.Writer) (*tabular.Writer, error) {
+	return tabular.NewWriter(w, ',', Columns, Row)
+}
+
+// Row returns the feed values of an item, in the order of Columns
+func Row(it feed.Item) []string {
+	return []string{
+		it.ID,
+		it.Title,
+		it.Description,
+		feed.URLString(it.Link),
+		feed.URLString(it.ImageLink),
+		string(it.Availability),
+		it.Price.String(), // amount and ISO currency code, e.g. "1200.00 AED"
+		tabular.Condition(it),
+		it.Brand,
+		it.GTIN,
+		it.MPN,
+		strings.Join(feed.URLStrings(it.AdditionalImageLinks), ","),
+		it.GoogleProductCategory,
+		it.ProductType,
+		it.ItemGroupID,
+		it.Color,
+		it.Size,
+		it.Material,
+		it.Gender,
+		it.AgeGroup,
+		tabular.SalePrice(it),
+		it.SalePriceEffectiveDate(),
+	}
+}
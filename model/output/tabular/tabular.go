@@ -0,0 +1,74 @@
+// Package tabular writes catalog files that hold one delimited row per item
+// under a header row, the layout most ad platforms accept. Each channel
+// package supplies its columns and how an item maps to them.
+package tabular
+
+import (
+	"encoding/csv"
+	"io"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+)
+
+// DefaultCondition is the condition written for items whose condition was
+// never set
+const DefaultCondition = feed.New
+
+// Condition returns the condition of an item, or DefaultCondition
+func Condition(it feed.Item) string {
+	if it.Condition == "" {
+		return string(DefaultCondition)
+	}
+	return string(it.Condition)
+}
+
+// SalePrice returns the sale price with its currency, such as
+// "950.00 AED", or "" when the item is not on sale
+func SalePrice(it feed.Item) string {
+	if it.SalePrice.IsZero() {
+		return ""
+	}
+	return it.SalePrice.String()
+}
+
+// RowFunc returns the values of an item, in the order of the columns
+type RowFunc func(it feed.Item) []string
+
+// Writer streams rows as UTF-8. Quoting of separators, quotes and newlines
+// is handled by encoding/csv.
+type Writer struct {
+	csv *csv.Writer
+	row RowFunc
+}
+
+// NewWriter writes the header row of columns to w, separated by comma
+// (',' for CSV, '\t' for TSV)
+func NewWriter(w io.Writer, comma rune, columns []string, row RowFunc) (*Writer, error) {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+	return &Writer{csv: writer, row: row}, nil
+}
+
+// Write adds the row of one item
+func (w *Writer) Write(ad input.AdItem) error {
+	it, err := feed.FromAdItem(ad)
+	if err != nil {
+		return err
+	}
+	return w.csv.Write(w.row(it))
+}
+
+// Flush writes any buffered rows to the underlying writer
+func (w *Writer) Flush() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// Close flushes buffered rows. The underlying writer is left open.
+func (w *Writer) Close() error {
+	return w.Flush()
+}
@@ -0,0 +1,78 @@
+// Package tiktok exports ads as a TikTok catalog file. TikTok takes the
+// CSV template written here, or Google's RSS feed for XML uploads.
+package tiktok
+
+import (
+	"io"
+	"strings"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/output/tabular"
+)
+
+// Columns is the header row of the TikTok catalog template, in the order
+// values are written
+var Columns = []string{
+	"sku_id",
+	"title",
+	"description",
+	"availability",
+	"condition",
+	"price",
+	"link",
+	"image_link",
+	"additional_image_link",
+	"brand",
+	"gtin",
+	"mpn",
+	"google_product_category",
+	"product_type",
+	"item_group_id",
+	"color",
+	"size",
+	"material",
+	"gender",
+	"age_group",
+	"sale_price",
+	"sale_price_effective_date",
+}
+
+// maxAdditionalImages is the most additional images TikTok reads
+const maxAdditionalImages = 10
+
+// NewWriter writes the header row to w
+func NewWriter(w io.Writer) (*tabular.Writer, error) {
+	return tabular.NewWriter(w, ',', Columns, Row)
+}
+
+// Row returns the catalog values of an item, in the order of Columns
+func Row(it feed.Item) []string {
+	images := feed.URLStrings(it.AdditionalImageLinks)
+	if len(images) > maxAdditionalImages {
+		images = images[:maxAdditionalImages]
+	}
+	return []string{
+		it.ID,
+		it.Title,
+		it.Description,
+		string(it.Availability),
+		tabular.Condition(it),
+		it.Price.String(), // amount and ISO currency code, e.g. "1200.00 AED"
+		feed.URLString(it.Link),
+		feed.URLString(it.ImageLink),
+		strings.Join(images, ","),
+		it.Brand,
+		it.GTIN,
+		it.MPN,
+		it.GoogleProductCategory,
+		it.ProductType,
+		it.ItemGroupID,
+		it.Color,
+		it.Size,
+		it.Material,
+		it.Gender,
+		it.AgeGroup,
+		tabular.SalePrice(it),
+		it.SalePriceEffectiveDate(),
+	}
+}
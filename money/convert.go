@@ -0,0 +1,87 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// RoundingMode says which way converted amounts are rounded to their step
+type RoundingMode string
+
+const (
+	RoundHalfUp RoundingMode = "half_up" // to the nearest step, halves away from zero
+	RoundDown   RoundingMode = "down"    // towards zero
+	RoundUp     RoundingMode = "up"      // away from zero, e.g. for charm prices
+)
+
+// Rounding is the rounding rule for amounts converted into one currency
+type Rounding struct {
+	Mode RoundingMode
+	// Step is the rounding increment in minor units; 0 or 1 rounds to the
+	// currency's minor unit, 100 to whole units for a two-decimal currency
+	Step int64
+}
+
+// ValidRoundingMode reports whether mode is one of the known modes
+func ValidRoundingMode(mode RoundingMode) bool {
+	switch mode {
+	case RoundHalfUp, RoundDown, RoundUp:
+		return true
+	}
+	return false
+}
+
+// Convert returns m in the currency to, where rate is the number of units of
+// to that one unit of m.Currency is worth. The result is rounded by r.
+func Convert(m Money, to string, rate *big.Rat, r Rounding) Money {
+	if m.Currency == to {
+		return m
+	}
+
+	// minor units of to = m.Minor / 10^exp(from) * rate * 10^exp(to)
+	amount := new(big.Rat).SetInt64(m.Minor)
+	amount.Mul(amount, rate)
+	amount.Mul(amount, pow10(Exponent(to)))
+	amount.Quo(amount, pow10(Exponent(m.Currency)))
+
+	step := r.Step
+	if step < 1 {
+		step = 1
+	}
+	amount.Quo(amount, new(big.Rat).SetInt64(step))
+
+	return Money{Minor: round(amount, r.Mode) * step, Currency: to}
+}
+
+// round returns the non-negative amount rounded to an integer by mode
+func round(amount *big.Rat, mode RoundingMode) int64 {
+	q, rem := new(big.Int).QuoRem(amount.Num(), amount.Denom(), new(big.Int))
+	if rem.Sign() == 0 {
+		return q.Int64()
+	}
+	switch mode {
+	case RoundDown:
+	case RoundUp:
+		q.Add(q, big.NewInt(1))
+	default:
+		// Round up when the remainder is at least half the denominator
+		if new(big.Int).Mul(rem, big.NewInt(2)).Cmp(amount.Denom()) >= 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+// pow10 returns 10^n as a rational
+func pow10(n int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+}
+
+// ParseRate reads an exchange rate written as a decimal, e.g. "0.2723"
+func ParseRate(s string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid exchange rate %q", s)
+	}
+	return rate, nil
+}
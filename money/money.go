@@ -0,0 +1,144 @@
+// Package money holds prices as exact amounts in the minor unit of their
+// currency, so they can be parsed from seller input and formatted for each
+// feed without floating point rounding.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// exponents lists the ISO 4217 currencies whose minor unit is not 1/100
+var exponents = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// Errors returned by Parse
+var (
+	ErrEmpty     = errors.New("price is empty")
+	ErrNegative  = errors.New("price is negative")
+	ErrPrecision = errors.New("price has more decimals than the currency allows")
+)
+
+// Money is an amount in the minor unit of Currency, e.g. fils for AED
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 code: three
+// upper case letters
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Exponent returns the number of decimals of the currency's minor unit
+func Exponent(currency string) int {
+	if e, ok := exponents[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+// Parse reads a seller-entered price in the given currency, such as
+// "1,250", "1250.5", "AED 99.90", "99,90" or "١٢٥٠ د.إ". Digits,
+// separators and currency marks are read as described in normalize and
+// decimalPoint. A range such as "100-150" or "100 to 150" is read as its
+// lower bound, the least a shopper pays, and is an error when it ends below
+// its start. Errors quote the value.
+func Parse(s, currency string) (Money, error) {
+	raw, err := normalize(s, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if raw == "" {
+		return Money{}, ErrEmpty
+	}
+	low, high, ok := strings.Cut(raw[1:], "-")
+	if !ok {
+		return parseNumber(raw, s, currency)
+	}
+	min, err := parseNumber(raw[:1]+low, s, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	max, err := parseNumber(high, s, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if max.Minor < min.Minor {
+		return Money{}, fmt.Errorf("price range %q ends below its start", s)
+	}
+	return min, nil
+}
+
+// parseNumber reads one amount of a normalized price s
+func parseNumber(raw, s, currency string) (Money, error) {
+	if raw == "" {
+		return Money{}, fmt.Errorf("price %q: %w", s, ErrEmpty)
+	}
+	if strings.HasPrefix(raw, "-") {
+		return Money{}, fmt.Errorf("price %q: %w", s, ErrNegative)
+	}
+
+	whole, frac, _ := strings.Cut(decimalPoint(raw), ".")
+	if whole == "" {
+		whole = "0"
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return Money{}, fmt.Errorf("price %q is not a number", s)
+		}
+	}
+
+	exp := Exponent(currency)
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("price %q: %w", s, ErrPrecision)
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("price %q is out of range", s)
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// IsZero reports whether m is the zero value, i.e. no price at all
+func (m Money) IsZero() bool {
+	return m == Money{}
+}
+
+// Decimal formats the amount alone with the currency's decimals, e.g.
+// "123.00"
+func (m Money) Decimal() string {
+	exp := Exponent(m.Currency)
+	s := strconv.FormatInt(m.Minor, 10)
+	if exp == 0 {
+		return s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+// String formats the amount followed by the currency code, e.g.
+// "123.00 AED", as Google Merchant Center expects
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
@@ -0,0 +1,82 @@
+package money
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// symbols are the local ways of writing a currency sellers use besides its
+// code, longest first so a shorter one does not cut a longer one apart
+var symbols = map[string][]string{
+	"AED": {"DIRHAMS", "DIRHAM", "DHS", "DH", "د.إ", "درهم"},
+	"SAR": {"RIYALS", "RIYAL", "ر.س", "ريال", "SR"},
+	"QAR": {"ر.ق", "QR"},
+	"KWD": {"د.ك", "KD"},
+	"BHD": {"د.ب", "BD"},
+	"OMR": {"ر.ع.", "RO"},
+	"USD": {"US$", "$"},
+	"EUR": {"€"},
+	"GBP": {"£"},
+}
+
+// normalize rewrites a seller-entered price in ASCII: its currency code and
+// symbols are removed, Arabic-Indic digits become ASCII digits, the Arabic
+// decimal and thousands separators become "." and ",", spaces and
+// apostrophes used to group digits are dropped, and range dashes and "to"
+// become "-". Text left after that, such as another currency, is an error.
+func normalize(s, currency string) (string, error) {
+	raw := strings.ToUpper(strings.TrimSpace(s))
+	raw = strings.TrimSuffix(raw, "/-") // "100/-" means a round amount
+	if currency != "" {
+		raw = strings.ReplaceAll(raw, currency, "")
+	}
+	for _, symbol := range symbols[currency] {
+		raw = strings.ReplaceAll(raw, symbol, "")
+	}
+	raw = strings.Join(strings.Fields(strings.ReplaceAll(" "+raw+" ", " TO ", " - ")), " ")
+
+	var b strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '\u0660' && r <= '\u0669':
+			b.WriteRune('0' + r - '\u0660')
+		case r >= '\u06f0' && r <= '\u06f9':
+			b.WriteRune('0' + r - '\u06f0')
+		case r == '\u066b':
+			b.WriteByte('.')
+		case r == '\u066c' || r == '\u060c':
+			b.WriteByte(',')
+		case r == '\u2013' || r == '\u2014' || r == '~':
+			b.WriteByte('-')
+		case unicode.IsSpace(r), r == '\'', r == '\u2019', unicode.Is(unicode.Cf, r):
+		case unicode.IsLetter(r), unicode.Is(unicode.Sc, r):
+			return "", fmt.Errorf("price %q is not in %s", s, currency)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
+
+// decimalPoint rewrites an amount so "." is its decimal point and it has no
+// thousands separators. When both "." and "," appear the last of them is
+// the decimal point. A lone "," is a decimal comma unless three digits
+// follow it, so "1,250" is 1250 and "99,90" is 99.90; "." alone is always
+// the decimal point unless it appears more than once.
+func decimalPoint(s string) string {
+	dot, comma := strings.LastIndexByte(s, '.'), strings.LastIndexByte(s, ',')
+	switch {
+	case dot >= 0 && comma > dot:
+		return strings.Replace(strings.ReplaceAll(s, ".", ""), ",", ".", 1)
+	case comma >= 0 && dot > comma:
+		return strings.ReplaceAll(s, ",", "")
+	case comma >= 0 && strings.Count(s, ",") == 1 && len(s)-comma-1 != 3:
+		return strings.Replace(s, ",", ".", 1)
+	case comma >= 0:
+		return strings.ReplaceAll(s, ",", "")
+	case strings.Count(s, ".") > 1:
+		return strings.ReplaceAll(s, ".", "")
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package money
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name, in, currency string
+		want               int64
+	}{
+		{"bare amount", "12", "AED", 1200},
+		{"decimal comma with thousands dots", "1.234,56", "EUR", 123456},
+		{"decimal point with thousands commas", "1,234.56", "USD", 123456},
+		{"lone comma before three digits groups", "1,250", "AED", 125000},
+		{"lone comma before two digits is decimal", "99,90", "AED", 9990},
+		{"code", "AED 99.90", "AED", 9990},
+		{"symbol", "€1.234,56", "EUR", 123456},
+		{"arabic symbol and digits", "١٢٥٠ د.إ", "AED", 125000},
+		{"three decimal currency", "1.250", "KWD", 1250},
+		{"range reads its lower bound", "100 to 150", "AED", 10000},
+		{"round amount", "100/-", "AED", 10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in, tt.currency)
+			if err != nil {
+				t.Fatalf("Parse(%q, %s): %v", tt.in, tt.currency, err)
+			}
+			if want := (Money{Minor: tt.want, Currency: tt.currency}); got != want {
+				t.Errorf("Parse(%q, %s) = %v, want %v", tt.in, tt.currency, got, want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, in, currency string
+		want               error // nil when only the failure is checked
+	}{
+		{"empty", "", "AED", ErrEmpty},
+		{"only the currency", "AED", "AED", ErrEmpty},
+		{"negative", "-12", "AED", ErrNegative},
+		{"too many decimals", "12.345", "AED", ErrPrecision},
+		{"another currency", "$12", "AED", nil},
+		{"text", "call me", "AED", nil},
+		{"range ending below its start", "150-100", "AED", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in, tt.currency)
+			if err == nil {
+				t.Fatalf("Parse(%q, %s) = %v, want an error", tt.in, tt.currency, got)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Parse(%q, %s) error %v, want %v", tt.in, tt.currency, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package money
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateProvider returns exchange rates: how many units of to one unit of
+// from is worth
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+// Table is a set of rates quoted against one base currency. It answers
+// cross rates between any two of its currencies through the base.
+type Table struct {
+	Base  string
+	Rates map[string]*big.Rat // units of each currency per unit of Base
+}
+
+// Rate implements RateProvider
+func (t *Table) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	fromRate, err := t.perBase(from)
+	if err != nil {
+		return nil, err
+	}
+	toRate, err := t.perBase(to)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+// perBase returns the units of currency per unit of the base
+func (t *Table) perBase(currency string) (*big.Rat, error) {
+	if currency == t.Base {
+		return big.NewRat(1, 1), nil
+	}
+	rate, ok := t.Rates[currency]
+	if !ok {
+		return nil, fmt.Errorf("no %s exchange rate against %s", currency, t.Base)
+	}
+	return rate, nil
+}
+
+// NewStaticRates returns a fixed Table from rates written as decimals, e.g.
+// {"USD": "0.2723"} for base AED
+func NewStaticRates(base string, rates map[string]string) (*Table, error) {
+	t := &Table{Base: base, Rates: make(map[string]*big.Rat, len(rates))}
+	for code, s := range rates {
+		rate, err := ParseRate(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", code, err)
+		}
+		t.Rates[code] = rate
+	}
+	return t, nil
+}
+
+// ECBURL is the European Central Bank daily reference rates feed
+const ECBURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+
+// cachedTable refetches a rate table once it is older than ttl
+type cachedTable struct {
+	ttl   time.Duration
+	fetch func(ctx context.Context) (*Table, error)
+
+	mu      sync.Mutex
+	table   *Table
+	fetched time.Time
+}
+
+// Rate implements RateProvider
+func (c *cachedTable) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.table == nil || time.Since(c.fetched) > c.ttl {
+		table, err := c.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.table, c.fetched = table, time.Now()
+	}
+	return c.table.Rate(ctx, from, to)
+}
+
+// NewECBRates returns a provider backed by the ECB reference rates, which
+// are quoted against EUR and published once per working day. Rates are
+// cached for ttl. A nil client uses http.DefaultClient.
+func NewECBRates(client *http.Client, ttl time.Duration) RateProvider {
+	return &cachedTable{ttl: ttl, fetch: func(ctx context.Context) (*Table, error) {
+		var doc struct {
+			Cubes []struct {
+				Currency string `xml:"currency,attr"`
+				Rate     string `xml:"rate,attr"`
+			} `xml:"Cube>Cube>Cube"`
+		}
+		if err := getRates(ctx, client, ECBURL, nil, func(res *http.Response) error {
+			return xml.NewDecoder(res.Body).Decode(&doc)
+		}); err != nil {
+			return nil, err
+		}
+
+		rates := make(map[string]string, len(doc.Cubes))
+		for _, cube := range doc.Cubes {
+			rates[cube.Currency] = cube.Rate
+		}
+		return NewStaticRates("EUR", rates)
+	}}
+}
+
+// NewHTTPRates returns a provider that GETs rates from a custom API
+// answering {"base": "AED", "rates": {"USD": 0.2723, ...}}. Rates are cached
+// for ttl. A nil client uses http.DefaultClient.
+func NewHTTPRates(client *http.Client, url string, headers map[string]string, ttl time.Duration) RateProvider {
+	return &cachedTable{ttl: ttl, fetch: func(ctx context.Context) (*Table, error) {
+		var body struct {
+			Base  string                 `json:"base"`
+			Rates map[string]json.Number `json:"rates"`
+		}
+		if err := getRates(ctx, client, url, headers, func(res *http.Response) error {
+			return json.NewDecoder(res.Body).Decode(&body)
+		}); err != nil {
+			return nil, err
+		}
+
+		rates := make(map[string]string, len(body.Rates))
+		for code, rate := range body.Rates {
+			rates[code] = rate.String()
+		}
+		return NewStaticRates(body.Base, rates)
+	}}
+}
+
+// getRates requests url and hands a successful response to decode
+func getRates(ctx context.Context, client *http.Client, url string, headers map[string]string, decode func(*http.Response) error) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching exchange rates: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching exchange rates from %s: unexpected status %s", url, res.Status)
+	}
+	if err := decode(res); err != nil {
+		return fmt.Errorf("decoding exchange rates from %s: %w", url, err)
+	}
+	return nil
+}
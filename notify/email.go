@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/awssign"
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/httpclient"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/retry"
+)
+
+// Mailer emails the ads left out of a run as configured in cfg.Email
+type Mailer struct {
+	cfg    config.Email
+	retry  config.Retry
+	http   *http.Client
+	creds  awssign.Credentials // for ses
+	logger *slog.Logger
+}
+
+// NewMailer returns a Mailer for cfg, which is expected to have
+// recipients. The ses transport needs AWS credentials in the environment.
+func NewMailer(cfg *config.Config, logger *slog.Logger) (*Mailer, error) {
+	client, err := httpclient.NewRetrying(cfg.HTTP, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	m := &Mailer{
+		cfg:    cfg.Email,
+		retry:  cfg.Retry,
+		http:   client,
+		logger: logging.OrDefault(logger),
+	}
+	if cfg.Email.Transport == config.EmailSES {
+		creds, err := awssign.EnvCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("email: %w", err)
+		}
+		m.creds = creds
+	}
+	return m, nil
+}
+
+// Reported returns the reports of the reasons in cfg.Email.Reasons, or all
+// of them when it lists none
+func (m *Mailer) Reported(reports []input.SkipReport) []input.SkipReport {
+	if len(m.cfg.Reasons) == 0 {
+		return reports
+	}
+	var kept []input.SkipReport
+	for _, r := range reports {
+		if slices.Contains(m.cfg.Reasons, string(r.Reason)) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// SendSkipped emails the reported ads of a run that started at started,
+// counted by reason in the body and listed in an attached CSV. Nothing is
+// sent when there are none.
+func (m *Mailer) SendSkipped(ctx context.Context, started time.Time, reports []input.SkipReport) error {
+	reports = m.Reported(reports)
+	if len(reports) == 0 {
+		return nil
+	}
+	attachment, err := SkipCSV(reports)
+	if err != nil {
+		return err
+	}
+	msg, err := m.message(started, reports, attachment)
+	if err != nil {
+		return err
+	}
+
+	send := m.sendSMTP
+	if m.cfg.Transport == config.EmailSES {
+		send = m.sendSES
+	}
+	if err := retry.Do(ctx, m.logger, m.retry, "Sending skipped ads email", func() error { return send(ctx, msg) }); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	m.logger.Info("Emailed skipped ads", "ads", len(reports), "to", strings.Join(m.cfg.To, ", "))
+	return nil
+}
+
+// SkipCSV returns reports as a CSV file with a header row
+func SkipCSV(reports []input.SkipReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"ad_id", "draft_id", "seller_id", "title", "reason", "detail"})
+	for _, r := range reports {
+		w.Write([]string{r.AdID, r.DraftID, r.SellerID, r.Title, string(r.Reason), r.Detail})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// message returns the email as a MIME message with the CSV attached
+func (m *Mailer) message(started time.Time, reports []input.SkipReport, attachment []byte) ([]byte, error) {
+	counts := map[input.SkipReason]int{}
+	for _, r := range reports {
+		counts[r.Reason]++
+	}
+	var body strings.Builder
+	fmt.Fprintf(&body, "%d ads were left out of the feed by the run started %s.\n\n", len(reports), started.UTC().Format(time.RFC3339))
+	for _, reason := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Fprintf(&body, "%s: %d\n", reason, counts[reason])
+	}
+	body.WriteString("\nThe attached CSV lists each ad with the reason. Fixing the ad on the marketplace brings it back in the next run.\n")
+
+	// The writer writes nothing before the first part, so the headers that
+	// name its boundary go first
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	header := []string{
+		"From: " + m.cfg.From,
+		"To: " + strings.Join(m.cfg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", m.cfg.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + strconv.Quote(mw.Boundary()),
+	}
+	msg.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	io.WriteString(part, strings.ReplaceAll(body.String(), "\n", "\r\n"))
+
+	name := "skipped-ads-" + started.UTC().Format("2006-01-02") + ".csv"
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/csv; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+	for len(encoded) > 76 {
+		io.WriteString(part, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(part, encoded+"\r\n")
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// sendSMTP sends msg through the SMTP server
+func (m *Mailer) sendSMTP(ctx context.Context, msg []byte) error {
+	var auth smtp.Auth
+	if m.cfg.SMTP.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.SMTP.Username, m.cfg.SMTP.Password, m.cfg.SMTP.Host)
+	}
+	addr := m.cfg.SMTP.Host + ":" + strconv.Itoa(m.cfg.SMTP.Port)
+	return smtp.SendMail(addr, auth, m.cfg.From, m.cfg.To, msg)
+}
+
+// sendSES sends msg as a raw email with the SES v2 API
+func (m *Mailer) sendSES(ctx context.Context, msg []byte) error {
+	endpoint := m.cfg.SESEndpoint
+	if endpoint == "" {
+		endpoint = "https://email." + m.cfg.SESRegion + ".amazonaws.com"
+	}
+	body, err := json.Marshal(map[string]any{
+		"FromEmailAddress": m.cfg.From,
+		"Destination":      map[string]any{"ToAddresses": m.cfg.To},
+		"Content":          map[string]any{"Raw": map[string]string{"Data": base64.StdEncoding.EncodeToString(msg)}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	awssign.Sign(req, body, m.creds, m.cfg.SESRegion, "ses", time.Now())
+
+	res, err := m.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("ses: %s: %s", res.Status, strings.TrimSpace(string(text)))
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Package notify tells people about runs: it posts the results of the runs
+// worth their attention to a Slack or Microsoft Teams incoming webhook, and
+// emails the catalog team the ads each run left out.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/httpclient"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/retry"
+)
+
+// Run is the outcome of a run as reported in a message
+type Run struct {
+	Err           error  // why the run failed, nil when it succeeded
+	Items         int    // items written
+	PreviousItems int    // items written by the last successful run, -1 when unknown
+	Details       string // the run summary as a plain text table
+}
+
+// Notifier sends the messages configured in cfg.Notify
+type Notifier struct {
+	cfg    config.Notify
+	retry  config.Retry
+	http   *http.Client
+	logger *slog.Logger
+}
+
+// New returns a Notifier for cfg, which is expected to have a WebhookURL
+func New(cfg *config.Config, logger *slog.Logger) (*Notifier, error) {
+	client, err := httpclient.NewRetrying(cfg.HTTP, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{
+		cfg:    cfg.Notify,
+		retry:  cfg.Retry,
+		http:   client,
+		logger: logging.OrDefault(logger),
+	}, nil
+}
+
+// Reason returns why r should be reported, or "" when it should not be: a
+// failed run, a drop in items of more than DropPercent since the last run,
+// or any run with Always
+func (n *Notifier) Reason(r Run) string {
+	if r.Err != nil {
+		return "failed"
+	}
+	if r.PreviousItems > 0 {
+		drop := 100 * float64(r.PreviousItems-r.Items) / float64(r.PreviousItems)
+		if drop > n.cfg.DropPercent {
+			return fmt.Sprintf("items dropped %.0f%%, from %d to %d", drop, r.PreviousItems, r.Items)
+		}
+	}
+	if n.cfg.Always {
+		return "succeeded"
+	}
+	return ""
+}
+
+// Notify posts a message about r to the webhook when Reason says it should
+// be reported
+func (n *Notifier) Notify(ctx context.Context, r Run) error {
+	reason := n.Reason(r)
+	if reason == "" {
+		return nil
+	}
+	title := "Feed run " + reason
+	if n.cfg.Environment != "" {
+		title = "[" + n.cfg.Environment + "] " + title
+	}
+
+	var payload any
+	switch n.cfg.Kind {
+	case config.NotifyTeams:
+		payload = teamsMessage(title, r)
+	default:
+		payload = slackMessage(title, r)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	err = retry.Do(ctx, n.logger, n.retry, "Posting run notification", func() error {
+		return n.post(ctx, body)
+	})
+	if err != nil {
+		return fmt.Errorf("posting run notification: %w", err)
+	}
+	n.logger.Info("Sent run notification", "kind", n.cfg.Kind, "reason", reason)
+	return nil
+}
+
+// post sends one message. The webhook URL holds its credentials, so errors
+// do not name it.
+func (n *Notifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := n.http.Do(req)
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("webhook: %s: %s", res.Status, strings.TrimSpace(string(text)))
+	}
+	return nil
+}
+
+// slackMessage is an incoming webhook message with the details in a code
+// block
+func slackMessage(title string, r Run) map[string]string {
+	text := "*" + title + "*"
+	if r.Err != nil {
+		text += "\n" + r.Err.Error()
+	}
+	if r.Details != "" {
+		text += "\n```\n" + r.Details + "```"
+	}
+	return map[string]string{"text": text}
+}
+
+// teamsMessage is a connector card, red for failures and green otherwise,
+// with the details preformatted
+func teamsMessage(title string, r Run) map[string]string {
+	color := "2EB886"
+	if r.Err != nil {
+		color = "D70000"
+	}
+	var text strings.Builder
+	if r.Err != nil {
+		text.WriteString("<p>" + html.EscapeString(r.Err.Error()) + "</p>")
+	}
+	if r.Details != "" {
+		text.WriteString("<pre>" + html.EscapeString(r.Details) + "</pre>")
+	}
+	return map[string]string{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    title,
+		"title":      title,
+		"themeColor": color,
+		"text":       text.String(),
+	}
+}
@@ -0,0 +1,466 @@
+// Package pipeline streams ads from a source through transformation and
+// filtering stages into one or more sinks. Stages run concurrently and are
+// connected by bounded channels, so memory use does not grow with the size
+// of the catalog.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go_data_fashion_accessories/model/input"
+)
+
+// DefaultBufferSize is the capacity of the channels between stages
+const DefaultBufferSize = 100
+
+// Parser turns a raw ad into its feed items, one per variant, or into a
+// SkipReport for ads that should not appear in the feed. input.Processor
+// satisfies it.
+type Parser interface {
+	Process(ad input.RawAd) ([]input.AdItem, *input.SkipReport)
+}
+
+// Transformer rewrites an item in place. An error aborts the run.
+type Transformer func(item *input.AdItem) error
+
+// Filter drops the items Keep rejects, reporting them with Reason and
+// Detail
+type Filter struct {
+	Reason input.SkipReason
+	Detail string
+	Keep   func(item input.AdItem) bool
+}
+
+// Check is a filter that may block, such as one that makes network
+// requests. Keep may also update the item, to flag it instead of dropping
+// it. Checks run after the filters on a pool of CheckWorkers goroutines.
+type Check struct {
+	Reason input.SkipReason
+	Keep   func(ctx context.Context, item *input.AdItem) bool
+}
+
+// DefaultCheckWorkers is the number of items checked at once when
+// CheckWorkers is unset
+const DefaultCheckWorkers = 8
+
+// Dedup keeps one item of each group sharing a key, such as a GTIN. Items
+// with an empty key are never duplicates. As a later item may replace an
+// earlier one, the items are held until the source is exhausted.
+type Dedup struct {
+	Reason input.SkipReason
+	Key    func(item input.AdItem) string
+	// Prefer reports whether a should be kept over b; ties keep the item
+	// that arrived first
+	Prefer func(a, b input.AdItem) bool
+}
+
+// Sink receives every item that passes the filters. Close is called once
+// when the run ends; if the run failed, sinks that are an Aborter get Abort
+// instead.
+type Sink interface {
+	Write(item input.AdItem) error
+	Close() error
+}
+
+// Aborter is a Sink that can throw away what it wrote, such as a feed file
+// that should not replace the previous one when the run failed
+type Aborter interface {
+	Abort() error
+}
+
+// Abort ends sink after a failed run: it is aborted if it is an Aborter and
+// closed otherwise
+func Abort(sink Sink) error {
+	if a, ok := sink.(Aborter); ok {
+		return a.Abort()
+	}
+	return sink.Close()
+}
+
+// Pipeline wires a source to its sinks
+type Pipeline struct {
+	Source       input.RawStreamer
+	Options      input.FetchOptions
+	Parser       Parser
+	Transformers []Transformer
+	Filters      []Filter
+	Checks       []Check
+	CheckWorkers int
+	Dedup        *Dedup
+	Order        func(a, b input.AdItem) int // sorts the items before they are written, as for slices.SortFunc
+	Sinks        []Sink
+	BufferSize   int
+
+	// Limit, if positive, ends the run once that many items were written,
+	// stopping the source and the other stages early. The run succeeds.
+	Limit int
+
+	// OnSkip, if set, is called for every ad the parser or a filter drops.
+	// It runs on the processing goroutine and must not block for long.
+	OnSkip func(report input.SkipReport)
+
+	// Fail lists the reasons for which a parser skip aborts the run instead
+	// of only dropping the ad
+	Fail []input.SkipReason
+
+	// Guard, if set, is called once every item was written, before the
+	// sinks are closed. An error fails the run, aborting the sinks.
+	Guard func(stats Stats) error
+
+	// mu serializes skips, which both the processing and check stages report
+	mu sync.Mutex
+}
+
+// Stats counts what happened to the ads during a run
+type Stats struct {
+	Read     int // raw ads received from the source
+	Parsed   int // ads the parser kept
+	Filtered int // items dropped by a filter or check
+	Deduped  int // items dropped as duplicates
+	Written  int // items delivered to the sinks
+	Duration time.Duration
+
+	// Skipped counts the ads dropped by the parser or a filter by reason
+	Skipped map[input.SkipReason]int
+	// Details counts the items dropped by each filter that has a Detail,
+	// such as each inclusion rule, by reason and detail
+	Details map[input.SkipReason]map[string]int
+	// Subcategories counts the items written by subcategory name, or by
+	// subcategory ID when the name is not known
+	Subcategories map[string]int
+	// Stages is the time each stage spent on its work
+	Stages StageDurations
+}
+
+// StageDurations is the time each stage of a run spent working. Stages run
+// at the same time, so they add up to more than the run took. Apart from
+// Fetch, the time a stage waited for the stages around it is not counted.
+type StageDurations struct {
+	Fetch   time.Duration // streaming from the source, start to end
+	Process time.Duration // parsing, transforming and filtering
+	Check   time.Duration // checks, summed over the workers
+	Collect time.Duration // deduplicating and sorting
+	Write   time.Duration // writing to and closing the sinks
+}
+
+// Run streams every ad from the source to the sinks. The first stage error
+// cancels the others; sinks are always closed before Run returns.
+func (p *Pipeline) Run(ctx context.Context) (Stats, error) {
+	start := time.Now()
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	size := p.BufferSize
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	raw := make(chan input.RawAd, size)
+	parsed := make(chan input.AdItem, size)
+
+	stats := Stats{
+		Skipped:       map[input.SkipReason]int{},
+		Details:       map[input.SkipReason]map[string]int{},
+		Subcategories: map[string]int{},
+	}
+	stages := 3
+	errc := make(chan error, 5)
+	go func() {
+		defer close(raw)
+		fetchStart := time.Now()
+		err := p.Source.Stream(ctx, p.Options, raw)
+		stats.Stages.Fetch = time.Since(fetchStart)
+		errc <- err
+	}()
+	go func() {
+		defer close(parsed)
+		errc <- p.process(ctx, raw, parsed, &stats)
+	}()
+	items := parsed
+	if len(p.Checks) > 0 {
+		checked := make(chan input.AdItem, size)
+		stages++
+		go func() {
+			defer close(checked)
+			errc <- p.check(ctx, parsed, checked, &stats)
+		}()
+		items = checked
+	}
+	if p.Dedup != nil || p.Order != nil {
+		collected := make(chan input.AdItem, size)
+		stages++
+		go func(in <-chan input.AdItem) {
+			defer close(collected)
+			errc <- p.collect(ctx, in, collected, &stats)
+		}(items)
+		items = collected
+	}
+	go func() {
+		errc <- p.write(ctx, items, &stats)
+	}()
+
+	var runErr error
+	limited := false
+	for i := 0; i < stages; i++ {
+		err := <-errc
+		if errors.Is(err, errLimit) {
+			limited = true
+			cancel()
+			continue
+		}
+		// Stopping the stages at the Limit is not a failure
+		if limited && errors.Is(err, context.Canceled) && parent.Err() == nil {
+			continue
+		}
+		if err != nil {
+			cancel()
+			// Keep the error that caused the cancellation, not its echoes
+			if runErr == nil || errors.Is(runErr, context.Canceled) {
+				runErr = err
+			}
+		}
+	}
+	if runErr == nil && p.Guard != nil {
+		runErr = p.Guard(stats)
+	}
+
+	closeStart := time.Now()
+	for _, sink := range p.Sinks {
+		if runErr != nil {
+			Abort(sink)
+			continue
+		}
+		if err := sink.Close(); err != nil {
+			runErr = err
+		}
+	}
+	stats.Stages.Write += time.Since(closeStart)
+
+	stats.Duration = time.Since(start)
+	return stats, runErr
+}
+
+// process parses, transforms and filters each raw ad
+func (p *Pipeline) process(ctx context.Context, raw <-chan input.RawAd, items chan<- input.AdItem, stats *Stats) error {
+	for ad := range raw {
+		stats.Read++
+		start := time.Now()
+
+		parsed, skipped := p.Parser.Process(ad)
+		if skipped != nil {
+			p.skip(stats, *skipped, false)
+			stats.Stages.Process += time.Since(start)
+			if slices.Contains(p.Fail, skipped.Reason) {
+				return fmt.Errorf("ad %s", skipped)
+			}
+			continue
+		}
+		stats.Parsed++
+
+		for _, item := range parsed {
+			for _, transform := range p.Transformers {
+				if err := transform(&item); err != nil {
+					return err
+				}
+			}
+
+			if filter, ok := p.keep(item); !ok {
+				if filter.Detail != "" {
+					if stats.Details[filter.Reason] == nil {
+						stats.Details[filter.Reason] = map[string]int{}
+					}
+					stats.Details[filter.Reason][filter.Detail]++
+				}
+				p.skip(stats, input.SkipReport{AdID: item.AdID, DraftID: item.DraftID, SellerID: item.SellerID, Title: item.Title, Reason: filter.Reason, Detail: filter.Detail}, true)
+				continue
+			}
+
+			stats.Stages.Process += time.Since(start)
+			select {
+			case items <- item:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			start = time.Now()
+		}
+		stats.Stages.Process += time.Since(start)
+	}
+	return nil
+}
+
+// check runs the checks on up to CheckWorkers items at a time. Items are
+// passed on in the order they arrived, whatever order their checks finish in.
+func (p *Pipeline) check(ctx context.Context, in <-chan input.AdItem, out chan<- input.AdItem, stats *Stats) error {
+	workers := p.CheckWorkers
+	if workers <= 0 {
+		workers = DefaultCheckWorkers
+	}
+
+	// Each item waits in the queue for its result, which bounds the number
+	// of items in flight to the workers plus the queue's capacity
+	type result struct {
+		item    input.AdItem
+		reason  input.SkipReason
+		ok      bool
+		elapsed time.Duration
+	}
+	queue := make(chan chan result, workers)
+	sem := make(chan struct{}, workers)
+	go func() {
+		defer close(queue)
+		for item := range in {
+			done := make(chan result, 1)
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(item input.AdItem) {
+				defer func() { <-sem }()
+				start := time.Now()
+				reason, ok := p.passChecks(ctx, &item)
+				done <- result{item, reason, ok, time.Since(start)}
+			}(item)
+			select {
+			case queue <- done:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for done := range queue {
+		r := <-done
+		stats.Stages.Check += r.elapsed
+		if !r.ok {
+			p.skip(stats, input.SkipReport{AdID: r.item.AdID, DraftID: r.item.DraftID, SellerID: r.item.SellerID, Title: r.item.Title, Reason: r.reason}, true)
+			continue
+		}
+		select {
+		case out <- r.item:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return ctx.Err()
+}
+
+// passChecks reports whether every check accepts the item, and if not the
+// reason of the first check that rejected it
+func (p *Pipeline) passChecks(ctx context.Context, item *input.AdItem) (input.SkipReason, bool) {
+	for _, check := range p.Checks {
+		if !check.Keep(ctx, item) {
+			return check.Reason, false
+		}
+	}
+	return "", true
+}
+
+// keep reports whether every filter accepts the item, and if not the first
+// filter that rejected it
+func (p *Pipeline) keep(item input.AdItem) (Filter, bool) {
+	for _, filter := range p.Filters {
+		if !filter.Keep(item) {
+			return filter, false
+		}
+	}
+	return Filter{}, true
+}
+
+// skip counts a dropped ad, or a filtered item, and passes its report to
+// OnSkip
+func (p *Pipeline) skip(stats *Stats, report input.SkipReport, filtered bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if filtered {
+		stats.Filtered++
+	}
+	stats.Skipped[report.Reason]++
+	if p.OnSkip != nil {
+		p.OnSkip(report)
+	}
+}
+
+// collect holds the items until the source is exhausted, keeping the one
+// Dedup prefers from each group, and passes them on sorted by Order or else
+// in the order their groups were first seen. Sorting makes the output
+// independent of the order the source returns ads in; ties keep their
+// source order.
+func (p *Pipeline) collect(ctx context.Context, in <-chan input.AdItem, out chan<- input.AdItem, stats *Stats) error {
+	var kept []input.AdItem
+	index := map[string]int{}
+	for item := range in {
+		start := time.Now()
+		key := ""
+		if p.Dedup != nil {
+			key = p.Dedup.Key(item)
+		}
+		if key == "" {
+			kept = append(kept, item)
+			stats.Stages.Collect += time.Since(start)
+			continue
+		}
+		i, seen := index[key]
+		if !seen {
+			index[key] = len(kept)
+			kept = append(kept, item)
+			stats.Stages.Collect += time.Since(start)
+			continue
+		}
+		dropped := item
+		if p.Dedup.Prefer(item, kept[i]) {
+			dropped, kept[i] = kept[i], item
+		}
+		stats.Deduped++
+		p.skip(stats, input.SkipReport{AdID: dropped.AdID, DraftID: dropped.DraftID, SellerID: dropped.SellerID, Title: dropped.Title, Reason: p.Dedup.Reason, Detail: "kept ad " + kept[i].AdID}, false)
+		stats.Stages.Collect += time.Since(start)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if p.Order != nil {
+		start := time.Now()
+		slices.SortStableFunc(kept, p.Order)
+		stats.Stages.Collect += time.Since(start)
+	}
+	for _, item := range kept {
+		select {
+		case out <- item:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// errLimit stops the write stage once Limit items were written
+var errLimit = errors.New("item limit reached")
+
+// write delivers each item to every sink
+func (p *Pipeline) write(ctx context.Context, items <-chan input.AdItem, stats *Stats) error {
+	for item := range items {
+		if p.Limit > 0 && stats.Written >= p.Limit {
+			return errLimit
+		}
+		start := time.Now()
+		for _, sink := range p.Sinks {
+			if err := sink.Write(item); err != nil {
+				return err
+			}
+		}
+		stats.Stages.Write += time.Since(start)
+		stats.Written++
+		subcategory := item.SubcategoryName
+		if subcategory == "" {
+			subcategory = item.Subcategory
+		}
+		stats.Subcategories[subcategory]++
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,151 @@
+// Package promotions reads the Merchant Center promotions of the config and
+// the promotions table, links the items they apply to by promotion_id and
+// writes the promotions feed.
+package promotions
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/model/output/googlefeed"
+)
+
+// Enabled reports whether cfg defines any promotions
+func Enabled(cfg *config.Config) bool {
+	return len(cfg.Promotions.List) > 0 || cfg.Promotions.Table != ""
+}
+
+// Load returns the promotions of the config followed by those of the
+// promotions table, read through source, leaving out the ones that have
+// ended by now. A promotion of the table that is invalid, or whose ID is
+// already taken, is an error.
+func Load(ctx context.Context, cfg *config.Config, source any, now time.Time) ([]config.Promotion, error) {
+	promotions := slices.Clone(cfg.Promotions.List)
+	if cfg.Promotions.Table != "" {
+		lookup, ok := source.(input.PromotionLookup)
+		if !ok {
+			return nil, errors.New("promotions can only be read from the hasura source")
+		}
+		rows, err := lookup.LookupPromotions(ctx, cfg.Promotions.Table)
+		if err != nil {
+			return nil, err
+		}
+		ids := map[string]bool{}
+		for _, p := range promotions {
+			ids[p.ID] = true
+		}
+		for _, p := range rows {
+			if err := p.Validate(); err != nil {
+				return nil, fmt.Errorf("%s: %w", cfg.Promotions.Table, err)
+			}
+			if ids[p.ID] {
+				return nil, fmt.Errorf("%s: duplicate promotion ID %q", cfg.Promotions.Table, p.ID)
+			}
+			ids[p.ID] = true
+			promotions = append(promotions, p)
+		}
+	}
+	return slices.DeleteFunc(promotions, func(p config.Promotion) bool { return !p.End.After(now) }), nil
+}
+
+// Linker sets the promotion_id of items from the specific_products
+// promotions they match
+type Linker []config.Promotion
+
+// NewLinker returns a Linker for the specific_products promotions of
+// promotions
+func NewLinker(promotions []config.Promotion) Linker {
+	var l Linker
+	for _, p := range promotions {
+		if p.ProductApplicability == config.PromotionSpecificProducts {
+			l = append(l, p)
+		}
+	}
+	return l
+}
+
+// Apply sets the item's PromotionIDs. It satisfies pipeline.Transformer.
+func (l Linker) Apply(item *input.AdItem) error {
+	item.PromotionIDs = nil
+	for _, p := range l {
+		if Matches(p, *item) {
+			item.PromotionIDs = append(item.PromotionIDs, p.ID)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the promotion applies to the item: every list
+// the promotion sets contains the item's value
+func Matches(p config.Promotion, item input.AdItem) bool {
+	if p.ProductApplicability == config.PromotionAllProducts {
+		return true
+	}
+	if len(p.AdIDs) > 0 && !slices.Contains(p.AdIDs, item.AdID) {
+		return false
+	}
+	if len(p.Brands) > 0 && !slices.ContainsFunc(p.Brands, func(b string) bool { return strings.EqualFold(b, item.Brand) }) {
+		return false
+	}
+	if len(p.Subcategories) > 0 && !slices.ContainsFunc(p.Subcategories, func(s string) bool {
+		return s == item.Subcategory || (item.SubcategoryName != "" && strings.EqualFold(s, item.SubcategoryName))
+	}) {
+		return false
+	}
+	return true
+}
+
+// item is one promotion of the feed
+type item struct {
+	XMLName               xml.Name `xml:"item"`
+	ID                    string   `xml:"g:promotion_id"`
+	ProductApplicability  string   `xml:"g:product_applicability"`
+	OfferType             string   `xml:"g:offer_type"`
+	GenericRedemptionCode string   `xml:"g:generic_redemption_code,omitempty"`
+	LongTitle             string   `xml:"g:long_title"`
+	EffectiveDates        string   `xml:"g:promotion_effective_dates"` // start/end, ISO 8601
+	RedemptionChannel     string   `xml:"g:redemption_channel"`
+}
+
+// feed is the root of the promotions feed
+type feed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	NS      string   `xml:"xmlns:g,attr"`
+	Items   []item   `xml:"channel>item"`
+}
+
+// WriteXML writes the promotions as a Merchant Center promotions feed in RSS
+// 2.0, redeemable online
+func WriteXML(w io.Writer, promotions []config.Promotion) error {
+	f := feed{Version: "2.0", NS: googlefeed.Namespace}
+	for _, p := range promotions {
+		f.Items = append(f.Items, item{
+			ID:                    p.ID,
+			ProductApplicability:  p.ProductApplicability,
+			OfferType:             p.OfferType,
+			GenericRedemptionCode: p.GenericRedemptionCode,
+			LongTitle:             p.LongTitle,
+			EffectiveDates:        p.Start.UTC().Format(time.RFC3339) + "/" + p.End.UTC().Format(time.RFC3339),
+			RedemptionChannel:     "online",
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(f); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
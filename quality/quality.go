@@ -0,0 +1,71 @@
+// Package quality rates how complete each item is, so thin listings can be
+// kept out of the feeds and the run summary shows how many items fall short.
+// An item scores a point for each of: a brand, a valid GTIN, at least
+// MinImages images, a description of at least MinDescriptionLength
+// characters, and a color and a size the synonym dictionary knows.
+package quality
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/vocab"
+)
+
+// Criteria thresholds
+const (
+	MinImages            = 2
+	MinDescriptionLength = 200
+)
+
+// Scorer scores items against the canonical values of a synonym dictionary
+type Scorer struct {
+	synonyms vocab.Dictionary
+}
+
+// NewScorer returns a Scorer checking colors and sizes against synonyms
+func NewScorer(synonyms vocab.Dictionary) Scorer {
+	return Scorer{synonyms: synonyms}
+}
+
+// Score returns the item's score, 0 to config.MaxQualityScore
+func (s Scorer) Score(item input.AdItem) int {
+	images := len(item.AdditionalImageLinks)
+	if item.ImageLink != "" {
+		images++
+	}
+	score := 0
+	for _, met := range []bool{
+		item.Brand != "",
+		item.GTIN != "",
+		images >= MinImages,
+		utf8.RuneCountInString(item.Description) >= MinDescriptionLength,
+		s.synonyms.Canonical(vocab.Color, item.Color),
+		s.synonyms.Canonical(vocab.Size, item.Size),
+	} {
+		if met {
+			score++
+		}
+	}
+	return score
+}
+
+// Counts are the items written by score, as a decimal string so they can
+// be summarized like the other counts. It implements pipeline.Sink.
+type Counts struct {
+	scorer Scorer
+	Scores map[string]int
+}
+
+// NewCounts returns an empty Counts scoring with scorer
+func NewCounts(scorer Scorer) *Counts {
+	return &Counts{scorer: scorer, Scores: map[string]int{}}
+}
+
+func (c *Counts) Write(item input.AdItem) error {
+	c.Scores[strconv.Itoa(c.scorer.Score(item))]++
+	return nil
+}
+
+func (c *Counts) Close() error { return nil }
@@ -0,0 +1,130 @@
+// Package retry retries transient failures of network calls with
+// exponential backoff, following the Retry policy in the config.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"go_data_fashion_accessories/config"
+)
+
+// StatusError reports an HTTP response with a server error or rate limit
+// status, before a client tries to decode it
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned HTTP %d", e.StatusCode)
+}
+
+// Error is returned once every attempt allowed by the retry policy has
+// failed. Err is the error from the last attempt.
+type Error struct {
+	Attempts int
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("giving up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusTransport turns 5xx and 429 responses into a StatusError so they
+// are retried, and can be told apart from API errors returned with HTTP 200
+// such as GraphQL errors. A nil Base uses http.DefaultTransport.
+type StatusTransport struct {
+	Base http.RoundTripper
+}
+
+func (t StatusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests {
+		res.Body.Close()
+		return nil, &StatusError{StatusCode: res.StatusCode}
+	}
+	return res, nil
+}
+
+// Retryable reports whether err is a transient failure worth retrying
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// Backoff returns the delay before the given retry (1 for the first retry):
+// exponential growth from BaseDelay, capped at MaxDelay, with jitter applied
+func Backoff(policy config.Retry, retry int) time.Duration {
+	delay := policy.BaseDelay.Duration << (retry - 1)
+	if delay <= 0 || delay > policy.MaxDelay.Duration {
+		delay = policy.MaxDelay.Duration
+	}
+	if policy.Jitter > 0 {
+		spread := float64(delay) * policy.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+// Do calls fn until it succeeds, fails with a non-retryable error or the
+// policy runs out of attempts. Retries are logged with name.
+func Do(ctx context.Context, logger *slog.Logger, policy config.Retry, name string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !Retryable(err) {
+			return err
+		}
+		if attempt >= policy.MaxAttempts {
+			return &Error{Attempts: attempt, Err: err}
+		}
+
+		delay := Backoff(policy, attempt)
+		logger.Warn(name+" failed; retrying",
+			"attempt", attempt, "max_attempts", policy.MaxAttempts, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package rules
+
+import (
+	"fmt"
+	"log/slog"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+)
+
+// Labels sets the custom labels of items by the CustomLabels of the config
+type Labels struct {
+	rules  [config.MaxCustomLabels][]labelRule // by index, in config order
+	logger *slog.Logger
+}
+
+// labelRule is a compiled custom label
+type labelRule struct {
+	value string
+	rule  *Rule
+}
+
+// CompileLabels type-checks the expression of every custom label. A nil
+// logger uses slog.Default().
+func CompileLabels(labels []config.CustomLabel, logger *slog.Logger) (*Labels, error) {
+	exprs := make([]config.Rule, len(labels))
+	for i, label := range labels {
+		exprs[i] = config.Rule{Name: fmt.Sprintf("custom_label_%d=%s", label.Index, label.Value), When: label.When}
+	}
+	compiled, err := Compile(exprs)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Labels{logger: logging.OrDefault(logger)}
+	for i, label := range labels {
+		l.rules[label.Index] = append(l.rules[label.Index], labelRule{value: label.Value, rule: compiled[i]})
+	}
+	return l, nil
+}
+
+// Apply sets each custom label of item to the value of the first label of
+// its index whose expression holds, leaving it empty when none does. A label
+// that fails to evaluate is logged and treated as false. It satisfies
+// pipeline.Transformer.
+func (l *Labels) Apply(item *input.AdItem) error {
+	for i, candidates := range l.rules {
+		item.CustomLabels[i] = ""
+		for _, c := range candidates {
+			ok, err := c.rule.Eval(*item)
+			if err != nil {
+				l.logger.Warn("Custom label failed to evaluate",
+					logging.AdID, item.AdID, logging.DraftID, item.DraftID, "error", err)
+			}
+			if ok {
+				item.CustomLabels[i] = c.value
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Package rules evaluates the inclusion rules set in the config. A rule is a
+// CEL expression over the fields of a feed item, such as
+//
+//	price >= 50 && brand != "" && subcategory in ["a1b2...", "c3d4..."]
+//
+// and an item is only kept when every rule is true for it. Custom labels
+// are set by expressions of the same kind.
+package rules
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/cel-go/cel"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/money"
+)
+
+// variables are the item fields rules can refer to, with their CEL types
+var variables = []struct {
+	name  string
+	typ   *cel.Type
+	value func(item input.AdItem) any
+}{
+	{"id", cel.StringType, func(i input.AdItem) any { return i.ID }},
+	{"ad_id", cel.StringType, func(i input.AdItem) any { return i.AdID }},
+	{"title", cel.StringType, func(i input.AdItem) any { return i.Title }},
+	{"description", cel.StringType, func(i input.AdItem) any { return i.Description }},
+	{"brand", cel.StringType, func(i input.AdItem) any { return i.Brand }},
+	{"price", cel.DoubleType, func(i input.AdItem) any { return amount(i.Price) }},
+	{"currency", cel.StringType, func(i input.AdItem) any { return i.Price.Currency }},
+	{"availability", cel.StringType, func(i input.AdItem) any { return i.Availability }},
+	{"condition", cel.StringType, func(i input.AdItem) any { return i.Condition }},
+	{"code_number", cel.StringType, func(i input.AdItem) any { return i.CodeNumber.String() }},
+	{"gtin", cel.StringType, func(i input.AdItem) any { return i.GTIN }},
+	{"mpn", cel.StringType, func(i input.AdItem) any { return i.MPN }},
+	{"subcategory", cel.StringType, func(i input.AdItem) any { return i.Subcategory }},
+	{"subcategory_name", cel.StringType, func(i input.AdItem) any { return i.SubcategoryName }},
+	{"google_product_category", cel.StringType, func(i input.AdItem) any { return i.GoogleProductCategory }},
+	{"product_type", cel.StringType, func(i input.AdItem) any { return i.ProductType }},
+	{"item_group_id", cel.StringType, func(i input.AdItem) any { return i.ItemGroupID }},
+	{"color", cel.StringType, func(i input.AdItem) any { return i.Color }},
+	{"size", cel.StringType, func(i input.AdItem) any { return i.Size }},
+	{"material", cel.StringType, func(i input.AdItem) any { return i.Material }},
+	{"gender", cel.StringType, func(i input.AdItem) any { return i.Gender }},
+	{"age_group", cel.StringType, func(i input.AdItem) any { return i.AgeGroup }},
+	{"image_link", cel.StringType, func(i input.AdItem) any { return i.ImageLink }},
+	{"image_count", cel.IntType, func(i input.AdItem) any { return imageCount(i) }},
+	{"feed", cel.StringType, func(i input.AdItem) any { return i.Feed }},
+	{"days_since_update", cel.IntType, func(i input.AdItem) any { return daysSinceUpdate(i) }},
+}
+
+// daysSinceUpdate returns the whole days since the ad was last edited, or
+// -1 when the source does not say
+func daysSinceUpdate(item input.AdItem) int64 {
+	if item.UpdatedAt.IsZero() {
+		return -1
+	}
+	return int64(time.Since(item.UpdatedAt) / (24 * time.Hour))
+}
+
+// amount returns m in major units, e.g. 12.5 for 1250 fils
+func amount(m money.Money) float64 {
+	return float64(m.Minor) / math.Pow10(money.Exponent(m.Currency))
+}
+
+// imageCount counts the main and additional images of an item
+func imageCount(item input.AdItem) int64 {
+	n := int64(len(item.AdditionalImageLinks))
+	if item.ImageLink != "" {
+		n++
+	}
+	return n
+}
+
+// Rule is a compiled inclusion rule
+type Rule struct {
+	Name string
+	Expr string
+	prg  cel.Program
+}
+
+// Compile type-checks each rule; every expression must be a bool
+func Compile(rules []config.Rule) ([]*Rule, error) {
+	// Let price >= 50 compare the double price with an int literal
+	opts := []cel.EnvOption{cel.CrossTypeNumericComparisons(true)}
+	for _, v := range variables {
+		opts = append(opts, cel.Variable(v.name, v.typ))
+	}
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := make([]*Rule, 0, len(rules))
+	for _, r := range rules {
+		ast, issues := env.Compile(r.When)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return nil, fmt.Errorf("rule %q: expression is %s, not bool", r.Name, ast.OutputType())
+		}
+		prg, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		compiled = append(compiled, &Rule{Name: r.Name, Expr: r.When, prg: prg})
+	}
+	return compiled, nil
+}
+
+// Eval reports whether the rule holds for item. An expression that fails
+// at run time, such as one dividing by zero, returns the error.
+func (r *Rule) Eval(item input.AdItem) (bool, error) {
+	vars := make(map[string]any, len(variables))
+	for _, v := range variables {
+		vars[v.name] = v.value(item)
+	}
+	out, _, err := r.prg.Eval(vars)
+	if err != nil {
+		return false, fmt.Errorf("rule %q: %w", r.Name, err)
+	}
+	ok, _ := out.Value().(bool)
+	return ok, nil
+}
+
+// Names returns the variables rules can refer to
+func Names() []string {
+	names := make([]string, len(variables))
+	for i, v := range variables {
+		names[i] = v.name
+	}
+	return names
+}
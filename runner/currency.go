@@ -0,0 +1,309 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/httpclient"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/money"
+	"go_data_fashion_accessories/pipeline"
+)
+
+// NewRateProvider returns the exchange rate provider configured in cfg.
+// Rates are fetched with the settings in cfg.HTTP.
+func NewRateProvider(cfg *config.Config) (money.RateProvider, error) {
+	switch cfg.Rates.Provider {
+	case config.RatesStatic:
+		rates := make(map[string]string, len(cfg.Rates.Static))
+		for code, rate := range cfg.Rates.Static {
+			rates[code] = rate.String()
+		}
+		return money.NewStaticRates(cfg.Currency, rates)
+	case config.RatesECB:
+		client, err := httpclient.New(cfg.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		return money.NewECBRates(client, cfg.Rates.TTL.Duration), nil
+	case config.RatesHTTP:
+		client, err := httpclient.New(cfg.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		return money.NewHTTPRates(client, cfg.Rates.URL, cfg.Rates.Headers, cfg.Rates.TTL.Duration), nil
+	default:
+		return nil, fmt.Errorf("unknown rates provider %q", cfg.Rates.Provider)
+	}
+}
+
+// CurrencyPath returns the file path of the feed at path converted to
+// currency, e.g. productsfashionaccessories_usd.xml
+func CurrencyPath(path, currency string) string {
+	return suffixPath(path, strings.ToLower(currency))
+}
+
+// CategoryPath returns the file path of the feed at path for the category
+// with the given FeedLabel, e.g. productsfashionaccessories_apparel.xml. The
+// category without a label keeps path.
+func CategoryPath(path, label string) string {
+	if label == "" {
+		return path
+	}
+	return suffixPath(path, label)
+}
+
+// FeedPath returns the file path of the separate feed at path, e.g.
+// productsfashionaccessories_cod.xml. The main feed "" keeps path.
+func FeedPath(path, feed string) string {
+	if feed == "" {
+		return path
+	}
+	return suffixPath(path, feed)
+}
+
+// DeltaPath returns the file path of the delta feed of the feed at path,
+// e.g. productsfashionaccessories_delta.xml
+func DeltaPath(path string) string {
+	return suffixPath(path, "delta")
+}
+
+// suffixPath adds "_" and suffix to the file name at path, before its
+// extension; a .gz after the extension stays with it
+func suffixPath(path, suffix string) string {
+	base, gz := strings.CutSuffix(path, ".gz")
+	ext := filepath.Ext(base)
+	if gz {
+		ext += ".gz"
+	}
+	return strings.TrimSuffix(path, ext) + "_" + suffix + ext
+}
+
+// currencyFeed is a feed currency with its exchange rate resolved
+type currencyFeed struct {
+	code     string
+	rate     *big.Rat
+	rounding money.Rounding
+}
+
+// resolveCurrencies looks up the rate of every feed currency in cfg
+func resolveCurrencies(ctx context.Context, cfg *config.Config) ([]currencyFeed, error) {
+	if len(cfg.FeedCurrencies) == 0 {
+		return nil, nil
+	}
+	provider, err := NewRateProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds := make([]currencyFeed, 0, len(cfg.FeedCurrencies))
+	for _, fc := range cfg.FeedCurrencies {
+		rate, err := provider.Rate(ctx, cfg.Currency, fc.Code)
+		if err != nil {
+			return nil, err
+		}
+		rounding := money.Rounding{Mode: money.RoundingMode(fc.Rounding)}
+		if fc.Step != "" {
+			step, err := money.Parse(fc.Step, fc.Code)
+			if err != nil {
+				return nil, fmt.Errorf("%s rounding step: %w", fc.Code, err)
+			}
+			rounding.Step = step.Minor
+		}
+		feeds = append(feeds, currencyFeed{code: fc.Code, rate: rate, rounding: rounding})
+	}
+	return feeds, nil
+}
+
+// convertSink converts each item's price before handing it to the wrapped
+// sink
+type convertSink struct {
+	pipeline.Sink
+	feed currencyFeed
+}
+
+func (s *convertSink) Write(item input.AdItem) error {
+	item.Price = money.Convert(item.Price, s.feed.code, s.feed.rate, s.feed.rounding)
+	if !item.StartingBid.IsZero() {
+		item.StartingBid = money.Convert(item.StartingBid, s.feed.code, s.feed.rate, s.feed.rounding)
+	}
+	return s.Sink.Write(item)
+}
+
+func (s *convertSink) Abort() error {
+	return pipeline.Abort(s.Sink)
+}
+
+// changedSink only writes the items marked Changed, for delta feeds
+type changedSink struct {
+	pipeline.Sink
+}
+
+func (s *changedSink) Write(item input.AdItem) error {
+	if !item.Changed {
+		return nil
+	}
+	return s.Sink.Write(item)
+}
+
+func (s *changedSink) Abort() error {
+	return pipeline.Abort(s.Sink)
+}
+
+// feedFile is one file written by CreateSinks
+type feedFile struct {
+	format   string
+	category string // FeedLabel of the category
+	feed     string // separate feed, "" for the main one
+	currency string // feed currency, "" for the base currency
+	language string // content language, "" for Languages.Default
+	delta    bool   // only the changed items
+	path     string
+}
+
+// feedFiles lists the files CreateSinks writes for formats
+func feedFiles(cfg *config.Config, formats []string, path string) ([]feedFile, error) {
+	var files []feedFile
+	for _, format := range formats {
+		format = strings.TrimSpace(format)
+		f, err := LookupFormat(format)
+		if err != nil {
+			return nil, err
+		}
+		main := path
+		if main == "" {
+			main = f.DefaultPath
+		}
+		if cfg.Gzip && !strings.HasSuffix(main, ".gz") {
+			main += ".gz"
+		}
+
+		for _, category := range cfg.CategoryRegistry().ForFormat(format) {
+			for _, feed := range append([]string{""}, SeparateFeeds(cfg)...) {
+				base := FeedPath(CategoryPath(main, category.FeedLabel), feed)
+				currencies := []string{""}
+				for _, fc := range cfg.FeedCurrencies {
+					currencies = append(currencies, fc.Code)
+				}
+				for _, currency := range currencies {
+					for _, lang := range append([]string{""}, cfg.Languages.Feeds...) {
+						file := feedFile{format: format, category: category.FeedLabel, feed: feed, currency: currency, language: lang, path: base}
+						if currency != "" {
+							file.path = CurrencyPath(file.path, currency)
+						}
+						if lang != "" {
+							file.path = LanguagePath(file.path, lang)
+						}
+						files = append(files, file)
+						if cfg.Delta.Enabled {
+							file.delta = true
+							file.path = DeltaPath(file.path)
+							files = append(files, file)
+						}
+					}
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// FeedPaths returns the paths of the files CreateSinks wrote for formats,
+// plus the deletions list when delta feeds are enabled. Split feeds are
+// listed as their parts and manifest, so FeedPaths must be called after the
+// run.
+func FeedPaths(cfg *config.Config, formats []string, path string) ([]string, error) {
+	files, err := feedFiles(cfg, formats, path)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		written, err := writtenPaths(cfg, f.format, f.path)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, written...)
+	}
+	if cfg.Delta.Enabled {
+		paths = append(paths, cfg.Delta.DeletionsPath)
+	}
+	return paths, nil
+}
+
+// writtenPaths returns the files written for the feed of format at path:
+// path itself, or the parts and manifest of a split feed
+func writtenPaths(cfg *config.Config, format, path string) ([]string, error) {
+	if !cfg.Channels[format].Split() {
+		return []string{path}, nil
+	}
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(manifest.Parts)+1)
+	for _, part := range manifest.Parts {
+		paths = append(paths, filepath.Join(filepath.Dir(path), part.File))
+	}
+	return append(paths, ManifestPath(path)), nil
+}
+
+// CreateSinks opens a file sink for each format in the base currency, at
+// path or the format's default path, plus one for each feed currency named
+// by CurrencyPath, and each of these in every language of Languages.Feeds,
+// named by LanguagePath. Each category written in the format gets its own
+// set of files, named by CategoryPath. Separate feeds get the same set of files,
+// named by FeedPath, and with delta feeds enabled every file gets a delta
+// named by DeltaPath. Channels with a MaxItems or MaxBytes get each file in parts
+// named by PartPath. Each sink applies its channel options from cfg.
+// Exchange rates are resolved before any file is created, once per call.
+func CreateSinks(ctx context.Context, cfg *config.Config, formats []string, path string) ([]pipeline.Sink, error) {
+	return createSinks(ctx, cfg, formats, path, func(f feedFile) (pipeline.Sink, error) {
+		if channel := cfg.Channels[f.format]; channel.Split() {
+			return newSplitSink(f.format, f.path, channel), nil
+		}
+		return CreateSink(f.format, f.path)
+	})
+}
+
+// createSinks is CreateSinks opening the innermost sink of every file with
+// open
+func createSinks(ctx context.Context, cfg *config.Config, formats []string, path string, open func(f feedFile) (pipeline.Sink, error)) ([]pipeline.Sink, error) {
+	files, err := feedFiles(cfg, formats, path)
+	if err != nil {
+		return nil, err
+	}
+	currencies, err := resolveCurrencies(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	rates := make(map[string]currencyFeed, len(currencies))
+	for _, c := range currencies {
+		rates[c.code] = c
+	}
+
+	sinks := make([]pipeline.Sink, 0, len(files))
+	for _, f := range files {
+		sink, err := open(f)
+		if err != nil {
+			abortAll(sinks)
+			return nil, err
+		}
+		if f.delta {
+			sink = &changedSink{Sink: sink}
+		}
+		if f.currency != "" {
+			sink = &convertSink{Sink: sink, feed: rates[f.currency]}
+		}
+		sink = ChannelSink(cfg, f.format, f.feed, CategorySink(f.category, sink))
+		if f.language != "" {
+			sink = newLanguageSink(sink, f.language, cfg.Links)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/feeddiff"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+)
+
+// Preview is a feed file encoded in memory by a dry run, in place of the
+// file a real run would write at Path
+type Preview struct {
+	Format       string
+	Path         string
+	Destinations []string // where a real run would upload the file
+	Split        bool     // a real run would write the file in parts
+	Items        int
+	Bytes        int64 // encoded size, before any compression
+	data         bytes.Buffer
+}
+
+// CreatePreviews returns the sinks CreateSinks would for formats, encoding
+// every file in memory instead of writing it, and the previews they fill.
+// Split feeds are previewed as one file. Delta feeds come out empty, since
+// a dry run does not compare the items with the last run's.
+func CreatePreviews(ctx context.Context, cfg *config.Config, formats []string, path string) ([]pipeline.Sink, []*Preview, error) {
+	var previews []*Preview
+	sinks, err := createSinks(ctx, cfg, formats, path, func(f feedFile) (pipeline.Sink, error) {
+		p := &Preview{Format: f.format, Path: f.path, Destinations: cfg.Upload.Destinations, Split: cfg.Channels[f.format].Split()}
+		previews = append(previews, p)
+		return newPreviewSink(p)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return sinks, previews, nil
+}
+
+// TargetPreviews returns a sink for every target in cfg that encodes its
+// feed in memory, as CreateTargets would write it, and the previews they
+// fill. The targets are written in turn rather than on goroutines of their
+// own, and any target that cannot be encoded fails the run.
+func TargetPreviews(ctx context.Context, cfg *config.Config) ([]pipeline.Sink, []*Preview, error) {
+	currencies, err := resolveCurrencies(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	rates := make(map[string]currencyFeed, len(currencies))
+	for _, c := range currencies {
+		rates[c.code] = c
+	}
+
+	sinks := make([]pipeline.Sink, 0, len(cfg.Targets))
+	previews := make([]*Preview, 0, len(cfg.Targets))
+	for _, t := range cfg.Targets {
+		path, err := TargetPath(cfg, t)
+		if err != nil {
+			return nil, nil, fmt.Errorf("target %s: %w", t.Name, err)
+		}
+		p := &Preview{Format: t.Format, Path: path, Destinations: t.Destinations, Split: cfg.Channels[t.Format].Split()}
+		sink, err := newPreviewSink(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("target %s: %w", t.Name, err)
+		}
+		sinks = append(sinks, openTarget(cfg, t, sink, rates))
+		previews = append(previews, p)
+	}
+	return sinks, previews, nil
+}
+
+// Uploads returns where a real run would upload the file, with any
+// password hidden
+func (p *Preview) Uploads() []string {
+	uploads := make([]string, len(p.Destinations))
+	for i, dest := range p.Destinations {
+		uploads[i] = redact(dest + filepath.Base(p.Path))
+	}
+	return uploads
+}
+
+// PreviewDiff is a preview compared with the feed already at its path
+type PreviewDiff struct {
+	feeddiff.Diff
+	Before int // items in the feed at the path, 0 if there is none
+	After  int // items in the preview
+}
+
+// Diff compares the preview with the feed a previous run wrote at Path, as
+// if that were empty when there is none. It returns nil for formats the
+// feeddiff package cannot read.
+func (p *Preview) Diff() (*PreviewDiff, error) {
+	format := strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(p.Path, ".gz")), ".")
+	if format != feeddiff.FormatXML && format != feeddiff.FormatCSV {
+		return nil, nil
+	}
+	after, err := feeddiff.Read(bytes.NewReader(p.data.Bytes()), format)
+	if err != nil {
+		return nil, fmt.Errorf("reading the preview of %s: %w", p.Path, err)
+	}
+	before, err := feeddiff.Open(p.Path, format)
+	if errors.Is(err, fs.ErrNotExist) {
+		before, err = feeddiff.Feed{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PreviewDiff{Diff: feeddiff.Compare(before, after), Before: len(before), After: len(after)}, nil
+}
+
+// previewSink encodes the items of a Preview into its buffer
+type previewSink struct {
+	pipeline.Sink
+	preview *Preview
+}
+
+// newPreviewSink returns a sink encoding p.Format into p
+func newPreviewSink(p *Preview) (*previewSink, error) {
+	f, err := LookupFormat(p.Format)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := f.New(&countingWriter{w: &p.data, n: &p.Bytes})
+	if err != nil {
+		return nil, err
+	}
+	return &previewSink{Sink: sink, preview: p}, nil
+}
+
+func (s *previewSink) Write(item input.AdItem) error {
+	s.preview.Items++
+	return s.Sink.Write(item)
+}
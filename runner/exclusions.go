@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/notify"
+)
+
+// writeExclusions writes the ads and items a run that started at started
+// left out to a report per format in cfg.Exclusions.Dir. A report that
+// cannot be written is logged; it does not fail the run.
+func writeExclusions(cfg *config.Config, started time.Time, skipped []input.SkipReport, logger *slog.Logger) {
+	if err := os.MkdirAll(cfg.Exclusions.Dir, 0o755); err != nil {
+		logger.Error("Error writing the exclusions report", "error", err)
+		return
+	}
+	name := "exclusions_" + started.UTC().Format("20060102T150405Z")
+	for _, format := range cfg.Exclusions.Formats {
+		path := filepath.Join(cfg.Exclusions.Dir, name+"."+format)
+		if err := writeExclusionsFile(path, format, skipped); err != nil {
+			logger.Error("Error writing the exclusions report", "path", path, "error", err)
+			continue
+		}
+		logger.Info("Wrote exclusions report", "path", path, "excluded", len(skipped))
+	}
+}
+
+// writeExclusionsFile replaces the file at path with the reports in format
+func writeExclusionsFile(path, format string, skipped []input.SkipReport) error {
+	var data []byte
+	var err error
+	if format == config.ExclusionsJSON {
+		if skipped == nil {
+			skipped = []input.SkipReport{}
+		}
+		data, err = json.MarshalIndent(skipped, "", "  ")
+	} else {
+		data, err = notify.SkipCSV(skipped)
+	}
+	if err != nil {
+		return err
+	}
+	file, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.discard()
+		return err
+	}
+	return file.commit()
+}
@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/validate"
+)
+
+// Explain fetches one ad and walks it through the same checks a run applies,
+// returning every decision made along the way. A "result" decision for each
+// of the ad's items, one per variant, says whether it would be in the feed.
+// Source logs go to logger.
+func Explain(ctx context.Context, cfg *config.Config, adID string, logger *slog.Logger) ([]input.Decision, error) {
+	source, err := input.NewSource(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var decisions []input.Decision
+	add := func(step string, passed bool, format string, args ...any) {
+		decisions = append(decisions, input.Decision{Step: step, Passed: passed, Detail: fmt.Sprintf(format, args...)})
+	}
+	exclude := func(reason string) []input.Decision {
+		add("result", false, "excluded: %s", reason)
+		return decisions
+	}
+
+	ad, err := input.LookupAd(ctx, source, adID)
+	if err != nil {
+		return nil, err
+	}
+	if ad == nil {
+		add("lookup", false, "no ad with ID %s in the %s source", adID, cfg.Source.Type)
+		return exclude("not found"), nil
+	}
+	add("lookup", true, "found in the %s source", cfg.Source.Type)
+
+	// The feed query only selects published ads of the categories; lookups
+	// by ID bypass it, so repeat those checks when the source reports them
+	if ad.Status != "" {
+		if ad.Status != cfg.Source.Filters.Status {
+			add("status", false, "status is %q, only %s ads are fetched", ad.Status, cfg.Source.Filters.Status)
+			return exclude("wrong status"), nil
+		}
+		add("status", true, "%s", ad.Status)
+	}
+	if ad.CategoryID != "" {
+		categories := cfg.CategoryRegistry()
+		if _, ok := categories.ByID(ad.CategoryID); !ok {
+			add("category", false, "category %s is not one of %s", ad.CategoryID, strings.Join(categories.IDs(), ", "))
+			return exclude("wrong category"), nil
+		}
+		add("category", true, "%s", ad.CategoryID)
+	}
+
+	processor := input.NewProcessor(cfg, logger)
+	processor.Trace = func(d input.Decision) { decisions = append(decisions, d) }
+	items, skipped := processor.Process(*ad)
+	if skipped != nil {
+		return exclude(string(skipped.Reason)), nil
+	}
+
+	// The GTIN problem is reported as a decision below instead of logged
+	brands, err := loadBrands(cfg)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := transformers(ctx, cfg, source, brands, logging.Discard())
+	if err != nil {
+		return nil, err
+	}
+	fs, err := filters(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := checks(cfg, logging.Discard())
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if reason := explainItem(ctx, cfg, steps, fs, cs, &item, add); reason != "" {
+			add("result", false, "item %s excluded: %s", item.ID, reason)
+			continue
+		}
+		if item.Feed != "" {
+			add("result", true, "included as item %s in the %s feed", item.ID, item.Feed)
+			continue
+		}
+		add("result", true, "included as item %s", item.ID)
+	}
+	return decisions, nil
+}
+
+// explainItem runs one parsed item through the transformers, filters and
+// checks, adding a decision for each, and returns why it was excluded, if
+// it was
+func explainItem(ctx context.Context, cfg *config.Config, steps []pipeline.Transformer, fs []pipeline.Filter, cs []pipeline.Check, item *input.AdItem,
+	add func(step string, passed bool, format string, args ...any)) string {
+	before := *item
+	for _, step := range steps {
+		if err := step(item); err != nil {
+			add("transform", false, "%v", err)
+			return "transform failed"
+		}
+	}
+	if item.ID != before.ID {
+		add("sanitize", true, "keyed by code_number %s", item.ID)
+	}
+	if item.Title != before.Title {
+		add("sanitize", true, "title cleaned to %q", item.Title)
+	}
+	if item.Description != before.Description {
+		add("sanitize", true, "description cleaned to %q", item.Description)
+	}
+	add("categorize", item.GoogleProductCategory != "", "google_product_category %q, product_type %q",
+		item.GoogleProductCategory, item.ProductType)
+	if item.CodeNumber != "" {
+		if _, err := validate.NormalizeGTIN(item.CodeNumber.String()); err != nil {
+			add("gtin_check", false, "%v; InvalidGTIN is %q", err, cfg.InvalidGTIN)
+		} else {
+			add("gtin_check", true, "valid GTIN %s", item.GTIN)
+		}
+	}
+
+	for _, f := range fs {
+		name := string(f.Reason)
+		if f.Detail != "" {
+			name += " " + f.Detail
+		}
+		if !f.Keep(*item) {
+			add("filter", false, "%s", name)
+			return name
+		}
+		add("filter", true, "%s", name)
+	}
+
+	for _, c := range cs {
+		if !c.Keep(ctx, item) {
+			add("check", false, "%s", c.Reason)
+			return string(c.Reason)
+		}
+		add("check", true, "%s", c.Reason)
+	}
+
+	for _, issue := range validate.Item(*item) {
+		add("validate", false, "%s: %s", issue.Field, issue.Message)
+	}
+	return ""
+}
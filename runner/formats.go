@@ -0,0 +1,318 @@
+package runner
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/linkurl"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/model/output/googlefeed"
+	"go_data_fashion_accessories/model/output/localinventory"
+	"go_data_fashion_accessories/model/output/metacsv"
+	"go_data_fashion_accessories/model/output/ndjson"
+	"go_data_fashion_accessories/model/output/parquet"
+	"go_data_fashion_accessories/model/output/pinterest"
+	"go_data_fashion_accessories/model/output/snapchat"
+	"go_data_fashion_accessories/model/output/tiktok"
+	"go_data_fashion_accessories/pipeline"
+)
+
+// Format describes one output file format
+type Format struct {
+	DefaultPath string
+	ContentType string
+	// New returns a sink that encodes items to w
+	New func(w io.Writer) (pipeline.Sink, error)
+}
+
+// Formats lists the output formats by the name used on the command line
+var Formats = map[string]Format{
+	"xml": {
+		DefaultPath: "productsfashionaccessories.xml",
+		ContentType: "application/xml; charset=utf-8",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return googlefeed.NewWriter(w) },
+	},
+	"csv": {
+		DefaultPath: "productsfashionaccessories.csv",
+		ContentType: "text/csv; charset=utf-8",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return metacsv.NewWriter(w) },
+	},
+	"tiktok": {
+		DefaultPath: "productsfashionaccessories_tiktok.csv",
+		ContentType: "text/csv; charset=utf-8",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return tiktok.NewWriter(w) },
+	},
+	// TikTok reads Google's RSS feed as is
+	"tiktok-xml": {
+		DefaultPath: "productsfashionaccessories_tiktok.xml",
+		ContentType: "application/xml; charset=utf-8",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return googlefeed.NewWriter(w) },
+	},
+	"snapchat": {
+		DefaultPath: "productsfashionaccessories_snapchat.csv",
+		ContentType: "text/csv; charset=utf-8",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return snapchat.NewWriter(w) },
+	},
+	"pinterest": {
+		DefaultPath: "productsfashionaccessories_pinterest.csv",
+		ContentType: "text/csv; charset=utf-8",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return pinterest.NewWriter(w) },
+	},
+	"pinterest-tsv": {
+		DefaultPath: "productsfashionaccessories_pinterest.tsv",
+		ContentType: "text/tab-separated-values; charset=utf-8",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return pinterest.NewTSVWriter(w) },
+	},
+	"ndjson": {
+		DefaultPath: "productsfashionaccessories.ndjson",
+		ContentType: "application/x-ndjson",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return ndjson.NewWriter(w) },
+	},
+	// Stock in physical stores, matched to the items of the main feed by id
+	"local-inventory": {
+		DefaultPath: "productsfashionaccessories_local_inventory.tsv",
+		ContentType: "text/tab-separated-values; charset=utf-8",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return localinventory.NewWriter(w) },
+	},
+	"parquet": {
+		DefaultPath: "productsfashionaccessories.parquet",
+		ContentType: "application/vnd.apache.parquet",
+		New:         func(w io.Writer) (pipeline.Sink, error) { return parquet.NewWriter(w) },
+	},
+}
+
+// FormatNames returns the registered format names in sorted order
+func FormatNames() []string {
+	names := make([]string, 0, len(Formats))
+	for name := range Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupFormat returns the named format
+func LookupFormat(name string) (Format, error) {
+	f, ok := Formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("unknown format %q (want one of %v)", name, FormatNames())
+	}
+	return f, nil
+}
+
+// CreateSink opens a file sink for format at path, or at the format's default
+// path when path is empty. Paths ending in .gz are gzip compressed. The feed
+// is written to a temporary file next to path that replaces it only once the
+// sink closes cleanly, so a failed or crashed run never leaves a truncated
+// feed for fetchers to pick up.
+func CreateSink(format, path string) (pipeline.Sink, error) {
+	return createFileSink(format, path)
+}
+
+// createFileSink is CreateSink returning the concrete sink
+func createFileSink(format, path string) (*fileSink, error) {
+	f, err := LookupFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = f.DefaultPath
+	}
+
+	file, err := createAtomic(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &fileSink{file: file}
+	var w io.Writer = file
+	if strings.HasSuffix(path, ".gz") {
+		s.gzip = gzip.NewWriter(file)
+		w = s.gzip
+	}
+	s.Sink, err = f.New(&countingWriter{w: w, n: &s.written})
+	if err != nil {
+		file.discard()
+		return nil, err
+	}
+	return s, nil
+}
+
+// ChannelSink makes sink receive only the items of feed ("" for the main
+// feed) and applies the options of the format's channel in cfg, such as
+// leaving out items in excluded conditions and items of categories not
+// written in the format, and tagging the links with its UTM parameters
+func ChannelSink(cfg *config.Config, format, feed string, sink pipeline.Sink) pipeline.Sink {
+	channel := cfg.Channels[format]
+	excluded := make(map[string]bool, len(channel.ExcludeConditions))
+	for _, condition := range channel.ExcludeConditions {
+		excluded[condition] = true
+	}
+	categories := map[string]bool{}
+	for _, category := range cfg.CategoryRegistry().ForFormat(format) {
+		categories[category.FeedLabel] = true
+	}
+	return &channelSink{Sink: sink, feed: feed, excluded: excluded, categories: categories, utm: linkurl.UTM(cfg, format)}
+}
+
+// channelSink drops items of other feeds, items whose condition is excluded
+// from its channel and items of categories its format is not written for
+type channelSink struct {
+	pipeline.Sink
+	feed       string
+	excluded   map[string]bool
+	categories map[string]bool   // by FeedLabel
+	utm        map[string]string // added to the item links
+}
+
+func (s *channelSink) Write(item input.AdItem) error {
+	if item.Feed != s.feed || s.excluded[item.Condition] || !s.categories[item.Category] {
+		return nil
+	}
+	item.Link = linkurl.Tag(item.Link, s.utm)
+	return s.Sink.Write(item)
+}
+
+func (s *channelSink) Abort() error {
+	return pipeline.Abort(s.Sink)
+}
+
+// CategorySink makes sink receive only the items of the category with the
+// given FeedLabel
+func CategorySink(label string, sink pipeline.Sink) pipeline.Sink {
+	return &categorySink{Sink: sink, label: label}
+}
+
+// categorySink drops the items of other categories
+type categorySink struct {
+	pipeline.Sink
+	label string
+}
+
+func (s *categorySink) Write(item input.AdItem) error {
+	if item.Category != s.label {
+		return nil
+	}
+	return s.Sink.Write(item)
+}
+
+func (s *categorySink) Abort() error {
+	return pipeline.Abort(s.Sink)
+}
+
+// SeparateFeeds returns the feeds that cfg writes to their own files besides
+// the main one
+func SeparateFeeds(cfg *config.Config) []string {
+	var feeds []string
+	if cfg.Eligibility.CashOnDelivery == config.CashOnDeliverySeparate {
+		feeds = append(feeds, input.FeedCashOnDelivery)
+	}
+	if cfg.Eligibility.Auctions == config.AuctionsSeparate {
+		feeds = append(feeds, input.FeedAuction)
+	}
+	return feeds
+}
+
+// fileSink moves the feed into place once the format's sink has finished
+// writing
+type fileSink struct {
+	pipeline.Sink
+	file    *atomicFile
+	gzip    *gzip.Writer // nil for uncompressed files
+	written int64        // bytes encoded so far, before compression
+}
+
+func (s *fileSink) Close() error {
+	if err := s.finish(); err != nil {
+		return err
+	}
+	return s.file.commit()
+}
+
+// finish ends the feed without moving it into place. The temporary file is
+// removed if that fails.
+func (s *fileSink) finish() error {
+	err := s.Sink.Close()
+	if s.gzip != nil {
+		if gzipErr := s.gzip.Close(); err == nil {
+			err = gzipErr
+		}
+	}
+	if err != nil {
+		s.file.discard()
+	}
+	return err
+}
+
+// Flush pushes any item the format's sink buffered to the file, so written
+// is up to date
+func (s *fileSink) Flush() error {
+	if f, ok := s.Sink.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// countingWriter adds the bytes written through it to n
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// Abort leaves any previous feed in place
+func (s *fileSink) Abort() error {
+	s.Sink.Close()
+	return s.file.discard()
+}
+
+// atomicFile is a temporary file that replaces the file at path when it is
+// committed
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+// createAtomic opens a temporary file in the directory of path
+func createAtomic(path string) (*atomicFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: file, path: path}, nil
+}
+
+// commit flushes the file to disk and renames it over path
+func (f *atomicFile) commit() error {
+	// Temporary files are private; feeds are meant to be served
+	err := f.Chmod(0o644)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// discard closes and removes the file
+func (f *atomicFile) discard() error {
+	f.Close()
+	return os.Remove(f.Name())
+}
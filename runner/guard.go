@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/state"
+)
+
+// ErrGuard is wrapped by the error of a run that cfg.Guard kept from
+// publishing its feeds
+var ErrGuard = errors.New("item count dropped")
+
+// baseline is the average item count of the runs in a history
+type baseline struct {
+	runs          int
+	total         float64
+	subcategories map[string]float64
+}
+
+// newBaseline averages history, or returns the zero baseline when it is
+// empty
+func newBaseline(history []state.ItemCounts) baseline {
+	b := baseline{runs: len(history), subcategories: map[string]float64{}}
+	for _, counts := range history {
+		b.total += float64(counts.Total) / float64(len(history))
+		for name, n := range counts.Subcategories {
+			b.subcategories[name] += float64(n) / float64(len(history))
+		}
+	}
+	return b
+}
+
+// guard returns the pipeline guard of cfg.Guard against the runs in
+// history, or nil when it is off or there is no history yet
+func guard(cfg *config.Config, history []state.ItemCounts) func(pipeline.Stats) error {
+	if cfg.Guard.MaxDropPercent == 0 || len(history) == 0 {
+		return nil
+	}
+	b := newBaseline(history)
+	return func(stats pipeline.Stats) error {
+		var drops []string
+		check := func(name string, n int, average float64) {
+			if average <= 0 {
+				return
+			}
+			if drop := 100 * (average - float64(n)) / average; drop > cfg.Guard.MaxDropPercent {
+				drops = append(drops, fmt.Sprintf("%s has %d items, %.0f%% below the average of %.0f", name, n, drop, average))
+			}
+		}
+		check("the feed", stats.Written, b.total)
+		for _, name := range slices.Sorted(maps.Keys(b.subcategories)) {
+			if average := b.subcategories[name]; average >= float64(cfg.Guard.MinItems) {
+				check(name, stats.Subcategories[name], average)
+			}
+		}
+		if len(drops) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%w over the last %d runs: %s", ErrGuard, b.runs, strings.Join(drops, "; "))
+	}
+}
@@ -0,0 +1,25 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/lifecycle"
+)
+
+// recordHistory records the items of a run that started at started in the
+// database of cfg.History. A run that cannot be recorded is logged; it does
+// not fail the run.
+func recordHistory(ctx context.Context, cfg *config.Config, recorder *lifecycle.Recorder, started time.Time, logger *slog.Logger) {
+	db, err := sql.Open(cfg.History.Driver, cfg.History.DSN)
+	if err == nil {
+		defer db.Close()
+		err = recorder.Commit(ctx, db, started, cfg.FullRefresh)
+	}
+	if err != nil {
+		logger.Error("Error recording the item history", "error", err)
+	}
+}
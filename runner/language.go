@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/linkurl"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+)
+
+// LanguagePath returns the file path of the feed at path in the content
+// language lang, e.g. productsfashionaccessories_ar.xml
+func LanguagePath(path, lang string) string {
+	return suffixPath(path, lang)
+}
+
+// languageSink writes each item with its title and description in one
+// content language, linking to the item's page in that locale
+type languageSink struct {
+	pipeline.Sink
+	lang  string
+	links *linkurl.Builder
+}
+
+// newLanguageSink returns a sink writing the items to sink in lang, with
+// links built from links with lang as their locale unless they set one
+func newLanguageSink(sink pipeline.Sink, lang string, links config.Links) *languageSink {
+	if links.Locale == "" {
+		links.Locale = lang
+	}
+	return &languageSink{Sink: sink, lang: lang, links: linkurl.New(links)}
+}
+
+func (s *languageSink) Write(item input.AdItem) error {
+	// The link keeps the slug of the ad's own title
+	item.Link = s.links.Build(item.AdID, item.DraftID, item.Title)
+	return s.Sink.Write(item.Localized(s.lang))
+}
+
+func (s *languageSink) Abort() error {
+	return pipeline.Abort(s.Sink)
+}
@@ -0,0 +1,903 @@
+// Package runner wires the configured ad source, run state and pipeline
+// together so every entry point generates feeds the same way.
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/bigquery"
+	"go_data_fashion_accessories/brand"
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/delta"
+	"go_data_fashion_accessories/diagnostics"
+	"go_data_fashion_accessories/enrich"
+	"go_data_fashion_accessories/httpclient"
+	"go_data_fashion_accessories/imagecheck"
+	"go_data_fashion_accessories/lifecycle"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/metrics"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/money"
+	"go_data_fashion_accessories/notify"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/promotions"
+	"go_data_fashion_accessories/quality"
+	"go_data_fashion_accessories/rules"
+	"go_data_fashion_accessories/state"
+	"go_data_fashion_accessories/taxonomy"
+	"go_data_fashion_accessories/transform"
+	"go_data_fashion_accessories/translate"
+	"go_data_fashion_accessories/vocab"
+)
+
+// Options adjusts a single run
+type Options struct {
+	// Sinks receive every item that makes it into the feed
+	Sinks []pipeline.Sink
+	// Store makes the run incremental: it starts from the last successful
+	// run recorded there. A nil Store fetches the configured window.
+	Store state.Store
+	// ReadOnly leaves the Store untouched, for runs that only inspect the
+	// ads
+	ReadOnly bool
+	// Report sends the reports of a full run: the skipped ads are emailed
+	// to the catalog team and written to the exclusions reports, the
+	// lifecycle of the items is recorded in the history database and the
+	// run is exported to BigQuery. Scheduled and command line runs set it;
+	// the server's refreshes leave it off so they do not repeat the reports
+	// on every refresh.
+	Report bool
+	// Ads, if not nil, are processed instead of the ads fetched from the
+	// source, e.g. ads delivered by a webhook. The source still serves
+	// lookups such as Source.Names.
+	Ads []input.RawAd
+	// AllStatuses fetches ads in any status, reporting the ones no longer
+	// in the fetched status to OnSkip as input.Unpublished, for runs that
+	// track removals
+	AllStatuses bool
+	// OnSkip, if set, receives a report for every ad left out of the feed
+	OnSkip func(report input.SkipReport)
+	// OnDelete, if set, receives the tombstones of a run with delta feeds
+	// enabled, before the run is recorded, so channels updated through an
+	// API can delete the items too
+	OnDelete func(ctx context.Context, tombstones []delta.Tombstone) error
+	// Logger receives the run's logs, including a debug record for every
+	// skipped ad. Nil uses slog.Default().
+	Logger *slog.Logger
+	// Summary, if set, receives the run's Summary as a table once the run
+	// has ended, whether or not it succeeded
+	Summary io.Writer
+	// OnSummary, if set, receives the run's Summary once the run has
+	// ended, whether or not it succeeded
+	OnSummary func(summary Summary)
+	// Force publishes the feeds even when their item count dropped beyond
+	// cfg.Guard
+	Force bool
+	// Limit, if positive, ends the run once that many items were written,
+	// for sample feeds
+	Limit int
+}
+
+// DefaultStore returns the file state store configured in cfg
+func DefaultStore(cfg *config.Config) state.Store {
+	return state.NewFileStore(cfg.State.Path)
+}
+
+// LookupAds fetches the ads with the given IDs from the configured source,
+// for runs of chosen ads. An ID the source does not have is an error.
+func LookupAds(ctx context.Context, cfg *config.Config, ids []string, logger *slog.Logger) ([]input.RawAd, error) {
+	source, err := input.NewSource(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	ads := make([]input.RawAd, 0, len(ids))
+	for _, id := range ids {
+		ad, err := input.LookupAd(ctx, source, id)
+		if err != nil {
+			return nil, err
+		}
+		if ad == nil {
+			return nil, fmt.Errorf("no ad with ID %s in the %s source", id, cfg.Source.Type)
+		}
+		ads = append(ads, *ad)
+	}
+	return ads, nil
+}
+
+// Run streams the ads from the configured source into the sinks. With a
+// Store, the run starts from the last successful run and records its own
+// start time once every sink closed cleanly. The sinks are closed before Run
+// returns.
+func Run(ctx context.Context, cfg *config.Config, opts Options) (pipeline.Stats, error) {
+	sinks := opts.Sinks
+	logger := logging.OrDefault(opts.Logger)
+
+	source, err := input.NewSource(cfg, logger)
+	if err != nil {
+		abortAll(sinks)
+		return pipeline.Stats{}, err
+	}
+
+	// Pick up where the last successful run left off
+	var since time.Time
+	if opts.Store != nil {
+		since, err = state.Since(ctx, opts.Store, cfg.State.Overlap.Duration)
+		if err != nil {
+			abortAll(sinks)
+			return pipeline.Stats{}, err
+		}
+	}
+	if !since.IsZero() && !cfg.FullRefresh {
+		logger.Info("Fetching ads updated since the last run", "since", since.Format(time.RFC3339))
+	}
+
+	// Runs of given ads or limited runs cover only some of the ads, so they
+	// neither compare their item counts with the last runs' nor record them
+	partial := opts.Ads != nil || opts.Limit > 0
+	countItems := opts.Store != nil && !opts.ReadOnly && !partial
+	var history []state.ItemCounts
+	if countItems {
+		if history, err = state.ItemCountHistory(ctx, opts.Store); err != nil {
+			abortAll(sinks)
+			return pipeline.Stats{}, err
+		}
+	}
+	previousItems := -1
+	if len(history) > 0 {
+		previousItems = history[len(history)-1].Total
+	}
+
+	brands, err := loadBrands(cfg)
+	if err != nil {
+		abortAll(sinks)
+		return pipeline.Stats{}, err
+	}
+	transformers, err := transformers(ctx, cfg, source, brands, logger)
+	if err != nil {
+		abortAll(sinks)
+		return pipeline.Stats{}, err
+	}
+	filters, err := filters(cfg, logger)
+	if err != nil {
+		abortAll(sinks)
+		return pipeline.Stats{}, err
+	}
+	if cfg.Diagnostics.ExcludeAfter > 0 && opts.Store != nil {
+		disapproved, err := diagnostics.Load(ctx, opts.Store)
+		if err != nil {
+			abortAll(sinks)
+			return pipeline.Stats{}, err
+		}
+		filters = append(filters, pipeline.Filter{
+			Reason: input.Disapproved,
+			Keep: func(item input.AdItem) bool {
+				return !disapproved.Excluded(item.AdID, item.UpdatedAt, cfg.Diagnostics.ExcludeAfter)
+			},
+		})
+	}
+
+	// The summary breaks the items written down by quality score
+	synonyms, err := loadSynonyms(cfg)
+	if err != nil {
+		abortAll(sinks)
+		return pipeline.Stats{}, err
+	}
+	scores := quality.NewCounts(quality.NewScorer(synonyms))
+	sinks = append(slices.Clip(sinks), scores)
+
+	// Full runs record the lifecycle of their items
+	var recorder *lifecycle.Recorder
+	if cfg.History.DSN != "" && opts.Report && !opts.ReadOnly && !partial {
+		recorder = lifecycle.NewRecorder()
+		sinks = append(sinks, recorder)
+	}
+
+	// Full runs are exported to BigQuery with their items
+	var exporter *bigquery.Exporter
+	if cfg.BigQuery.ProjectID != "" && opts.Report && !opts.ReadOnly && !partial {
+		if exporter, err = bigquery.NewExporter(ctx, cfg, logger); err != nil {
+			abortAll(sinks)
+			return pipeline.Stats{}, err
+		}
+		sinks = append(sinks, exporter)
+	}
+
+	// Delta feeds compare against the items of the last recorded run
+	var tracker *delta.Tracker
+	if cfg.Delta.Enabled && opts.Store != nil && !opts.ReadOnly {
+		last, err := delta.Load(ctx, opts.Store)
+		if err != nil {
+			abortAll(sinks)
+			return pipeline.Stats{}, err
+		}
+		tracker = delta.NewTracker(last)
+		sinks = []pipeline.Sink{tracker.Sink(sinks)}
+	}
+
+	// Descriptions are enriched first, ahead of the checks that may look at
+	// them, by enough workers to fill a batch
+	checks, err := checks(cfg, logger)
+	if err != nil {
+		abortAll(sinks)
+		return pipeline.Stats{}, err
+	}
+	workers := cfg.ImageCheck.Workers
+	var enricher *enrich.Batcher
+	if cfg.Enrich.Enabled {
+		if enricher, err = newEnricher(ctx, cfg, opts.Store, logger); err != nil {
+			abortAll(sinks)
+			return pipeline.Stats{}, err
+		}
+		checks = append([]pipeline.Check{{Keep: enricher.Keep}}, checks...)
+		workers = max(workers, cfg.Enrich.BatchSize)
+	}
+
+	// The catalog team is emailed the ads a full run left out, and they are
+	// kept in the exclusions reports
+	var skipped []input.SkipReport
+	emailSkipped := len(cfg.Email.To) > 0 && opts.Report && !opts.ReadOnly && !partial
+	reportSkipped := cfg.Exclusions.Dir != "" && opts.Report && !opts.ReadOnly && !partial
+
+	started := time.Now()
+	processor := input.NewProcessor(cfg, logger)
+	var stream input.RawStreamer = source
+	if opts.Ads != nil {
+		stream = input.RawAds(opts.Ads)
+	}
+	p := &pipeline.Pipeline{
+		Source: stream,
+		Options: input.FetchOptions{
+			Since:       since,
+			Window:      cfg.Window.Duration,
+			FullRefresh: cfg.FullRefresh,
+			AllStatuses: opts.AllStatuses,
+		},
+		Parser:       processor,
+		Transformers: transformers,
+		Filters:      filters,
+		Checks:       checks,
+		CheckWorkers: workers,
+		Dedup:        dedup(cfg),
+		Order:        order(cfg),
+		Sinks:        sinks,
+		Limit:        opts.Limit,
+		Fail:         fail(cfg),
+		OnSkip: func(report input.SkipReport) {
+			logger.Debug("Skipped ad",
+				logging.AdID, report.AdID, logging.DraftID, report.DraftID,
+				logging.Reason, report.Reason, "detail", report.Detail)
+			if tracker != nil {
+				tracker.Skip(report)
+			}
+			if recorder != nil {
+				recorder.Skip(report)
+			}
+			if emailSkipped || reportSkipped {
+				skipped = append(skipped, report)
+			}
+			if opts.OnSkip != nil {
+				opts.OnSkip(report)
+			}
+		},
+	}
+
+	if !opts.Force {
+		p.Guard = guard(cfg, history)
+	}
+
+	stats, err := p.Run(ctx)
+	record(stats, err)
+	if err == nil && tracker != nil {
+		err = finishDelta(ctx, cfg, opts, tracker, logger)
+	}
+	// The descriptions are kept even when the run failed or was cancelled,
+	// so the next run does not ask for them again
+	if enricher != nil && opts.Store != nil && !opts.ReadOnly {
+		if saveErr := enricher.Save(context.WithoutCancel(ctx), opts.Store); saveErr != nil {
+			if err != nil {
+				logger.Error("Error saving enrichment cache", "error", saveErr)
+			} else {
+				err = saveErr
+			}
+		}
+	}
+	if err == nil && opts.Store != nil && !opts.ReadOnly {
+		err = state.RecordSuccessfulRun(ctx, opts.Store, started)
+	}
+	if err == nil && countItems {
+		counts := state.ItemCounts{Total: stats.Written, Subcategories: stats.Subcategories}
+		err = state.RecordItemCounts(ctx, opts.Store, counts, cfg.Guard.Runs)
+	}
+	if err == nil && brands != nil && !opts.ReadOnly {
+		reportBrands(cfg, brands, logger)
+	}
+	summary := newSummary(started, stats, processor.AdTypes, err)
+	summary.Quality = scores.Scores
+	summarize(cfg, opts, summary, logger)
+	if opts.OnSummary != nil {
+		opts.OnSummary(summary)
+	}
+	if exporter != nil {
+		exportRun(ctx, exporter, summary, logger)
+	}
+	if !opts.ReadOnly {
+		notifyRun(ctx, cfg, summary, err, previousItems, logger)
+	}
+	if err == nil && emailSkipped {
+		emailSkippedAds(ctx, cfg, started, skipped, logger)
+	}
+	if err == nil && reportSkipped {
+		writeExclusions(cfg, started, skipped, logger)
+	}
+	if err == nil && recorder != nil {
+		recordHistory(ctx, cfg, recorder, started, logger)
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	for _, reason := range sortedReasons(stats.Skipped) {
+		logger.Info("Skipped ads", logging.Reason, reason, "count", stats.Skipped[reason])
+		details := stats.Details[reason]
+		for _, detail := range slices.Sorted(maps.Keys(details)) {
+			logger.Info("Skipped ads by filter", logging.Reason, reason, "filter", detail, "count", details[detail])
+		}
+	}
+	logger.Info("Run finished",
+		"read", stats.Read, "written", stats.Written, "duplicates", stats.Deduped,
+		"auctions", processor.AdTypes[input.AdTypeAuction], "duration", stats.Duration.Round(time.Millisecond).String())
+	return stats, nil
+}
+
+// loadBrands returns the normalizer of the canonical brands in cfg, or nil
+// when there are none
+func loadBrands(cfg *config.Config) (*brand.Normalizer, error) {
+	names := slices.Concat(cfg.Brands.Canonical, cfg.Brands.Allow, cfg.Brands.Block)
+	if cfg.Brands.File != "" {
+		listed, err := brand.ReadFile(cfg.Brands.File)
+		if err != nil {
+			return nil, fmt.Errorf("reading brands: %w", err)
+		}
+		names = append(names, listed...)
+	}
+	if len(names) == 0 && len(cfg.Brands.Aliases) == 0 {
+		return nil, nil
+	}
+	return brand.NewNormalizer(names, cfg.Brands.Aliases, cfg.Brands.MinSimilarity, cfg.Brands.MaxEdits), nil
+}
+
+// reportBrands logs how many brands no canonical name matched in the run
+// and writes them to cfg.Brands.UnmatchedPath, logging rather than
+// returning its errors so the report never fails a run
+func reportBrands(cfg *config.Config, brands *brand.Normalizer, logger *slog.Logger) {
+	unmatched := brands.Unmatched()
+	if len(unmatched) > 0 {
+		logger.Info("Brands without a canonical name", "brands", len(unmatched), "canonical", brands.Len())
+	}
+	if cfg.Brands.UnmatchedPath == "" {
+		return
+	}
+	file, err := createAtomic(cfg.Brands.UnmatchedPath)
+	if err == nil {
+		if err = brand.WriteUnmatched(file, unmatched); err != nil {
+			file.discard()
+		} else {
+			err = file.commit()
+		}
+	}
+	if err != nil {
+		logger.Error("Error writing unmatched brands", "path", cfg.Brands.UnmatchedPath, "error", err)
+	}
+}
+
+// newEnricher returns the batcher of the enrichment service in cfg, with
+// the descriptions cached in store when there is one
+func newEnricher(ctx context.Context, cfg *config.Config, store state.Store, logger *slog.Logger) (*enrich.Batcher, error) {
+	cache := enrich.Cache{}
+	if store != nil {
+		var err error
+		if cache, err = enrich.LoadCache(ctx, store); err != nil {
+			return nil, err
+		}
+	}
+	client, err := httpclient.New(cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	enricher := enrich.NewHTTP(client, cfg.Enrich.URL, cfg.Enrich.Headers, cfg.Languages.Default)
+	return enrich.NewBatcher(ctx, enricher, cfg.Enrich, cache, logger), nil
+}
+
+// summarize writes the summary of a run to the file in cfg.Summary and the
+// table to opts.Summary, logging rather than returning their errors so a
+// report never fails a run
+func summarize(cfg *config.Config, opts Options, summary Summary, logger *slog.Logger) {
+	if cfg.Summary.Path != "" {
+		if err := summary.WriteFile(cfg.Summary.Path); err != nil {
+			logger.Error("Error writing run summary", "path", cfg.Summary.Path, "error", err)
+		}
+	}
+	if opts.Summary != nil {
+		if err := summary.WriteTable(opts.Summary); err != nil {
+			logger.Error("Error writing run summary", "error", err)
+		}
+	}
+}
+
+// notifyRun reports the run to the webhook in cfg.Notify, if any, when it
+// failed or its item count dropped. A message that cannot be sent is
+// logged; it does not fail the run.
+func notifyRun(ctx context.Context, cfg *config.Config, summary Summary, err error, previousItems int, logger *slog.Logger) {
+	if cfg.Notify.WebhookURL == "" {
+		return
+	}
+	var details strings.Builder
+	summary.WriteTable(&details)
+	run := notify.Run{Err: err, Items: summary.Emitted, PreviousItems: previousItems, Details: details.String()}
+	// Failed runs are often cancelled ones, which should still be reported
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+	notifier, err := notify.New(cfg, logger)
+	if err == nil {
+		err = notifier.Notify(ctx, run)
+	}
+	if err != nil {
+		logger.Error("Error sending run notification", "error", err)
+	}
+}
+
+// emailSkippedAds emails the ads a run left out to the addresses in
+// cfg.Email. An email that cannot be sent is logged; it does not fail the
+// run.
+func emailSkippedAds(ctx context.Context, cfg *config.Config, started time.Time, skipped []input.SkipReport, logger *slog.Logger) {
+	mailer, err := notify.NewMailer(cfg, logger)
+	if err == nil {
+		err = mailer.SendSkipped(ctx, started, skipped)
+	}
+	if err != nil {
+		logger.Error("Error emailing skipped ads", "error", err)
+	}
+}
+
+// finishDelta writes the tombstones of a successful run and saves its items
+// for the next one to compare against
+func finishDelta(ctx context.Context, cfg *config.Config, opts Options, tracker *delta.Tracker, logger *slog.Logger) error {
+	tombstones := tracker.Tombstones(cfg.FullRefresh)
+	file, err := createAtomic(cfg.Delta.DeletionsPath)
+	if err != nil {
+		return err
+	}
+	if err = delta.WriteTombstones(file, tombstones); err == nil {
+		err = file.commit()
+	} else {
+		file.discard()
+	}
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", cfg.Delta.DeletionsPath, err)
+	}
+	logger.Info("Wrote deletions", "path", cfg.Delta.DeletionsPath, "items", len(tombstones))
+	if opts.OnDelete != nil {
+		if err := opts.OnDelete(ctx, tombstones); err != nil {
+			return err
+		}
+	}
+	return delta.Save(ctx, opts.Store, tracker.Snapshot(tombstones))
+}
+
+// record adds the outcome of a run to the Prometheus metrics
+func record(stats pipeline.Stats, err error) {
+	metrics.AdsFetched.Add(float64(stats.Read))
+	metrics.ItemsEmitted.Add(float64(stats.Written))
+	for reason, n := range stats.Skipped {
+		metrics.ItemsSkipped.WithLabelValues(string(reason)).Add(float64(n))
+	}
+	metrics.ObserveRun(stats.Duration, err)
+}
+
+// PushMetrics pushes the run metrics to the Pushgateway configured in cfg,
+// if any. One-shot runs call it before exiting; it still pushes when ctx was
+// cancelled so interrupted runs are reported too.
+func PushMetrics(ctx context.Context, cfg *config.Config, logger *slog.Logger) {
+	if cfg.Metrics.PushgatewayURL == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	client, err := httpclient.New(cfg.HTTP)
+	if err == nil {
+		err = metrics.Push(ctx, client, cfg.Metrics.PushgatewayURL, cfg.Metrics.Job)
+	}
+	if err != nil {
+		logging.OrDefault(logger).Error("Error pushing metrics", "error", err)
+	}
+}
+
+// transformers returns the per-item steps applied by every run. Names are
+// resolved first when enabled and the source supports it, so the taxonomy
+// and the cleanup steps see them.
+func transformers(ctx context.Context, cfg *config.Config, source input.StreamingSource, brands *brand.Normalizer, logger *slog.Logger) ([]pipeline.Transformer, error) {
+	categories, err := loadTaxonomy(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	synonyms, err := loadSynonyms(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sanitize, err := transform.Sanitize(cfg)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := rules.CompileLabels(cfg.CustomLabels, logger)
+	if err != nil {
+		return nil, err
+	}
+	title, err := transform.Title(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var steps []pipeline.Transformer
+	if lookup, ok := source.(input.NameLookup); ok && cfg.Source.Names.Enabled {
+		steps = append(steps, input.NewNames(ctx, lookup, cfg.Source.Names, logger).Apply)
+	}
+	if cfg.Source.Inventory.Enabled {
+		lookup, ok := source.(input.InventoryLookup)
+		if !ok {
+			return nil, errors.New("store inventory can only be read from the hasura source")
+		}
+		inventory, err := input.LoadInventory(ctx, lookup, cfg.Source.Inventory.Table)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, inventory.Apply)
+	}
+	if promotions.Enabled(cfg) {
+		list, err := promotions.Load(ctx, cfg, source, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, promotions.NewLinker(list).Apply)
+	}
+	steps = append(steps, sanitize)
+	if brands != nil {
+		steps = append(steps, brands.Apply)
+	}
+	steps = append(steps,
+		transform.Prepare(logger),
+		categories.Apply,
+		synonyms.Apply,
+	)
+	// Titles are built from the normalized brand and attributes
+	if title != nil {
+		steps = append(steps, title)
+	}
+	// Texts are translated once cleaned up, and cut to length with them
+	if langs := cfg.ContentLanguages(); len(langs) > 0 && cfg.Languages.TranslateURL != "" {
+		client, err := httpclient.New(cfg.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		translator := translate.NewHTTP(client, cfg.Languages.TranslateURL, cfg.Languages.TranslateHeaders)
+		steps = append(steps, translate.NewFiller(ctx, translator, cfg.Languages.Default, langs, logger).Apply)
+	}
+	return append(steps,
+		transform.Truncate(cfg, logger),
+		labels.Apply,
+	), nil
+}
+
+// loadSynonyms returns the built-in attribute dictionary extended with the
+// synonyms file. Only a missing default file is tolerated.
+func loadSynonyms(cfg *config.Config) (vocab.Dictionary, error) {
+	synonyms, err := vocab.Load(cfg.SynonymsFile)
+	if errors.Is(err, fs.ErrNotExist) && cfg.SynonymsFile == config.DefaultSynonymsFile {
+		return vocab.Default(), nil
+	}
+	return synonyms, err
+}
+
+// loadTaxonomy reads the subcategory mapping. Only a missing default file
+// is tolerated, leaving items uncategorized.
+func loadTaxonomy(cfg *config.Config, logger *slog.Logger) (*taxonomy.Map, error) {
+	categories, err := taxonomy.Load(cfg.TaxonomyFile)
+	if errors.Is(err, fs.ErrNotExist) && cfg.TaxonomyFile == config.DefaultTaxonomyFile {
+		logger.Warn("No taxonomy file; items will not be categorized", "path", cfg.TaxonomyFile)
+		return &taxonomy.Map{}, nil
+	}
+	return categories, err
+}
+
+// gtinRule is the inclusion rule behind InvalidGTIN=reject. Prepare leaves
+// the GTIN empty when the code number is invalid.
+var gtinRule = config.Rule{Name: "valid_gtin", When: `gtin != "" || code_number == ""`}
+
+// filters returns the pipeline filters enabled by cfg: the built-in rules
+// of its policies, then its inclusion rules. A rule that fails to evaluate
+// drops the item and is logged to logger.
+func filters(cfg *config.Config, logger *slog.Logger) ([]pipeline.Filter, error) {
+	var builtin []config.Rule
+	if cfg.InvalidGTIN == config.InvalidGTINReject {
+		builtin = append(builtin, gtinRule)
+	}
+	compiled, err := rules.Compile(append(builtin, cfg.Rules...))
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := priceFilters(cfg)
+	if err != nil {
+		return nil, err
+	}
+	fs = append(fs, brandFilters(cfg)...)
+	for i, rule := range compiled {
+		filter := pipeline.Filter{Reason: input.RuleFailed, Detail: rule.Name}
+		if i < len(builtin) {
+			// Built-in rules report their policy's own reason
+			filter = pipeline.Filter{Reason: input.InvalidGTIN}
+		}
+		filter.Keep = func(item input.AdItem) bool {
+			ok, err := rule.Eval(item)
+			if err != nil {
+				logger.Warn("Rule failed to evaluate",
+					logging.AdID, item.AdID, logging.DraftID, item.DraftID, "error", err)
+			}
+			return ok
+		}
+		fs = append(fs, filter)
+	}
+
+	if cfg.Quality.MinScore > 0 {
+		synonyms, err := loadSynonyms(cfg)
+		if err != nil {
+			return nil, err
+		}
+		scorer := quality.NewScorer(synonyms)
+		fs = append(fs, pipeline.Filter{
+			Reason: input.LowQuality,
+			Keep: func(item input.AdItem) bool {
+				return scorer.Score(item) >= cfg.Quality.MinScore
+			},
+		})
+	}
+	return fs, nil
+}
+
+// priceFilters returns a filter for each subcategory with its own price
+// range and one applying the default range to all other items
+func priceFilters(cfg *config.Config) ([]pipeline.Filter, error) {
+	inRange := func(r config.PriceRange) (func(money.Money) bool, error) {
+		min, max, err := r.Bounds(cfg.Currency)
+		if err != nil {
+			return nil, err
+		}
+		return func(price money.Money) bool {
+			return price.Minor >= min.Minor && (max.IsZero() || price.Minor <= max.Minor)
+		}, nil
+	}
+	inSubcategory := func(item input.AdItem, subcategory string) bool {
+		return item.Subcategory == subcategory || strings.EqualFold(item.SubcategoryName, subcategory)
+	}
+
+	var fs []pipeline.Filter
+	subcategories := slices.Sorted(maps.Keys(cfg.PriceBounds.Subcategories))
+	for _, subcategory := range subcategories {
+		ok, err := inRange(cfg.PriceBounds.Subcategories[subcategory])
+		if err != nil {
+			return nil, fmt.Errorf("price bounds for %s: %w", subcategory, err)
+		}
+		fs = append(fs, pipeline.Filter{
+			Reason: input.PriceOutOfRange,
+			Detail: subcategory,
+			Keep: func(item input.AdItem) bool {
+				return !inSubcategory(item, subcategory) || ok(item.Price)
+			},
+		})
+	}
+
+	if cfg.PriceBounds.Default == (config.PriceRange{}) {
+		return fs, nil
+	}
+	ok, err := inRange(cfg.PriceBounds.Default)
+	if err != nil {
+		return nil, fmt.Errorf("default price bounds: %w", err)
+	}
+	fs = append(fs, pipeline.Filter{
+		Reason: input.PriceOutOfRange,
+		Keep: func(item input.AdItem) bool {
+			for _, subcategory := range subcategories {
+				if inSubcategory(item, subcategory) {
+					return true
+				}
+			}
+			return ok(item.Price)
+		},
+	})
+	return fs, nil
+}
+
+// brandFilters returns a filter for each blocked brand, so the items each
+// one removes are counted, and one for the allowlist if there is one
+func brandFilters(cfg *config.Config) []pipeline.Filter {
+	var fs []pipeline.Filter
+	for key, name := range brand.NewSet(cfg.Brands.Block) {
+		fs = append(fs, pipeline.Filter{
+			Reason: input.BrandBlocked,
+			Detail: name,
+			Keep:   func(item input.AdItem) bool { return brand.Key(item.Brand) != key },
+		})
+	}
+	// Map order would make the first matching filter vary between runs
+	sort.Slice(fs, func(i, j int) bool { return fs[i].Detail < fs[j].Detail })
+
+	if allowed := brand.NewSet(cfg.Brands.Allow); len(allowed) > 0 {
+		fs = append(fs, pipeline.Filter{
+			Reason: input.BrandNotAllowed,
+			Keep: func(item input.AdItem) bool {
+				_, ok := allowed.Match(item.Brand)
+				return ok
+			},
+		})
+	}
+	return fs
+}
+
+// fail returns the skip reasons that abort a run: ads whose attributes
+// drifted from the expected form, in strict mode
+func fail(cfg *config.Config) []input.SkipReason {
+	if cfg.Source.StrictAttributes {
+		return []input.SkipReason{input.SchemaChanged}
+	}
+	return nil
+}
+
+// dedup returns the duplicate GTIN stage when cfg keeps only the newest of
+// the items sharing a GTIN. Items in different categories or feeds go to
+// different files and are not duplicates of each other.
+func dedup(cfg *config.Config) *pipeline.Dedup {
+	if cfg.DuplicateGTIN != config.DuplicateGTINKeepNewest {
+		return nil
+	}
+	return &pipeline.Dedup{
+		Reason: input.DuplicateGTIN,
+		Key: func(item input.AdItem) string {
+			if item.GTIN == "" {
+				return ""
+			}
+			// The same code may be written as a GTIN-13 and a GTIN-14
+			return item.Category + "/" + item.Feed + "/" + strings.Repeat("0", max(0, 14-len(item.GTIN))) + item.GTIN
+		},
+		Prefer: func(a, b input.AdItem) bool { return a.UpdatedAt.After(b.UpdatedAt) },
+	}
+}
+
+// order returns the sort order of the items selected by cfg, or nil to
+// write them as the source returns them
+func order(cfg *config.Config) func(a, b input.AdItem) int {
+	switch cfg.Order {
+	case config.OrderID:
+		return func(a, b input.AdItem) int { return strings.Compare(a.ID, b.ID) }
+	case config.OrderUpdatedAt:
+		return func(a, b input.AdItem) int {
+			if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+				return c
+			}
+			return strings.Compare(a.ID, b.ID)
+		}
+	}
+	return nil
+}
+
+// checks returns the pipeline checks enabled by cfg
+func checks(cfg *config.Config, logger *slog.Logger) ([]pipeline.Check, error) {
+	if !cfg.ImageCheck.Enabled {
+		return nil, nil
+	}
+	images, err := NewImageChecker(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return []pipeline.Check{{
+		Reason: input.BrokenImage,
+		Keep:   keepImages(cfg, images, logger),
+	}}, nil
+}
+
+// NewImageChecker returns a checker with the settings in cfg.ImageCheck,
+// connecting with the ones in cfg.HTTP
+func NewImageChecker(cfg *config.Config) (*imagecheck.Checker, error) {
+	client, err := httpclient.New(cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = cfg.ImageCheck.Timeout.Duration
+	return imagecheck.New(client, imagecheck.Options{
+		PerSecond: cfg.ImageCheck.RatePerSecond,
+		MinBytes:  cfg.ImageCheck.MinBytes,
+		Decode:    cfg.ImageCheck.Mode == config.ImageCheckDecode,
+	}), nil
+}
+
+// keepImages returns a check that removes broken additional images from an
+// item and drops or flags the item when its main image is broken
+func keepImages(cfg *config.Config, images *imagecheck.Checker, logger *slog.Logger) func(ctx context.Context, item *input.AdItem) bool {
+	return func(ctx context.Context, item *input.AdItem) bool {
+		minSide := MinImageSide(cfg, *item)
+		problem := func(ctx context.Context, link string) string {
+			r, err := images.Check(ctx, link)
+			if err != nil {
+				return err.Error()
+			}
+			return images.Problem(r, minSide)
+		}
+
+		// Variants share the parent's slice, so the kept links get their own
+		var kept []string
+		for _, link := range item.AdditionalImageLinks {
+			if reason := problem(ctx, link); reason != "" {
+				logger.Debug("Removed broken additional image",
+					logging.AdID, item.AdID, logging.DraftID, item.DraftID, "url", link, logging.Reason, reason)
+				continue
+			}
+			kept = append(kept, link)
+		}
+		item.AdditionalImageLinks = kept
+
+		if item.ImageLink == "" {
+			return true // reported by validation as a missing field
+		}
+		reason := problem(ctx, item.ImageLink)
+		if reason == "" {
+			return true
+		}
+		logger.Warn("Broken image",
+			logging.AdID, item.AdID, logging.DraftID, item.DraftID, "url", item.ImageLink, logging.Reason, reason)
+		return cfg.ImageCheck.Action == config.ImageCheckFlag
+	}
+}
+
+// MinImageSide returns the smallest width and height cfg.ImageCheck
+// accepts for the images of item, which is larger for apparel
+func MinImageSide(cfg *config.Config, item input.AdItem) int {
+	if isApparel(item.GoogleProductCategory, cfg.ImageCheck.ApparelCategories) {
+		return cfg.ImageCheck.ApparelMinSize
+	}
+	return cfg.ImageCheck.MinSize
+}
+
+// isApparel reports whether category is one of apparel, or a taxonomy path
+// below one of them
+func isApparel(category string, apparel []string) bool {
+	for _, a := range apparel {
+		if category == a || strings.HasPrefix(category, a+" > ") {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedReasons returns the skip reasons in counts in a stable order
+func sortedReasons(counts map[input.SkipReason]int) []input.SkipReason {
+	reasons := make([]input.SkipReason, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool { return reasons[i] < reasons[j] })
+	return reasons
+}
+
+// abortAll aborts sinks that were opened for a run that never started
+func abortAll(sinks []pipeline.Sink) {
+	for _, sink := range sinks {
+		pipeline.Abort(sink)
+	}
+}
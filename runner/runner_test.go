@@ -0,0 +1,178 @@
+package runner_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/runner"
+	"go_data_fashion_accessories/state"
+	"go_data_fashion_accessories/testutil"
+)
+
+// subcategory is the allowed subcategory of the fixtures
+const subcategory = "212818c2-5ae3-4a95-88c9-370b3b906df0"
+
+// memorySink keeps the items written to it
+type memorySink struct {
+	mu     sync.Mutex
+	items  []input.AdItem
+	closed bool
+}
+
+func (s *memorySink) Write(item input.AdItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append(s.items, item)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// ids returns the ad IDs of the items written, sorted
+func (s *memorySink) ids() []string {
+	out := make([]string, len(s.items))
+	for i, item := range s.items {
+		out[i] = item.AdID
+	}
+	slices.Sort(out)
+	return out
+}
+
+// newConfig returns a config reading the ads of fake two at a time
+func newConfig(t *testing.T, fake *testutil.FakeHasura) *config.Config {
+	t.Helper()
+	cfg, err := config.Read(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Configure(cfg)
+	cfg.CategoryID = "e87e7959-03ef-4bd1-930d-4a96c5743108"
+	cfg.AllowedSubcategories = []string{subcategory}
+	cfg.PageSize = 2
+	cfg.Retry.BaseDelay.Duration, cfg.Retry.MaxDelay.Duration = time.Millisecond, time.Millisecond
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestRunIntoMemorySink(t *testing.T) {
+	cashOnly := testutil.Ad("ad-03", subcategory)
+	cashOnly.Attributes = []byte(`{"stepsData": [
+		{"name": "search_product", "data": {"id": {"id": "` + subcategory + `"}, "inputSearchValue": {"value": "Bag"}}},
+		{"name": "product_detail", "data": {"values": {"brand": "Gucci", "price": "900"}}},
+		{"name": "delivery_and_payment_methods", "data": {"paymentMethods": {"data": [{"value": "Cash on Delivery"}]}}}
+	]}`)
+	elsewhere := testutil.Ad("ad-04", "00000000-0000-4000-8000-000000000000")
+	fake := testutil.NewFakeHasura(t,
+		testutil.Ad("ad-01", subcategory),
+		testutil.Ad("ad-02", subcategory),
+		cashOnly,
+		elsewhere,
+		testutil.Ad("ad-05", subcategory),
+	)
+	cfg := newConfig(t, fake)
+
+	sink := &memorySink{}
+	var skipped []input.SkipReport
+	store := state.NewMemoryStore()
+	stats, err := runner.Run(context.Background(), cfg, runner.Options{
+		Sinks:  []pipeline.Sink{sink},
+		Store:  store,
+		OnSkip: func(r input.SkipReport) { skipped = append(skipped, r) },
+		Logger: logging.Discard(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"ad-01", "ad-02", "ad-05"}; !slices.Equal(sink.ids(), want) {
+		t.Errorf("wrote %v, want %v", sink.ids(), want)
+	}
+	if !sink.closed {
+		t.Error("sink was not closed")
+	}
+	if stats.Read != 5 || stats.Written != 3 {
+		t.Errorf("read %d and wrote %d ads, want 5 and 3", stats.Read, stats.Written)
+	}
+	reasons := map[string]input.SkipReason{}
+	for _, r := range skipped {
+		reasons[r.AdID] = r.Reason
+	}
+	if reasons["ad-03"] != input.NoOnlinePayment || reasons["ad-04"] != input.DisallowedSubcategory || len(reasons) != 2 {
+		t.Errorf("skipped %v", reasons)
+	}
+
+	item := sink.items[0]
+	if item.Brand != "Gucci" || item.Price.Decimal() != "1200.00" || item.Price.Currency != config.DefaultCurrency || item.ImageLink == "" {
+		t.Errorf("unexpected item %+v", item)
+	}
+	// Three pages of two ads
+	if n := len(fake.Requests()); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+
+	// The next run starts from this one
+	last, err := state.LastSuccessfulRun(context.Background(), store)
+	if err != nil || last.IsZero() {
+		t.Fatalf("no successful run recorded: %v", err)
+	}
+}
+
+func TestRunReportsOnlyWhenAsked(t *testing.T) {
+	for _, report := range []bool{false, true} {
+		fake := testutil.NewFakeHasura(t, testutil.Ad("ad-01", "00000000-0000-4000-8000-000000000000"))
+		cfg := newConfig(t, fake)
+		cfg.Exclusions.Dir = t.TempDir()
+
+		if _, err := runner.Run(context.Background(), cfg, runner.Options{
+			Sinks:  []pipeline.Sink{&memorySink{}},
+			Store:  state.NewMemoryStore(),
+			Report: report,
+			Logger: logging.Discard(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		reports, err := os.ReadDir(cfg.Exclusions.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written := len(reports) > 0; written != report {
+			t.Errorf("Report %v wrote %d exclusions reports", report, len(reports))
+		}
+	}
+}
+
+func TestRunFailsOnSourceError(t *testing.T) {
+	fake := testutil.NewFakeHasura(t, testutil.Ad("ad-01", subcategory))
+	fake.Status = 503
+	cfg := newConfig(t, fake)
+
+	sink := &memorySink{}
+	store := state.NewMemoryStore()
+	if _, err := runner.Run(context.Background(), cfg, runner.Options{
+		Sinks:  []pipeline.Sink{sink},
+		Store:  store,
+		Logger: logging.Discard(),
+	}); err == nil {
+		t.Fatal("run succeeded")
+	}
+	if last, _ := state.LastSuccessfulRun(context.Background(), store); !last.IsZero() {
+		t.Error("failed run was recorded as successful")
+	}
+	if n := len(fake.Requests()); n != cfg.Retry.MaxAttempts {
+		t.Errorf("%d requests, want %d", n, cfg.Retry.MaxAttempts)
+	}
+}
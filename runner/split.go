@@ -0,0 +1,218 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/model/input"
+)
+
+// Manifest lists the parts of a feed split by its channel's MaxItems or
+// MaxBytes. It is written next to the parts, named by ManifestPath.
+type Manifest struct {
+	Format      string    `json:"format"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Items       int       `json:"items"`
+	Parts       []Part    `json:"parts"`
+}
+
+// Part is one file of a split feed
+type Part struct {
+	File  string `json:"file"` // name relative to the manifest
+	Items int    `json:"items"`
+	Bytes int64  `json:"bytes"` // before compression
+}
+
+// footerReserve is the room left at the end of a part for the format's
+// closing tags
+const footerReserve = 64
+
+// ManifestPath returns the path of the manifest of the split feed at path,
+// e.g. productsfashionaccessories.xml.manifest.json
+func ManifestPath(path string) string {
+	return strings.TrimSuffix(path, ".gz") + ".manifest.json"
+}
+
+// PartPath returns the path of the nth part of the feed at path, counting
+// from 1, e.g. productsfashionaccessories_2.xml
+func PartPath(path string, n int) string {
+	return suffixPath(path, strconv.Itoa(n))
+}
+
+// ReadManifest reads the manifest of the split feed at path
+func ReadManifest(path string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(ManifestPath(path))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// splitSink writes a feed in numbered parts. A part ends once it holds
+// MaxItems items, or before an item that could take it past MaxBytes,
+// judged by the largest item so far. Parts replace the previous ones only
+// when the sink closes, together with the manifest.
+type splitSink struct {
+	format  string
+	path    string
+	channel config.Channel
+
+	current  *fileSink
+	items    int   // in the current part
+	largest  int64 // largest item so far, in bytes
+	finished []*fileSink
+	manifest Manifest
+}
+
+func newSplitSink(format, path string, channel config.Channel) *splitSink {
+	return &splitSink{
+		format:   format,
+		path:     path,
+		channel:  channel,
+		manifest: Manifest{Format: format, GeneratedAt: time.Now().UTC()},
+	}
+}
+
+func (s *splitSink) Write(item input.AdItem) error {
+	if s.current == nil || s.full() {
+		if err := s.next(); err != nil {
+			return err
+		}
+	}
+
+	before := s.current.written
+	if err := s.current.Write(item); err != nil {
+		return err
+	}
+	if err := s.current.Flush(); err != nil {
+		return err
+	}
+	s.items++
+	s.largest = max(s.largest, s.current.written-before)
+	return nil
+}
+
+// full reports whether the current part must end before the next item
+func (s *splitSink) full() bool {
+	if s.items == 0 {
+		return false
+	}
+	if s.channel.MaxItems > 0 && s.items >= s.channel.MaxItems {
+		return true
+	}
+	return s.channel.MaxBytes > 0 && s.current.written+s.largest+footerReserve > s.channel.MaxBytes
+}
+
+// next finishes the current part, if any, and starts the following one
+func (s *splitSink) next() error {
+	if err := s.finishCurrent(); err != nil {
+		return err
+	}
+	path := PartPath(s.path, len(s.finished)+1)
+	sink, err := createFileSink(s.format, path)
+	if err != nil {
+		return err
+	}
+	s.current, s.items = sink, 0
+	return nil
+}
+
+// finishCurrent ends the current part and records it in the manifest
+func (s *splitSink) finishCurrent() error {
+	if s.current == nil {
+		return nil
+	}
+	sink := s.current
+	s.current = nil
+	if err := sink.finish(); err != nil {
+		return err
+	}
+	s.finished = append(s.finished, sink)
+	s.manifest.Items += s.items
+	s.manifest.Parts = append(s.manifest.Parts, Part{
+		File:  filepath.Base(sink.file.path),
+		Items: s.items,
+		Bytes: sink.written,
+	})
+	return nil
+}
+
+// Close moves the parts into place, writes the manifest and removes parts
+// left over from a previous, larger feed
+func (s *splitSink) Close() error {
+	// An empty feed still gets one (empty) part
+	if s.current == nil && len(s.finished) == 0 {
+		if err := s.next(); err != nil {
+			return err
+		}
+	}
+	if err := s.finishCurrent(); err != nil {
+		s.discard()
+		return err
+	}
+
+	previous, _ := ReadManifest(s.path)
+	var err error
+	for _, part := range s.finished {
+		if err == nil {
+			err = part.file.commit()
+		} else {
+			part.file.discard()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.writeManifest(); err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	for _, part := range previous.Parts[min(len(s.manifest.Parts), len(previous.Parts)):] {
+		if err := os.Remove(filepath.Join(dir, part.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *splitSink) writeManifest() error {
+	file, err := createAtomic(ManifestPath(s.path))
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s.manifest); err != nil {
+		file.discard()
+		return err
+	}
+	return file.commit()
+}
+
+// Abort drops every part, leaving the previous feed in place
+func (s *splitSink) Abort() error {
+	var err error
+	if s.current != nil {
+		err = s.current.Abort()
+		s.current = nil
+	}
+	s.discard()
+	return err
+}
+
+// discard removes the finished parts
+func (s *splitSink) discard() {
+	for _, part := range s.finished {
+		part.file.discard()
+	}
+	s.finished = nil
+}
@@ -0,0 +1,200 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"go_data_fashion_accessories/bigquery"
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+)
+
+// Summary reports what a run did, for the JSON file in cfg.Summary and the
+// table in Options.Summary
+type Summary struct {
+	Started       time.Time                           `json:"started"`
+	Duration      config.Duration                     `json:"duration"`
+	Error         string                              `json:"error,omitempty"` // why the run failed
+	Fetched       int                                 `json:"fetched"`         // raw ads read from the source
+	Parsed        int                                 `json:"parsed"`          // ads the parser kept
+	Emitted       int                                 `json:"emitted"`         // items written to the feeds
+	Filtered      int                                 `json:"filtered"`        // items dropped by a filter or check
+	Deduped       int                                 `json:"deduped"`         // items dropped as duplicates
+	AdTypes       map[string]int                      `json:"ad_types"`
+	Skipped       map[input.SkipReason]int            `json:"skipped"`
+	Filters       map[input.SkipReason]map[string]int `json:"skipped_by_filter,omitempty"`
+	Subcategories map[string]int                      `json:"subcategories"` // items emitted
+	Quality       map[string]int                      `json:"quality"`       // items emitted by quality score
+	Stages        StageSummary                        `json:"stages"`
+}
+
+// StageSummary is the time each pipeline stage spent working, as in
+// pipeline.StageDurations
+type StageSummary struct {
+	Fetch   config.Duration `json:"fetch"`
+	Process config.Duration `json:"process"`
+	Check   config.Duration `json:"check"`
+	Collect config.Duration `json:"collect"`
+	Write   config.Duration `json:"write"`
+}
+
+// newSummary summarizes a run that started at started and ended with err
+func newSummary(started time.Time, stats pipeline.Stats, adTypes map[string]int, err error) Summary {
+	// Round to milliseconds, or microseconds for what took less
+	ms := func(d time.Duration) config.Duration {
+		if d < time.Millisecond {
+			return config.Duration{Duration: d.Round(time.Microsecond)}
+		}
+		return config.Duration{Duration: d.Round(time.Millisecond)}
+	}
+	s := Summary{
+		Started:       started.UTC(),
+		Duration:      ms(stats.Duration),
+		Fetched:       stats.Read,
+		Parsed:        stats.Parsed,
+		Emitted:       stats.Written,
+		Filtered:      stats.Filtered,
+		Deduped:       stats.Deduped,
+		AdTypes:       adTypes,
+		Skipped:       stats.Skipped,
+		Filters:       stats.Details,
+		Subcategories: stats.Subcategories,
+		Stages: StageSummary{
+			Fetch:   ms(stats.Stages.Fetch),
+			Process: ms(stats.Stages.Process),
+			Check:   ms(stats.Stages.Check),
+			Collect: ms(stats.Stages.Collect),
+			Write:   ms(stats.Stages.Write),
+		},
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// WriteFile replaces the file at path with the summary as indented JSON
+func (s Summary) WriteFile(path string) error {
+	file, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		file.discard()
+		return err
+	}
+	return file.commit()
+}
+
+// WriteTable writes the summary to w as aligned tables for people to read
+func (s Summary) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Run started %s, took %s\n", s.Started.Format(time.RFC3339), s.Duration)
+	if s.Error != "" {
+		fmt.Fprintf(tw, "Failed: %s\n", s.Error)
+	}
+	fmt.Fprintln(tw)
+	for _, row := range []struct {
+		name  string
+		count int
+	}{
+		{"fetched", s.Fetched},
+		{"parsed", s.Parsed},
+		{"emitted", s.Emitted},
+		{"filtered", s.Filtered},
+		{"deduped", s.Deduped},
+	} {
+		fmt.Fprintf(tw, "%s\t%d\n", row.name, row.count)
+	}
+
+	table(tw, "AD TYPE\tADS", s.AdTypes)
+	skipped := make(map[string]int, len(s.Skipped))
+	for reason, n := range s.Skipped {
+		skipped[string(reason)] = n
+	}
+	table(tw, "SKIP REASON\tADS", skipped)
+	for _, reason := range sortedReasons(s.Skipped) {
+		if details := s.Filters[reason]; len(details) > 0 {
+			table(tw, "FILTER ("+string(reason)+")\tITEMS", details)
+		}
+	}
+	table(tw, "SUBCATEGORY\tITEMS", s.Subcategories)
+	table(tw, "QUALITY SCORE\tITEMS", s.Quality)
+
+	fmt.Fprintln(tw, "\nSTAGE\tTIME")
+	for _, row := range []struct {
+		name string
+		d    config.Duration
+	}{
+		{"fetch", s.Stages.Fetch},
+		{"process", s.Stages.Process},
+		{"check", s.Stages.Check},
+		{"collect", s.Stages.Collect},
+		{"write", s.Stages.Write},
+	} {
+		fmt.Fprintf(tw, "%s\t%s\n", row.name, row.d)
+	}
+	return tw.Flush()
+}
+
+// table writes counts under header sorted by key, or nothing when there
+// are none
+func table(w io.Writer, header string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", header)
+	for _, key := range slices.Sorted(maps.Keys(counts)) {
+		name := key
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Fprintf(w, "%s\t%d\n", name, counts[key])
+	}
+}
+
+// exportRun exports the summary and, when the run succeeded, its items to
+// BigQuery. A run that cannot be exported is logged; it does not fail the
+// run.
+func exportRun(ctx context.Context, exporter *bigquery.Exporter, s Summary, logger *slog.Logger) {
+	counts := func(m map[string]int) []bigquery.Count {
+		list := make([]bigquery.Count, 0, len(m))
+		for _, name := range slices.Sorted(maps.Keys(m)) {
+			list = append(list, bigquery.Count{Name: name, Count: m[name]})
+		}
+		return list
+	}
+	skipped := make(map[string]int, len(s.Skipped))
+	for reason, n := range s.Skipped {
+		skipped[string(reason)] = n
+	}
+	run := bigquery.Run{
+		Started:       s.Started,
+		Duration:      s.Duration.Seconds(),
+		Error:         s.Error,
+		Fetched:       s.Fetched,
+		Parsed:        s.Parsed,
+		Emitted:       s.Emitted,
+		Filtered:      s.Filtered,
+		Deduped:       s.Deduped,
+		Skipped:       counts(skipped),
+		Subcategories: counts(s.Subcategories),
+		Quality:       counts(s.Quality),
+	}
+	// Failed runs are often cancelled ones, which should still be exported
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Minute)
+	defer cancel()
+	if err := exporter.Export(ctx, run); err != nil {
+		logger.Error("Error exporting the run to BigQuery", "error", err)
+	}
+}
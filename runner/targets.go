@@ -0,0 +1,257 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/linkurl"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+)
+
+// Target is a feed target from cfg.Targets, opened by CreateTargets
+type Target struct {
+	config.Target
+	Path string // local file the feed is written to
+	sink *targetSink
+}
+
+// Err returns the error that stopped the target, or nil while it is
+// healthy
+func (t *Target) Err() error {
+	return t.sink.err
+}
+
+// TargetSinks returns the sinks of targets, for Options.Sinks
+func TargetSinks(targets []*Target) []pipeline.Sink {
+	sinks := make([]pipeline.Sink, len(targets))
+	for i, t := range targets {
+		sinks[i] = t.sink
+	}
+	return sinks
+}
+
+// TargetPath returns the default file of a target: the format's default
+// path with "_" and the target's name before its extension
+func TargetPath(cfg *config.Config, t config.Target) (string, error) {
+	if t.Path != "" {
+		return t.Path, nil
+	}
+	f, err := LookupFormat(t.Format)
+	if err != nil {
+		return "", err
+	}
+	path := suffixPath(f.DefaultPath, t.Name)
+	if cfg.Gzip {
+		path += ".gz"
+	}
+	return path, nil
+}
+
+// CreateTargets opens a sink for every target in cfg. Each target writes
+// the main feed of its format, every category in one file, applying its
+// channel's options with the target's overrides. Targets write on their
+// own goroutines; a target whose file cannot be opened or written is
+// logged and stops, while the run and the other targets go on. Unknown
+// formats and currencies without a rate fail before any file is created.
+func CreateTargets(ctx context.Context, cfg *config.Config, logger *slog.Logger) ([]*Target, error) {
+	logger = logging.OrDefault(logger)
+	paths := make([]string, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		path, err := TargetPath(cfg, t)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %w", t.Name, err)
+		}
+		paths[i] = path
+	}
+	currencies, err := resolveCurrencies(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	rates := make(map[string]currencyFeed, len(currencies))
+	for _, c := range currencies {
+		rates[c.code] = c
+	}
+
+	targets := make([]*Target, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		logger := logger.With("target", t.Name)
+		sink, err := createTargetFile(cfg, t, paths[i])
+		if err == nil {
+			sink = openTarget(cfg, t, sink, rates)
+		}
+		if err != nil {
+			logger.Error("Error opening feed target", "path", paths[i], "error", err)
+		}
+		targets[i] = &Target{Target: t, Path: paths[i], sink: newTargetSink(sink, err, logger)}
+	}
+	return targets, nil
+}
+
+// createTargetFile opens the file sink of a target writing to path
+func createTargetFile(cfg *config.Config, t config.Target, path string) (pipeline.Sink, error) {
+	if channel := cfg.Channels[t.Format]; channel.Split() {
+		return newSplitSink(t.Format, path, channel), nil
+	}
+	return CreateSink(t.Format, path)
+}
+
+// openTarget wraps sink, which receives the feed of a target, in the
+// target's currency conversion, channel options, link settings and
+// language
+func openTarget(cfg *config.Config, t config.Target, sink pipeline.Sink, rates map[string]currencyFeed) pipeline.Sink {
+	if feed, ok := rates[t.Currency]; ok {
+		sink = &convertSink{Sink: sink, feed: feed}
+	}
+
+	targetCfg := *cfg
+	targetCfg.Links = t.LinkSettings(cfg.Links)
+	targetCfg.Channels = maps.Clone(cfg.Channels)
+	if targetCfg.Channels == nil {
+		targetCfg.Channels = map[string]config.Channel{}
+	}
+	channel := targetCfg.Channels[t.Format]
+	channel.UTM = maps.Clone(channel.UTM)
+	if channel.UTM == nil {
+		channel.UTM = map[string]string{}
+	}
+	maps.Copy(channel.UTM, t.UTM)
+	targetCfg.Channels[t.Format] = channel
+
+	sink = ChannelSink(&targetCfg, t.Format, "", sink)
+	switch {
+	case t.Language != "" && t.Language != cfg.Languages.Default:
+		sink = newLanguageSink(sink, t.Language, targetCfg.Links)
+	case t.Links != (config.Links{}):
+		sink = &linkSink{Sink: sink, links: linkurl.New(targetCfg.Links)}
+	}
+	return sink
+}
+
+// linkSink rebuilds each item's link with the link settings of its target
+type linkSink struct {
+	pipeline.Sink
+	links *linkurl.Builder
+}
+
+func (s *linkSink) Write(item input.AdItem) error {
+	item.Link = s.links.Build(item.AdID, item.DraftID, item.Title)
+	return s.Sink.Write(item)
+}
+
+func (s *linkSink) Abort() error {
+	return pipeline.Abort(s.Sink)
+}
+
+// targetSink hands the items to the sink of one target on a goroutine of
+// its own, so a slow target does not hold up the others. The first error
+// aborts the target's sink and is kept in err; the targetSink itself never
+// fails, so the run goes on for the other targets.
+type targetSink struct {
+	sink   pipeline.Sink // nil when the target failed to open
+	items  chan input.AdItem
+	done   chan struct{}
+	err    error // read only after done is closed
+	logger *slog.Logger
+}
+
+// newTargetSink starts writing to sink, or only discarding the items when
+// err says it could not be opened
+func newTargetSink(sink pipeline.Sink, err error, logger *slog.Logger) *targetSink {
+	s := &targetSink{
+		sink:   sink,
+		items:  make(chan input.AdItem, pipeline.DefaultBufferSize),
+		done:   make(chan struct{}),
+		err:    err,
+		logger: logger,
+	}
+	go s.run()
+	return s
+}
+
+func (s *targetSink) run() {
+	defer close(s.done)
+	for item := range s.items {
+		if s.err != nil {
+			continue
+		}
+		if err := s.sink.Write(item); err != nil {
+			s.fail(err)
+		}
+	}
+}
+
+// fail records err and throws away what the target wrote
+func (s *targetSink) fail(err error) {
+	s.err = err
+	s.logger.Error("Error writing feed target", "error", err)
+	pipeline.Abort(s.sink)
+}
+
+func (s *targetSink) Write(item input.AdItem) error {
+	s.items <- item
+	return nil
+}
+
+func (s *targetSink) Close() error {
+	close(s.items)
+	<-s.done
+	if s.err == nil {
+		if err := s.sink.Close(); err != nil {
+			s.err = err
+			s.logger.Error("Error writing feed target", "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *targetSink) Abort() error {
+	close(s.items)
+	<-s.done
+	if s.err == nil {
+		return pipeline.Abort(s.sink)
+	}
+	return nil
+}
+
+// DeliverTargets uploads the files of every target that was written to its
+// destinations, all targets at once. A target that failed to write or
+// upload does not stop the others; the errors of every failed target are
+// returned together.
+func DeliverTargets(ctx context.Context, cfg *config.Config, targets []*Target, logger *slog.Logger) error {
+	logger = logging.OrDefault(logger)
+	RegisterUploaders(cfg, logger)
+
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		if err := t.Err(); err != nil {
+			errs[i] = fmt.Errorf("target %s: %w", t.Name, err)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger := logger.With("target", t.Name)
+			paths, err := writtenPaths(cfg, t.Format, t.Path)
+			if err == nil {
+				err = uploadFiles(ctx, t.Destinations, paths, logger)
+			}
+			if err != nil {
+				logger.Error("Error uploading feed target", "error", err)
+				errs[i] = fmt.Errorf("target %s: %w", t.Name, err)
+				return
+			}
+			logger.Info("Delivered feed target", "files", strings.Join(paths, ", "))
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
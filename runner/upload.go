@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/upload"
+)
+
+// RegisterUploaders sets up the uploaders that need settings from cfg, such
+// as the SFTP login, the object storage headers or the HTTP proxy
+func RegisterUploaders(cfg *config.Config, logger *slog.Logger) {
+	upload.Register("http", upload.HTTPUploader{HTTP: cfg.HTTP})
+	upload.Register("https", upload.HTTPUploader{HTTP: cfg.HTTP})
+	upload.Register("sftp", upload.NewSFTPUploader(cfg, logger))
+	upload.Register("s3", upload.NewS3Uploader(cfg, logger))
+	upload.Register("gs", upload.NewGCSUploader(cfg, logger))
+}
+
+// UploadFeeds copies the files at paths to every destination in
+// cfg.Upload, keeping their names. It stops at the first failed upload.
+func UploadFeeds(ctx context.Context, cfg *config.Config, paths []string, logger *slog.Logger) error {
+	if len(cfg.Upload.Destinations) == 0 {
+		return nil
+	}
+	logger = logging.OrDefault(logger)
+	RegisterUploaders(cfg, logger)
+	return uploadFiles(ctx, cfg.Upload.Destinations, paths, logger)
+}
+
+// uploadFiles copies the files at paths to every destination, stopping at
+// the first failed upload
+func uploadFiles(ctx context.Context, destinations, paths []string, logger *slog.Logger) error {
+	for _, dest := range destinations {
+		for _, path := range paths {
+			target := dest + filepath.Base(path)
+			if err := upload.Upload(ctx, path, target); err != nil {
+				return err
+			}
+			logger.Info("Uploaded feed", "file", path, "dest", redact(target))
+		}
+	}
+	return nil
+}
+
+// redact hides any password in a destination URL before it is logged
+func redact(dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+	return u.Redacted()
+}
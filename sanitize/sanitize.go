@@ -0,0 +1,251 @@
+// Package sanitize cleans up free text entered by sellers before it goes
+// into the feeds. Each cleanup is a Sanitizer step; a Chain composes them
+// and is built from a list of step names so it can be configured per field.
+package sanitize
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Sanitizer rewrites one text value
+type Sanitizer func(s string) string
+
+// Chain applies its steps in order
+type Chain []Sanitizer
+
+// Apply runs s through every step of the chain
+func (c Chain) Apply(s string) string {
+	for _, step := range c {
+		s = step(s)
+	}
+	return s
+}
+
+// Step names accepted by Parse. Steps that take an argument are written as
+// name=value, for example max_length=150 or remove=&.
+const (
+	StepStripControl   = "strip_control"
+	StepStripInvisible = "strip_invisible"
+	StepNFC            = "nfc"
+	StepStripHTML      = "strip_html"
+	StepDecodeEntities = "decode_entities"
+	StepNormalizeSpace = "normalize_space"
+	StepRemoveEmoji    = "remove_emoji"
+	StepMaxLength      = "max_length"
+	StepTruncate       = "truncate"
+	StepRemove         = "remove"
+)
+
+// Parse builds a chain from step names, in order
+func Parse(steps []string) (Chain, error) {
+	chain := make(Chain, 0, len(steps))
+	for _, spec := range steps {
+		name, arg, hasArg := strings.Cut(spec, "=")
+		var step Sanitizer
+		switch name {
+		case StepStripControl:
+			step = StripControl
+		case StepStripInvisible:
+			step = StripInvisible
+		case StepNFC:
+			step = NFC
+		case StepStripHTML:
+			step = StripHTML
+		case StepDecodeEntities:
+			step = DecodeEntities
+		case StepNormalizeSpace:
+			step = NormalizeSpace
+		case StepRemoveEmoji:
+			step = RemoveEmoji
+		case StepMaxLength:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("sanitize: %s needs a positive length, got %q", name, arg)
+			}
+			step = MaxLength(n)
+		case StepTruncate:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 1 {
+				return nil, fmt.Errorf("sanitize: %s needs a length above 1, got %q", name, arg)
+			}
+			step = Truncate(n, Ellipsis)
+		case StepRemove:
+			if arg == "" {
+				return nil, fmt.Errorf("sanitize: %s needs the characters to remove", name)
+			}
+			step = Remove(arg)
+		default:
+			return nil, fmt.Errorf("sanitize: unknown step %q", name)
+		}
+		if hasArg && name != StepMaxLength && name != StepTruncate && name != StepRemove {
+			return nil, fmt.Errorf("sanitize: %s takes no argument", name)
+		}
+		chain = append(chain, step)
+	}
+	return chain, nil
+}
+
+// StripControl removes control characters and explicit bidi formatting
+// marks such as U+200E, which sellers paste in from RTL editors. Line breaks
+// and tabs become spaces. The zero width joiner and non-joiner are kept as
+// they change how Arabic and Persian text is shaped.
+func StripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case unicode.IsControl(r), isBidiMark(r):
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// isBidiMark reports whether r is a directional mark, embedding, override
+// or isolate
+func isBidiMark(r rune) bool {
+	return r == '\u200e' || r == '\u200f' || r == '\u061c' ||
+		(r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069')
+}
+
+// StripInvisible removes the characters that take no space but break
+// matching and search: zero width spaces, joiners and non-joiners, word
+// joiners, the byte order mark and every bidi mark StripControl removes.
+// Unlike StripControl it also drops the joiners, so it suits Latin fields
+// and Arabic text that reads the same without them.
+func StripInvisible(s string) string {
+	return strings.Map(func(r rune) rune {
+		if isInvisible(r) || isBidiMark(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// isInvisible reports whether r is a zero width or invisible formatting
+// character
+func isInvisible(r rune) bool {
+	return (r >= '\u200b' && r <= '\u200f') || (r >= '\u2060' && r <= '\u2064') ||
+		r == '\ufeff' || r == '\u00ad' || r == '\u180e'
+}
+
+// NFC normalizes s to Unicode Normalization Form C, so an accented letter
+// typed as a letter and a combining mark compares equal to the same letter
+// typed precomposed
+func NFC(s string) string {
+	return norm.NFC.String(s)
+}
+
+// htmlTag matches all HTML tags and comments
+var htmlTag = regexp.MustCompile(`<!--[\s\S]*?-->|<[^>]*>`)
+
+// htmlHidden matches elements whose content is never shown as text
+var htmlHidden = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)\s*>`)
+
+// StripHTML removes HTML tags, comments and script or style blocks, leaving
+// a space where a tag separated words
+func StripHTML(s string) string {
+	s = htmlHidden.ReplaceAllString(s, " ")
+	return htmlTag.ReplaceAllString(s, " ")
+}
+
+// DecodeEntities replaces HTML character references such as &amp;, &#39;
+// and &nbsp; with the characters they stand for. It runs after StripHTML so
+// escaped markup in the text stays text.
+func DecodeEntities(s string) string {
+	return html.UnescapeString(s)
+}
+
+// NormalizeSpace collapses runs of whitespace into single spaces and trims
+// both ends
+func NormalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// RemoveEmoji removes emoji and pictographs along with the variation
+// selectors, skin tone modifiers and joiners that build emoji sequences
+func RemoveEmoji(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	afterEmoji := false
+	for _, r := range s {
+		switch {
+		case isEmoji(r), r == '\ufe0f':
+			afterEmoji = true
+			continue
+		case r == '\u200d' && afterEmoji:
+			continue
+		}
+		afterEmoji = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isEmoji reports whether r is in one of the emoji or pictograph blocks
+func isEmoji(r rune) bool {
+	return (r >= 0x1F000 && r <= 0x1FAFF) || // pictographs, emoticons, transport, flags
+		(r >= 0x2600 && r <= 0x27BF) || // miscellaneous symbols and dingbats
+		(r >= 0x2B00 && r <= 0x2BFF) || // arrows and stars
+		(r >= 0xE0020 && r <= 0xE007F) // tag sequences
+}
+
+// Ellipsis marks text cut by Truncate
+const Ellipsis = "…"
+
+// MaxLength cuts values longer than n characters at the last word boundary
+// that fits, or at n characters when a single word is longer. Lengths count
+// runes, not bytes, so Arabic text gets the same limit as Latin text.
+func MaxLength(n int) Sanitizer {
+	return Truncate(n, "")
+}
+
+// Truncate is MaxLength with suffix appended to values it cut. The result,
+// suffix included, is at most n characters long.
+func Truncate(n int, suffix string) Sanitizer {
+	return func(s string) string {
+		if utf8.RuneCountInString(s) <= n {
+			return s
+		}
+		n := n - utf8.RuneCountInString(suffix)
+		if n <= 0 {
+			return ""
+		}
+		cut, count := 0, 0
+		for i := range s {
+			if count == n {
+				cut = i
+				break
+			}
+			count++
+		}
+		// Prefer ending on a whole word unless the cut falls between words
+		next, _ := utf8.DecodeRuneInString(s[cut:])
+		if !unicode.IsSpace(next) {
+			if i := strings.LastIndexFunc(s[:cut], unicode.IsSpace); i > 0 {
+				cut = i
+			}
+		}
+		return strings.TrimRightFunc(s[:cut], unicode.IsSpace) + suffix
+	}
+}
+
+// Remove deletes every occurrence of the given characters
+func Remove(chars string) Sanitizer {
+	return func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if strings.ContainsRune(chars, r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+}
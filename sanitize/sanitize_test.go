@@ -0,0 +1,107 @@
+package sanitize
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStripControlBidi(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"left-to-right mark", "Gucci\u200e Bag", "Gucci Bag"},
+		{"right-to-left mark", "\u200fحقيبة", "حقيبة"},
+		{"arabic letter mark", "ساعة\u061c رولكس", "ساعة رولكس"},
+		{"embedding and pop", "\u202bحقيبة Gucci\u202c", "حقيبة Gucci"},
+		{"override", "\u202eevil\u202c", "evil"},
+		{"isolates", "\u2066Ray-Ban\u2069 \u2067نظارة\u2069 \u2068x\u2069", "Ray-Ban نظارة x"},
+		{"line breaks become spaces", "a\nb\r\nc\td", "a b  c d"},
+		{"joiners kept", "می\u200cخواهم", "می\u200cخواهم"},
+		{"other controls dropped", "a\x00b\x7fc", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripControl(tt.in); got != tt.want {
+				t.Errorf("StripControl(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripInvisibleBidi(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"marks", "\u200eGucci\u200f", "Gucci"},
+		{"isolates", "\u2066Hermès\u2069", "Hermès"},
+		{"joiners dropped", "Ray\u200d-\u200cBan", "Ray-Ban"},
+		{"zero width space and bom", "\ufeffPrada\u200b Bag", "Prada Bag"},
+		{"soft hyphen", "Louis\u00adVuitton", "LouisVuitton"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripInvisible(tt.in); got != tt.want {
+				t.Errorf("StripInvisible(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArabicDiacriticsKept(t *testing.T) {
+	// Fatha, damma, kasra, shadda, sukun and tanween are combining marks
+	// that belong to the text, unlike the bidi marks around them
+	tests := []struct {
+		name, in, want string
+	}{
+		{"shadda and fatha", "مُحَمَّد", "مُحَمَّد"},
+		{"tanween", "كِتَابٌ جَدِيدٌ", "كِتَابٌ جَدِيدٌ"},
+		{"sukun", "حَقِيبَةْ", "حَقِيبَةْ"},
+		{"among bidi marks", "\u200fسَاعَة\u200f ذَهَبِيَّة", "سَاعَة ذَهَبِيَّة"},
+		{"tatweel", "حقيـــبة", "حقيـــبة"},
+	}
+	chain, err := Parse([]string{StepDecodeEntities, StepStripControl, StepStripInvisible, StepNormalizeSpace, StepNFC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chain.Apply(tt.in); got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateMultiByte(t *testing.T) {
+	tests := []struct {
+		name   string
+		n      int
+		suffix string
+		in     string
+		want   string
+	}{
+		{"short arabic kept", 10, Ellipsis, "حقيبة يد", "حقيبة يد"},
+		{"exact length kept", 8, Ellipsis, "حقيبة يد", "حقيبة يد"},
+		{"arabic cut at word", 12, Ellipsis, "حقيبة يد جلدية فاخرة", "حقيبة يد…"},
+		{"arabic cut between words", 9, "", "حقيبة يد جلدية", "حقيبة يد"},
+		{"long arabic word cut mid-word", 4, "", "الإكسسوارات", "الإك"},
+		{"accents count once", 10, Ellipsis, "Hermès Kelly sac à main", "Hermès…"},
+		{"cjk", 5, Ellipsis, "手提包 限量版 新款", "手提包…"},
+		{"emoji are one character", 4, "", "👜👜👜👜👜", "👜👜👜👜"},
+		{"suffix as long as the limit", 1, Ellipsis, "ساعة", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.n, tt.suffix)(tt.in)
+			if got != tt.want {
+				t.Errorf("Truncate(%d)(%q) = %q, want %q", tt.n, tt.in, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Truncate(%d)(%q) = %q is not valid UTF-8", tt.n, tt.in, got)
+			}
+			if count := utf8.RuneCountInString(got); count > tt.n {
+				t.Errorf("Truncate(%d)(%q) has %d characters", tt.n, tt.in, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Package scheduler runs a job on a cron schedule, one run at a time, and
+// keeps the status of the last run for monitoring.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"go_data_fashion_accessories/shutdown"
+)
+
+// ErrRunInProgress is returned by Trigger while a run is already going
+var ErrRunInProgress = errors.New("a run is already in progress")
+
+// Job is the work done on every scheduled run
+type Job func(ctx context.Context) error
+
+// Status describes the scheduler and its most recent run
+type Status struct {
+	Schedule    string    `json:"schedule"`
+	Running     bool      `json:"running"`
+	NextRun     time.Time `json:"next_run"`
+	LastStart   time.Time `json:"last_start,omitempty"`
+	LastEnd     time.Time `json:"last_end,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	Runs        int       `json:"runs"`
+	Failures    int       `json:"failures"`
+	Skipped     int       `json:"skipped"` // ticks missed because a run was still going
+}
+
+// Scheduler triggers a Job according to a cron expression
+type Scheduler struct {
+	// GracePeriod is how long a run in progress when Run's ctx is cancelled
+	// may go on before its own context is cancelled too
+	GracePeriod time.Duration
+
+	spec     string
+	schedule cron.Schedule
+	job      Job
+	logger   *slog.Logger
+
+	running sync.Mutex // held for the duration of a run
+	mu      sync.Mutex // guards status
+	status  Status
+}
+
+// New parses spec, a standard five field cron expression or a descriptor
+// such as "@hourly" or "@every 30m", and returns a Scheduler for job. A nil
+// logger uses slog.Default().
+func New(spec string, job Job, logger *slog.Logger) (*Scheduler, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{
+		spec:     spec,
+		schedule: schedule,
+		job:      job,
+		logger:   logger,
+		status:   Status{Schedule: spec},
+	}, nil
+}
+
+// Status returns a snapshot of the scheduler state
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Trigger runs the job now unless a run is already in progress, in which
+// case it returns ErrRunInProgress
+func (s *Scheduler) Trigger(ctx context.Context) error {
+	if !s.running.TryLock() {
+		s.mu.Lock()
+		s.status.Skipped++
+		s.mu.Unlock()
+		return ErrRunInProgress
+	}
+	defer s.running.Unlock()
+
+	start := time.Now()
+	s.mu.Lock()
+	s.status.Running = true
+	s.status.LastStart = start
+	s.mu.Unlock()
+
+	err := s.job(ctx)
+
+	end := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.LastEnd = end
+	s.status.Runs++
+	if err != nil {
+		s.status.Failures++
+		s.status.LastError = err.Error()
+	} else {
+		s.status.LastError = ""
+		s.status.LastSuccess = end
+	}
+	return err
+}
+
+// Run triggers the job at every scheduled time until ctx is cancelled. A run
+// in progress when ctx is cancelled is allowed to finish within GracePeriod:
+// it gets a context that is only cancelled once that is over, and Run waits
+// for it before returning.
+func (s *Scheduler) Run(ctx context.Context) error {
+	jobCtx, cancel := shutdown.Graceful(ctx, s.GracePeriod, s.logger)
+	defer cancel()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		next := s.schedule.Next(time.Now())
+		s.mu.Lock()
+		s.status.NextRun = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.logger.Info("Scheduler stopping; waiting for any run in progress")
+			return nil
+		case <-timer.C:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch err := s.Trigger(jobCtx); {
+			case errors.Is(err, ErrRunInProgress):
+				s.logger.Warn("Skipping scheduled run: previous run still in progress")
+			case err != nil:
+				s.logger.Error("Scheduled run failed", "error", err)
+			}
+		}()
+	}
+}
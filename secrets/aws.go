@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go_data_fashion_accessories/awssign"
+)
+
+// AWS reads secrets from the keys of one AWS Secrets Manager secret, whose
+// value is a JSON object such as {"ADMIN_SECRET": "..."}
+type AWS struct {
+	SecretID    string // name or ARN
+	Region      string
+	Endpoint    string // empty for AWS
+	Credentials awssign.Credentials
+	Client      *http.Client
+
+	bundle bundle
+}
+
+// Get implements Provider. The secret is read once.
+func (a *AWS) Get(ctx context.Context, name string) (string, error) {
+	return a.bundle.get(ctx, name, a.load)
+}
+
+// load reads the current version of the secret with GetSecretValue
+func (a *AWS) load(ctx context.Context) (map[string]string, error) {
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + a.Region + ".amazonaws.com/"
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": a.SecretID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awssign.Sign(req, body, a.Credentials, a.Region, "secretsmanager", time.Now())
+
+	res, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if err := checkStatus(res); err != nil {
+		return nil, fmt.Errorf("aws: reading secret %s: %w", a.SecretID, err)
+	}
+
+	var response struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("aws: reading secret %s: %w", a.SecretID, err)
+	}
+	var data map[string]any
+	if err := json.Unmarshal([]byte(response.SecretString), &data); err != nil {
+		return nil, fmt.Errorf("aws: secret %s is not a JSON object: %w", a.SecretID, err)
+	}
+	return stringValues(data)
+}
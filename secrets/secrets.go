@@ -0,0 +1,155 @@
+// Package secrets reads the admin secret and API tokens from a secret
+// store, so they need not be written in the config file or passed on the
+// command line.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go_data_fashion_accessories/awssign"
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/httpclient"
+)
+
+// ErrNotFound is returned by a Provider that holds no secret of that name
+var ErrNotFound = errors.New("secret not found")
+
+// Provider looks secrets up by name
+type Provider interface {
+	Get(ctx context.Context, name string) (string, error)
+}
+
+// Env reads secrets from the environment variable of the same name
+type Env struct{}
+
+// Get implements Provider
+func (Env) Get(ctx context.Context, name string) (string, error) {
+	if v := os.Getenv(name); v != "" {
+		return v, nil
+	}
+	return "", ErrNotFound
+}
+
+// New returns the provider selected by cfg.Secrets.Provider, connecting
+// with the settings in cfg.HTTP
+func New(cfg *config.Config) (Provider, error) {
+	client, err := httpclient.NewRetrying(cfg.HTTP, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	secrets := cfg.Secrets
+	switch secrets.Provider {
+	case "", config.SecretsEnv:
+		return Env{}, nil
+	case config.SecretsVault:
+		token := os.Getenv("VAULT_TOKEN")
+		if token == "" {
+			return nil, errors.New("secrets: VAULT_TOKEN must be set for vault")
+		}
+		return &Vault{
+			Address: secrets.VaultAddress,
+			Token:   token,
+			Mount:   secrets.VaultMount,
+			Path:    secrets.VaultPath,
+			Client:  client,
+		}, nil
+	case config.SecretsAWS:
+		creds, err := awssign.EnvCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("secrets: %w", err)
+		}
+		return &AWS{
+			SecretID:    secrets.AWSSecretID,
+			Region:      secrets.AWSRegion,
+			Endpoint:    secrets.AWSEndpoint,
+			Credentials: creds,
+			Client:      client,
+		}, nil
+	default:
+		return nil, fmt.Errorf("secrets: unknown provider %q", secrets.Provider)
+	}
+}
+
+// Apply fills in the secrets of cfg that are still empty from the provider
+// selected by cfg.Secrets. It runs after the file and environment are read
+// and before the config is validated.
+func Apply(ctx context.Context, cfg *config.Config) error {
+	if cfg.Secrets.Provider == config.SecretsEnv {
+		return nil // the environment was already applied
+	}
+	provider, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]*string{
+		"ADMIN_SECRET":         &cfg.AdminSecret,
+		"HASURA_JWT":           &cfg.HasuraAuth.Token,
+		"HASURA_CLIENT_SECRET": &cfg.HasuraAuth.ClientSecret,
+		"META_ACCESS_TOKEN":    &cfg.MetaAPI.AccessToken,
+		"SFTP_PASSWORD":        &cfg.Upload.SFTP.Password,
+		"WEBHOOK_SECRET":       &cfg.Watch.WebhookSecret,
+		"NOTIFY_WEBHOOK_URL":   &cfg.Notify.WebhookURL,
+		"SMTP_PASSWORD":        &cfg.Email.SMTP.Password,
+	}
+	// A JWT takes the place of the token file or endpoint
+	if cfg.HasuraAuth.TokenFile != "" || cfg.HasuraAuth.TokenURL != "" {
+		delete(fields, "HASURA_JWT")
+	}
+	for name, field := range fields {
+		if *field != "" {
+			continue
+		}
+		value, err := provider.Get(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("secrets: reading %s: %w", name, err)
+		}
+		*field = value
+	}
+	return nil
+}
+
+// bundle holds the values of one stored secret, loaded on first use
+type bundle struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// get returns the value called name, loading the values with load first
+func (b *bundle) get(ctx context.Context, name string, load func(ctx context.Context) (map[string]string, error)) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.values == nil {
+		values, err := load(ctx)
+		if err != nil {
+			return "", err
+		}
+		b.values = values
+	}
+	value, ok := b.values[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// checkStatus turns a response other than 2xx into an error quoting the
+// start of its body
+func checkStatus(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+}
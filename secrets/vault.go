@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Vault reads secrets from the keys of one secret in a HashiCorp Vault KV
+// version 2 engine
+type Vault struct {
+	Address string // e.g. https://vault.example.com:8200
+	Token   string
+	Mount   string // path of the KV engine, e.g. secret
+	Path    string // secret within the engine
+	Client  *http.Client
+
+	bundle bundle
+}
+
+// Get implements Provider. The secret is read once.
+func (v *Vault) Get(ctx context.Context, name string) (string, error) {
+	return v.bundle.get(ctx, name, v.load)
+}
+
+// load reads the latest version of the secret
+func (v *Vault) load(ctx context.Context) (map[string]string, error) {
+	target := strings.TrimSuffix(v.Address, "/") + "/v1/" + escapePath(v.Mount) + "/data/" + escapePath(v.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+
+	res, err := v.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if err := checkStatus(res); err != nil {
+		return nil, fmt.Errorf("vault: reading %s/%s: %w", v.Mount, v.Path, err)
+	}
+
+	var response struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("vault: reading %s/%s: %w", v.Mount, v.Path, err)
+	}
+	return stringValues(response.Data.Data)
+}
+
+// escapePath escapes each segment of a slash separated path
+func escapePath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// stringValues keeps the values of a secret, which must all be strings
+func stringValues(data map[string]any) (map[string]string, error) {
+	values := make(map[string]string, len(data))
+	for name, value := range data {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("secret value %s is not a string", name)
+		}
+		values[name] = s
+	}
+	return values, nil
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/model/output/ndjson"
+)
+
+// Page sizes of GET /items
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+)
+
+// itemPage is the answer to GET /items
+type itemPage struct {
+	Items   []ndjson.Record `json:"items"`
+	Total   int             `json:"total"` // items matching the filters
+	Page    int             `json:"page"`  // from 1
+	PerPage int             `json:"per_page"`
+}
+
+// serveItems answers GET /items with a page of the items written by the
+// last refresh, in feed order. The subcategory parameter matches the name
+// or ID of the items' subcategory and brand their brand, both ignoring
+// case; page and per_page pick the page.
+func (s *Server) serveItems(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	page, err := intParam(query.Get("page"), 1)
+	if err != nil || page < 1 {
+		writeError(w, http.StatusBadRequest, "invalid page "+strconv.Quote(query.Get("page")))
+		return
+	}
+	perPage, err := intParam(query.Get("per_page"), defaultPerPage)
+	if err != nil || perPage < 1 || perPage > maxPerPage {
+		writeError(w, http.StatusBadRequest, "per_page must be between 1 and "+strconv.Itoa(maxPerPage))
+		return
+	}
+	if page > math.MaxInt/perPage {
+		writeError(w, http.StatusBadRequest, "page "+strconv.Itoa(page)+" is out of range")
+		return
+	}
+	subcategory, brand := query.Get("subcategory"), query.Get("brand")
+
+	s.mu.RLock()
+	items := s.items
+	s.mu.RUnlock()
+
+	answer := itemPage{Items: []ndjson.Record{}, Page: page, PerPage: perPage}
+	start := (page - 1) * perPage
+	for _, item := range items {
+		if !inFilter(item, subcategory, brand) {
+			continue
+		}
+		if answer.Total >= start && len(answer.Items) < perPage {
+			record, err := newRecord(item)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			answer.Items = append(answer.Items, record)
+		}
+		answer.Total++
+	}
+	writeJSON(w, http.StatusOK, answer)
+}
+
+// inFilter reports whether the item is in the subcategory, by name or ID,
+// and of the brand, ignoring case. An empty filter matches every item.
+func inFilter(item input.AdItem, subcategory, brand string) bool {
+	if subcategory != "" && !strings.EqualFold(item.SubcategoryName, subcategory) && !strings.EqualFold(item.Subcategory, subcategory) {
+		return false
+	}
+	return brand == "" || strings.EqualFold(item.Brand, brand)
+}
+
+// serveItem answers GET /items/<id> with one item of the last refresh
+func (s *Server) serveItem(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/items/")
+
+	s.mu.RLock()
+	items := s.items
+	s.mu.RUnlock()
+
+	i := slices.IndexFunc(items, func(item input.AdItem) bool { return item.ID == id })
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "no item with ID "+strconv.Quote(id))
+		return
+	}
+	record, err := newRecord(items[i])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// newRecord returns the ndjson record of an item
+func newRecord(item input.AdItem) (ndjson.Record, error) {
+	it, err := feed.FromAdItem(item)
+	if err != nil {
+		return ndjson.Record{}, err
+	}
+	return ndjson.NewRecord(it), nil
+}
+
+// serveLatestRun answers GET /runs/latest with the summary of the last
+// refresh, whether or not it succeeded
+func (s *Server) serveLatestRun(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.runs) == 0 {
+		writeError(w, http.StatusNotFound, "no run has ended yet")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.runs[len(s.runs)-1])
+}
+
+// allowGet answers requests other than GET and HEAD with 405 and reports
+// whether r may be served
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+// intParam parses a query parameter, def when it is empty
+func intParam(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// writeJSON writes v as the JSON body of a response with status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(v)
+}
+
+// writeError writes a JSON error body, {"error": message}
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{message})
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+)
+
+func TestItemsOnlyOnAdminHandler(t *testing.T) {
+	s := New(&config.Config{}, time.Hour, logging.Discard())
+	s.items = []input.AdItem{{ID: "a1"}}
+	for _, path := range []string{"/items", "/items/a1", "/runs/latest"} {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("public %s answered %d, want 404", path, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	s.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/a1", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("admin /items/a1 answered %d, want 200", rec.Code)
+	}
+}
+
+func TestServeItemsPages(t *testing.T) {
+	s := New(&config.Config{}, time.Hour, logging.Discard())
+	s.items = []input.AdItem{{ID: "a1"}, {ID: "a2"}, {ID: "a3"}}
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"page=1&per_page=2", http.StatusOK},
+		{"page=2&per_page=2", http.StatusOK},
+		{"page=0", http.StatusBadRequest},
+		{"per_page=501", http.StatusBadRequest},
+		// (page - 1) * per_page would overflow
+		{"page=" + strconv.Itoa(math.MaxInt) + "&per_page=2", http.StatusBadRequest},
+		{"page=" + strconv.Itoa(math.MaxInt/500+1) + "&per_page=500", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.AdminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items?"+tt.query, nil))
+		if rec.Code != tt.want {
+			t.Errorf("/items?%s answered %d, want %d: %s", tt.query, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
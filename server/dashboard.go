@@ -0,0 +1,162 @@
+package server
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/runner"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// maxListed is how many matching items the dashboard lists
+const maxListed = 100
+
+// countBar is the item count of one run, drawn as a bar
+type countBar struct {
+	Started time.Time
+	Items   int
+	Percent int // of the largest count
+	Failed  bool
+}
+
+// reasonCount is how many ads the last run skipped for one reason
+type reasonCount struct {
+	Reason input.SkipReason
+	Count  int
+}
+
+// dashboardPage is what the dashboard template renders
+type dashboardPage struct {
+	Runs       []runner.Summary // newest first
+	Counts     []countBar       // oldest first
+	Skipped    []reasonCount    // most frequent first
+	Modified   time.Time
+	Refreshing bool
+	Query      string
+	Items      []input.AdItem // at most maxListed
+	Matches    int
+}
+
+// serveDashboard renders the last runs, their item counts, the skip
+// reasons of the last run and the items matching the q parameter
+func (s *Server) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page := dashboardPage{Query: strings.TrimSpace(r.URL.Query().Get("q"))}
+	s.mu.RLock()
+	runs, items := s.runs, s.items
+	page.Modified, page.Refreshing = s.modified, s.refreshing
+	s.mu.RUnlock()
+
+	largest := 1
+	for _, run := range runs {
+		largest = max(largest, run.Emitted)
+	}
+	for _, run := range runs {
+		page.Counts = append(page.Counts, countBar{
+			Started: run.Started,
+			Items:   run.Emitted,
+			Percent: run.Emitted * 100 / largest,
+			Failed:  run.Error != "",
+		})
+	}
+	page.Runs = slices.Clone(runs)
+	slices.Reverse(page.Runs)
+
+	if len(runs) > 0 {
+		for reason, count := range runs[len(runs)-1].Skipped {
+			page.Skipped = append(page.Skipped, reasonCount{Reason: reason, Count: count})
+		}
+		slices.SortFunc(page.Skipped, func(a, b reasonCount) int {
+			if a.Count != b.Count {
+				return b.Count - a.Count
+			}
+			return strings.Compare(string(a.Reason), string(b.Reason))
+		})
+	}
+
+	query := strings.ToLower(page.Query)
+	for _, item := range items {
+		if query != "" && !matches(item, query) {
+			continue
+		}
+		page.Matches++
+		if len(page.Items) < maxListed {
+			page.Items = append(page.Items, item)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		s.logger.Error("Error rendering dashboard", "error", err)
+	}
+}
+
+// matches reports whether the item's ID, ad ID, title or brand contains
+// query, which is lower case
+func matches(item input.AdItem, query string) bool {
+	for _, field := range []string{item.ID, item.AdID, item.Title, item.Brand} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveRefresh triggers a refresh and sends the browser back to the
+// dashboard. Only forms of the dashboard itself are accepted, at most one
+// every manualRefreshInterval.
+func (s *Server) serveRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, "cross-origin request", http.StatusForbidden)
+		return
+	}
+	if !s.manual.Allow() {
+		w.Header().Set("Retry-After", strconv.Itoa(int(manualRefreshInterval.Seconds())))
+		http.Error(w, "a refresh was requested less than "+manualRefreshInterval.String()+" ago", http.StatusTooManyRequests)
+		return
+	}
+	s.logger.Info("Refresh requested from the dashboard")
+	s.Trigger()
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// sameOrigin reports whether r comes from a page of the same host, going by
+// the Sec-Fetch-Site header browsers send or else the Origin header.
+// Requests with neither, such as from curl, are not from a browser and are
+// let through.
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+)
+
+func TestRefreshOnlyOnAdminHandler(t *testing.T) {
+	s := New(&config.Config{}, time.Hour, logging.Discard())
+	for _, path := range []string{"/dashboard", "/dashboard/refresh"} {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("public %s answered %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+func TestServeRefresh(t *testing.T) {
+	s := New(&config.Config{}, time.Hour, logging.Discard())
+	refresh := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodPost, "http://127.0.0.1:8081/dashboard/refresh", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		s.AdminHandler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := refresh("Sec-Fetch-Site", "cross-site"); code != http.StatusForbidden {
+		t.Errorf("cross-site refresh answered %d, want 403", code)
+	}
+	if code := refresh("Origin", "https://evil.example"); code != http.StatusForbidden {
+		t.Errorf("refresh from another origin answered %d, want 403", code)
+	}
+	if len(s.trigger) != 0 {
+		t.Fatal("a rejected request triggered a refresh")
+	}
+
+	if code := refresh("Origin", "http://127.0.0.1:8081"); code != http.StatusSeeOther {
+		t.Errorf("refresh from the dashboard answered %d, want 303", code)
+	}
+	if len(s.trigger) != 1 {
+		t.Error("the refresh was not triggered")
+	}
+	if code := refresh("Sec-Fetch-Site", "same-origin"); code != http.StatusTooManyRequests {
+		t.Errorf("second refresh answered %d, want 429", code)
+	}
+}
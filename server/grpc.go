@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"go_data_fashion_accessories/feedpb"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/runner"
+)
+
+// grpcService implements feedpb.FeedServiceServer over the state of a
+// Server
+type grpcService struct {
+	feedpb.UnimplementedFeedServiceServer
+	s *Server
+}
+
+// authorize checks the bearer token in the metadata of a call against
+// Server.GRPCToken; without a configured token every call is allowed
+func (s *Server) authorize(ctx context.Context) error {
+	token := s.cfg.Server.GRPCToken
+	if token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		got, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+// authorizeUnary is the unary interceptor of authorize
+func (s *Server) authorizeUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authorizeStream is the stream interceptor of authorize
+func (s *Server) authorizeStream(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(stream.Context()); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// TriggerRun implements feedpb.FeedServiceServer
+func (g grpcService) TriggerRun(ctx context.Context, req *feedpb.TriggerRunRequest) (*feedpb.TriggerRunResponse, error) {
+	g.s.logger.Info("Refresh requested over gRPC")
+	return &feedpb.TriggerRunResponse{AlreadyPending: !g.s.Trigger()}, nil
+}
+
+// GetRunStatus implements feedpb.FeedServiceServer
+func (g grpcService) GetRunStatus(ctx context.Context, req *feedpb.GetRunStatusRequest) (*feedpb.RunStatus, error) {
+	g.s.mu.RLock()
+	defer g.s.mu.RUnlock()
+	status := &feedpb.RunStatus{Running: g.s.refreshing}
+	if len(g.s.runs) > 0 {
+		status.LastRun = runMessage(g.s.runs[len(g.s.runs)-1])
+	}
+	if !g.s.modified.IsZero() {
+		status.LastSuccess = timestamppb.New(g.s.modified)
+	}
+	return status, nil
+}
+
+// StreamItems implements feedpb.FeedServiceServer
+func (g grpcService) StreamItems(req *feedpb.StreamItemsRequest, stream feedpb.FeedService_StreamItemsServer) error {
+	g.s.mu.RLock()
+	items := g.s.items
+	g.s.mu.RUnlock()
+
+	for _, item := range items {
+		if !inFilter(item, req.Subcategory, req.Brand) {
+			continue
+		}
+		if err := stream.Send(itemMessage(item)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runMessage converts the summary of a run
+func runMessage(summary runner.Summary) *feedpb.Run {
+	run := &feedpb.Run{
+		Started:       timestamppb.New(summary.Started),
+		Duration:      durationpb.New(summary.Duration.Duration),
+		Error:         summary.Error,
+		Fetched:       int32(summary.Fetched),
+		Parsed:        int32(summary.Parsed),
+		Emitted:       int32(summary.Emitted),
+		Filtered:      int32(summary.Filtered),
+		Deduped:       int32(summary.Deduped),
+		Skipped:       make(map[string]int32, len(summary.Skipped)),
+		Subcategories: make(map[string]int32, len(summary.Subcategories)),
+	}
+	for reason, count := range summary.Skipped {
+		run.Skipped[string(reason)] = int32(count)
+	}
+	for name, count := range summary.Subcategories {
+		run.Subcategories[name] = int32(count)
+	}
+	return run
+}
+
+// itemMessage converts an item as the feeds carry it
+func itemMessage(item input.AdItem) *feedpb.Item {
+	m := &feedpb.Item{
+		Id:                    item.ID,
+		AdId:                  item.AdID,
+		SellerId:              item.SellerID,
+		Title:                 item.Title,
+		Description:           item.Description,
+		Link:                  item.Link,
+		ImageLink:             item.ImageLink,
+		AdditionalImageLinks:  item.AdditionalImageLinks,
+		Brand:                 item.Brand,
+		Price:                 item.Price.Decimal(),
+		Currency:              item.Price.Currency,
+		Availability:          item.Availability,
+		Condition:             item.Condition,
+		Gtin:                  item.GTIN,
+		Mpn:                   item.MPN,
+		ItemGroupId:           item.ItemGroupID,
+		Color:                 item.Color,
+		Size:                  item.Size,
+		Material:              item.Material,
+		Gender:                item.Gender,
+		AgeGroup:              item.AgeGroup,
+		Subcategory:           item.Subcategory,
+		SubcategoryName:       item.SubcategoryName,
+		GoogleProductCategory: item.GoogleProductCategory,
+		ProductType:           item.ProductType,
+	}
+	if !item.SalePrice.IsZero() {
+		m.SalePrice = item.SalePrice.Decimal()
+	}
+	if !item.UpdatedAt.IsZero() {
+		m.UpdatedAt = timestamppb.New(item.UpdatedAt)
+	}
+	return m
+}
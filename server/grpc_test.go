@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+)
+
+func TestAuthorize(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.GRPCToken = "s3cret"
+	s := New(cfg, time.Hour, logging.Discard())
+
+	tests := []struct {
+		name          string
+		authorization []string
+		ok            bool
+	}{
+		{"no metadata", nil, false},
+		{"wrong token", []string{"Bearer nope"}, false},
+		{"not a bearer token", []string{"s3cret"}, false},
+		{"token", []string{"Bearer s3cret"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.MD{"authorization": tt.authorization})
+			}
+			err := s.authorize(ctx)
+			if tt.ok && err != nil {
+				t.Errorf("authorize: %v", err)
+			}
+			if !tt.ok && status.Code(err) != codes.Unauthenticated {
+				t.Errorf("authorize = %v, want Unauthenticated", err)
+			}
+		})
+	}
+}
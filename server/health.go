@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/model/input"
+)
+
+// pingTimeout bounds the source check of /readyz
+const pingTimeout = 5 * time.Second
+
+// health is the answer to /healthz and /readyz
+type health struct {
+	Status      string          `json:"status"` // ok or failing
+	Problems    []string        `json:"problems,omitempty"`
+	LastSuccess *time.Time      `json:"last_success,omitempty"` // of a refresh, unset before the first
+	Age         config.Duration `json:"age"`                    // since LastSuccess, or since the server started
+	Threshold   config.Duration `json:"threshold"`              // Age it fails beyond
+	Refreshing  bool            `json:"refreshing"`
+	Source      string          `json:"source,omitempty"` // ok or why it is unreachable, /readyz only
+}
+
+// threshold returns d, or intervals refresh intervals when it is zero
+func (s *Server) threshold(d config.Duration, intervals int) time.Duration {
+	if d.Duration > 0 {
+		return d.Duration
+	}
+	return time.Duration(intervals) * s.interval
+}
+
+// check reports the age of the feeds against threshold. Before the first
+// successful refresh the age counts from the server's start.
+func (s *Server) check(threshold time.Duration) health {
+	s.mu.RLock()
+	modified, refreshing := s.modified, s.refreshing
+	s.mu.RUnlock()
+
+	h := health{Threshold: config.Duration{Duration: threshold}, Refreshing: refreshing}
+	since := s.started
+	if !modified.IsZero() {
+		since = modified
+		last := modified.UTC()
+		h.LastSuccess = &last
+	}
+	h.Age = config.Duration{Duration: time.Since(since).Round(time.Second)}
+	if h.Age.Duration > threshold {
+		h.Problems = append(h.Problems, "no successful refresh in "+h.Age.String())
+	}
+	return h
+}
+
+// serveHealth answers /healthz, for liveness probes: it fails once no
+// refresh succeeded for Server.StuckAfter, when restarting may help
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	writeHealth(w, s.check(s.threshold(s.cfg.Server.StuckAfter, 4)))
+}
+
+// serveReady answers /readyz, for readiness probes and uptime monitors: it
+// fails until the feeds are generated, once they are older than
+// Server.StaleAfter and while the source cannot be reached
+func (s *Server) serveReady(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	h := s.check(s.threshold(s.cfg.Server.StaleAfter, 2))
+	if h.LastSuccess == nil {
+		h.Problems = append(h.Problems, "feeds are not generated yet")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+	defer cancel()
+	if err := s.ping(ctx); err != nil {
+		h.Source = err.Error()
+		h.Problems = append(h.Problems, "source is unreachable")
+	} else {
+		h.Source = "ok"
+	}
+	writeHealth(w, h)
+}
+
+// ping checks that the configured source answers, when it can tell
+func (s *Server) ping(ctx context.Context) error {
+	s.sourceOnce.Do(func() {
+		s.source, s.sourceErr = input.NewSource(s.cfg, s.logger)
+	})
+	if s.sourceErr != nil {
+		return s.sourceErr
+	}
+	if pinger, ok := s.source.(input.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// writeHealth answers with h, failing with 503 when it has problems
+func writeHealth(w http.ResponseWriter, h health) {
+	status := http.StatusOK
+	h.Status = "ok"
+	if len(h.Problems) > 0 {
+		status = http.StatusServiceUnavailable
+		h.Status = "failing"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, h)
+}
@@ -0,0 +1,383 @@
+// Package server keeps the product feeds in memory, regenerates them on a
+// schedule and serves them over HTTP so channels such as Google Merchant
+// Center can fetch them by URL.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/feedpb"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/metrics"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/model/output/jsonld"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/runner"
+	"go_data_fashion_accessories/shutdown"
+)
+
+// feedFile is one rendered feed file
+type feedFile struct {
+	data        []byte
+	etag        string
+	contentType string
+	modified    time.Time
+}
+
+// Server regenerates and serves every registered output format at
+// /feed.<format>, with labelled categories at /feed_<label>.<format>, the
+// schema.org JSON-LD of each item at /jsonld/<id>, liveness and readiness
+// at /healthz and /readyz and the Prometheus metrics at /metrics. The
+// dashboard, which can start a refresh, is served at /dashboard on the
+// separate admin address, with the items as JSON at /items and /items/<id>
+// and the last run's summary at /runs/latest.
+type Server struct {
+	cfg      *config.Config
+	interval time.Duration
+	logger   *slog.Logger
+	trigger  chan struct{} // asks refreshLoop for a refresh now
+	manual   *rate.Limiter // of the refreshes asked for from outside
+	started  time.Time
+
+	sourceOnce sync.Once // builds source for the health checks
+	source     input.StreamingSource
+	sourceErr  error
+
+	mu         sync.RWMutex
+	feeds      map[string]*feedFile
+	products   map[string][]byte // JSON-LD by item ID
+	items      []input.AdItem    // written by the last successful refresh, in feed order
+	modified   time.Time         // when products were last refreshed
+	runs       []runner.Summary  // the last maxRuns refreshes, oldest first
+	refreshing bool
+}
+
+// maxRuns is how many run summaries the server keeps
+const maxRuns = 50
+
+// manualRefreshInterval is the least time between two refreshes asked for
+// from the dashboard
+const manualRefreshInterval = time.Minute
+
+// New returns a Server that rebuilds the feeds every interval. A nil logger
+// uses slog.Default().
+func New(cfg *config.Config, interval time.Duration, logger *slog.Logger) *Server {
+	return &Server{
+		cfg:      cfg,
+		interval: interval,
+		logger:   logging.OrDefault(logger),
+		trigger:  make(chan struct{}, 1),
+		manual:   rate.NewLimiter(rate.Every(manualRefreshInterval), 1),
+		started:  time.Now(),
+		feeds:    map[string]*feedFile{},
+	}
+}
+
+// route is the URL path of one served feed
+type route struct {
+	path     string
+	format   string
+	category string // FeedLabel
+}
+
+// routes lists every feed served: one per format and category written in
+// it, at /feed.<format> or, for labelled categories, e.g. /feed_apparel.xml
+func (s *Server) routes() []route {
+	var routes []route
+	for _, name := range runner.FormatNames() {
+		for _, category := range s.cfg.CategoryRegistry().ForFormat(name) {
+			routes = append(routes, route{
+				path:     "/" + runner.CategoryPath("feed."+name, category.FeedLabel),
+				format:   name,
+				category: category.FeedLabel,
+			})
+		}
+	}
+	return routes
+}
+
+// Handler returns the HTTP handler serving the feeds
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, r := range s.routes() {
+		mux.HandleFunc(r.path, s.serveFeed(r.path))
+	}
+	mux.HandleFunc("/jsonld/", s.serveProduct)
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/readyz", s.serveReady)
+	mux.Handle("/metrics", metrics.Handler())
+	return mux
+}
+
+// AdminHandler returns the HTTP handler of the admin address: the
+// dashboard, the JSON-LD it links to and the items and runs API
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jsonld/", s.serveProduct)
+	mux.HandleFunc("/dashboard", s.serveDashboard)
+	mux.HandleFunc("/dashboard/refresh", s.serveRefresh)
+	mux.HandleFunc("/items", s.serveItems)
+	mux.HandleFunc("/items/", s.serveItem)
+	mux.HandleFunc("/runs/latest", s.serveLatestRun)
+	return mux
+}
+
+// serveFeed answers requests for one feed. http.ServeContent takes care of
+// Last-Modified, If-Modified-Since, If-None-Match and range requests.
+func (s *Server) serveFeed(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		s.mu.RLock()
+		f := s.feeds[name]
+		s.mu.RUnlock()
+		if f == nil {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "feed is not generated yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", f.contentType)
+		w.Header().Set("ETag", f.etag)
+		http.ServeContent(w, r, "", f.modified, bytes.NewReader(f.data))
+	}
+}
+
+// serveProduct answers requests for the JSON-LD of one item
+func (s *Server) serveProduct(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/jsonld/"), ".json")
+	s.mu.RLock()
+	data, ok := s.products[id]
+	modified := s.modified
+	s.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", jsonld.ContentType)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.ServeContent(w, r, "", modified, bytes.NewReader(data))
+}
+
+// Refresh regenerates every feed in one pipeline run. The previous feeds stay
+// in place if the run fails. A feed whose content did not change keeps its
+// ETag and Last-Modified time so clients can skip downloading it again.
+func (s *Server) Refresh(ctx context.Context) error {
+	routes := s.routes()
+	buffers := make([]*bytes.Buffer, len(routes))
+	sinks := make([]pipeline.Sink, 0, len(routes)+1)
+	for i, r := range routes {
+		buffers[i] = &bytes.Buffer{}
+		sink, err := runner.Formats[r.format].New(buffers[i])
+		if err != nil {
+			for _, opened := range sinks {
+				opened.Close()
+			}
+			return err
+		}
+		sinks = append(sinks, runner.ChannelSink(s.cfg, r.format, "", runner.CategorySink(r.category, sink)))
+	}
+	products := jsonld.NewCollector()
+	items := &itemSink{}
+	sinks = append(sinks, products, items)
+
+	s.mu.Lock()
+	s.refreshing = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.refreshing = false
+		s.mu.Unlock()
+	}()
+
+	// The served feed always reflects the full configured window, so the
+	// run state used for incremental file runs is not consulted
+	if _, err := runner.Run(ctx, s.cfg, runner.Options{Sinks: sinks, Logger: s.logger, OnSummary: s.addRun}); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.products, s.items, s.modified = products.Products, items.items, now
+	for i, r := range routes {
+		data := buffers[i].Bytes()
+		sum := sha256.Sum256(data)
+		etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		if old := s.feeds[r.path]; old != nil && old.etag == etag {
+			continue
+		}
+		s.feeds[r.path] = &feedFile{
+			data:        data,
+			etag:        etag,
+			contentType: runner.Formats[r.format].ContentType,
+			modified:    now,
+		}
+	}
+	return nil
+}
+
+// itemSink keeps the items of a refresh for the dashboard
+type itemSink struct {
+	items []input.AdItem
+}
+
+func (s *itemSink) Write(item input.AdItem) error {
+	s.items = append(s.items, item)
+	return nil
+}
+
+func (s *itemSink) Close() error { return nil }
+
+// Run serves the feeds on addr, the dashboard on Server.AdminAddr and the
+// gRPC API on Server.GRPCAddr when it is set, refreshing them every
+// interval until ctx is cancelled, then shuts the servers down gracefully
+func (s *Server) Run(ctx context.Context, addr string) error {
+	if s.cfg.Server.GRPCAddr != "" {
+		listener, err := net.Listen("tcp", s.cfg.Server.GRPCAddr)
+		if err != nil {
+			return err
+		}
+		grpcServer := grpc.NewServer(
+			grpc.UnaryInterceptor(s.authorizeUnary),
+			grpc.StreamInterceptor(s.authorizeStream),
+		)
+		feedpb.RegisterFeedServiceServer(grpcServer, grpcService{s: s})
+		go func() {
+			s.logger.Info("Serving gRPC API", "addr", s.cfg.Server.GRPCAddr)
+			if err := grpcServer.Serve(listener); err != nil {
+				s.logger.Error("gRPC server stopped", "error", err)
+			}
+		}()
+		defer grpcServer.GracefulStop()
+	}
+
+	errc := make(chan error, 2)
+	httpServer := s.listen("Serving feeds", addr, s.Handler(), errc)
+	adminServer := s.listen("Serving dashboard and API", s.cfg.Server.AdminAddr, s.AdminHandler(), errc)
+
+	// Refreshes stop with ctx, but the one in progress may finish within
+	// the grace period while the last feeds are still served
+	loopCtx, stopLoop := context.WithCancel(ctx)
+	defer stopLoop()
+	refreshCtx, cancelRefresh := shutdown.Graceful(loopCtx, s.cfg.Shutdown.GracePeriod.Duration, s.logger)
+	defer cancelRefresh()
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		s.refreshLoop(loopCtx, refreshCtx)
+	}()
+
+	select {
+	case err := <-errc:
+		stopLoop()
+		cancelRefresh()
+		<-loopDone
+		httpServer.Close()
+		adminServer.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	s.mu.RLock()
+	refreshing := s.refreshing
+	s.mu.RUnlock()
+	if refreshing {
+		s.logger.Info("Shutting down; waiting for the refresh in progress")
+	}
+	<-loopDone
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, server := range []*http.Server{httpServer, adminServer} {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// listen serves handler on addr in the background, sending the error that
+// ends it to errc
+func (s *Server) listen(msg, addr string, handler http.Handler, errc chan<- error) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		s.logger.Info(msg, "addr", addr)
+		errc <- server.ListenAndServe()
+	}()
+	return server
+}
+
+// addRun records the summary of a refresh
+func (s *Server) addRun(summary runner.Summary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs = append(s.runs, summary)
+	if len(s.runs) > maxRuns {
+		s.runs = s.runs[len(s.runs)-maxRuns:]
+	}
+}
+
+// Trigger asks for a refresh as soon as the one in progress, if any, ends.
+// It reports false when a refresh was already waiting to start.
+func (s *Server) Trigger() bool {
+	select {
+	case s.trigger <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// refreshLoop regenerates the feeds now, then every interval and whenever
+// Trigger is called, with refreshCtx, until ctx is cancelled
+func (s *Server) refreshLoop(ctx, refreshCtx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Refresh(refreshCtx); err != nil && refreshCtx.Err() == nil {
+			s.logger.Error("Error refreshing feeds", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.trigger:
+			ticker.Reset(s.interval)
+		}
+	}
+}
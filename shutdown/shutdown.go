@@ -0,0 +1,37 @@
+// Package shutdown lets a run in progress outlive the signal that stops a
+// long-running command, so it can finish its uploads instead of leaving
+// them half done, for as long as the deployment allows.
+package shutdown
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go_data_fashion_accessories/logging"
+)
+
+// Graceful returns the context for the runs of a command stopped through
+// ctx. It is cancelled grace after ctx is done, or by the returned cancel
+// function, which must be called once the runs are over. A nil logger uses
+// slog.Default().
+func Graceful(ctx context.Context, grace time.Duration, logger *slog.Logger) (context.Context, context.CancelFunc) {
+	logger = logging.OrDefault(logger)
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-runCtx.Done():
+			return
+		case <-ctx.Done():
+		}
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-runCtx.Done():
+		case <-timer.C:
+			logger.Warn("Shutdown grace period is over; cancelling the run in progress", "grace_period", grace)
+			cancel()
+		}
+	}()
+	return runCtx, cancel
+}
@@ -0,0 +1,44 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// MemoryStore keeps every key in memory, for long-running processes that
+// track their own runs and do not persist them
+type MemoryStore struct {
+	mu     sync.Mutex
+	values map[string]json.RawMessage
+}
+
+// NewMemoryStore returns an empty store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: map[string]json.RawMessage{}}
+}
+
+// Load implements Store
+func (s *MemoryStore) Load(ctx context.Context, key string, v interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, ok := s.values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Save implements Store
+func (s *MemoryStore) Save(ctx context.Context, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = raw
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package state persists bookkeeping between runs, such as when the feed was
+// last generated successfully.
+package state
+
+import (
+	"context"
+	"time"
+)
+
+// Store is a small key/value store for run state. Values are JSON encoded,
+// so any backend that can hold bytes per key (a file, Redis, a database
+// table) can implement it.
+type Store interface {
+	// Load decodes the value stored under key into v, reporting false when
+	// nothing has been stored yet
+	Load(ctx context.Context, key string, v interface{}) (bool, error)
+	// Save replaces the value stored under key
+	Save(ctx context.Context, key string, v interface{}) error
+}
+
+// keyLastRun holds the start time of the last successful run
+const keyLastRun = "last_successful_run"
+
+// LastSuccessfulRun returns the start time of the last successful run, or the
+// zero time if none has been recorded
+func LastSuccessfulRun(ctx context.Context, s Store) (time.Time, error) {
+	var t time.Time
+	_, err := s.Load(ctx, keyLastRun, &t)
+	return t, err
+}
+
+// RecordSuccessfulRun stores the start time of a run that completed. The
+// start time is used rather than the end so ads updated while the run was in
+// progress are picked up next time.
+func RecordSuccessfulRun(ctx context.Context, s Store, started time.Time) error {
+	return s.Save(ctx, keyLastRun, started.UTC())
+}
+
+// Since returns the updated_at lower bound for an incremental fetch: the last
+// successful run minus overlap. It returns the zero time when there is no
+// previous run, so callers fall back to their default window.
+func Since(ctx context.Context, s Store, overlap time.Duration) (time.Time, error) {
+	last, err := LastSuccessfulRun(ctx, s)
+	if err != nil || last.IsZero() {
+		return time.Time{}, err
+	}
+	return last.Add(-overlap), nil
+}
+
+// keyItemCounts holds the item counts of the last successful runs
+const keyItemCounts = "item_counts"
+
+// ItemCounts is the number of items a run wrote, in all and by subcategory
+type ItemCounts struct {
+	Total         int            `json:"total"`
+	Subcategories map[string]int `json:"subcategories,omitempty"`
+}
+
+// ItemCountHistory returns the item counts of the last successful runs,
+// oldest first, or none when nothing has been recorded
+func ItemCountHistory(ctx context.Context, s Store) ([]ItemCounts, error) {
+	var history []ItemCounts
+	_, err := s.Load(ctx, keyItemCounts, &history)
+	return history, err
+}
+
+// RecordItemCounts adds the item counts of a successful run to the
+// history, which keeps the last keep runs
+func RecordItemCounts(ctx context.Context, s Store, counts ItemCounts, keep int) error {
+	history, err := ItemCountHistory(ctx, s)
+	if err != nil {
+		return err
+	}
+	history = append(history, counts)
+	if len(history) > keep {
+		history = history[len(history)-keep:]
+	}
+	return s.Save(ctx, keyItemCounts, history)
+}
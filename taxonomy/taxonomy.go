@@ -0,0 +1,70 @@
+// Package taxonomy maps marketplace subcategories to the Google product
+// taxonomy and to the merchant's own product_type, so every item is
+// categorized in Merchant Center.
+package taxonomy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go_data_fashion_accessories/model/input"
+)
+
+// Category is the categorization given to items of one subcategory
+type Category struct {
+	// GoogleProductCategory is a Google product taxonomy ID such as "3032"
+	// or its full path such as "Apparel & Accessories > Handbags"
+	GoogleProductCategory string `json:"google_product_category"`
+	// ProductType is the merchant's own category path
+	ProductType string `json:"product_type"`
+}
+
+// Map holds the categorization of each subcategory UUID, and a default for
+// subcategories that are not listed
+type Map struct {
+	Default       Category            `json:"default"`
+	Subcategories map[string]Category `json:"subcategories"`
+}
+
+// Load reads a Map from a JSON file
+func Load(path string) (*Map, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Map
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("reading taxonomy %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Lookup returns the categorization for a subcategory. Fields the mapping
+// leaves empty come from the default; a missing product_type becomes the
+// default product_type followed by the subcategory name.
+func (m *Map) Lookup(subcategory, name string) Category {
+	c := m.Subcategories[subcategory]
+	if c.GoogleProductCategory == "" {
+		c.GoogleProductCategory = m.Default.GoogleProductCategory
+	}
+	if c.ProductType == "" {
+		c.ProductType = m.Default.ProductType
+		if name != "" {
+			if c.ProductType != "" {
+				c.ProductType += " > "
+			}
+			c.ProductType += name
+		}
+	}
+	return c
+}
+
+// Apply sets the item's google_product_category and product_type from its
+// subcategory. It satisfies pipeline.Transformer.
+func (m *Map) Apply(item *input.AdItem) error {
+	c := m.Lookup(item.Subcategory, item.SubcategoryName)
+	item.GoogleProductCategory = c.GoogleProductCategory
+	item.ProductType = c.ProductType
+	return nil
+}
@@ -0,0 +1,290 @@
+// Package testutil provides a fake Hasura GraphQL endpoint, so sources and
+// the pipeline can be exercised by integration tests without credentials.
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/graphql"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/validate"
+)
+
+// AdminSecret is the admin secret the fake expects by default
+const AdminSecret = "test-admin-secret"
+
+// Request is a GraphQL request received by the fake
+type Request struct {
+	Query     string
+	Variables map[string]any
+	Header    http.Header
+}
+
+// FakeHasura serves the queries of the hasura source from fixtures. The ads
+// query applies the status, category, updated_at and cursor filters and the
+// limit the way Hasura does; a fixture field left empty matches any filter.
+// Seller and brand filters are not applied. Set the fields before the first
+// request.
+type FakeHasura struct {
+	Server *httptest.Server
+
+	Ads []input.RawAd
+	// Names answers name lookups, by table and then ID
+	Names map[string]map[string]string
+	// Headers must be sent with every request, or it is denied like Hasura
+	// denies a wrong admin secret. It starts with X-Hasura-Admin-Secret set
+	// to AdminSecret.
+	Headers map[string]string
+	// Status, when set, answers every request with this HTTP status instead
+	Status int
+	// Failures, when positive, limits Status to that many requests, after
+	// which requests are answered normally
+	Failures int
+	// Errors are returned along with the data of every response
+	Errors []graphql.Error
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// tablePattern finds the table a query selects from
+var tablePattern = regexp.MustCompile(`\{\s*(\w+)\s*\(`)
+
+// NewFakeHasura starts a fake serving ads, stopped when the test ends
+func NewFakeHasura(t testing.TB, ads ...input.RawAd) *FakeHasura {
+	t.Helper()
+	f := &FakeHasura{
+		Ads:     ads,
+		Names:   map[string]map[string]string{},
+		Headers: map[string]string{"X-Hasura-Admin-Secret": AdminSecret},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Server.Close)
+	return f
+}
+
+// Ad returns a published ad with complete attributes: a bag in subcategory
+// with a price of 1200 paid online, and a GTIN derived from id
+func Ad(id, subcategory string) input.RawAd {
+	attributes := fmt.Sprintf(`{"stepsData": [
+		{"name": "search_product", "data": {"id": {"id": %q, "value": "Bags"}, "inputSearchValue": {"value": "Gucci Marmont bag %s"}}},
+		{"name": "product_detail", "data": {"values": {"brand": "Gucci", "price": "1200", "condition": "new",
+			"images": [{"src": "https://cdn.example.com/%s.jpg"}]}}},
+		{"name": "delivery_and_payment_methods", "data": {"paymentMethods": {"data": [{"value": "Online Payment"}]}}}
+	]}`, subcategory, id, id)
+	hash := fnv.New64a()
+	hash.Write([]byte(id))
+	body := fmt.Sprintf("400%09d", hash.Sum64()%1e9)
+	return input.RawAd{
+		ID:          id,
+		CodeNumber:  json.Number(body + string(validate.CheckDigit(body))),
+		Description: "Leather shoulder bag",
+		Attributes:  json.RawMessage(attributes),
+		UpdatedAt:   time.Now().Add(-time.Hour).UTC().Truncate(time.Second),
+	}
+}
+
+// URL returns the GraphQL endpoint
+func (f *FakeHasura) URL() string {
+	return f.Server.URL + "/v1/graphql"
+}
+
+// Configure points cfg at the fake, with the admin secret it expects
+func (f *FakeHasura) Configure(cfg *config.Config) {
+	cfg.Source.Type = config.SourceHasura
+	cfg.HasuraEndpoint = f.URL()
+	cfg.HasuraAuth.Mode = config.HasuraAuthAdminSecret
+	cfg.AdminSecret = f.Headers["X-Hasura-Admin-Secret"]
+}
+
+// Requests returns the requests received so far
+func (f *FakeHasura) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.requests)
+}
+
+// ExpectVariable fails the test unless every request for table sent the
+// variable name with a value equal to want once encoded as JSON, e.g. a
+// []any of strings for a list of IDs
+func (f *FakeHasura) ExpectVariable(t testing.TB, table, name string, want any) {
+	t.Helper()
+	wantJSON, _ := json.Marshal(want)
+	seen := false
+	for _, req := range f.Requests() {
+		if queryTable(req.Query) != table {
+			continue
+		}
+		seen = true
+		gotJSON, _ := json.Marshal(req.Variables[name])
+		if !jsonEqual(gotJSON, wantJSON) {
+			t.Errorf("%s query variable %s = %s, want %s", table, name, gotJSON, wantJSON)
+		}
+	}
+	if !seen {
+		t.Errorf("no %s query was received", table)
+	}
+}
+
+// ExpectHeader fails the test unless every request sent header name with
+// the value want
+func (f *FakeHasura) ExpectHeader(t testing.TB, name, want string) {
+	t.Helper()
+	requests := f.Requests()
+	if len(requests) == 0 {
+		t.Errorf("no request was received")
+	}
+	for _, req := range requests {
+		if got := req.Header.Get(name); got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func (f *FakeHasura) serve(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse("invalid request body", "invalid-json"))
+		return
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, Request{Query: body.Query, Variables: body.Variables, Header: r.Header.Clone()})
+	failing := f.Status != 0 && (f.Failures <= 0 || len(f.requests) <= f.Failures)
+	f.mu.Unlock()
+
+	if failing {
+		writeJSON(w, f.Status, errorResponse(http.StatusText(f.Status), "unexpected"))
+		return
+	}
+	for name, value := range f.Headers {
+		if r.Header.Get(name) != value {
+			writeJSON(w, http.StatusOK, errorResponse("invalid "+name, "access-denied"))
+			return
+		}
+	}
+
+	table := queryTable(body.Query)
+	var data any
+	switch table {
+	case "ads":
+		data = f.ads(body.Variables)
+	case "ads_by_pk":
+		data = f.adByID(body.Variables["id"])
+	default:
+		data = f.names(table, body.Variables["ids"])
+	}
+	response := map[string]any{"data": map[string]any{table: data}}
+	if len(f.Errors) > 0 {
+		response["errors"] = f.Errors
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// ads applies the filters of the ads query to the fixtures
+func (f *FakeHasura) ads(vars map[string]any) []input.RawAd {
+	status, _ := vars["status"].(string)
+	after, _ := vars["after"].(string)
+	categories := stringSet(vars["categoryIDs"])
+	var since time.Time
+	if s, ok := vars["since"].(string); ok {
+		since, _ = time.Parse(time.RFC3339, s)
+	}
+
+	var ads []input.RawAd
+	for _, ad := range f.Ads {
+		switch {
+		case status != "" && ad.Status != "" && ad.Status != status,
+			categories != nil && ad.CategoryID != "" && !categories[ad.CategoryID],
+			!since.IsZero() && !ad.UpdatedAt.IsZero() && ad.UpdatedAt.Before(since),
+			ad.ID <= after:
+			continue
+		}
+		ads = append(ads, ad)
+	}
+	sort.Slice(ads, func(i, j int) bool { return ads[i].ID < ads[j].ID })
+	if limit, ok := vars["limit"].(float64); ok && int(limit) < len(ads) {
+		ads = ads[:int(limit)]
+	}
+	if ads == nil {
+		ads = []input.RawAd{}
+	}
+	return ads
+}
+
+// adByID returns the fixture with the ID, or nil
+func (f *FakeHasura) adByID(id any) *input.RawAd {
+	for _, ad := range f.Ads {
+		if ad.ID == id {
+			return &ad
+		}
+	}
+	return nil
+}
+
+// names returns the id and name rows of table for the IDs asked for
+func (f *FakeHasura) names(table string, ids any) []map[string]string {
+	rows := []map[string]string{}
+	for id := range stringSet(ids) {
+		if name, ok := f.Names[table][id]; ok {
+			rows = append(rows, map[string]string{"id": id, "name": name})
+		}
+	}
+	return rows
+}
+
+// queryTable returns the table a query selects from
+func queryTable(query string) string {
+	if m := tablePattern.FindStringSubmatch(query); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// stringSet returns the strings of a list variable, or nil when it is absent
+func stringSet(v any) map[string]bool {
+	list, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	set := make(map[string]bool, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+func jsonEqual(a, b []byte) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return strings.TrimSpace(string(a)) == strings.TrimSpace(string(b))
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func errorResponse(message, code string) map[string]any {
+	return map[string]any{"errors": []graphql.Error{{Message: message, Extensions: map[string]any{"code": code}}}}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,138 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/model/input"
+)
+
+// titleFields are the placeholders a title template can use
+var titleFields = map[string]func(item *input.AdItem) string{
+	"brand":       func(item *input.AdItem) string { return item.Brand },
+	"title":       func(item *input.AdItem) string { return item.Title },
+	"color":       func(item *input.AdItem) string { return item.Color },
+	"size":        func(item *input.AdItem) string { return item.Size },
+	"material":    func(item *input.AdItem) string { return item.Material },
+	"gender":      func(item *input.AdItem) string { return item.Gender },
+	"condition":   func(item *input.AdItem) string { return item.Condition },
+	"mpn":         func(item *input.AdItem) string { return item.MPN },
+	"subcategory": func(item *input.AdItem) string { return item.SubcategoryName },
+}
+
+// titlePart is a placeholder of a title template with the text before it
+type titlePart struct {
+	prefix string
+	field  string
+}
+
+// titleTemplate is a parsed config.Config.TitleTemplate
+type titleTemplate struct {
+	parts  []titlePart
+	suffix string // text after the last placeholder
+}
+
+// parseTitle splits a template into its placeholders. It must use {title},
+// and only the placeholders in titleFields.
+func parseTitle(template string) (*titleTemplate, error) {
+	t := &titleTemplate{}
+	rest := template
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in %q", template)
+		}
+		field := rest[start+1 : start+end]
+		if _, ok := titleFields[field]; !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s} in %q", field, template)
+		}
+		t.parts = append(t.parts, titlePart{prefix: rest[:start], field: field})
+		rest = rest[start+end+1:]
+	}
+	t.suffix = rest
+	for _, p := range t.parts {
+		if p.field == "title" {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("%q does not use {title}", template)
+}
+
+// render builds the title of item from the parts in use. A missing part
+// is left out with the text before it, and two parts that remain are joined
+// by the text before the first part that followed the earlier one, so
+// "{title} - {color} {size}" without a color gives "title - size". Parts the
+// ad's title already mentions, such as its brand, are left out too.
+func (t *titleTemplate) render(item *input.AdItem, use []bool) string {
+	var b strings.Builder
+	sep, chosen := "", false
+	for i, p := range t.parts {
+		if !chosen {
+			sep, chosen = p.prefix, true
+		}
+		value := strings.TrimSpace(titleFields[p.field](item))
+		if !use[i] || value == "" || (p.field != "title" && mentions(item.Title, value)) {
+			continue
+		}
+		b.WriteString(sep)
+		b.WriteString(value)
+		chosen = false
+	}
+	if !chosen {
+		b.WriteString(t.suffix)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// mentions reports whether the words of value appear in order in title,
+// ignoring case and punctuation
+func mentions(title, value string) bool {
+	words := func(s string) string {
+		return " " + strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}), " ") + " "
+	}
+	v := words(value)
+	return v != "  " && strings.Contains(words(title), v)
+}
+
+// Title returns a transformer that builds the item titles from
+// cfg.TitleTemplate, or nil when no template is set. A title longer than
+// LengthLimits allows drops the template's other parts, last first, until it
+// fits; the ad's title alone is left for Truncate.
+func Title(cfg *config.Config) (func(item *input.AdItem) error, error) {
+	if cfg.TitleTemplate == "" {
+		return nil, nil
+	}
+	t, err := parseTitle(cfg.TitleTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("title template: %w", err)
+	}
+	limit := cfg.LengthLimits[config.FieldTitle]
+
+	return func(item *input.AdItem) error {
+		use := make([]bool, len(t.parts))
+		for i := range use {
+			use[i] = true
+		}
+		title := t.render(item, use)
+		for i := len(t.parts) - 1; i >= 0 && limit > 0 && utf8.RuneCountInString(title) > limit; i-- {
+			if t.parts[i].field == "title" {
+				continue
+			}
+			use[i] = false
+			title = t.render(item, use)
+		}
+		if title != "" {
+			item.Title = title
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,161 @@
+// Package transform holds the per-item steps that prepare parsed ads for the
+// output feeds.
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"unicode/utf8"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/metrics"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/sanitize"
+	"go_data_fashion_accessories/validate"
+)
+
+// Function to clean up the punctuation and length of the description. Tags
+// and extra whitespace are removed beforehand by Sanitize.
+func cleanUpDescription(description string) string {
+	// Ensure proper punctuation between sentences
+	cleaned := strings.ReplaceAll(description, ". ", ".")
+	cleaned = strings.ReplaceAll(cleaned, ".", ". ")
+	// Replace multiple spaces/newlines with a single space
+	cleaned = strings.TrimSpace(strings.Join(strings.Fields(cleaned), " "))
+
+	// Check description length (Google Merchant Center recommends at least 30 characters)
+	if len(cleaned) < 30 {
+		cleaned += " This product is of high quality and in stock."
+	}
+
+	return cleaned
+}
+
+// Prepare returns a transformer that keys the item by its code number,
+// validates the code as a GTIN and cleans up the description. An invalid
+// code is logged to logger with its reason and left out of the GTIN field.
+// Items without a code number keep the ad ID. The result satisfies
+// pipeline.Transformer.
+func Prepare(logger *slog.Logger) func(item *input.AdItem) error {
+	logger = logging.OrDefault(logger)
+	return func(item *input.AdItem) error {
+		if item.CodeNumber != "" {
+			gtin, err := validate.NormalizeGTIN(item.CodeNumber.String())
+			if err != nil {
+				logger.Warn("Invalid GTIN",
+					logging.AdID, item.AdID, logging.DraftID, item.DraftID,
+					logging.Reason, gtinReason(err), "error", err)
+			}
+
+			// Merchant Center items are keyed by the product code number
+			item.ID = item.CodeNumber.String()
+			item.GTIN = gtin
+		}
+
+		// Clean up the description before adding it to the output
+		item.Description = cleanUpDescription(item.Description)
+		return nil
+	}
+}
+
+// Sanitize returns a transformer that runs the title, description and brand
+// through the cleanup chains configured for them in cfg.Sanitize, removing
+// emoji after the chains when cfg.StripEmoji is set
+func Sanitize(cfg *config.Config) (func(item *input.AdItem) error, error) {
+	chains := map[string]sanitize.Chain{}
+	for field, steps := range cfg.Sanitize {
+		chain, err := sanitize.Parse(steps)
+		if err != nil {
+			return nil, fmt.Errorf("sanitize %s: %w", field, err)
+		}
+		chains[field] = chain
+	}
+	if cfg.StripEmoji {
+		for _, field := range []string{config.FieldTitle, config.FieldDescription, config.FieldBrand} {
+			chains[field] = append(chains[field], sanitize.RemoveEmoji, sanitize.NormalizeSpace)
+		}
+	}
+	title, description, brand := chains[config.FieldTitle], chains[config.FieldDescription], chains[config.FieldBrand]
+	return func(item *input.AdItem) error {
+		item.Title = title.Apply(item.Title)
+		item.Description = description.Apply(item.Description)
+		item.Brand = brand.Apply(item.Brand)
+		if item.Translations != nil {
+			// Variants share the map of their ad
+			item.Translations = maps.Clone(item.Translations)
+			for lang, text := range item.Translations {
+				item.Translations[lang] = input.Text{Title: title.Apply(text.Title), Description: description.Apply(text.Description)}
+			}
+		}
+		return nil
+	}, nil
+}
+
+// Truncate returns a transformer that cuts fields longer than their limit
+// in cfg.LengthLimits on a word boundary, ending them with an ellipsis. Every
+// truncated field is logged to logger and counted in the metrics so the ads
+// can be fixed at the source.
+func Truncate(cfg *config.Config, logger *slog.Logger) func(item *input.AdItem) error {
+	logger = logging.OrDefault(logger)
+	fields := []struct {
+		name  string
+		value func(item *input.AdItem) *string
+	}{
+		{config.FieldTitle, func(item *input.AdItem) *string { return &item.Title }},
+		{config.FieldDescription, func(item *input.AdItem) *string { return &item.Description }},
+		{config.FieldBrand, func(item *input.AdItem) *string { return &item.Brand }},
+		{config.FieldProductType, func(item *input.AdItem) *string { return &item.ProductType }},
+		{config.FieldColor, func(item *input.AdItem) *string { return &item.Color }},
+		{config.FieldSize, func(item *input.AdItem) *string { return &item.Size }},
+		{config.FieldMaterial, func(item *input.AdItem) *string { return &item.Material }},
+	}
+	limits := map[string]sanitize.Sanitizer{}
+	for _, field := range fields {
+		if limit := cfg.LengthLimits[field.name]; limit > 0 {
+			limits[field.name] = sanitize.Truncate(limit, sanitize.Ellipsis)
+		}
+	}
+
+	return func(item *input.AdItem) error {
+		for _, field := range fields {
+			truncate, ok := limits[field.name]
+			if !ok {
+				continue
+			}
+			value := field.value(item)
+			if cut := truncate(*value); cut != *value {
+				logger.Warn("Truncated field",
+					logging.AdID, item.AdID, logging.DraftID, item.DraftID,
+					"field", field.name, "length", utf8.RuneCountInString(*value), "limit", cfg.LengthLimits[field.name])
+				metrics.FieldsTruncated.WithLabelValues(field.name).Inc()
+				*value = cut
+			}
+		}
+		if item.Translations != nil {
+			item.Translations = maps.Clone(item.Translations)
+			for lang, text := range item.Translations {
+				if truncate, ok := limits[config.FieldTitle]; ok {
+					text.Title = truncate(text.Title)
+				}
+				if truncate, ok := limits[config.FieldDescription]; ok {
+					text.Description = truncate(text.Description)
+				}
+				item.Translations[lang] = text
+			}
+		}
+		return nil
+	}
+}
+
+// gtinReason returns the GTIN error reason code, for log fields
+func gtinReason(err error) string {
+	var gtinErr *validate.GTINError
+	if errors.As(err, &gtinErr) {
+		return string(gtinErr.Reason)
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+// Package translate fills in the title and description of items in the
+// content languages their ads give no text in, through a Translator hook.
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+)
+
+// Translator translates text from one ISO 639-1 language to another
+type Translator interface {
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+// HTTP is a Translator calling an endpoint that is POSTed
+// {"text", "source", "target"} and answers {"text"}
+type HTTP struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+}
+
+// NewHTTP returns a Translator for the endpoint at url, sending headers
+// with every request. A nil client uses http.DefaultClient.
+func NewHTTP(client *http.Client, url string, headers map[string]string) *HTTP {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTP{client: client, url: url, headers: headers}
+}
+
+// Translate implements Translator
+func (t *HTTP) Translate(ctx context.Context, text, source, target string) (string, error) {
+	body, err := json.Marshal(map[string]string{"text": text, "source": source, "target": target})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("translating to %s: %s", target, res.Status)
+	}
+	var answer struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&answer); err != nil {
+		return "", fmt.Errorf("translating to %s: %w", target, err)
+	}
+	return answer.Text, nil
+}
+
+// Filler sets the title and description items lack in each of its
+// languages from a Translator. Every text is translated once per run; a
+// text that cannot be translated is logged and left in the source
+// language.
+type Filler struct {
+	ctx        context.Context
+	translator Translator // nil leaves every missing text in the source language
+	source     string
+	languages  []string
+	logger     *slog.Logger
+	cache      map[[2]string]string // by target language and source text
+}
+
+// NewFiller returns a Filler translating from source into languages with
+// ctx. A nil logger uses slog.Default().
+func NewFiller(ctx context.Context, translator Translator, source string, languages []string, logger *slog.Logger) *Filler {
+	return &Filler{
+		ctx:        ctx,
+		translator: translator,
+		source:     source,
+		languages:  languages,
+		logger:     logging.OrDefault(logger),
+		cache:      map[[2]string]string{},
+	}
+}
+
+// Apply fills in the item's Translations. It satisfies
+// pipeline.Transformer.
+func (f *Filler) Apply(item *input.AdItem) error {
+	if f.translator == nil {
+		return nil
+	}
+	// Variants share the map of their ad
+	translations := maps.Clone(item.Translations)
+	if translations == nil {
+		translations = map[string]input.Text{}
+	}
+	for _, lang := range f.languages {
+		text := translations[lang]
+		if text.Title == "" {
+			text.Title = f.translate(item, item.Title, lang)
+		}
+		if text.Description == "" {
+			text.Description = f.translate(item, item.Description, lang)
+		}
+		if text != (input.Text{}) {
+			translations[lang] = text
+		}
+	}
+	item.Translations = translations
+	return nil
+}
+
+// translate returns text in lang, or "" when it cannot be translated
+func (f *Filler) translate(item *input.AdItem, text, lang string) string {
+	if text == "" {
+		return ""
+	}
+	key := [2]string{lang, text}
+	if translated, ok := f.cache[key]; ok {
+		return translated
+	}
+	translated, err := f.translator.Translate(f.ctx, text, f.source, lang)
+	if err != nil {
+		f.logger.Warn("Error translating item text, keeping it in the source language",
+			logging.AdID, item.AdID, "language", lang, "error", err)
+		// Not cached, so the next item tries again
+		return ""
+	}
+	f.cache[key] = translated
+	return translated
+}
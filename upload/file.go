@@ -0,0 +1,43 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	Register("file", FileUploader{})
+}
+
+// FileUploader copies feeds to a local or mounted path, e.g.
+// file:///srv/feeds/products.xml. The copy is written next to the target and
+// renamed into place.
+type FileUploader struct{}
+
+// Upload implements Uploader
+func (FileUploader) Upload(ctx context.Context, localPath string, dest *url.URL) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	target := dest.Path
+	tmp, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
@@ -0,0 +1,95 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/googleauth"
+	"go_data_fashion_accessories/httpclient"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/retry"
+)
+
+// gcsScope is the OAuth scope for writing objects
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+func init() {
+	Register("gs", &GCSUploader{Config: config.DefaultObjectStorage, HTTP: config.DefaultHTTP})
+}
+
+// GCSUploader puts feeds into a Google Cloud Storage bucket, e.g.
+// gs://feeds-bucket/google/, through the XML API, authenticating with the
+// service account key in GCSCredentialsFile
+type GCSUploader struct {
+	Config config.ObjectStorage
+	Retry  config.Retry
+	HTTP   config.HTTP
+	Logger *slog.Logger
+}
+
+// NewGCSUploader returns an uploader using the settings in
+// cfg.Upload.ObjectStorage and cfg.HTTP
+func NewGCSUploader(cfg *config.Config, logger *slog.Logger) *GCSUploader {
+	return &GCSUploader{Config: cfg.Upload.ObjectStorage, Retry: cfg.Retry, HTTP: cfg.HTTP, Logger: logger}
+}
+
+// Upload implements Uploader
+func (u *GCSUploader) Upload(ctx context.Context, localPath string, dest *url.URL) error {
+	if u.Config.GCSCredentialsFile == "" {
+		return errors.New("gcs: no GCSCredentialsFile configured")
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	base, err := httpclient.NewRetrying(u.HTTP, 5*time.Minute)
+	if err != nil {
+		return err
+	}
+	client, err := googleauth.Client(ctx, u.Config.GCSCredentialsFile, base, gcsScope)
+	if err != nil {
+		return err
+	}
+
+	key := objectKey(u.Config, dest, time.Now())
+	target := strings.TrimSuffix(u.Config.GCSEndpoint, "/") + "/" + dest.Host + "/" + escapeKey(key)
+
+	policy := u.Retry
+	if policy.MaxAttempts == 0 {
+		policy = config.DefaultRetry
+	}
+	logger := logging.OrDefault(u.Logger)
+	err = retry.Do(ctx, logger, policy, "GCS upload to "+target, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", objectContentType(u.Config, localPath))
+		if u.Config.CacheControl != "" {
+			req.Header.Set("Cache-Control", u.Config.CacheControl)
+		}
+		if u.Config.PublicRead {
+			req.Header.Set("X-Goog-Acl", "public-read")
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		return checkStatus(res)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("Uploaded object", "url", target, "public", u.Config.PublicRead)
+	return nil
+}
@@ -0,0 +1,72 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/httpclient"
+)
+
+func init() {
+	Register("http", HTTPUploader{HTTP: config.DefaultHTTP})
+	Register("https", HTTPUploader{HTTP: config.DefaultHTTP})
+}
+
+// HTTPUploader PUTs feeds to an HTTP endpoint. User info in the URL is sent
+// as basic auth.
+type HTTPUploader struct {
+	HTTP   config.HTTP // connections made when Client is nil
+	Client *http.Client
+}
+
+// Upload implements Uploader
+func (h HTTPUploader) Upload(ctx context.Context, localPath string, dest *url.URL) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	target := *dest
+	target.User = nil
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	if contentType := mime.TypeByExtension(filepath.Ext(localPath)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if dest.User != nil {
+		password, _ := dest.User.Password()
+		req.SetBasicAuth(dest.User.Username(), password)
+	}
+
+	client := h.Client
+	if client == nil {
+		if client, err = httpclient.New(h.HTTP); err != nil {
+			return err
+		}
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("PUT %s: unexpected status %s", target.String(), res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/config"
+)
+
+// objectKey returns the key of the object for dest: the directory part of
+// the URL path followed by the expanded key template, so
+// s3://bucket/feeds/products.xml with "{date}/{file}" becomes
+// feeds/2024-05-01/products.xml
+func objectKey(cfg config.ObjectStorage, dest *url.URL, now time.Time) string {
+	dir, file := path.Split(strings.TrimPrefix(dest.Path, "/"))
+	now = now.UTC()
+	return dir + strings.NewReplacer(
+		"{file}", file,
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+	).Replace(cfg.KeyTemplate)
+}
+
+// objectContentType returns the content type configured for the extension
+// of localPath, falling back to the system's MIME table
+func objectContentType(cfg config.ObjectStorage, localPath string) string {
+	ext := filepath.Ext(localPath)
+	if contentType, ok := cfg.ContentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// escapeKey percent-encodes every byte of an object key other than
+// unreserved characters and slashes, the encoding S3 signatures expect
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// checkStatus turns a response other than 2xx into an error carrying the
+// start of the body, where storage services explain what went wrong
+func checkStatus(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("%s %s: unexpected status %s: %s", res.Request.Method, res.Request.URL.Redacted(), res.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,105 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go_data_fashion_accessories/awssign"
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/httpclient"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/retry"
+)
+
+func init() {
+	Register("s3", &S3Uploader{Config: config.DefaultObjectStorage, HTTP: config.DefaultHTTP})
+}
+
+// S3Uploader puts feeds into an S3 bucket, e.g. s3://feeds-bucket/google/,
+// signing requests with AWS Signature Version 4. Credentials come from
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and, for temporary credentials,
+// AWS_SESSION_TOKEN.
+type S3Uploader struct {
+	Config config.ObjectStorage
+	Retry  config.Retry
+	HTTP   config.HTTP // connections made when Client is nil
+	Logger *slog.Logger
+	Client *http.Client
+}
+
+// NewS3Uploader returns an uploader using the settings in
+// cfg.Upload.ObjectStorage and cfg.HTTP
+func NewS3Uploader(cfg *config.Config, logger *slog.Logger) *S3Uploader {
+	return &S3Uploader{Config: cfg.Upload.ObjectStorage, Retry: cfg.Retry, HTTP: cfg.HTTP, Logger: logger}
+}
+
+// Upload implements Uploader
+func (u *S3Uploader) Upload(ctx context.Context, localPath string, dest *url.URL) error {
+	creds, err := awssign.EnvCredentials()
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+
+	key := objectKey(u.Config, dest, time.Now())
+	target := u.objectURL(dest.Host, key)
+	header := http.Header{}
+	header.Set("Content-Type", objectContentType(u.Config, localPath))
+	if u.Config.CacheControl != "" {
+		header.Set("Cache-Control", u.Config.CacheControl)
+	}
+	if u.Config.PublicRead {
+		header.Set("X-Amz-Acl", "public-read")
+	}
+
+	client := u.Client
+	if client == nil {
+		if client, err = httpclient.NewRetrying(u.HTTP, 5*time.Minute); err != nil {
+			return err
+		}
+	}
+	policy := u.Retry
+	if policy.MaxAttempts == 0 {
+		policy = config.DefaultRetry
+	}
+	logger := logging.OrDefault(u.Logger)
+	err = retry.Do(ctx, logger, policy, "S3 upload to "+target, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header = header.Clone()
+		awssign.Sign(req, data, creds, u.Config.S3Region, "s3", time.Now())
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		return checkStatus(res)
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("Uploaded object", "url", target, "public", u.Config.PublicRead)
+	return nil
+}
+
+// objectURL returns the URL of key in bucket: virtual-hosted style on AWS,
+// path style on a custom endpoint
+func (u *S3Uploader) objectURL(bucket, key string) string {
+	if u.Config.S3Endpoint != "" {
+		return strings.TrimSuffix(u.Config.S3Endpoint, "/") + "/" + bucket + "/" + escapeKey(key)
+	}
+	return "https://" + bucket + ".s3." + u.Config.S3Region + ".amazonaws.com/" + escapeKey(key)
+}
@@ -0,0 +1,185 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/retry"
+)
+
+func init() {
+	Register("sftp", &SFTPUploader{})
+}
+
+// SFTPUploader copies feeds to a partner's SFTP server, e.g.
+// sftp://feeds@sftp.example.com/incoming/products.xml. The file is written
+// under a temporary name and renamed into place, so the partner never picks
+// up a partial upload. Failed uploads are retried from the start.
+type SFTPUploader struct {
+	Config config.SFTP
+	Retry  config.Retry
+	Logger *slog.Logger
+}
+
+// NewSFTPUploader returns an uploader authenticating with the key or
+// password in cfg.Upload.SFTP
+func NewSFTPUploader(cfg *config.Config, logger *slog.Logger) *SFTPUploader {
+	return &SFTPUploader{Config: cfg.Upload.SFTP, Retry: cfg.Retry, Logger: logger}
+}
+
+// Upload implements Uploader
+func (u *SFTPUploader) Upload(ctx context.Context, localPath string, dest *url.URL) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	clientConfig, err := u.clientConfig(dest)
+	if err != nil {
+		return err
+	}
+
+	policy := u.Retry
+	if policy.MaxAttempts == 0 {
+		policy = config.DefaultRetry
+	}
+	logger := u.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return retry.Do(ctx, logger, policy, "SFTP upload to "+dest.Redacted(), func() error {
+		return u.put(ctx, clientConfig, dest, data)
+	})
+}
+
+// clientConfig returns the SSH settings for dest: the user from the URL,
+// the key file and password from the config or the URL, and the known hosts
+// file to check the server against
+func (u *SFTPUploader) clientConfig(dest *url.URL) (*ssh.ClientConfig, error) {
+	cfg := &ssh.ClientConfig{
+		User:    u.Config.User,
+		Timeout: 30 * time.Second,
+	}
+	if dest.User != nil {
+		cfg.User = dest.User.Username()
+	}
+	if cfg.User == "" {
+		return nil, errors.New("sftp: no user in the destination URL or config")
+	}
+
+	if u.Config.KeyFile != "" {
+		pem, err := os.ReadFile(u.Config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: reading key %s: %w", u.Config.KeyFile, err)
+		}
+		cfg.Auth = append(cfg.Auth, ssh.PublicKeys(signer))
+	}
+	password := u.Config.Password
+	if p, ok := dest.User.Password(); ok {
+		password = p
+	}
+	if password != "" {
+		cfg.Auth = append(cfg.Auth, ssh.Password(password))
+	}
+	if len(cfg.Auth) == 0 {
+		return nil, errors.New("sftp: no key file or password configured")
+	}
+
+	switch {
+	case u.Config.KnownHostsFile != "":
+		callback, err := knownhosts.New(u.Config.KnownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: reading known hosts: %w", err)
+		}
+		cfg.HostKeyCallback = callback
+	case u.Config.InsecureIgnoreHostKey:
+		cfg.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, errors.New("sftp: KnownHostsFile is required to verify the server")
+	}
+	return cfg, nil
+}
+
+// put connects, writes data to a temporary file next to the target and
+// renames it over the target
+func (u *SFTPUploader) put(ctx context.Context, cfg *ssh.ClientConfig, dest *url.URL, data []byte) error {
+	addr := dest.Host
+	if dest.Port() == "" {
+		addr = net.JoinHostPort(dest.Hostname(), "22")
+	}
+	dialer := net.Dialer{Timeout: cfg.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	// Unblock the transfer if the run is cancelled
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	session, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("sftp: starting the subsystem: %w", err)
+	}
+	defer session.Close()
+
+	target := dest.Path
+	tmp := path.Join(path.Dir(target), "."+path.Base(target)+"."+strconv.FormatInt(time.Now().UnixNano(), 36)+".tmp")
+	if err := writeFile(session, tmp, data); err != nil {
+		session.Remove(tmp)
+		return err
+	}
+	if err := rename(session, tmp, target); err != nil {
+		session.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// writeFile creates or truncates path and writes data to it
+func writeFile(session *sftp.Client, path string, data []byte) error {
+	file, err := session.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err := file.ReadFrom(bytes.NewReader(data)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// rename moves from to to, replacing to if it exists. Servers without the
+// posix-rename extension follow SFTP v3, whose renames fail when the target
+// exists, so it is removed first.
+func rename(session *sftp.Client, from, to string) error {
+	if _, ok := session.HasExtension("posix-rename@openssh.com"); ok {
+		return session.PosixRename(from, to)
+	}
+	session.Remove(to)
+	return session.Rename(from, to)
+}
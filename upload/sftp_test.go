@@ -0,0 +1,215 @@
+package upload
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"go_data_fashion_accessories/config"
+)
+
+// sftpServer serves an in-memory SFTP file system over SSH on a local
+// port, for user feeds with password secret
+func sftpServer(t *testing.T) *url.URL {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "feeds" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	handlers := sftp.InMemHandler()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, serverConfig, handlers)
+		}
+	}()
+	return &url.URL{Scheme: "sftp", Host: listener.Addr().String()}
+}
+
+// serveSSH answers the sftp subsystem requests of one connection
+func serveSSH(conn net.Conn, serverConfig *ssh.ServerConfig, handlers sftp.Handlers) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						server := sftp.NewRequestServer(channel, handlers)
+						server.Serve()
+						server.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// upload sends data to path on the server at base with an SFTPUploader
+func upload(t *testing.T, base *url.URL, path, data string) error {
+	t.Helper()
+	local := filepath.Join(t.TempDir(), "feed.xml")
+	if err := os.WriteFile(local, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dest := *base
+	dest.User = url.UserPassword("feeds", "secret")
+	dest.Path = path
+	u := &SFTPUploader{
+		Config: config.SFTP{InsecureIgnoreHostKey: true},
+		Retry:  config.Retry{MaxAttempts: 1},
+	}
+	return u.Upload(context.Background(), local, &dest)
+}
+
+// files returns the files in dir on the server at base, with their contents
+func files(t *testing.T, base *url.URL, dir string) map[string]string {
+	t.Helper()
+	client, err := ssh.Dial("tcp", base.Host, &ssh.ClientConfig{
+		User:            "feeds",
+		Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	session, err := sftp.NewClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	entries, err := session.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		file, err := session.Open(dir + "/" + entry.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[entry.Name()] = string(data)
+	}
+	return out
+}
+
+func TestSFTPUpload(t *testing.T) {
+	server := sftpServer(t)
+	// Bigger than a write request, so the file takes several
+	data := strings.Repeat("<item>Gucci bag</item>\n", 5000)
+	if err := upload(t, server, "/products.xml", data); err != nil {
+		t.Fatal(err)
+	}
+	got := files(t, server, "/")
+	if len(got) != 1 || got["products.xml"] != data {
+		t.Fatalf("server has %d files, products.xml of %d bytes; want only products.xml of %d bytes", len(got), len(got["products.xml"]), len(data))
+	}
+}
+
+func TestSFTPUploadReplacesWithPosixRename(t *testing.T) {
+	server := sftpServer(t)
+	if err := upload(t, server, "/products.xml", "old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload(t, server, "/products.xml", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if got := files(t, server, "/"); len(got) != 1 || got["products.xml"] != "new" {
+		t.Fatalf("server files = %v, want only products.xml with new", got)
+	}
+}
+
+func TestSFTPUploadReplacesWithoutPosixRename(t *testing.T) {
+	// The in-memory server's plain rename fails when the target exists, as
+	// SFTP v3 says, so the upload has to remove it first
+	if err := sftp.SetSFTPExtensions("hardlink@openssh.com", "statvfs@openssh.com"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sftp.SetSFTPExtensions("hardlink@openssh.com", "posix-rename@openssh.com", "statvfs@openssh.com")
+	})
+
+	server := sftpServer(t)
+	if err := upload(t, server, "/products.xml", "old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload(t, server, "/products.xml", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if got := files(t, server, "/"); len(got) != 1 || got["products.xml"] != "new" {
+		t.Fatalf("server files = %v, want only products.xml with new", got)
+	}
+}
+
+func TestSFTPUploadRejectsWrongPassword(t *testing.T) {
+	server := sftpServer(t)
+	local := filepath.Join(t.TempDir(), "feed.xml")
+	if err := os.WriteFile(local, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dest := *server
+	dest.User = url.UserPassword("feeds", "wrong")
+	dest.Path = "/products.xml"
+	u := &SFTPUploader{Config: config.SFTP{InsecureIgnoreHostKey: true}, Retry: config.Retry{MaxAttempts: 1}}
+	if err := u.Upload(context.Background(), local, &dest); err == nil {
+		t.Fatal("upload with a wrong password succeeded")
+	}
+}
@@ -0,0 +1,59 @@
+// Package upload pushes generated feed files to their destinations. The
+// destination is a URL and its scheme selects the Uploader.
+package upload
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Uploader copies a local file to a destination URL
+type Uploader interface {
+	Upload(ctx context.Context, localPath string, dest *url.URL) error
+}
+
+var (
+	mu        sync.RWMutex
+	uploaders = map[string]Uploader{}
+)
+
+// Register makes an Uploader available for a URL scheme. Registering a
+// scheme twice replaces the earlier Uploader.
+func Register(scheme string, u Uploader) {
+	mu.Lock()
+	defer mu.Unlock()
+	uploaders[scheme] = u
+}
+
+// Schemes returns the registered URL schemes in sorted order
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	schemes := make([]string, 0, len(uploaders))
+	for scheme := range uploaders {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Upload sends localPath to dest using the Uploader registered for the
+// scheme of dest
+func Upload(ctx context.Context, localPath, dest string) error {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return fmt.Errorf("invalid destination %q: %w", dest, err)
+	}
+
+	mu.RLock()
+	uploader, ok := uploaders[u.Scheme]
+	mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no uploader for scheme %q (have %v)", u.Scheme, Schemes())
+	}
+
+	return uploader.Upload(ctx, localPath, u)
+}
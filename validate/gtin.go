@@ -0,0 +1,89 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GTINReason says why a code is not a usable GTIN
+type GTINReason string
+
+const (
+	GTINEmpty         GTINReason = "empty"
+	GTINNotNumeric    GTINReason = "not numeric"
+	GTINBadLength     GTINReason = "length is not 8, 12, 13 or 14 digits"
+	GTINBadCheckDigit GTINReason = "check digit does not match"
+	GTINRestricted    GTINReason = "restricted or coupon prefix"
+)
+
+// GTINError reports a code that failed validation
+type GTINError struct {
+	Code   string
+	Reason GTINReason
+}
+
+func (e *GTINError) Error() string {
+	return fmt.Sprintf("invalid GTIN %q: %s", e.Code, e.Reason)
+}
+
+// NormalizeGTIN validates a GTIN-8, UPC-A (GTIN-12), EAN (GTIN-13) or
+// GTIN-14 code, including its check digit. Spaces and dashes are ignored.
+// UPC-A codes are returned padded to GTIN-13; other lengths are returned
+// as-is. Invalid codes return a *GTINError.
+func NormalizeGTIN(code string) (string, error) {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+	if digits == "" {
+		return "", &GTINError{Code: code, Reason: GTINEmpty}
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", &GTINError{Code: code, Reason: GTINNotNumeric}
+		}
+	}
+
+	switch len(digits) {
+	case 8, 13, 14:
+	case 12:
+		digits = "0" + digits
+	default:
+		return "", &GTINError{Code: code, Reason: GTINBadLength}
+	}
+
+	body, check := digits[:len(digits)-1], digits[len(digits)-1]
+	if CheckDigit(body) != check {
+		return "", &GTINError{Code: code, Reason: GTINBadCheckDigit}
+	}
+
+	if restricted(digits) {
+		return "", &GTINError{Code: code, Reason: GTINRestricted}
+	}
+
+	return digits, nil
+}
+
+// CheckDigit computes the GS1 check digit for a GTIN body (the code without
+// its last digit): digits are weighted 3 and 1 alternately from the right
+func CheckDigit(body string) byte {
+	sum := 0
+	for i := len(body) - 1; i >= 0; i-- {
+		digit := int(body[i] - '0')
+		if (len(body)-1-i)%2 == 0 {
+			sum += digit * 3
+		} else {
+			sum += digit
+		}
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// restricted reports codes from GS1 ranges that are not valid for retail
+// products: restricted circulation (2xx) and coupons (98x, 99x)
+func restricted(gtin string) bool {
+	if len(gtin) == 14 {
+		gtin = gtin[1:]
+	}
+	if len(gtin) != 13 {
+		return false
+	}
+	return gtin[0] == '2' || strings.HasPrefix(gtin, "98") || strings.HasPrefix(gtin, "99")
+}
@@ -0,0 +1,34 @@
+package validate
+
+import (
+	"context"
+	"fmt"
+
+	"go_data_fashion_accessories/imagecheck"
+	"go_data_fashion_accessories/model/input"
+)
+
+// Images requests the image links of item with images and returns an issue
+// for every one that cannot be fetched or is too small, with minSide the
+// smallest width and height accepted. Only a cancelled ctx is an error.
+func Images(ctx context.Context, images *imagecheck.Checker, minSide int, item input.AdItem) ([]Issue, error) {
+	links := []struct{ name, value string }{{"image_link", item.ImageLink}}
+	for i, link := range item.AdditionalImageLinks {
+		links = append(links, struct{ name, value string }{fmt.Sprintf("additional_image_link[%d]", i), link})
+	}
+
+	var issues []Issue
+	for _, link := range links {
+		if link.value == "" {
+			continue
+		}
+		r, err := images.Check(ctx, link.value)
+		if err != nil {
+			return nil, err
+		}
+		if problem := images.Problem(r, minSide); problem != "" {
+			issues = append(issues, Issue{ItemID: item.ID, Field: link.name, Message: "cannot be used: " + problem})
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,109 @@
+package validate
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Report collects the issues of every item checked, for CI systems to
+// gate a feed on
+type Report struct {
+	Items  int     `json:"items"`  // items checked
+	Failed int     `json:"failed"` // items with at least one issue
+	Issues []Issue `json:"issues"`
+}
+
+// Report formats accepted by Write
+const (
+	ReportJSON  = "json"
+	ReportJUnit = "junit"
+)
+
+// Add records one checked item and its issues
+func (r *Report) Add(issues []Issue) {
+	r.Items++
+	if len(issues) > 0 {
+		r.Failed++
+		r.Issues = append(r.Issues, issues...)
+	}
+}
+
+// Write encodes the report to w in format
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case ReportJSON:
+		report := *r
+		if report.Issues == nil {
+			report.Issues = []Issue{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case ReportJUnit:
+		return r.writeJUnit(w)
+	default:
+		return fmt.Errorf("unknown report format %q (want %s or %s)", format, ReportJSON, ReportJUnit)
+	}
+}
+
+// junitSuite is the JUnit XML most CI systems read test results from
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes the report as a JUnit test suite with one failed test
+// case per item with issues. Items without issues are counted in the tests
+// but not listed, to keep the file small for large feeds.
+func (r *Report) writeJUnit(w io.Writer) error {
+	suite := junitSuite{Name: "feed validation", Tests: r.Items, Failures: r.Failed}
+	var fields, lines []string
+	flush := func() {
+		if len(lines) == 0 {
+			return
+		}
+		suite.Cases[len(suite.Cases)-1].Failure = &junitFailure{
+			Message: "invalid " + strings.Join(fields, ", "),
+			Text:    strings.Join(lines, "\n"),
+		}
+		fields, lines = nil, nil
+	}
+	// An item's issues are added together, so they are next to each other
+	for i, issue := range r.Issues {
+		if i == 0 || issue.ItemID != r.Issues[i-1].ItemID {
+			flush()
+			suite.Cases = append(suite.Cases, junitCase{Name: issue.ItemID, ClassName: "feed.item"})
+		}
+		fields = append(fields, issue.Field)
+		lines = append(lines, issue.Field+": "+issue.Message)
+	}
+	flush()
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,90 @@
+// Package validate checks feed items against the rules of the channels they
+// are sent to, so bad rows are caught before a feed is uploaded.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"go_data_fashion_accessories/feed"
+	"go_data_fashion_accessories/model/input"
+)
+
+// Issue is one problem found on one item
+type Issue struct {
+	ItemID  string `json:"id"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s %s: %s", i.ItemID, i.Field, i.Message)
+}
+
+// maxLengths are the longest values Google Merchant accepts, in characters
+var maxLengths = []struct {
+	name  string
+	max   int
+	value func(feed.Item) string
+}{
+	{"id", 50, func(it feed.Item) string { return it.ID }},
+	{"title", 150, func(it feed.Item) string { return it.Title }},
+	{"description", 5000, func(it feed.Item) string { return it.Description }},
+	{"link", 2000, func(it feed.Item) string { return feed.URLString(it.Link) }},
+	{"image_link", 2000, func(it feed.Item) string { return feed.URLString(it.ImageLink) }},
+	{"brand", 70, func(it feed.Item) string { return it.Brand }},
+	{"mpn", 70, func(it feed.Item) string { return it.MPN }},
+	{"item_group_id", 50, func(it feed.Item) string { return it.ItemGroupID }},
+	{"color", 100, func(it feed.Item) string { return it.Color }},
+	{"size", 100, func(it feed.Item) string { return it.Size }},
+	{"material", 200, func(it feed.Item) string { return it.Material }},
+	{"product_type", 750, func(it feed.Item) string { return it.ProductType }},
+}
+
+// Item returns every issue found on item, checking it against the Google
+// Merchant product data specification. The typed form of the item is
+// checked by feed.Item.Validate for required fields, links, prices and
+// closed sets of values; Item adds the value lengths, the number of images
+// and the GTIN check digit.
+func Item(item input.AdItem) []Issue {
+	var issues []Issue
+	add := func(field, format string, args ...interface{}) {
+		issues = append(issues, Issue{ItemID: item.ID, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// A link that does not parse is reported once, not again as missing
+	typed, err := feed.FromAdItem(item)
+	reported := map[string]bool{}
+	var invalid *feed.ValidationError
+	if errors.As(err, &invalid) {
+		for _, field := range invalid.Fields {
+			add(field.Field, "%s", field.Message)
+			reported[field.Field] = true
+		}
+	}
+	if err := typed.Validate(); errors.As(err, &invalid) {
+		for _, field := range invalid.Fields {
+			if !reported[field.Field] {
+				add(field.Field, "%s", field.Message)
+			}
+		}
+	}
+
+	for _, field := range maxLengths {
+		if n := utf8.RuneCountInString(field.value(typed)); n > field.max {
+			add(field.name, "is %d characters, more than the %d allowed", n, field.max)
+		}
+	}
+	if n := len(typed.AdditionalImageLinks); n > input.MaxAdditionalImages {
+		add("additional_image_link", "has %d images, more than the %d allowed", n, input.MaxAdditionalImages)
+	}
+
+	if typed.GTIN != "" {
+		if _, err := NormalizeGTIN(typed.GTIN); err != nil {
+			add("gtin", "%v", err)
+		}
+	}
+
+	return issues
+}
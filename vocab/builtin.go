@@ -0,0 +1,60 @@
+package vocab
+
+// builtin holds the English and Arabic synonyms sellers commonly use
+var builtin = Dictionary{
+	Color: {
+		"black": "Black", "أسود": "Black", "اسود": "Black",
+		"white": "White", "أبيض": "White", "ابيض": "White",
+		"gold": "Gold", "golden": "Gold", "ذهبي": "Gold",
+		"silver": "Silver", "فضي": "Silver",
+		"rose gold": "Rose Gold", "روز جولد": "Rose Gold",
+		"red": "Red", "أحمر": "Red", "احمر": "Red",
+		"blue": "Blue", "أزرق": "Blue", "ازرق": "Blue",
+		"navy": "Navy", "كحلي": "Navy",
+		"green": "Green", "أخضر": "Green", "اخضر": "Green",
+		"brown": "Brown", "بني": "Brown",
+		"beige": "Beige", "بيج": "Beige",
+		"pink": "Pink", "وردي": "Pink", "زهري": "Pink",
+		"purple": "Purple", "بنفسجي": "Purple",
+		"yellow": "Yellow", "أصفر": "Yellow", "اصفر": "Yellow",
+		"orange": "Orange", "برتقالي": "Orange",
+		"grey": "Gray", "gray": "Gray", "رمادي": "Gray",
+		"multicolor": "Multicolor", "multi": "Multicolor", "متعدد الألوان": "Multicolor",
+	},
+	Material: {
+		"leather": "Leather", "genuine leather": "Leather", "جلد": "Leather", "جلد طبيعي": "Leather",
+		"faux leather": "Faux Leather", "pu": "Faux Leather", "جلد صناعي": "Faux Leather",
+		"gold": "Gold", "ذهب": "Gold",
+		"silver": "Silver", "فضة": "Silver",
+		"stainless steel": "Stainless Steel", "steel": "Stainless Steel", "ستانلس ستيل": "Stainless Steel",
+		"canvas": "Canvas", "كانفاس": "Canvas",
+		"cotton": "Cotton", "قطن": "Cotton",
+		"silk": "Silk", "حرير": "Silk",
+		"plastic": "Plastic", "بلاستيك": "Plastic",
+	},
+	Size: {
+		"one size": "One Size", "onesize": "One Size", "free size": "One Size", "مقاس واحد": "One Size",
+		"xs": "XS", "s": "S", "small": "S", "صغير": "S",
+		"m": "M", "medium": "M", "وسط": "M",
+		"l": "L", "large": "L", "كبير": "L",
+		"xl": "XL", "xxl": "XXL",
+	},
+	Gender: {
+		"men": "male", "man": "male", "mens": "male", "men's": "male", "رجالي": "male", "رجال": "male",
+		"women": "female", "woman": "female", "womens": "female", "women's": "female", "ladies": "female",
+		"نسائي": "female", "نساء": "female",
+		"both": "unisex", "للجنسين": "unisex",
+		"boys": "male", "girls": "female",
+	},
+	AgeGroup: {
+		"adults": "adult", "بالغين": "adult", "كبار": "adult",
+		"kid": "kids", "children": "kids", "child": "kids", "أطفال": "kids", "اطفال": "kids",
+		"baby": "infant", "رضع": "infant",
+	},
+	Condition: {
+		"brand new": "new", "new with tags": "new", "جديد": "new",
+		"pre-owned": "used", "preowned": "used", "second hand": "used", "second-hand": "used",
+		"like new": "used", "مستعمل": "used", "مستعمل كالجديد": "used",
+		"renewed": "refurbished", "مجدد": "refurbished",
+	},
+}
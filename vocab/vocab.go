@@ -0,0 +1,132 @@
+// Package vocab normalizes free-text product attributes such as color and
+// gender to the values Google Merchant Center accepts, using a synonym
+// dictionary that can be extended from a JSON file.
+package vocab
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"go_data_fashion_accessories/model/input"
+)
+
+// Attribute names used as dictionary sections
+const (
+	Color     = "color"
+	Size      = "size"
+	Material  = "material"
+	Gender    = "gender"
+	AgeGroup  = "age_group"
+	Condition = "condition"
+)
+
+// DefaultCondition is used for ads that do not state their condition, which
+// the marketplace has always listed as new
+const DefaultCondition = "new"
+
+// closed lists the attributes whose values must come from Google's fixed
+// vocabulary; anything else is dropped rather than sent
+var closed = map[string]map[string]bool{
+	Gender:    {"male": true, "female": true, "unisex": true},
+	AgeGroup:  {"newborn": true, "infant": true, "toddler": true, "kids": true, "adult": true},
+	Condition: {"new": true, "used": true, "refurbished": true},
+}
+
+// Dictionary maps, per attribute, a normalized synonym to its canonical
+// value, e.g. color: "ذهبي" → "Gold"
+type Dictionary map[string]map[string]string
+
+// Default returns a copy of the built-in dictionary
+func Default() Dictionary {
+	d := Dictionary{}
+	d.Merge(builtin)
+	return d
+}
+
+// Load returns the built-in dictionary extended with the entries in a JSON
+// file shaped like {"color": {"ذهبي": "Gold"}}. File entries win.
+func Load(path string) (Dictionary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var extra Dictionary
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return nil, fmt.Errorf("reading synonyms %s: %w", path, err)
+	}
+	d := Default()
+	d.Merge(extra)
+	return d, nil
+}
+
+// Merge adds the entries of other, replacing existing synonyms
+func (d Dictionary) Merge(other Dictionary) {
+	for attr, synonyms := range other {
+		if d[attr] == nil {
+			d[attr] = map[string]string{}
+		}
+		for synonym, canonical := range synonyms {
+			d[attr][key(synonym)] = canonical
+		}
+	}
+}
+
+// Normalize returns the canonical value of an attribute. Values without a
+// synonym are kept with their spacing tidied, except for attributes with a
+// closed vocabulary, where they become "".
+func (d Dictionary) Normalize(attr, value string) string {
+	k := key(value)
+	if k == "" {
+		return ""
+	}
+	if canonical, ok := d[attr][k]; ok {
+		return canonical
+	}
+	if allowed, ok := closed[attr]; ok {
+		if allowed[k] {
+			return k
+		}
+		return ""
+	}
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// Apply normalizes the item's color, size, material, gender, age group and
+// condition. An unknown condition becomes DefaultCondition. It satisfies
+// pipeline.Transformer.
+func (d Dictionary) Apply(item *input.AdItem) error {
+	item.Color = d.Normalize(Color, item.Color)
+	item.Size = d.Normalize(Size, item.Size)
+	item.Material = d.Normalize(Material, item.Material)
+	item.Gender = d.Normalize(Gender, item.Gender)
+	item.AgeGroup = d.Normalize(AgeGroup, item.AgeGroup)
+	item.Condition = d.Normalize(Condition, item.Condition)
+	if item.Condition == "" {
+		item.Condition = DefaultCondition
+	}
+	return nil
+}
+
+// Canonical reports whether value is one of the canonical values of an
+// attribute, as Normalize returns for a known synonym
+func (d Dictionary) Canonical(attr, value string) bool {
+	if value == "" {
+		return false
+	}
+	if allowed, ok := closed[attr]; ok && allowed[value] {
+		return true
+	}
+	for _, canonical := range d[attr] {
+		if canonical == value {
+			return true
+		}
+	}
+	return false
+}
+
+// key folds a value for dictionary lookups
+func key(value string) string {
+	return strings.ToLower(strings.Join(strings.Fields(value), " "))
+}
@@ -0,0 +1,278 @@
+// Package watch keeps a channel up to date within minutes instead of once a
+// day. It holds the channel's items in memory, polls the source for the ads
+// updated since the last poll, optionally takes ad changes from Hasura event
+// triggers as they happen, and pushes only the items that changed or went
+// away through the channel's API.
+package watch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go_data_fashion_accessories/config"
+	"go_data_fashion_accessories/delta"
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+	"go_data_fashion_accessories/pipeline"
+	"go_data_fashion_accessories/runner"
+	"go_data_fashion_accessories/shutdown"
+	"go_data_fashion_accessories/state"
+)
+
+// Pusher sends item changes to a channel API
+type Pusher struct {
+	Upsert func(ctx context.Context, items []input.AdItem) error
+	Delete func(ctx context.Context, ids []string) error
+}
+
+// Watcher polls the source and pushes the changes. It is not safe for
+// concurrent use.
+type Watcher struct {
+	cfg     *config.Config
+	channel string // channel options applied to the items, e.g. contentapi
+	pusher  Pusher
+	logger  *slog.Logger
+
+	items   delta.Snapshot // what the channel holds, by item ID
+	last    time.Time      // start of the last poll whose changes were pushed
+	events  chan change    // queued by the webhook
+	pending []change       // events whose changes failed to push, retried
+}
+
+// New returns a Watcher pushing the items of channel. A nil logger uses
+// slog.Default().
+func New(cfg *config.Config, channel string, pusher Pusher, logger *slog.Logger) *Watcher {
+	return &Watcher{
+		cfg:     cfg,
+		channel: channel,
+		pusher:  pusher,
+		logger:  logging.OrDefault(logger),
+		items:   delta.Snapshot{},
+		events:  make(chan change, queueSize),
+	}
+}
+
+// Run polls every Watch.PollInterval until ctx is cancelled, applying the
+// webhook's events as they arrive in between when Watch.Listen is set. A
+// failed poll is logged and its ads are fetched again by the next one;
+// failed events are retried on the next tick.
+func (w *Watcher) Run(ctx context.Context) error {
+	var httpServer *http.Server
+	errc := make(chan error, 1)
+	if addr := w.cfg.Watch.Listen; addr != "" {
+		httpServer = &http.Server{
+			Addr:              addr,
+			Handler:           w.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			w.logger.Info("Receiving Hasura events", "addr", addr)
+			errc <- httpServer.ListenAndServe()
+		}()
+	}
+
+	ticker := time.NewTicker(w.cfg.Watch.PollInterval.Duration)
+	defer ticker.Stop()
+
+	// A poll or push in progress when ctx is cancelled may finish within
+	// the grace period, so the channel is not left half updated
+	runCtx, cancel := shutdown.Graceful(ctx, w.cfg.Shutdown.GracePeriod.Duration, w.logger)
+	defer cancel()
+
+	tick := true
+	for {
+		if tick {
+			if err := w.Poll(runCtx); err != nil && runCtx.Err() == nil {
+				w.logger.Error("Error polling ads", "error", err)
+			}
+			if len(w.pending) > 0 {
+				w.apply(runCtx, nil)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return stopWebhook(httpServer, errc)
+		case err := <-errc:
+			return err
+		case <-ticker.C:
+			tick = true
+		case c := <-w.events:
+			tick = false
+			w.apply(runCtx, append([]change{c}, w.queued()...))
+		}
+	}
+}
+
+// stopWebhook stops the webhook server, if any, gracefully
+func stopWebhook(httpServer *http.Server, errc <-chan error) error {
+	if httpServer == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Poll fetches the ads updated since the last poll and pushes the changes.
+// The first poll loads every ad, pushing them only with Watch.PushInitial.
+func (w *Watcher) Poll(ctx context.Context) error {
+	first := w.last.IsZero()
+	cfg := *w.cfg
+	cfg.FullRefresh = first
+
+	// The run records its start in a store of its own, which becomes the
+	// next lower bound only once the changes are pushed
+	store := state.NewMemoryStore()
+	if !first {
+		if err := state.RecordSuccessfulRun(ctx, store, w.last); err != nil {
+			return err
+		}
+	}
+
+	upserted, deleted, err := w.sync(ctx, &cfg, runner.Options{Store: store, AllStatuses: !first}, nil, !first || w.cfg.Watch.PushInitial)
+	if err != nil {
+		return err
+	}
+	if w.last, err = state.LastSuccessfulRun(ctx, store); err != nil {
+		return err
+	}
+	switch {
+	case first && !w.cfg.Watch.PushInitial:
+		w.logger.Info("Loaded items", "channel", w.channel, "items", len(w.items))
+	case upserted > 0 || deleted > 0:
+		w.logger.Info("Pushed changes", "channel", w.channel, "upserted", upserted, "deleted", deleted, "items", len(w.items))
+	}
+	return nil
+}
+
+// apply pushes the changes of the events left pending and of changes. The
+// events are kept pending if that fails.
+func (w *Watcher) apply(ctx context.Context, changes []change) {
+	changes = append(w.pending, changes...)
+	w.pending = nil
+
+	// The last event of an ad wins
+	latest := map[string]change{}
+	for _, c := range changes {
+		latest[c.adID] = c
+	}
+	ads := []input.RawAd{}
+	var removed []string
+	for id, c := range latest {
+		if c.ad == nil {
+			removed = append(removed, id)
+		} else {
+			ads = append(ads, *c.ad)
+		}
+	}
+
+	cfg := *w.cfg
+	upserted, deleted, err := w.sync(ctx, &cfg, runner.Options{Ads: ads}, removed, true)
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.Error("Error applying events", "events", len(changes), "error", err)
+		}
+		w.pending = changes
+		return
+	}
+	w.logger.Info("Applied events", "channel", w.channel, "events", len(changes), "upserted", upserted, "deleted", deleted, "items", len(w.items))
+}
+
+// sync runs the pipeline with opts and records its items. Ads that went
+// through it, or are listed in removed, replace all their items: items
+// missing from the run were dropped or went to another feed. The changes are
+// pushed first when push is set.
+func (w *Watcher) sync(ctx context.Context, cfg *config.Config, opts runner.Options, removed []string, push bool) (upserted, deleted int, err error) {
+	cfg.Delta.Enabled = false // the watcher tracks changes itself
+
+	polled := map[string]bool{}
+	for _, id := range removed {
+		polled[id] = true
+	}
+	current := delta.Snapshot{}
+	var items []input.AdItem
+	collect := &collectSink{write: func(item input.AdItem) {
+		current[item.ID] = delta.Entry{AdID: item.AdID, Hash: delta.Hash(item)}
+		items = append(items, item)
+	}}
+	opts.Sinks = []pipeline.Sink{&pollSink{polled: polled, Sink: runner.ChannelSink(cfg, w.channel, "", collect)}}
+	opts.Logger = w.logger
+	opts.OnSkip = func(report input.SkipReport) { polled[report.AdID] = true }
+	if _, err := runner.Run(ctx, cfg, opts); err != nil {
+		return 0, 0, err
+	}
+
+	var upserts []input.AdItem
+	for _, item := range items {
+		if old, ok := w.items[item.ID]; !ok || old.Hash != current[item.ID].Hash {
+			upserts = append(upserts, item)
+		}
+	}
+	var deletes []string
+	for id, old := range w.items {
+		if _, ok := current[id]; !ok && polled[old.AdID] {
+			deletes = append(deletes, id)
+		}
+	}
+
+	if push {
+		if len(upserts) > 0 {
+			if err := w.pusher.Upsert(ctx, upserts); err != nil {
+				return 0, 0, err
+			}
+		}
+		if len(deletes) > 0 {
+			if err := w.pusher.Delete(ctx, deletes); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	for _, id := range deletes {
+		delete(w.items, id)
+	}
+	for id, entry := range current {
+		w.items[id] = entry
+	}
+	return len(upserts), len(deletes), nil
+}
+
+// pollSink records the ads that produced an item before the channel options
+// apply
+type pollSink struct {
+	pipeline.Sink
+	polled map[string]bool
+}
+
+func (s *pollSink) Write(item input.AdItem) error {
+	s.polled[item.AdID] = true
+	return s.Sink.Write(item)
+}
+
+func (s *pollSink) Abort() error {
+	return pipeline.Abort(s.Sink)
+}
+
+// collectSink hands every item to write
+type collectSink struct {
+	write func(item input.AdItem)
+}
+
+func (s *collectSink) Write(item input.AdItem) error {
+	s.write(item)
+	return nil
+}
+
+func (s *collectSink) Close() error {
+	return nil
+}
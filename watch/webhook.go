@@ -0,0 +1,101 @@
+package watch
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"go_data_fashion_accessories/logging"
+	"go_data_fashion_accessories/model/input"
+)
+
+// queueSize is the number of events the webhook holds before it answers
+// 503, leaving Hasura to deliver them again later
+const queueSize = 1000
+
+// maxEventSize bounds the body of one event
+const maxEventSize = 4 << 20
+
+// Event is a Hasura event trigger payload on the ads table, as far as the
+// watcher reads it
+type Event struct {
+	ID    string `json:"id"`
+	Event struct {
+		Op   string `json:"op"` // INSERT, UPDATE, DELETE or MANUAL
+		Data struct {
+			Old *input.RawAd `json:"old"`
+			New *input.RawAd `json:"new"`
+		} `json:"data"`
+	} `json:"event"`
+}
+
+// change is the new state of one ad: its row, or nil once it was deleted
+type change struct {
+	adID string
+	ad   *input.RawAd
+}
+
+// Handler returns the HTTP handler receiving Hasura events at /events
+func (w *Watcher) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", w.receive)
+	return mux
+}
+
+// receive queues the change of one event. The response only says whether
+// it was queued; the change is pushed by Run.
+func (w *Watcher) receive(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", "POST")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if secret := w.cfg.Watch.WebhookSecret; secret != "" {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Webhook-Secret")), []byte(secret)) != 1 {
+			http.Error(rw, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var event Event
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxEventSize)).Decode(&event); err != nil {
+		http.Error(rw, "invalid event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var c change
+	switch data := event.Event.Data; {
+	case event.Event.Op == "DELETE" && data.Old != nil:
+		c = change{adID: data.Old.ID}
+	case data.New != nil:
+		c = change{adID: data.New.ID, ad: data.New}
+	default:
+		http.Error(rw, "event has no row", http.StatusBadRequest)
+		return
+	}
+	if c.adID == "" {
+		http.Error(rw, "event row has no id", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case w.events <- c:
+		w.logger.Debug("Queued event", "event", event.ID, "op", event.Event.Op, logging.AdID, c.adID)
+		rw.WriteHeader(http.StatusAccepted)
+	default:
+		rw.Header().Set("Retry-After", "60")
+		http.Error(rw, "event queue is full", http.StatusServiceUnavailable)
+	}
+}
+
+// queued takes every event waiting in the queue
+func (w *Watcher) queued() []change {
+	var changes []change
+	for {
+		select {
+		case c := <-w.events:
+			changes = append(changes, c)
+		default:
+			return changes
+		}
+	}
+}